@@ -0,0 +1,30 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework Foundation
+#include <stdlib.h>
+
+void *iaptmBeginActivity(const char *reason);
+void iaptmEndActivity(void *token);
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// beginActivity keeps macOS from napping the app or coalescing its timers until the returned
+// function is called. The function may be called more than once.
+func beginActivity(reason string) func() {
+	cReason := C.CString(reason)
+	defer C.free(unsafe.Pointer(cReason))
+	token := C.iaptmBeginActivity(cReason)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { C.iaptmEndActivity(token) })
+	}
+}
@@ -0,0 +1,22 @@
+// Activity assertions through NSProcessInfo, so App Nap and timer coalescing don't delay
+// time-sensitive work while the app is in the background.
+
+#import <Foundation/Foundation.h>
+
+// iaptmBeginActivity disables App Nap and timer coalescing until iaptmEndActivity is called
+// with the returned token. Idle system sleep stays allowed.
+void *iaptmBeginActivity(const char *reason) {
+	@autoreleasepool {
+		NSActivityOptions options = NSActivityUserInitiatedAllowingIdleSystemSleep | NSActivityLatencyCritical;
+		id<NSObject> token = [[NSProcessInfo processInfo] beginActivityWithOptions:options
+		                                                                    reason:[NSString stringWithUTF8String:reason]];
+		return (void *)CFBridgingRetain(token);
+	}
+}
+
+// iaptmEndActivity ends an activity begun with iaptmBeginActivity.
+void iaptmEndActivity(void *token) {
+	@autoreleasepool {
+		[[NSProcessInfo processInfo] endActivity:CFBridgingRelease(token)];
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !darwin
+
+package main
+
+// beginActivity is only needed on macOS, where App Nap throttles background apps
+func beginActivity(reason string) func() {
+	return func() {}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	// AgentRegistryName is the agent's state file in the config directory
+	AgentRegistryName = "agent-state.json"
+	// TunnelsAdoptedEvent is emitted with the tunnels the GUI re-attached to at startup
+	TunnelsAdoptedEvent = "tunnels:adopted"
+)
+
+// AgentRegistry is the agent's state file. It tells a restarted GUI where the agent listens
+// and which tunnels it runs, and a restarted agent which tunnels died with its predecessor.
+type AgentRegistry struct {
+	PID       int                   `json:"pid"`
+	Socket    string                `json:"socket"`
+	StartedAt string                `json:"startedAt"`
+	UpdatedAt string                `json:"updatedAt"`
+	Tunnels   []AgentRegistryTunnel `json:"tunnels"`
+}
+
+// AgentRegistryTunnel is a tunnel recorded in the agent registry
+type AgentRegistryTunnel struct {
+	ID string `json:"id"`
+	TunnelSpec
+}
+
+// agentRegistryPath returns the agent registry path
+func (a *App) agentRegistryPath() string {
+	return filepath.Join(a.getConfigDir(), AgentRegistryName)
+}
+
+// saveAgentRegistry records the agent's running tunnels
+func (a *App) saveAgentRegistry(tunnels []*Tunnel) error {
+	a.agent.mu.Lock()
+	if a.agent.startedAt.IsZero() {
+		a.agent.startedAt = time.Now()
+	}
+	startedAt := a.agent.startedAt
+	a.agent.mu.Unlock()
+
+	registry := AgentRegistry{
+		PID:       os.Getpid(),
+		Socket:    a.agentSocketPath(),
+		StartedAt: startedAt.Format(time.RFC3339),
+		UpdatedAt: time.Now().Format(time.RFC3339),
+		Tunnels:   make([]AgentRegistryTunnel, 0, len(tunnels)),
+	}
+	for _, t := range tunnels {
+		registry.Tunnels = append(registry.Tunnels, AgentRegistryTunnel{
+			ID: t.ID,
+			TunnelSpec: TunnelSpec{
+				ProjectID:  t.ProjectID,
+				VMName:     t.VMName,
+				Zone:       t.Zone,
+				LocalPort:  t.LocalPort,
+				RemotePort: t.RemotePort,
+			},
+		})
+	}
+
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent registry: %w", err)
+	}
+	if err := writePrivateFile(a.agentRegistryPath(), data); err != nil {
+		return fmt.Errorf("failed to write agent registry: %w", err)
+	}
+	return nil
+}
+
+// loadAgentRegistry reads the agent registry, nil if there is none
+func (a *App) loadAgentRegistry() *AgentRegistry {
+	data, err := os.ReadFile(a.agentRegistryPath())
+	if err != nil {
+		return nil
+	}
+	var registry AgentRegistry
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil
+	}
+	return &registry
+}
+
+// removeAgentRegistry deletes the registry when the agent exits cleanly
+func (a *App) removeAgentRegistry() {
+	os.Remove(a.agentRegistryPath())
+}
+
+// processAlive reports whether a process with the PID exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// orphanedAgentTunnels returns the tunnels of an agent that exited without cleaning up
+func (a *App) orphanedAgentTunnels() []TunnelSpec {
+	registry := a.loadAgentRegistry()
+	if registry == nil || processAlive(registry.PID) {
+		return nil
+	}
+	specs := make([]TunnelSpec, 0, len(registry.Tunnels))
+	for _, t := range registry.Tunnels {
+		specs = append(specs, t.TunnelSpec)
+	}
+	return specs
+}
+
+// resumeOrphanedTunnels starts the tunnels that died with a previous agent
+func (a *App) resumeOrphanedTunnels(specs []TunnelSpec) {
+	for _, spec := range specs {
+		if _, err := a.StartTunnelWithRemotePort(spec.ProjectID, spec.VMName, spec.Zone, spec.LocalPort, spec.RemotePort); err != nil {
+			a.logWarningf("Failed to resume tunnel to %s: %v", spec.VMName, err)
+		}
+	}
+}
+
+// AdoptRunningTunnels re-attaches to tunnels the agent kept running while the GUI was closed
+// or crashed, even if agent mode has since been turned off, and returns them.
+// Called at startup, the frontend may call it again after installing the agent.
+func (a *App) AdoptRunningTunnels() []TunnelInfo {
+	if a.isAgent {
+		return nil
+	}
+	registry := a.loadAgentRegistry()
+	if registry == nil || len(registry.Tunnels) == 0 || !processAlive(registry.PID) {
+		return nil
+	}
+
+	a.agent.mu.Lock()
+	a.agent.adopted = true
+	a.agent.checkedAt = time.Time{}
+	a.agent.mu.Unlock()
+
+	tunnels := a.agentTunnels()
+	if len(tunnels) == 0 {
+		return nil
+	}
+
+	// Drop previous-session tunnels the agent already runs
+	a.pendingRestoreMu.Lock()
+	a.pendingRestore = withoutTunnels(a.pendingRestore, tunnels)
+	a.pendingRestoreMu.Unlock()
+
+	a.emitEvent(TunnelsAdoptedEvent, tunnels)
+	return tunnels
+}
+
+// withoutAgentTunnels filters out specs of tunnels the agent runs
+func (a *App) withoutAgentTunnels(specs []TunnelSpec) []TunnelSpec {
+	if len(specs) == 0 {
+		return specs
+	}
+	return withoutTunnels(specs, a.agentTunnels())
+}
+
+// withoutTunnels filters out specs matching a running or starting tunnel
+func withoutTunnels(specs []TunnelSpec, tunnels []TunnelInfo) []TunnelSpec {
+	var remaining []TunnelSpec
+	for _, spec := range specs {
+		running := false
+		for _, t := range tunnels {
+			if (t.Status == "running" || t.Status == "starting") &&
+				t.ProjectID == spec.ProjectID && t.VMName == spec.VMName &&
+				t.Zone == spec.Zone && t.LocalPort == spec.LocalPort {
+				running = true
+				break
+			}
+		}
+		if !running {
+			remaining = append(remaining, spec)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// AgentArg runs the tunnel engine headless, as started by the LaunchAgent
+	AgentArg = "--agent"
+	// AgentLabel is the launchd label of the background agent
+	AgentLabel = "com.wails.iap-tunnel-manager.agent"
+	// AgentSocketName is the control socket in the config directory
+	AgentSocketName = "agent.sock"
+
+	// agentCallTimeout bounds a control API call, starting a tunnel can take a few seconds
+	agentCallTimeout = 30 * time.Second
+	// agentPingTimeout bounds the reachability check
+	agentPingTimeout = 500 * time.Millisecond
+	// agentStatusTTL caches the reachability check between frequent calls like GetTunnels
+	agentStatusTTL = 5 * time.Second
+)
+
+// agentMethods are the App methods served on the control socket. Clients call them with
+// POST /call/<Method> and a JSON array of arguments, e.g.
+// curl --unix-socket agent.sock -d '["tunnel-id"]' http://agent/call/StopTunnel
+var agentMethods = map[string]bool{
+	"GetTunnels":                true,
+	"GetTunnel":                 true,
+	"StartTunnelWithRemotePort": true,
+	"StartTunnelForConnection":  true,
+	"ConfirmProductionConnect":  true,
+	"StopTunnel":                true,
+	"StopAllTunnels":            true,
+	"RemoveTunnel":              true,
+	"ProbeTunnel":               true,
+	"TailTunnelLog":             true,
+	"SetTunnelIdleTimeout":      true,
+	"SetTunnelMaxDuration":      true,
+	"GetTunnelMetrics":          true,
+	"SetTunnelsPaused":          true,
+}
+
+// agentState caches whether the GUI can reach the agent
+type agentState struct {
+	mu        sync.Mutex
+	reachable bool
+	checkedAt time.Time
+	// adopted is set once the GUI found tunnels the agent kept running
+	adopted bool
+	// startedAt is when the agent started, recorded in its registry
+	startedAt time.Time
+	// configStamp is the configStamp of the config files the agent last read
+	configStamp string
+}
+
+// AgentStatus reports the background agent's installation and reachability
+type AgentStatus struct {
+	Installed bool   `json:"installed"`
+	Running   bool   `json:"running"`
+	Attached  bool   `json:"attached"` // Tunnels are started in the agent
+	Tunnels   int    `json:"tunnels"`
+	Error     string `json:"error,omitempty"`
+}
+
+// agentCallResponse is the control API response
+type agentCallResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// runAgentMode runs the headless agent when started with --agent. It reports whether the
+// arguments selected agent mode, and the exit code.
+func runAgentMode(args []string) (int, bool) {
+	if len(args) == 0 || args[0] != AgentArg {
+		return 0, false
+	}
+
+	app := NewApp()
+	app.isAgent = true
+	// The GUI owns the config, a config it hasn't migrated yet is read as is
+	if err := app.reloadAgentConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if err := app.initCredentials(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1, true
+	}
+
+	// Tunnels recorded by an agent that died are orphans, start them again
+	orphans := app.orphanedAgentTunnels()
+
+	listener, err := app.listenAgentSocket()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1, true
+	}
+	if err := app.saveAgentRegistry(nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	server := &http.Server{Handler: app.agentHandler()}
+	go server.Serve(listener)
+
+	app.resumeOrphanedTunnels(orphans)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+
+	server.Close()
+	app.StopAllTunnels()
+	app.removeAgentRegistry()
+	return 0, true
+}
+
+// agentSocketPath returns the control socket path
+func (a *App) agentSocketPath() string {
+	return filepath.Join(a.getConfigDir(), AgentSocketName)
+}
+
+// listenAgentSocket creates the control socket, readable only by the user
+func (a *App) listenAgentSocket() (net.Listener, error) {
+	return a.listenConfigSocket(a.agentSocketPath())
+}
+
+// listenConfigSocket creates a Unix socket in the config directory, readable only by the user
+func (a *App) listenConfigSocket(path string) (net.Listener, error) {
+	if err := os.MkdirAll(a.getConfigDir(), configDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// A stale socket from a crashed process blocks Listen
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, configFileMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// agentHandler serves the control API
+func (a *App) agentHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/call/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		result, err := a.callAgentMethod(strings.TrimPrefix(r.URL.Path, "/call/"), r.Body)
+		resp := agentCallResponse{Result: result}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
+
+// reloadAgentConfig reads the config again when its files changed since the last read. A config
+// that can't be read completely is not used, the agent keeps the previous one and retries on the
+// next call.
+func (a *App) reloadAgentConfig() error {
+	stamp := a.configStamp()
+	a.agent.mu.Lock()
+	defer a.agent.mu.Unlock()
+	if stamp == a.agent.configStamp {
+		return nil
+	}
+	config, err := a.readConfigReadOnly()
+	if err != nil {
+		return err
+	}
+	a.configMu.Lock()
+	a.config = config
+	a.configMu.Unlock()
+	a.agent.configStamp = stamp
+	return nil
+}
+
+// configStamp describes the modification times and sizes of the config files, it changes when
+// any of them is written
+func (a *App) configStamp() string {
+	var b strings.Builder
+	for _, name := range []string{ConfigFileName, FavoritesFileName, SettingsFileName, StateFileName} {
+		if info, err := os.Stat(filepath.Join(a.getConfigDir(), name)); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", name, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return b.String()
+}
+
+// callAgentMethod decodes the JSON argument array, calls the App method and encodes its result
+func (a *App) callAgentMethod(name string, body io.Reader) (json.RawMessage, error) {
+	if !agentMethods[name] {
+		return nil, fmt.Errorf("unknown method %q", name)
+	}
+	// Pick up favorites and settings the GUI changed since the last call
+	if err := a.reloadAgentConfig(); err != nil {
+		a.logWarningf("Failed to reload config, keeping the previous one: %v", err)
+	}
+
+	method := reflect.ValueOf(a).MethodByName(name)
+	methodType := method.Type()
+
+	var rawArgs []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&rawArgs); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if len(rawArgs) != methodType.NumIn() {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, methodType.NumIn(), len(rawArgs))
+	}
+
+	args := make([]reflect.Value, len(rawArgs))
+	for i, raw := range rawArgs {
+		arg := reflect.New(methodType.In(i))
+		if err := json.Unmarshal(raw, arg.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid argument %d: %w", i+1, err)
+		}
+		args[i] = arg.Elem()
+	}
+
+	var result interface{}
+	for _, out := range method.Call(args) {
+		if err, ok := out.Interface().(error); ok && err != nil {
+			return nil, err
+		}
+		if out.Type() != reflect.TypeOf((*error)(nil)).Elem() {
+			result = out.Interface()
+		}
+	}
+	return json.Marshal(result)
+}
+
+// agentCall calls a method on the agent and decodes its result into result (may be nil)
+func (a *App) agentCall(method string, result interface{}, args ...interface{}) error {
+	// The agent reads favorites and settings from disk on each call
+	if err := a.flushConfig(); err != nil {
+		a.logWarningf("Failed to save config: %v", err)
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	body, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), agentCallTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://agent/call/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := a.agentHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("agent unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var callResp agentCallResponse
+	if err := json.NewDecoder(resp.Body).Decode(&callResp); err != nil {
+		return fmt.Errorf("invalid agent response: %w", err)
+	}
+	if callResp.Error != "" {
+		return fmt.Errorf("%s", callResp.Error)
+	}
+	if result != nil && len(callResp.Result) > 0 {
+		return json.Unmarshal(callResp.Result, result)
+	}
+	return nil
+}
+
+// agentHTTPClient returns an HTTP client that talks to the control socket
+func (a *App) agentHTTPClient() *http.Client {
+	return unixSocketClient(a.agentSocketPath())
+}
+
+// unixSocketClient returns an HTTP client that talks to a Unix socket, whatever the URL's host
+func unixSocketClient(socket string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// agentAttached reports whether the GUI works with the agent's tunnels: agent mode is enabled
+// or tunnels were adopted from it, and the agent answers on its socket
+func (a *App) agentAttached() bool {
+	if a.isAgent {
+		return false
+	}
+	if !a.GetSettings().UseAgent && !a.agentAdopted() {
+		return false
+	}
+
+	a.agent.mu.Lock()
+	defer a.agent.mu.Unlock()
+	if time.Since(a.agent.checkedAt) < agentStatusTTL {
+		return a.agent.reachable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), agentPingTimeout)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://agent/health", nil)
+	resp, err := a.agentHTTPClient().Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	a.agent.reachable = err == nil
+	a.agent.checkedAt = time.Now()
+	return a.agent.reachable
+}
+
+// agentStartsTunnels reports whether new tunnels are started in the agent
+func (a *App) agentStartsTunnels() bool {
+	return a.GetSettings().UseAgent && a.agentAttached()
+}
+
+// agentAdopted reports whether AdoptRunningTunnels attached to a running agent
+func (a *App) agentAdopted() bool {
+	a.agent.mu.Lock()
+	defer a.agent.mu.Unlock()
+	return a.agent.adopted
+}
+
+// agentTunnels returns the agent's tunnels, nil when not attached
+func (a *App) agentTunnels() []TunnelInfo {
+	if !a.agentAttached() {
+		return nil
+	}
+	var tunnels []TunnelInfo
+	if err := a.agentCall("GetTunnels", &tunnels); err != nil {
+		return nil
+	}
+	for i := range tunnels {
+		tunnels[i].Agent = true
+	}
+	return tunnels
+}
+
+// GetAgentStatus reports whether the LaunchAgent is installed and reachable
+func (a *App) GetAgentStatus() AgentStatus {
+	status := AgentStatus{}
+	if path, err := launchAgentPath(); err == nil {
+		_, statErr := os.Stat(path)
+		status.Installed = statErr == nil
+	}
+
+	var tunnels []TunnelInfo
+	if err := a.agentCall("GetTunnels", &tunnels); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Running = true
+	status.Attached = a.GetSettings().UseAgent
+	for _, t := range tunnels {
+		if t.Status == "running" || t.Status == "starting" {
+			status.Tunnels++
+		}
+	}
+	return status
+}
+
+// InstallAgent installs and starts the LaunchAgent so tunnels outlive the GUI
+func (a *App) InstallAgent() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	path, err := launchAgentPath()
+	if err != nil {
+		return err
+	}
+
+	// Launch through a link named after the agent so ps, Activity Monitor and firewall prompts
+	// show "IAP Tunnel Agent", firewalls still match the app's code signature
+	program := executable
+	if link, err := a.agentExecutableLink(executable); err == nil {
+		program = link
+	} else {
+		a.logWarningf("Failed to create agent link, launching the app binary directly: %v", err)
+	}
+
+	logPath := filepath.Join(a.getConfigDir(), "agent.log")
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>%s</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>ProcessType</key>
+	<string>Background</string>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, AgentLabel, xmlEscape(program), AgentArg, xmlEscape(logPath), xmlEscape(logPath))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create LaunchAgents directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(plist), 0644); err != nil {
+		return fmt.Errorf("failed to write LaunchAgent: %w", err)
+	}
+
+	// Reload so an updated plist takes effect
+	domain := fmt.Sprintf("gui/%d", os.Getuid())
+	runCommand("launchctl", "bootout", domain+"/"+AgentLabel)
+	if _, err := runCommand("launchctl", "bootstrap", domain, path); err != nil {
+		return commandError("failed to load LaunchAgent", err, nil)
+	}
+	a.resetAgentStatus()
+	return nil
+}
+
+// agentExecutableLink points <config dir>/IAP Tunnel Agent at the app binary
+func (a *App) agentExecutableLink(executable string) (string, error) {
+	dir := a.getConfigDir()
+	if dir == "" {
+		return "", fmt.Errorf("config path not set")
+	}
+	if err := os.MkdirAll(dir, configDirMode); err != nil {
+		return "", err
+	}
+	link := filepath.Join(dir, processAgent)
+	if target, err := os.Readlink(link); err == nil && target == executable {
+		return link, nil
+	}
+	os.Remove(link)
+	if err := os.Symlink(executable, link); err != nil {
+		return "", err
+	}
+	return link, nil
+}
+
+// UninstallAgent stops the agent (and its tunnels) and removes the LaunchAgent
+func (a *App) UninstallAgent() error {
+	path, err := launchAgentPath()
+	if err != nil {
+		return err
+	}
+	runCommand("launchctl", "bootout", fmt.Sprintf("gui/%d/%s", os.Getuid(), AgentLabel))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove LaunchAgent: %w", err)
+	}
+	if dir := a.getConfigDir(); dir != "" {
+		os.Remove(filepath.Join(dir, processAgent))
+	}
+	a.resetAgentStatus()
+	return nil
+}
+
+// resetAgentStatus forgets the cached reachability
+func (a *App) resetAgentStatus() {
+	a.agent.mu.Lock()
+	a.agent.checkedAt = time.Time{}
+	a.agent.mu.Unlock()
+}
+
+// launchAgentPath returns the LaunchAgent plist path
+func launchAgentPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", AgentLabel+".plist"), nil
+}
+
+// xmlEscape escapes text for a plist string
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -16,6 +16,7 @@ import (
 	"math"
 	"math/big"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -26,6 +27,7 @@ import (
 	"time"
 
 	"github.com/cedws/iapc/iap"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v1"
@@ -38,14 +40,28 @@ const (
 	WindowsAppPath = "/Applications/Windows App.app"
 	// WindowsAppCLI is the path to the Windows App CLI executable
 	WindowsAppCLI = "/Applications/Windows App.app/Contents/MacOS/Windows App"
-	// BookmarkGroup is the group name for IAP tunnel bookmarks
+	// BookmarkGroup is the default group name for IAP tunnel bookmarks
 	BookmarkGroup = "IAP Tunnels"
 	// AppName is the application name for config directory
 	AppName = "IAP Tunnel Manager"
-	// ConfigFileName is the name of the config file
+	// ConfigFileName is the single config file of older versions, see FavoritesFileName for the current ones
 	ConfigFileName = "config.json"
 	// KeychainService is the service name for Keychain storage
 	KeychainService = "IAP Tunnel Manager"
+	// PasswordProgressEvent is emitted with PasswordProgress updates during a password reset
+	PasswordProgressEvent = "password:progress"
+	// MaxNotesLength is the maximum length of favorite notes in bytes
+	MaxNotesLength = 16 * 1024
+)
+
+// Tunnel restore modes used by AppSettings.RestoreTunnels
+const (
+	// RestoreModeAsk keeps the previous session's tunnels pending until the user decides
+	RestoreModeAsk = "ask"
+	// RestoreModeAuto restores the previous session's tunnels on startup
+	RestoreModeAuto = "auto"
+	// RestoreModeOff never restores tunnels
+	RestoreModeOff = "off"
 )
 
 // App struct
@@ -56,14 +72,196 @@ type App struct {
 	tunnelsMu   sync.RWMutex
 	config      *AppConfig
 	configMu    sync.RWMutex
-	configPath  string
+	configPath  string // Legacy config.json, the split config files live next to it
+	storage     configStorage
+
+	// pendingRestore holds the tunnels that were open when the app last quit
+	pendingRestore   []TunnelSpec
+	pendingRestoreMu sync.Mutex
+
+	icloud iCloudSyncState
+
+	// launchConnectID is the favorite requested with --connect on the command line
+	launchConnectID string
+
+	health healthCheckerState
+
+	monitoring monitoringState
+
+	netActivity netActivityState
+
+	costCatalog costCatalogState
+
+	zoneCatalog zoneCatalogState
+
+	power powerState
+
+	// managed holds administrator-provided defaults, read once at launch
+	managed *ManagedDefaults
+
+	telemetry telemetryState
+
+	prodConfirmations productionConfirmations
+
+	windowsApp windowsAppState
+
+	startupIssues startupIssuesState
+
+	// ops tracks background operations started by the *Async methods
+	ops operationsState
+
+	gcloudUpdate gcloudUpdateState
+
+	// credentials caches passwords read from Keychain for a short while
+	credentials credentialCache
+
+	// operator is the administrator unlock of operator mode
+	operator operatorState
+
+	// tunnelPause makes tunnels refuse connections, e.g. while another user is active
+	tunnelPause tunnelPauseState
+
+	// services caches which Google APIs are enabled per project
+	services serviceStates
+
+	// dialLimit bounds and queues concurrent IAP dials
+	dialLimit dialLimiter
+
+	// vmWatches keep the VM lists of projects open in the VM picker current
+	vmWatches vmWatchState
+
+	// hosts are the /etc/hosts entries of running hostname tunnels
+	hosts hostsState
+
+	// lanShares are tunnels made reachable from the local network
+	lanShares lanShareState
+
+	// simulator is the echo server simulated tunnels connect to
+	simulator simulatorState
+
+	// recentErrors keeps the last errors of the subsystems for the errors center
+	recentErrors errorCenter
+
+	// statusServer reports the health of the GUI's tunnels to status mode
+	statusServer *http.Server
+
+	// recording guards the encrypted session log
+	recording recordingState
+
+	// jit holds the active just-in-time sessions
+	jit jitState
+
+	// detachedViews are the views shown outside the main layout
+	detachedViews detachedViewState
+
+	// smbMounts are the Windows shares mounted through tunnels
+	smbMounts smbMountState
+
+	// groupCursor spreads connections to instance groups over their members
+	groupCursor groupCursorState
+
+	// notifications are held back while quiet mode is active
+	notifications notificationQueue
+
+	// bookmarkCLI serializes the app's Windows App CLI calls
+	bookmarkCLI bookmarkExecutor
+
+	// catalogs are the message catalogs of the backend strings
+	catalogs catalogState
+
+	// cloudForwarders are the CLI processes tunnels to other clouds relay through
+	cloudForwarders cloudForwardersState
+
+	// isAgent is set when running as the background agent, agent caches the GUI's view of it
+	isAgent bool
+	agent   agentState
+
+	// passwordResets holds cancel functions of in-flight password resets by connection ID
+	passwordResets   map[string]context.CancelFunc
+	passwordResetsMu sync.Mutex
 }
 
-// AppConfig represents the persisted application configuration
+// AppConfig represents the persisted application configuration, stored split into
+// FavoritesFileName, SettingsFileName and StateFileName
 type AppConfig struct {
 	LastConnection *LastConnection `json:"lastConnection,omitempty"`
 	Favorites      []Favorite      `json:"favorites"`
-}
+	Settings       AppSettings     `json:"settings"`
+	OpenTunnels    []TunnelSpec    `json:"openTunnels,omitempty"` // Tunnels running at last save, restored on next launch
+	// DeletedFavorites records when favorites were removed (ID -> RFC3339) so sync doesn't resurrect them
+	DeletedFavorites map[string]string `json:"deletedFavorites,omitempty"`
+	// ProjectDefaults apply to new favorites and ad hoc tunnels, keyed by project ID
+	ProjectDefaults map[string]ProjectDefaults `json:"projectDefaults,omitempty"`
+	// Window is the main window geometry at last close
+	Window *WindowState `json:"window,omitempty"`
+	// JITSessions are the active just-in-time sessions, torn down on the next launch once expired
+	JITSessions []JITSession `json:"jitSessions,omitempty"`
+	// GroupAppearances are the icons and colors of favorite groups, keyed by group name
+	GroupAppearances map[string]Appearance `json:"groupAppearances,omitempty"`
+}
+
+// AppSettings represents user-configurable application settings
+type AppSettings struct {
+	RestoreTunnels string `json:"restoreTunnels,omitempty"` // "ask" (default), "auto" or "off"
+	ICloudSync     bool   `json:"icloudSync,omitempty"`     // Sync favorites through iCloud Drive
+	BookmarkGroup  string `json:"bookmarkGroup,omitempty"`  // Windows App group for bookmarks (default "IAP Tunnels")
+	// ReconnectHotkey is a global shortcut (e.g. "alt+cmd+i") that reconnects the last session
+	ReconnectHotkey string `json:"reconnectHotkey,omitempty"`
+	// HealthCheckInterval is how often (in seconds) favorite VMs are polled for power state, 0 disables
+	HealthCheckInterval int `json:"healthCheckInterval,omitempty"`
+	// AllowWellKnownPorts lets tunnels bind ports used by macOS services (e.g. 5900 Screen Sharing)
+	AllowWellKnownPorts bool `json:"allowWellKnownPorts,omitempty"`
+	// SessionRecording writes the metadata of every tunneled connection to an encrypted local log
+	SessionRecording bool `json:"sessionRecording,omitempty"`
+	// NotifyDuringFocus shows notifications even while Focus is on or a display is mirrored
+	NotifyDuringFocus bool `json:"notifyDuringFocus,omitempty"`
+	// LowPowerMode pauses background polling: "auto" (default, while on battery), "on" or "off"
+	LowPowerMode string `json:"lowPowerMode,omitempty"`
+	// TelemetryOptIn sends anonymous daily usage counts, see GetTelemetryPreview
+	TelemetryOptIn bool `json:"telemetryOptIn,omitempty"`
+	// Language overrides the macOS language for backend messages, e.g. "uk"
+	Language string `json:"language,omitempty"`
+	// UserSwitch is what happens to tunnels while another user is active: "keep" (default) or "pause"
+	UserSwitch string `json:"userSwitch,omitempty"`
+	// TimeZone overrides the Mac's time zone for formatted timestamps, e.g. "Europe/Kyiv"
+	TimeZone string `json:"timeZone,omitempty"`
+	// UseAgent starts tunnels in the background agent so they outlive the GUI, see InstallAgent
+	UseAgent bool `json:"useAgent,omitempty"`
+	// Production safeguards apply to favorites in the prod environment
+	Production ProductionSafeguards `json:"production"`
+	// ExperimentalProviders offers target providers for other clouds, e.g. AWS SSM and Azure Bastion
+	ExperimentalProviders bool `json:"experimentalProviders,omitempty"`
+	// Operator restricts actions to an administrator on shared Macs, see EnableOperatorMode
+	Operator OperatorPolicy `json:"operator"`
+	// ConfirmCloudMutations rejects password resets and VM starts that weren't explicitly confirmed
+	ConfirmCloudMutations bool `json:"confirmCloudMutations,omitempty"`
+	// Advanced tunes how IAP connections are dialed
+	Advanced TunnelAdvanced `json:"advanced"`
+	// CloudMonitoring exports tunnel metrics as custom metrics, see GetCloudMonitoringStatus
+	CloudMonitoring CloudMonitoringSettings `json:"cloudMonitoring"`
+}
+
+// TunnelAdvanced holds advanced IAP dial options
+type TunnelAdvanced struct {
+	// Compression enables per-message compression on the IAP websocket
+	Compression bool `json:"compression,omitempty"`
+	// ConnectTimeout is how long (in seconds) to wait for an IAP dial, 0 waits indefinitely
+	ConnectTimeout int `json:"connectTimeout,omitempty"`
+	// PreDial keeps an IAP connection ready for the next local connection of a tunnel for a few
+	// seconds after each one, so bursts of connections skip the websocket handshake
+	PreDial bool `json:"preDial,omitempty"`
+	// StallTimeout is how long (in seconds) a connection may wait for the VM to answer data it
+	// sent before it is reported stalled, 0 uses 30
+	StallTimeout int `json:"stallTimeout,omitempty"`
+	// ResetStalled closes the IAP leg of stalled connections so clients reconnect. Uploads the
+	// server only answers when complete can look stalled, so it is off by default.
+	ResetStalled bool `json:"resetStalled,omitempty"`
+	// MaxConcurrentDials is how many IAP dials run at once, further ones queue; 0 uses 8
+	MaxConcurrentDials int `json:"maxConcurrentDials,omitempty"`
+}
+
+// MaxConnectTimeout is the largest accepted IAP connect timeout in seconds
+const MaxConnectTimeout = 300
 
 // LastConnection represents the last used connection settings
 type LastConnection struct {
@@ -77,19 +275,75 @@ type LastConnection struct {
 
 // Favorite represents a saved favorite connection
 type Favorite struct {
-	ID           string `json:"id"` // Stable UUID for bookmark mapping
+	ID string `json:"id"` // Random UUID, independent of the VM
+	// BookmarkID is the Windows App bookmark ID, derived from ID (favorites saved by older versions
+	// keep the project+vm+zone hash they were created with)
+	BookmarkID   string `json:"bookmarkId"`
 	DisplayName  string `json:"displayName"`
 	ProjectID    string `json:"projectId"`
 	ProjectName  string `json:"projectName,omitempty"`
 	InstanceName string `json:"instanceName"`
 	Zone         string `json:"zone"`
 	RemotePort   int    `json:"remotePort"`
-	LocalPort    int    `json:"localPort"` // Fixed local port for this connection
-	CreatedAt    string `json:"createdAt"`
+	// Profile tells apart favorites for the same VM and port, e.g. "admin" and "readonly"
+	Profile   string `json:"profile,omitempty"`
+	LocalPort int    `json:"localPort"` // Fixed local port for this connection
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt,omitempty"` // Used to resolve sync conflicts
+	Notes     string `json:"notes,omitempty"`     // Free-text notes (markdown allowed)
+	// BookmarkGroup overrides the global Windows App bookmark group for this favorite
+	BookmarkGroup string `json:"bookmarkGroup,omitempty"`
+	SortIndex     int    `json:"sortIndex"` // Position in the user-defined order
+	// Usage statistics, updated by StartTunnelForConnection
+	LastConnectedAt string `json:"lastConnectedAt,omitempty"`
+	ConnectCount    int    `json:"connectCount"`
+	HourCounts      []int  `json:"hourCounts,omitempty"` // Connections per local hour of day (24 entries)
+	// MaxDurationMinutes stops tunnels for this favorite automatically after the given time, 0 disables
+	MaxDurationMinutes int `json:"maxDurationMinutes,omitempty"`
+	// IdleTimeoutMinutes stops tunnels for this favorite after the given time without connections, 0 disables
+	IdleTimeoutMinutes int `json:"idleTimeoutMinutes,omitempty"`
+	// MemberSelection picks a member when InstanceName names an instance group: "round-robin" (default) or "healthiest"
+	MemberSelection string `json:"memberSelection,omitempty"`
+	// TargetKind names the provider of a target other than an instance or hostname, see targetProviders
+	TargetKind string `json:"targetKind,omitempty"`
+	// AWS and Azure locate targets of the experimental providers for those clouds
+	AWS   *AWSTarget   `json:"aws,omitempty"`
+	Azure *AzureTarget `json:"azure,omitempty"`
+	// Kubernetes locates the service or pod of kubernetes targets
+	Kubernetes *KubernetesTarget `json:"kubernetes,omitempty"`
+	// Hostname targets an internal DNS name; InstanceName and Zone hold the last resolved instance
+	Hostname string `json:"hostname,omitempty"`
+	// HostsEntry maps Hostname to 127.0.0.1 in /etc/hosts while the tunnel runs, see UpdateFavoriteHostsEntry
+	HostsEntry bool `json:"hostsEntry,omitempty"`
+	// Destination group fallback used when Hostname doesn't resolve to an instance
+	Region    string `json:"region,omitempty"`
+	Network   string `json:"network,omitempty"`
+	DestGroup string `json:"destGroup,omitempty"`
+	// ConnectionType selects the client opened on connect: "rdp" (default), "vnc", "web" or "database"
+	ConnectionType string            `json:"connectionType,omitempty"`
+	VNC            *VNCSettings      `json:"vnc,omitempty"`
+	Web            *WebSettings      `json:"web,omitempty"`
+	Database       *DatabaseSettings `json:"database,omitempty"`
+	// RemoteApps are published applications that can be opened instead of the full desktop
+	RemoteApps []RemoteApp `json:"remoteApps,omitempty"`
+	// OnConnect replaces the connection type's client with a custom app or command
+	OnConnect *Launcher `json:"onConnect,omitempty"`
+	// Preamble is sent to the VM before each connection's data, e.g. a PROXY protocol header
+	Preamble *PreambleSettings `json:"preamble,omitempty"`
+	// Environment classifies the VM as "prod", "staging" or "dev", prod enables the production safeguards
+	Environment string `json:"environment,omitempty"`
+	// Icon and Color make the favorite stand out in long lists, see Appearance
+	Icon  string `json:"icon,omitempty"`
+	Color string `json:"color,omitempty"`
 	// Windows credentials
 	Username         string `json:"username,omitempty"`
 	HasBookmark      bool   `json:"hasBookmark"`
 	BookmarkHasCreds bool   `json:"bookmarkHasCreds"` // true if bookmark was created with username/password
+	// BookmarkUnavailable is set while the favorite has a bookmark but Windows App is not installed
+	BookmarkUnavailable bool `json:"bookmarkUnavailable,omitempty"`
+	// CreatedAtDisplay and LastConnectedAtDisplay are only set in GetFavorites, never saved
+	CreatedAtDisplay       *DisplayTime `json:"createdAtDisplay,omitempty"`
+	LastConnectedAtDisplay *DisplayTime `json:"lastConnectedAtDisplay,omitempty"`
 }
 
 // Project represents a GCP project
@@ -102,10 +356,12 @@ type Project struct {
 type VM struct {
 	Name        string `json:"name"`
 	Zone        string `json:"zone"`
+	Region      string `json:"region"`
 	Status      string `json:"status"`
 	PrivateIP   string `json:"privateIp"`
 	MachineType string `json:"machineType"`
 	IsWindows   bool   `json:"isWindows"`
+	InstanceTimes
 }
 
 // Tunnel represents an active IAP tunnel
@@ -118,26 +374,109 @@ type Tunnel struct {
 	RemotePort int       `json:"remotePort"`
 	Status     string    `json:"status"`
 	StartedAt  time.Time `json:"startedAt"`
-	Logs       []string  `json:"logs"`
 	BookmarkID string    `json:"bookmarkId,omitempty"`
-
+	ExpiresAt  time.Time `json:"expiresAt,omitempty"`
+	// InstanceGroup is set when VMName was picked from a managed instance group
+	InstanceGroup string `json:"instanceGroup,omitempty"`
+	// Host is set for tunnels through an IAP destination group instead of an instance
+	Host string `json:"host,omitempty"`
+	// FavoriteID and Environment come from the favorite the tunnel was started for
+	FavoriteID  string `json:"favoriteId,omitempty"`
+	Environment string `json:"environment,omitempty"`
+
+	target   *targetEndpoint
 	listener net.Listener
 	cancel   context.CancelFunc
 	logsMu   sync.Mutex
+
+	// Log lines and their streaming state, guarded by logsMu
+	logs              []TunnelLogEntry
+	logSeq            uint64
+	pendingLogs       []TunnelLogEntry
+	logFlushScheduled bool
+	emit              func(name string, data ...interface{})
+
+	// expiryTimers fire the expiry warning and the automatic stop
+	expiryTimers []*time.Timer
+	// stopHooks release helpers tied to the tunnel (preview proxies, mounts), guarded by logsMu
+	stopHooks []func()
+	// webProxies are the ports of the running Host rewrite proxies, guarded by logsMu
+	webProxies map[webProxyKey]int
+
+	// Idle tracking, guarded by logsMu
+	activeConns  int
+	lastActivity time.Time
+	idleTimeout  time.Duration
+	idleStop     chan struct{}
+
+	// Dial health, guarded by logsMu
+	lastDialOK        time.Time
+	dialFailures      int
+	lastError         string
+	reconnectAttempts int
+	// stalledConns counts open connections the VM stopped answering, guarded by logsMu
+	stalledConns int
+
+	// metrics counts the bytes through the tunnel for GetTunnelMetrics
+	metrics tunnelMetrics
+
+	// spare is the IAP connection pre-dialed for the next local connection
+	spare tunnelSpare
 }
 
 // TunnelInfo is the JSON-safe tunnel info returned to frontend
 type TunnelInfo struct {
-	ID         string   `json:"id"`
-	ProjectID  string   `json:"projectId"`
-	VMName     string   `json:"vmName"`
-	Zone       string   `json:"zone"`
-	LocalPort  int      `json:"localPort"`
-	RemotePort int      `json:"remotePort"`
-	Status     string   `json:"status"`
-	StartedAt  string   `json:"startedAt"`
-	Logs       []string `json:"logs"`
-	BookmarkID string   `json:"bookmarkId,omitempty"`
+	ID         string `json:"id"`
+	ProjectID  string `json:"projectId"`
+	VMName     string `json:"vmName"`
+	Zone       string `json:"zone"`
+	LocalPort  int    `json:"localPort"`
+	RemotePort int    `json:"remotePort"`
+	Status     string `json:"status"`
+	StartedAt  string `json:"startedAt"`
+	BookmarkID string `json:"bookmarkId,omitempty"`
+	ExpiresAt  string `json:"expiresAt,omitempty"`
+	// StartedAtDisplay and ExpiresAtDisplay are StartedAt and ExpiresAt formatted for the user
+	StartedAtDisplay *DisplayTime `json:"startedAtDisplay,omitempty"`
+	ExpiresAtDisplay *DisplayTime `json:"expiresAtDisplay,omitempty"`
+	// InstanceGroup is the group name the VM was picked from, if any
+	InstanceGroup string `json:"instanceGroup,omitempty"`
+	// Host is the destination group host, if the tunnel doesn't target an instance
+	Host string `json:"host,omitempty"`
+	// FavoriteID, Environment and EnvironmentColor come from the favorite the tunnel was started for
+	FavoriteID       string `json:"favoriteId,omitempty"`
+	Environment      string `json:"environment,omitempty"`
+	EnvironmentColor string `json:"environmentColor,omitempty"`
+
+	// Health metrics from the tunnel's IAP dials
+	Health            string `json:"health"` // healthy, degraded, stalled, failing
+	LastDialOK        string `json:"lastDialOk,omitempty"`
+	DialFailures      int    `json:"dialFailures"`
+	LastError         string `json:"lastError,omitempty"`
+	ReconnectAttempts int    `json:"reconnectAttempts"`
+
+	// Agent is set when the tunnel runs in the background agent
+	Agent bool `json:"agent,omitempty"`
+	// Simulated is set for tunnels started by SimulateTunnel, they reach a local echo server
+	Simulated bool `json:"simulated,omitempty"`
+}
+
+// Tunnel health levels
+const (
+	TunnelHealthy  = "healthy"
+	TunnelDegraded = "degraded"
+	TunnelStalled  = "stalled"
+	TunnelFailing  = "failing"
+
+	// failingDialThreshold is the number of consecutive dial failures before a tunnel is failing
+	failingDialThreshold = 3
+)
+
+// TunnelGroup groups tunnels belonging to the same project
+type TunnelGroup struct {
+	ProjectID   string       `json:"projectId"`
+	ActiveCount int          `json:"activeCount"`
+	Tunnels     []TunnelInfo `json:"tunnels"`
 }
 
 // AuthStatus represents the authentication status
@@ -158,7 +497,9 @@ type GcloudInfo struct {
 	Found   bool   `json:"found"`
 	Path    string `json:"path,omitempty"`
 	Version string `json:"version,omitempty"`
-	Error   string `json:"error,omitempty"`
+	// Outdated is set when the SDK is old enough that UpdateGcloud should be offered
+	Outdated bool   `json:"outdated,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
 // WindowsAppStatus represents the Windows App availability status
@@ -179,6 +520,7 @@ type BookmarkResult struct {
 	Success    bool   `json:"success"`
 	BookmarkID string `json:"bookmarkId,omitempty"`
 	Error      string `json:"error,omitempty"`
+	Warning    string `json:"warning,omitempty"`
 }
 
 // WindowsPasswordRequest represents a request to generate/rotate Windows password
@@ -187,6 +529,10 @@ type WindowsPasswordRequest struct {
 	Username       string `json:"username"`
 	SaveToKeychain bool   `json:"saveToKeychain"`
 	UpdateBookmark bool   `json:"updateBookmark"`
+	// DryRun returns the Plan without changing anything
+	DryRun bool `json:"dryRun,omitempty"`
+	// Confirmed must be set when the ConfirmCloudMutations setting is on
+	Confirmed bool `json:"confirmed,omitempty"`
 }
 
 // WindowsPasswordResult represents the result of password generation
@@ -197,14 +543,32 @@ type WindowsPasswordResult struct {
 	Error           string `json:"error,omitempty"`
 	BookmarkUpdated bool   `json:"bookmarkUpdated"`
 	KeychainSaved   bool   `json:"keychainSaved"`
+	// Plan is what the reset changes, set for dry runs
+	Plan *MutationPlan `json:"plan,omitempty"`
+}
+
+// PasswordProgress represents a step of a Windows password reset
+type PasswordProgress struct {
+	ConnectionID string `json:"connectionId"`
+	Stage        string `json:"stage"` // "key", "metadata", "waiting", "scanning", "decrypting", "saving", "done", "cancelled", "error"
+	Message      string `json:"message"`
+	Attempt      int    `json:"attempt,omitempty"` // Serial output scan count while waiting for the agent
 }
 
+// errPasswordResetCancelled is returned when the user aborts a password reset
+var errPasswordResetCancelled = errors.New("password reset cancelled")
+
+// windowsKeyExpiry is how long the windows-keys entry of a password reset stays valid
+const windowsKeyExpiry = 5 * time.Minute
+
 // windowsKeyMetadata represents the metadata structure for Windows password reset
+// Entries are stored one per line in the windows-keys item
 type windowsKeyMetadata struct {
 	ExpireOn string `json:"expireOn"`
 	Exponent string `json:"exponent"`
 	Modulus  string `json:"modulus"`
 	UserName string `json:"userName"`
+	Email    string `json:"email,omitempty"`
 }
 
 // windowsPasswordResponse represents the response from the Windows guest agent
@@ -228,11 +592,17 @@ func NewApp() *App {
 		config:  &AppConfig{Favorites: []Favorite{}},
 	}
 	app.initConfigPath()
+	app.managed = loadManagedDefaults()
 	return app
 }
 
 // initConfigPath sets up the config file path
 func (a *App) initConfigPath() {
+	if dir := configDirFromEnv(); dir != "" {
+		a.configPath = filepath.Join(dir, ConfigFileName)
+		return
+	}
+
 	// Get user's Application Support directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -250,7 +620,7 @@ func (a *App) getConfigDir() string {
 	return filepath.Dir(a.configPath)
 }
 
-// loadConfig loads the configuration from disk
+// loadConfig loads the configuration from disk, migrating a config.json written by older versions
 func (a *App) loadConfig() error {
 	a.configMu.Lock()
 	defer a.configMu.Unlock()
@@ -259,6 +629,65 @@ func (a *App) loadConfig() error {
 		return fmt.Errorf("config path not set")
 	}
 
+	// Configs written by older versions were world-readable
+	a.repairConfigPermissions()
+
+	if !a.splitConfigExists() {
+		return a.loadLegacyConfigLocked()
+	}
+
+	config, err := a.readSplitConfig()
+	if config.Favorites == nil {
+		config.Favorites = []Favorite{}
+	}
+	a.config = config
+	if err == nil {
+		if data, err := json.MarshalIndent(config, "", "  "); err == nil {
+			a.backupConfig(data)
+		}
+	}
+	if migrateFavoriteIDs(config.Favorites, config.DeletedFavorites) {
+		a.markConfigDirty()
+	}
+	return err
+}
+
+// loadConfigReadOnly reads the config for one-shot modes like status mode while the GUI may be
+// running: nothing is repaired, backed up, migrated or written
+func (a *App) loadConfigReadOnly() error {
+	config, err := a.readConfigReadOnly()
+	a.configMu.Lock()
+	a.config = config
+	a.configMu.Unlock()
+	return err
+}
+
+// readConfigReadOnly reads the config without repairing, migrating or writing anything. On error
+// the returned config has what could be read.
+func (a *App) readConfigReadOnly() (*AppConfig, error) {
+	var config *AppConfig
+	var err error
+	if a.splitConfigExists() {
+		config, err = a.readSplitConfig()
+	} else {
+		config = &AppConfig{}
+		data, readErr := os.ReadFile(a.configPath)
+		if readErr == nil {
+			readErr = json.Unmarshal(data, config)
+		}
+		if readErr != nil && !os.IsNotExist(readErr) {
+			err = fmt.Errorf("failed to read config: %w", readErr)
+		}
+	}
+	if config.Favorites == nil {
+		config.Favorites = []Favorite{}
+	}
+	return config, err
+}
+
+// loadLegacyConfigLocked reads the single config.json of older versions and splits it into the
+// current files. config.json is left in place for a downgrade. Caller must hold configMu.
+func (a *App) loadLegacyConfigLocked() error {
 	data, err := os.ReadFile(a.configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -266,62 +695,155 @@ func (a *App) loadConfig() error {
 			a.config = &AppConfig{Favorites: []Favorite{}}
 			return nil
 		}
-		return fmt.Errorf("failed to read config: %w", err)
+		return &configLoadError{Code: StartupIssueConfigUnreadable, Path: a.configPath, Err: fmt.Errorf("failed to read config: %w", err)}
 	}
 
 	var config AppConfig
 	if err := json.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("failed to parse config: %w", err)
+		return &configLoadError{Code: StartupIssueConfigCorrupt, Path: a.configPath, Err: fmt.Errorf("failed to parse config: %w", err)}
 	}
+	a.backupConfig(data)
 
 	// Ensure favorites is not nil
 	if config.Favorites == nil {
 		config.Favorites = []Favorite{}
 	}
+	migrateFavoriteIDs(config.Favorites, config.DeletedFavorites)
 
 	a.config = &config
+	// The GUI owns the config, the agent only reads it
+	if !a.isAgent {
+		if err := a.writeConfigFiles(a.config); err != nil {
+			a.logWarningf("Failed to migrate config: %v", err)
+		}
+	}
 	return nil
 }
 
-// saveConfig saves the configuration to disk
-func (a *App) saveConfig() error {
+// GetSettings returns the current application settings
+func (a *App) GetSettings() AppSettings {
 	a.configMu.RLock()
-	config := a.config
-	a.configMu.RUnlock()
+	defer a.configMu.RUnlock()
 
-	if a.configPath == "" {
-		return fmt.Errorf("config path not set")
+	if a.config == nil {
+		return AppSettings{}
 	}
+	return a.config.Settings
+}
 
-	// Ensure config directory exists
-	configDir := a.getConfigDir()
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+// UpdateSettings replaces the application settings
+func (a *App) UpdateSettings(settings AppSettings) error {
+	switch settings.RestoreTunnels {
+	case "", RestoreModeAsk, RestoreModeAuto, RestoreModeOff:
+	default:
+		return fmt.Errorf("invalid restore mode: %s", settings.RestoreTunnels)
 	}
-
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+	if settings.HealthCheckInterval != 0 && settings.HealthCheckInterval < MinHealthCheckInterval {
+		return fmt.Errorf("health check interval must be at least %d seconds", MinHealthCheckInterval)
+	}
+	if settings.Production.MaxDurationMinutes < 0 {
+		return fmt.Errorf("production maximum duration must not be negative")
+	}
+	if settings.Advanced.ConnectTimeout < 0 || settings.Advanced.ConnectTimeout > MaxConnectTimeout {
+		return fmt.Errorf("connect timeout must be between 0 and %d seconds", MaxConnectTimeout)
+	}
+	if settings.Advanced.StallTimeout < 0 || settings.Advanced.StallTimeout > MaxStallTimeout {
+		return fmt.Errorf("stall timeout must be between 0 and %d seconds", MaxStallTimeout)
+	}
+	if settings.Advanced.MaxConcurrentDials < 0 || settings.Advanced.MaxConcurrentDials > MaxConcurrentDialsLimit {
+		return fmt.Errorf("concurrent dials must be between 0 and %d", MaxConcurrentDialsLimit)
+	}
+	if err := validateLowPowerMode(settings.LowPowerMode); err != nil {
+		return err
+	}
+	if err := validateTimeZone(settings.TimeZone); err != nil {
+		return err
+	}
+	if err := validateUserSwitchMode(settings.UserSwitch); err != nil {
+		return err
+	}
+	if settings.ReconnectHotkey != "" {
+		if _, err := parseHotkey(settings.ReconnectHotkey); err != nil {
+			return err
+		}
 	}
 
-	if err := os.WriteFile(a.configPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+	if !a.telemetryAllowed() {
+		settings.TelemetryOptIn = false
+	}
+	if err := a.requireOperatorUnlock(OperatorActionSettings); err != nil {
+		return err
 	}
 
-	return nil
+	a.mutateConfig(func(cfg *AppConfig) error {
+		// The operator policy only changes through EnableOperatorMode and DisableOperatorMode
+		settings.Operator = cfg.Settings.Operator
+		cfg.Settings = settings
+		return nil
+	})
+
+	if settings.ICloudSync {
+		a.startICloudSync()
+	} else {
+		a.stopICloudSync()
+	}
+	a.applyPowerMode()
+	a.applyHealthChecker()
+	a.applyMonitoringExport()
+	return a.applyReconnectHotkey()
 }
 
 // startup is called when the app starts
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	// Load saved configuration
-	a.loadConfig()
+	// Load saved configuration, a broken config is reported instead of looking like an empty one
+	if err := a.loadConfig(); err != nil {
+		a.recordConfigLoadError(err)
+	}
 	// Try to initialize credentials
 	a.initCredentials()
+	// Re-attach to tunnels the agent kept running while the GUI was gone
+	adopted := a.AdoptRunningTunnels()
+	// Remove /etc/hosts entries a crash left behind, before tunnels add their own
+	a.resetHostsEntries(adopted)
+	// Report tunnel health to status mode
+	a.serveStatusSocket()
+	// Pick up tunnels left open by the previous session
+	a.initSessionRestore()
+	// Tear down just-in-time sessions that expired while the app was closed, re-arm the others
+	a.resumeJITSessions()
+	// Start watching iCloud Drive if sync is enabled
+	if a.GetSettings().ICloudSync {
+		a.startICloudSync()
+	}
+	// Pause background polling while on battery
+	a.startPowerWatcher()
+	// Drop cached passwords when the Mac sleeps or locks
+	a.startCredentialFlusher()
+	// Pause tunnels while another user is active, if enabled
+	a.startUserSwitchWatcher()
+	// Start polling favorite VM power state if enabled
+	a.applyHealthChecker()
+	// Export tunnel metrics to Cloud Monitoring if enabled
+	a.applyMonitoringExport()
+	// Notice Windows App being removed or reinstalled
+	a.startWindowsAppWatcher()
+	// Register the global reconnect shortcut
+	if err := a.applyReconnectHotkey(); err != nil {
+		a.logWarningf("Failed to register reconnect hotkey: %v", err)
+	}
+	// Send usage metrics if the user opted in
+	a.startTelemetryReporter()
+	// Bring Windows App bookmarks in line with the favorites
+	a.reconcileBookmarksOnStartup()
+	// Connect to the favorite requested by a launcher
+	a.handleLaunchConnect()
 }
 
 // shutdown is called when the app is closing
 func (a *App) shutdown(ctx context.Context) {
+	a.saveTelemetry()
+
 	// Use a timeout for shutdown operations
 	shutdownTimeout := 5 * time.Second
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
@@ -363,10 +885,27 @@ func (a *App) shutdown(ctx context.Context) {
 		}
 		a.tunnelsMu.Unlock()
 	}
+
+	// The stop hooks removing /etc/hosts entries run in the background, don't leave them behind
+	a.releaseHostsEntries()
+	if a.statusServer != nil {
+		a.statusServer.Close()
+	}
+
+	// Write changes that are still waiting for the debounced save
+	if err := a.flushConfig(); err != nil {
+		a.logWarningf("Failed to save config: %v", err)
+	}
+
+	// Kill helper commands that are still running, e.g. a hung Windows App CLI
+	cancelCommands()
 }
 
 // stopTunnelInternal stops a tunnel without locking (caller must handle locking)
 func (a *App) stopTunnelInternal(tunnel *Tunnel) {
+	tunnel.stopExpiryTimers()
+	tunnel.stopIdleWatcher()
+	tunnel.runStopHooks()
 	if tunnel.cancel != nil {
 		tunnel.cancel()
 	}
@@ -391,136 +930,179 @@ func (a *App) GetLastConnection() *LastConnection {
 
 // SaveLastConnection saves the last used connection settings
 func (a *App) SaveLastConnection(projectID, projectName, instanceName, zone string, remotePort, preferredLocalPort int) error {
-	a.configMu.Lock()
-	if a.config == nil {
-		a.config = &AppConfig{Favorites: []Favorite{}}
-	}
-	a.config.LastConnection = &LastConnection{
-		ProjectID:          projectID,
-		ProjectName:        projectName,
-		InstanceName:       instanceName,
-		Zone:               zone,
-		RemotePort:         remotePort,
-		PreferredLocalPort: preferredLocalPort,
-	}
-	a.configMu.Unlock()
-
-	return a.saveConfig()
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		cfg.LastConnection = &LastConnection{
+			ProjectID:          projectID,
+			ProjectName:        projectName,
+			InstanceName:       instanceName,
+			Zone:               zone,
+			RemotePort:         remotePort,
+			PreferredLocalPort: preferredLocalPort,
+		}
+		return nil
+	})
 }
 
 // GetFavorites returns all saved favorites
 func (a *App) GetFavorites() []Favorite {
 	a.configMu.RLock()
-	defer a.configMu.RUnlock()
-
 	if a.config == nil || a.config.Favorites == nil {
+		a.configMu.RUnlock()
 		return []Favorite{}
 	}
 
-	// Return a copy
+	// Return a copy in the user-defined order
 	favorites := make([]Favorite, len(a.config.Favorites))
 	copy(favorites, a.config.Favorites)
+	a.configMu.RUnlock()
+
+	sort.SliceStable(favorites, func(i, j int) bool {
+		return favorites[i].SortIndex < favorites[j].SortIndex
+	})
+	for i := range favorites {
+		favorites[i].CreatedAtDisplay = a.displayTime(favorites[i].CreatedAt)
+		favorites[i].LastConnectedAtDisplay = a.displayTime(favorites[i].LastConnectedAt)
+	}
 	return favorites
 }
 
 // AddFavorite adds a new favorite connection
 func (a *App) AddFavorite(displayName, projectID, projectName, instanceName, zone string, remotePort, preferredLocalPort int) (*Favorite, error) {
-	// Get a free port first (before locking config)
-	localPort, err := a.GetFreePort()
-	if err != nil {
-		return nil, fmt.Errorf("failed to allocate local port: %w", err)
+	return a.addFavorite(displayName, projectID, projectName, instanceName, zone, remotePort, nil)
+}
+
+// AddFavoriteWithProfile adds another favorite for a VM and port that is already saved, told apart
+// by profile (e.g. "admin" and "readonly" logins to the same jump box)
+func (a *App) AddFavoriteWithProfile(displayName, projectID, projectName, instanceName, zone, profile string, remotePort int) (*Favorite, error) {
+	profile = strings.TrimSpace(profile)
+	if profile == "" {
+		return nil, fmt.Errorf("profile cannot be empty")
 	}
+	return a.addFavorite(displayName, projectID, projectName, instanceName, zone, remotePort, func(f *Favorite) {
+		f.Profile = profile
+	})
+}
 
-	a.configMu.Lock()
-	defer a.configMu.Unlock()
+// addFavorite adds a favorite, customize sets up connection-type specific fields before it is
+// saved so other writers never see it half configured
+func (a *App) addFavorite(displayName, projectID, projectName, instanceName, zone string, remotePort int, customize func(f *Favorite)) (*Favorite, error) {
+	remotePort = a.projectRemotePort(projectID, remotePort)
 
-	if a.config == nil {
-		a.config = &AppConfig{Favorites: []Favorite{}}
+	// Get a free port no favorite uses first (before locking config)
+	ports, err := a.freePortsForFavorites(1)
+	if err != nil {
+		return nil, err
 	}
+	localPort := ports[0]
 
-	// Check if already exists (same project+instance+zone)
-	for _, f := range a.config.Favorites {
-		if f.ProjectID == projectID && f.InstanceName == instanceName && f.Zone == zone {
-			return nil, fmt.Errorf("connection already exists for this VM")
+	var favorite Favorite
+	err = a.mutateConfig(func(cfg *AppConfig) error {
+		id, err := newFavoriteID()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		favorite = Favorite{
+			ID:           id,
+			BookmarkID:   favoriteBookmarkID(id),
+			DisplayName:  displayName,
+			ProjectID:    projectID,
+			ProjectName:  projectName,
+			InstanceName: instanceName,
+			Zone:         zone,
+			RemotePort:   remotePort,
+			LocalPort:    localPort,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			SortIndex:    a.nextSortIndexLocked(),
+		}
+		a.applyProjectDefaultsLocked(&favorite)
+		if customize != nil {
+			customize(&favorite)
+		}
+
+		// Check if already exists (same VM, remote port and profile)
+		key := favoriteVMKey(favorite)
+		for _, f := range cfg.Favorites {
+			if favoriteVMKey(f) == key {
+				return duplicateFavoriteError(favorite)
+			}
 		}
-	}
 
-	// Check if port conflicts with existing connections
-	for _, f := range a.config.Favorites {
-		if f.LocalPort == localPort {
-			// Try to get another port
-			a.configMu.Unlock()
-			localPort, err = a.GetFreePort()
-			a.configMu.Lock()
-			if err != nil {
-				return nil, fmt.Errorf("failed to allocate local port: %w", err)
+		// A favorite added concurrently may have taken the port
+		for _, f := range cfg.Favorites {
+			if f.LocalPort == localPort {
+				return fmt.Errorf("local port %d was just taken by %s, try again", localPort, favoriteLabel(f))
 			}
 		}
-	}
-
-	// Generate stable ID based on project+instance+zone
-	favoriteID := a.GenerateBookmarkID(projectID, instanceName, zone)
-
-	favorite := Favorite{
-		ID:           favoriteID,
-		DisplayName:  displayName,
-		ProjectID:    projectID,
-		ProjectName:  projectName,
-		InstanceName: instanceName,
-		Zone:         zone,
-		RemotePort:   remotePort,
-		LocalPort:    localPort,
-		CreatedAt:    time.Now().Format(time.RFC3339),
-	}
-
-	a.config.Favorites = append(a.config.Favorites, favorite)
-
-	// Save config (unlock first to avoid deadlock)
-	a.configMu.Unlock()
-	err = a.saveConfig()
-	a.configMu.Lock()
 
+		cfg.Favorites = append(cfg.Favorites, favorite)
+		return nil
+	})
 	if err != nil {
-		// Remove the favorite we just added
-		a.config.Favorites = a.config.Favorites[:len(a.config.Favorites)-1]
-		return nil, fmt.Errorf("failed to save connection: %w", err)
+		return nil, err
 	}
-
 	return &favorite, nil
 }
 
-// RemoveFavorite removes a favorite by its ID
-func (a *App) RemoveFavorite(favoriteID string) error {
-	a.configMu.Lock()
-	defer a.configMu.Unlock()
+// connectionSettings are the settings of a connection type other than RDP, e.g. WebSettings
+type connectionSettings interface {
+	validate() error
+	connectionType() string
+	// apply stores the settings on a favorite of their connection type
+	apply(f *Favorite)
+}
 
-	if a.config == nil || a.config.Favorites == nil {
-		return fmt.Errorf("favorite not found")
+// addConnectionFavorite adds a favorite of the settings' connection type
+func (a *App) addConnectionFavorite(displayName, projectID, projectName, instanceName, zone string, remotePort int, settings connectionSettings) (*Favorite, error) {
+	if err := settings.validate(); err != nil {
+		return nil, err
 	}
 
-	// Find and remove the favorite
-	found := false
-	newFavorites := make([]Favorite, 0, len(a.config.Favorites))
-	for _, f := range a.config.Favorites {
-		if f.ID == favoriteID {
-			found = true
-			continue
-		}
-		newFavorites = append(newFavorites, f)
-	}
+	return a.addFavorite(displayName, projectID, projectName, instanceName, zone, remotePort, func(f *Favorite) {
+		f.ConnectionType = settings.connectionType()
+		settings.apply(f)
+	})
+}
 
-	if !found {
-		return fmt.Errorf("favorite not found")
+// updateConnectionSettings replaces the settings of a favorite of their connection type
+func (a *App) updateConnectionSettings(favoriteID string, settings connectionSettings) error {
+	if err := settings.validate(); err != nil {
+		return err
 	}
 
-	a.config.Favorites = newFavorites
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		if f.ConnectionType != settings.connectionType() {
+			return fmt.Errorf("favorite is not a %s connection", settings.connectionType())
+		}
+		settings.apply(f)
+		return nil
+	})
+}
 
-	// Save config
-	a.configMu.Unlock()
-	err := a.saveConfig()
-	a.configMu.Lock()
+// RemoveFavorite removes a favorite by its ID
+func (a *App) RemoveFavorite(favoriteID string) error {
+	// The tombstone must carry the UUID for iCloud sync to match it
+	favoriteID = canonicalFavoriteID(favoriteID)
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		kept := make([]Favorite, 0, len(cfg.Favorites))
+		for _, f := range cfg.Favorites {
+			if f.ID != favoriteID {
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) == len(cfg.Favorites) {
+			return fmt.Errorf("favorite not found")
+		}
 
-	return err
+		cfg.Favorites = kept
+		if cfg.DeletedFavorites == nil {
+			cfg.DeletedFavorites = make(map[string]string)
+		}
+		cfg.DeletedFavorites[favoriteID] = time.Now().Format(time.RFC3339)
+		return nil
+	})
 }
 
 // IsFavorite checks if a VM is in favorites
@@ -558,39 +1140,78 @@ func (a *App) GetFavoriteByVM(projectID, instanceName, zone string) *Favorite {
 	return nil
 }
 
-// UpdateFavorite updates an existing favorite
-func (a *App) UpdateFavorite(favoriteID, displayName string, remotePort int) error {
-	a.configMu.Lock()
-	defer a.configMu.Unlock()
+// favoriteFor returns the favorite of a VM for remotePort, preferring the one on localPort since
+// a VM can have several favorites. A zero port matches any. nil when no favorite matches.
+func (a *App) favoriteFor(projectID, instanceName, zone string, remotePort, localPort int) *Favorite {
+	a.configMu.RLock()
+	defer a.configMu.RUnlock()
 
-	if a.config == nil || a.config.Favorites == nil {
-		return fmt.Errorf("favorite not found")
+	if a.config == nil {
+		return nil
 	}
 
-	found := false
-	for i := range a.config.Favorites {
-		if a.config.Favorites[i].ID == favoriteID {
-			if displayName != "" {
-				a.config.Favorites[i].DisplayName = displayName
-			}
-			if remotePort > 0 {
-				a.config.Favorites[i].RemotePort = remotePort
-			}
-			found = true
-			break
+	var match *Favorite
+	for _, f := range a.config.Favorites {
+		if f.ProjectID != projectID || f.InstanceName != instanceName || f.Zone != zone {
+			continue
+		}
+		if remotePort != 0 && f.RemotePort != remotePort {
+			continue
+		}
+		if match == nil || (localPort != 0 && f.LocalPort == localPort) {
+			fav := f // Copy
+			match = &fav
 		}
 	}
+	return match
+}
 
-	if !found {
-		return fmt.Errorf("favorite not found")
+// UpdateFavorite updates an existing favorite
+func (a *App) UpdateFavorite(favoriteID, displayName string, remotePort int) error {
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		if displayName != "" {
+			f.DisplayName = displayName
+		}
+		if remotePort > 0 {
+			f.RemotePort = remotePort
+		}
+		return nil
+	})
+}
+
+// UpdateFavoriteNotes sets the free-text notes for a favorite
+func (a *App) UpdateFavoriteNotes(favoriteID, notes string) error {
+	if len(notes) > MaxNotesLength {
+		return fmt.Errorf("notes are too long (max %d bytes)", MaxNotesLength)
 	}
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		f.Notes = notes
+		return nil
+	})
+}
 
-	// Save config
-	a.configMu.Unlock()
-	err := a.saveConfig()
-	a.configMu.Lock()
+// UpdateFavoriteBookmarkGroup sets the Windows App bookmark group for a favorite.
+// An empty group falls back to the global setting.
+func (a *App) UpdateFavoriteBookmarkGroup(favoriteID, group string) error {
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		f.BookmarkGroup = strings.TrimSpace(group)
+		return nil
+	})
+}
 
-	return err
+// bookmarkGroupFor returns the Windows App group to use for a favorite's bookmark.
+// fav may be nil for ad-hoc tunnels.
+func (a *App) bookmarkGroupFor(fav *Favorite) string {
+	if fav != nil && fav.BookmarkGroup != "" {
+		return fav.BookmarkGroup
+	}
+	if group := strings.TrimSpace(a.GetSettings().BookmarkGroup); group != "" {
+		return group
+	}
+	if a.managed != nil && a.managed.BookmarkGroup != "" {
+		return a.managed.BookmarkGroup
+	}
+	return BookmarkGroup
 }
 
 // initCredentials initializes Google Cloud credentials using ADC
@@ -603,7 +1224,7 @@ func (a *App) initCredentials() error {
 	if err != nil {
 		return fmt.Errorf("failed to get default credentials: %w", err)
 	}
-	a.tokenSource = tokenSource
+	a.tokenSource = &activityTokenSource{src: tokenSource, record: func() { a.recordNetActivity(hostOAuth) }}
 	return nil
 }
 
@@ -611,6 +1232,7 @@ func (a *App) initCredentials() error {
 func (a *App) CheckAuth() AuthStatus {
 	if a.tokenSource == nil {
 		if err := a.initCredentials(); err != nil {
+			a.reportError(ErrorSourceAuth, "", err)
 			return AuthStatus{
 				Authenticated: false,
 				Error:         "Application Default Credentials not found. Please run 'gcloud auth application-default login' to authenticate.",
@@ -621,6 +1243,7 @@ func (a *App) CheckAuth() AuthStatus {
 	// Try to get a token to verify credentials work
 	token, err := a.tokenSource.Token()
 	if err != nil {
+		a.reportError(ErrorSourceAuth, "", err)
 		return AuthStatus{
 			Authenticated: false,
 			Error:         fmt.Sprintf("Failed to get token: %v. Please run 'gcloud auth application-default login'", err),
@@ -677,11 +1300,11 @@ func (a *App) FindGcloud() GcloudInfo {
 
 // verifyGcloud verifies gcloud works and gets version
 func (a *App) verifyGcloud(path string) GcloudInfo {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, path, "version", "--format=value(version)")
-	output, err := cmd.Output()
+	output, err := runCommandSpec(commandSpec{
+		name:    path,
+		args:    []string{"version", "--format=value(version)"},
+		timeout: 10 * time.Second,
+	})
 	if err != nil {
 		return GcloudInfo{
 			Found: true,
@@ -692,15 +1315,17 @@ func (a *App) verifyGcloud(path string) GcloudInfo {
 
 	version := strings.TrimSpace(string(output))
 	return GcloudInfo{
-		Found:   true,
-		Path:    path,
-		Version: version,
+		Found:    true,
+		Path:     path,
+		Version:  version,
+		Outdated: gcloudOutdated(version),
 	}
 }
 
 // OpenGcloudInstallPage opens the Google Cloud SDK installation page in the browser
 func (a *App) OpenGcloudInstallPage() error {
-	return exec.Command("open", "https://cloud.google.com/sdk/docs/install").Run()
+	_, err := runCommand("open", "https://cloud.google.com/sdk/docs/install")
+	return err
 }
 
 // RunADCLogin runs gcloud auth application-default login
@@ -716,18 +1341,17 @@ func (a *App) RunADCLogin() AuthProgress {
 
 	// Run the auth command
 	// Note: This command opens a browser for OAuth flow
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, gcloudInfo.Path, "auth", "application-default", "login")
-
-	// Capture output
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+	_, err := runCommandSpec(commandSpec{
+		name:     gcloudInfo.Path,
+		args:     []string{"auth", "application-default", "login"},
+		timeout:  5 * time.Minute,
+		combined: true,
+	})
 
 	if err != nil {
 		// Check if it was cancelled/timeout
-		if ctx.Err() == context.DeadlineExceeded {
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) && cmdErr.TimedOut {
 			return AuthProgress{
 				Status:  "error",
 				Message: "Authentication timed out after 5 minutes",
@@ -735,7 +1359,7 @@ func (a *App) RunADCLogin() AuthProgress {
 		}
 		return AuthProgress{
 			Status:  "error",
-			Message: fmt.Sprintf("Authentication failed: %v\n%s", err, outputStr),
+			Message: fmt.Sprintf("Authentication failed: %v", err),
 		}
 	}
 
@@ -791,8 +1415,8 @@ func (a *App) ListProjects(filter string) ([]Project, error) {
 
 	err = crmService.Projects.List().Pages(ctx, func(page *cloudresourcemanager.ListProjectsResponse) error {
 		for _, p := range page.Projects {
-			// Only include active projects
-			if p.LifecycleState != "ACTIVE" {
+			// Only include active projects the administrator allows
+			if p.LifecycleState != "ACTIVE" || !a.projectAllowed(p.ProjectId) {
 				continue
 			}
 			// Apply filter if provided
@@ -810,7 +1434,9 @@ func (a *App) ListProjects(filter string) ([]Project, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list projects: %w", err)
+		err = fmt.Errorf("failed to list projects: %w", err)
+		a.reportError(ErrorSourceAPI, "", err)
+		return nil, err
 	}
 
 	// Sort by name
@@ -823,11 +1449,17 @@ func (a *App) ListProjects(filter string) ([]Project, error) {
 
 // ListVMs returns all VMs for a given project
 func (a *App) ListVMs(projectID, filter string) ([]VM, error) {
+	vms, err := a.listVMs(context.Background(), projectID, filter)
+	a.reportError(ErrorSourceAPI, projectID, err)
+	return vms, err
+}
+
+// listVMs lists the project's VMs matching filter until ctx is canceled
+func (a *App) listVMs(ctx context.Context, projectID, filter string) ([]VM, error) {
 	if a.tokenSource == nil {
 		return nil, fmt.Errorf("not authenticated")
 	}
 
-	ctx := context.Background()
 	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create compute client: %w", err)
@@ -869,43 +1501,48 @@ func (a *App) ListVMs(projectID, filter string) ([]VM, error) {
 					machineType = machineType[idx+1:]
 				}
 
-				// Detect if Windows based on disks licenses or OS
-				isWindows := false
-				for _, disk := range instance.Disks {
-					for _, license := range disk.Licenses {
-						licenseLower := strings.ToLower(license)
-						if strings.Contains(licenseLower, "windows") {
-							isWindows = true
-							break
-						}
-					}
-					if isWindows {
-						break
-					}
-				}
-
 				vms = append(vms, VM{
-					Name:        instance.Name,
-					Zone:        zone,
-					Status:      instance.Status,
-					PrivateIP:   privateIP,
-					MachineType: machineType,
-					IsWindows:   isWindows,
+					Name:          instance.Name,
+					Zone:          zone,
+					Region:        zoneRegion(zone),
+					Status:        instance.Status,
+					PrivateIP:     privateIP,
+					MachineType:   machineType,
+					IsWindows:     instanceIsWindows(instance),
+					InstanceTimes: instanceTimes(instance),
 				})
 			}
 		}
 		return nil
 	})
 	if err != nil {
+		if disabled := a.serviceDisabled(projectID, err); disabled != nil {
+			return nil, disabled
+		}
 		return nil, fmt.Errorf("failed to list VMs: %w", err)
 	}
 
-	// Sort by name
+	sortVMs(vms)
+	return vms, nil
+}
+
+// sortVMs sorts VMs by name
+func sortVMs(vms []VM) {
 	sort.Slice(vms, func(i, j int) bool {
 		return vms[i].Name < vms[j].Name
 	})
+}
 
-	return vms, nil
+// instanceIsWindows detects Windows VMs based on their disk licenses
+func instanceIsWindows(instance *compute.Instance) bool {
+	for _, disk := range instance.Disks {
+		for _, license := range disk.Licenses {
+			if strings.Contains(strings.ToLower(license), "windows") {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // GetFreePort finds an available local port that is not used by any active tunnel
@@ -956,20 +1593,27 @@ func (a *App) GetUsedPorts() []int {
 
 // StartTunnel starts an IAP tunnel to the specified VM
 func (a *App) StartTunnel(projectID, vmName, zone string, localPort int) (*TunnelInfo, error) {
-	return a.StartTunnelWithRemotePort(projectID, vmName, zone, localPort, 3389)
+	return a.StartTunnelWithRemotePort(projectID, vmName, zone, localPort, 0)
 }
 
 // StartTunnelForConnection starts a tunnel using the connection's fixed port
 func (a *App) StartTunnelForConnection(connectionID string) (*TunnelInfo, error) {
-	// Find the connection
-	a.configMu.RLock()
-	var conn *Favorite
-	for i := range a.config.Favorites {
-		if a.config.Favorites[i].ID == connectionID {
-			conn = &a.config.Favorites[i]
-			break
+	fav := a.GetConnectionInfo(connectionID)
+	if err := a.checkProductionConfirmation(fav); err != nil {
+		return nil, err
+	}
+	if fav != nil && a.agentStartsTunnels() {
+		var info TunnelInfo
+		if err := a.agentCall("StartTunnelForConnection", &info, connectionID); err != nil {
+			return nil, err
 		}
+		info.Agent = true
+		return a.afterConnectionStarted(fav, &info), nil
 	}
+
+	// Find the connection
+	a.configMu.RLock()
+	conn := favoriteIn(a.config, connectionID)
 	a.configMu.RUnlock()
 
 	if conn == nil {
@@ -992,15 +1636,96 @@ func (a *App) StartTunnelForConnection(connectionID string) (*TunnelInfo, error)
 	}
 	testListener.Close()
 
+	// The provider of the favorite's target picks the endpoint, e.g. a member of an instance group
+	ep, err := a.resolveTarget(conn)
+	if err != nil {
+		return nil, err
+	}
+	ep.preamble = conn.Preamble
+
 	// Start the tunnel with the connection's fixed port
-	return a.StartTunnelWithRemotePort(conn.ProjectID, conn.InstanceName, conn.Zone, conn.LocalPort, conn.RemotePort)
+	info, err := a.startTunnel(ep, conn.LocalPort, conn.RemotePort)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.afterConnectionStarted(conn, info), nil
 }
 
-// StartTunnelWithRemotePort starts an IAP tunnel to the specified VM with a custom remote port
+// afterConnectionStarted records usage and applies the favorite's tunnel limits. For tunnels
+// started in the agent it runs in the GUI too, which owns the config and the /etc/hosts block,
+// and applies the limits through the agent API.
+func (a *App) afterConnectionStarted(conn *Favorite, info *TunnelInfo) *TunnelInfo {
+	// Tag the tunnel in the process that runs it
+	a.tunnelsMu.Lock()
+	if t, ok := a.tunnels[info.ID]; ok {
+		t.FavoriteID = conn.ID
+		t.Environment = conn.Environment
+	}
+	a.tunnelsMu.Unlock()
+
+	if !a.isAgent {
+		a.recordConnectionUsage(conn.ID)
+
+		if conn.HostsEntry && conn.Hostname != "" {
+			if err := a.addHostsEntry(info.ID, conn.Hostname); err != nil {
+				a.reportError(ErrorSourceTunnel, conn.Hostname, err)
+			}
+		}
+
+		// startTunnel enforced the maximum duration in the process running the tunnel
+		if conn.IdleTimeoutMinutes > 0 {
+			a.SetTunnelIdleTimeout(info.ID, conn.IdleTimeoutMinutes)
+		}
+	}
+
+	if updated, err := a.GetTunnel(info.ID); err == nil {
+		info = updated
+	}
+	return info
+}
+
+// StartTunnelWithRemotePort starts an IAP tunnel to the specified VM with a custom remote port.
+// A remote port of 0 uses the project's default. The project's automatic stop settings apply.
 func (a *App) StartTunnelWithRemotePort(projectID, vmName, zone string, localPort, remotePort int) (*TunnelInfo, error) {
-	if a.tokenSource == nil {
+	a.countFeature("tunnel_start")
+	if a.agentStartsTunnels() {
+		// Ask before the call, the agent checks again
+		ep := a.instanceEndpoint(projectID, vmName, zone)
+		if err := a.checkProductionConfirmation(a.endpointFavorite(ep, localPort, a.projectRemotePort(projectID, remotePort))); err != nil {
+			return nil, err
+		}
+		var info TunnelInfo
+		if err := a.agentCall("StartTunnelWithRemotePort", &info, projectID, vmName, zone, localPort, remotePort); err != nil {
+			return nil, err
+		}
+		info.Agent = true
+		return &info, nil
+	}
+	info, err := a.startTunnel(a.instanceEndpoint(projectID, vmName, zone), localPort, a.projectRemotePort(projectID, remotePort))
+	if err != nil {
+		a.countError("tunnel_start", err.Error())
+		a.reportError(ErrorSourceTunnel, vmName, err)
+		return nil, err
+	}
+	return a.applyProjectTunnelLimits(info), nil
+}
+
+// startTunnel starts a tunnel to an endpoint resolved by a target provider
+func (a *App) startTunnel(ep *targetEndpoint, localPort, remotePort int) (*TunnelInfo, error) {
+	projectID, vmName, zone := ep.ProjectID, ep.Name, ep.Zone
+	// Targets reached through another CLI use its sign-in
+	if a.tokenSource == nil && !ep.external() {
 		return nil, fmt.Errorf("not authenticated")
 	}
+	if !a.projectAllowed(projectID) {
+		return nil, fmt.Errorf("project %s is not allowed by your administrator", projectID)
+	}
+	// Production safeguards apply however the tunnel is started
+	fav := a.endpointFavorite(ep, localPort, remotePort)
+	if err := a.checkProductionConfirmation(fav); err != nil {
+		return nil, err
+	}
 
 	// Generate unique tunnel ID using timestamp to allow multiple tunnels to same VM
 	tunnelID := fmt.Sprintf("%s-%s-%s-%d", projectID, vmName, zone, time.Now().UnixNano())
@@ -1013,6 +1738,11 @@ func (a *App) StartTunnelWithRemotePort(projectID, vmName, zone string, localPor
 			return nil, fmt.Errorf("failed to find free port: %w", err)
 		}
 	} else {
+		// Don't shadow macOS services like Screen Sharing
+		if err := a.checkWellKnownPort(localPort); err != nil {
+			return nil, err
+		}
+
 		// Check if the specified port is already used by another tunnel
 		if a.isPortInUse(localPort) {
 			// Try to find a free port instead
@@ -1035,16 +1765,21 @@ func (a *App) StartTunnelWithRemotePort(projectID, vmName, zone string, localPor
 	ctx, cancel := context.WithCancel(context.Background())
 
 	tunnel := &Tunnel{
-		ID:         tunnelID,
-		ProjectID:  projectID,
-		VMName:     vmName,
-		Zone:       zone,
-		LocalPort:  localPort,
-		RemotePort: remotePort,
-		Status:     "starting",
-		StartedAt:  time.Now(),
-		Logs:       []string{},
-		cancel:     cancel,
+		ID:            tunnelID,
+		ProjectID:     projectID,
+		VMName:        vmName,
+		Zone:          zone,
+		LocalPort:     localPort,
+		RemotePort:    remotePort,
+		Status:        "starting",
+		StartedAt:     time.Now(),
+		InstanceGroup: ep.group,
+		cancel:        cancel,
+		target:        ep,
+		emit:          a.emitEvent,
+	}
+	if ep.dest != nil {
+		tunnel.Host = ep.dest.Host
 	}
 
 	// Store tunnel
@@ -1055,23 +1790,39 @@ func (a *App) StartTunnelWithRemotePort(projectID, vmName, zone string, localPor
 	// Start the tunnel in a goroutine
 	go a.runTunnel(ctx, tunnel)
 
+	if limit := a.managedIdleTimeout(0); limit > 0 {
+		a.SetTunnelIdleTimeout(tunnelID, limit)
+	}
+	if fav != nil {
+		if minutes := a.maxDurationFor(fav); minutes > 0 {
+			a.SetTunnelMaxDuration(tunnelID, minutes)
+		}
+	}
+	a.saveOpenTunnels()
+
 	return tunnel.toInfo(), nil
 }
 
 // runTunnel runs the IAP tunnel
 func (a *App) runTunnel(ctx context.Context, tunnel *Tunnel) {
-	tunnel.addLog(fmt.Sprintf("Starting tunnel to %s in zone %s (remote port %d)", tunnel.VMName, tunnel.Zone, tunnel.RemotePort))
+	if tunnel.target.note != "" {
+		tunnel.addLog(tunnel.target.note)
+	}
+	tunnel.addLog(fmt.Sprintf("Starting tunnel to %s (remote port %d)", tunnel.target, tunnel.RemotePort))
 
 	// Create local listener
 	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", tunnel.LocalPort))
 	if err != nil {
 		tunnel.Status = "error"
 		tunnel.addLog(fmt.Sprintf("Failed to create listener: %v", err))
+		a.reportError(ErrorSourceTunnel, tunnel.target.String(), fmt.Errorf("failed to create listener on port %d: %w", tunnel.LocalPort, err))
+		a.saveOpenTunnels()
 		return
 	}
 	tunnel.listener = listener
 	tunnel.Status = "running"
 	tunnel.addLog(fmt.Sprintf("Listening on 127.0.0.1:%d -> remote:%d", tunnel.LocalPort, tunnel.RemotePort))
+	go a.sampleTunnelThroughput(ctx, tunnel)
 
 	// Accept connections
 	go func() {
@@ -1083,9 +1834,15 @@ func (a *App) runTunnel(ctx context.Context, tunnel *Tunnel) {
 					return
 				default:
 					tunnel.addLog(fmt.Sprintf("Accept error: %v", err))
+					tunnel.recordError(err)
 					continue
 				}
 			}
+			if a.tunnelsPaused() {
+				tunnel.addLog(fmt.Sprintf("Refused connection from %s, tunnels are paused", conn.RemoteAddr()))
+				conn.Close()
+				continue
+			}
 			tunnel.addLog(fmt.Sprintf("New connection from %s", conn.RemoteAddr()))
 			go a.handleConnection(ctx, tunnel, conn)
 		}
@@ -1096,28 +1853,54 @@ func (a *App) runTunnel(ctx context.Context, tunnel *Tunnel) {
 	tunnel.Status = "stopped"
 	tunnel.addLog("Tunnel stopped")
 	listener.Close()
+	tunnel.spare.discard()
 }
 
 // handleConnection handles a single connection through the IAP tunnel
 func (a *App) handleConnection(ctx context.Context, tunnel *Tunnel, localConn net.Conn) {
 	defer localConn.Close()
+	// Tunneled sessions are interactive even while the app is in the background
+	defer beginActivity("Forwarding a tunneled connection")()
+	tunnel.connOpened()
+	defer tunnel.connClosed()
+
+	started := time.Now()
+	record := SessionRecord{
+		Client:    localConn.RemoteAddr().String(),
+		StartedAt: started.Format(time.RFC3339),
+	}
+	defer func() {
+		record.EndedAt = time.Now().Format(time.RFC3339)
+		record.Seconds = int64(time.Since(started).Seconds())
+		a.recordSession(tunnel, record)
+	}()
 
 	// Dial IAP tunnel
-	opts := []iap.DialOption{
-		iap.WithProject(tunnel.ProjectID),
-		iap.WithInstance(tunnel.VMName, tunnel.Zone, "nic0"),
-		iap.WithPort(fmt.Sprintf("%d", tunnel.RemotePort)),
-		iap.WithTokenSource(&a.tokenSource),
-	}
-
-	iapConn, err := iap.Dial(ctx, opts...)
+	iapConn, preDialed, err := a.tunnelConn(ctx, tunnel)
 	if err != nil {
 		tunnel.addLog(fmt.Sprintf("Failed to dial IAP: %v", err))
+		a.reportError(ErrorSourceTunnel, tunnel.target.String(), fmt.Errorf("failed to dial IAP: %w", err))
+		record.Error = err.Error()
+		return
+	}
+	defer iapConn.Close()
+
+	if preDialed {
+		tunnel.addLog("Using pre-dialed IAP connection")
+	} else {
+		tunnel.addLog("IAP connection established")
+	}
+	if err := writePreamble(iapConn, tunnel.target.preamble, localConn, tunnel.target.String()); err != nil {
+		tunnel.addLog(err.Error())
+		record.Error = err.Error()
 		return
 	}
-	defer iapConn.Close()
 
-	tunnel.addLog("IAP connection established")
+	// Data sent without an answer for too long means the IAP leg is half dead
+	watch := &stallWatch{}
+	stop := make(chan struct{})
+	defer close(stop)
+	go a.watchStall(tunnel, record.Client, watch, func() { iapConn.Close() }, stop)
 
 	// Bidirectional copy
 	var wg sync.WaitGroup
@@ -1126,29 +1909,85 @@ func (a *App) handleConnection(ctx context.Context, tunnel *Tunnel, localConn ne
 	// Local -> IAP
 	go func() {
 		defer wg.Done()
-		io.Copy(iapConn, localConn)
+		record.BytesSent, _ = relay(countingWriter{markingWriter{iapConn, watch.sending}, &tunnel.metrics.bytesOut}, localConn)
 	}()
 
 	// IAP -> Local
 	go func() {
 		defer wg.Done()
-		io.Copy(localConn, iapConn)
+		record.BytesRecv, _ = relay(countingWriter{markingWriter{localConn, watch.received}, &tunnel.metrics.bytesIn}, iapConn)
 	}()
 
 	wg.Wait()
 	tunnel.addLog("Connection closed")
 }
 
+// iapDialOptions returns the IAP dial options for a VM port
+func (a *App) iapDialOptions(projectID, vmName, zone string, remotePort int) []iap.DialOption {
+	return a.withCommonDialOptions(
+		iap.WithProject(projectID),
+		iap.WithInstance(vmName, zone, "nic0"),
+		iap.WithPort(fmt.Sprintf("%d", remotePort)),
+	)
+}
+
+// withCommonDialOptions adds credentials and the advanced settings to target options
+func (a *App) withCommonDialOptions(opts ...iap.DialOption) []iap.DialOption {
+	opts = append(opts, iap.WithTokenSource(&a.tokenSource))
+	if a.GetSettings().Advanced.Compression {
+		opts = append(opts, iap.WithCompression())
+	}
+	return opts
+}
+
+// dialIAP dials target through IAP, queued behind the concurrent dial limit and honouring the
+// configured connect timeout
+func (a *App) dialIAP(ctx context.Context, target string, opts []iap.DialOption) (*iap.Conn, error) {
+	// Only the handshake takes a slot, established connections don't count against the limit
+	limit := a.maxConcurrentDials()
+	if err := a.dialLimit.acquire(ctx, target, limit); err != nil {
+		return nil, err
+	}
+	defer a.dialLimit.release(limit)
+
+	a.recordNetActivity(hostIAP)
+	timeout := a.GetSettings().Advanced.ConnectTimeout
+	if timeout <= 0 {
+		return iap.Dial(ctx, opts...)
+	}
+
+	// The connection keeps using the dial context, so only cancel it if the dial doesn't finish in time
+	dialCtx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(time.Duration(timeout)*time.Second, cancel)
+	conn, err := iap.Dial(dialCtx, opts...)
+	if !timer.Stop() {
+		if err == nil {
+			conn.Close()
+		}
+		return nil, fmt.Errorf("failed to connect within %ds: %w", timeout, context.DeadlineExceeded)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return conn, nil
+}
+
 // StopTunnel stops an active tunnel
 func (a *App) StopTunnel(tunnelID string) error {
 	a.tunnelsMu.Lock()
-	defer a.tunnelsMu.Unlock()
-
 	tunnel, ok := a.tunnels[tunnelID]
 	if !ok {
+		a.tunnelsMu.Unlock()
+		if a.agentAttached() {
+			return a.agentCall("StopTunnel", nil, tunnelID)
+		}
 		return fmt.Errorf("tunnel not found")
 	}
 
+	tunnel.stopExpiryTimers()
+	tunnel.stopIdleWatcher()
+	tunnel.runStopHooks()
 	if tunnel.cancel != nil {
 		tunnel.cancel()
 	}
@@ -1157,45 +1996,81 @@ func (a *App) StopTunnel(tunnelID string) error {
 	}
 
 	tunnel.Status = "stopped"
+	a.tunnelsMu.Unlock()
+
+	a.saveOpenTunnels()
 	return nil
 }
 
 // GetTunnels returns all tunnels sorted by start time (newest first)
 func (a *App) GetTunnels() []TunnelInfo {
-	a.tunnelsMu.RLock()
-	defer a.tunnelsMu.RUnlock()
+	tunnels := a.agentTunnels()
 
-	var tunnels []TunnelInfo
+	a.tunnelsMu.RLock()
 	for _, t := range a.tunnels {
 		tunnels = append(tunnels, *t.toInfo())
 	}
+	a.tunnelsMu.RUnlock()
 
 	// Sort by start time (newest first)
 	sort.Slice(tunnels, func(i, j int) bool {
 		return tunnels[i].StartedAt > tunnels[j].StartedAt
 	})
 
-	return tunnels
+	return a.withDisplayTimes(tunnels)
+}
+
+// GetTunnelsByProject returns all tunnels grouped by project.
+// Groups are sorted by project ID, tunnels within a group by start time (newest first)
+func (a *App) GetTunnelsByProject() []TunnelGroup {
+	tunnels := a.GetTunnels()
+
+	groupIndex := make(map[string]int)
+	var groups []TunnelGroup
+	for _, t := range tunnels {
+		idx, ok := groupIndex[t.ProjectID]
+		if !ok {
+			idx = len(groups)
+			groupIndex[t.ProjectID] = idx
+			groups = append(groups, TunnelGroup{ProjectID: t.ProjectID})
+		}
+		groups[idx].Tunnels = append(groups[idx].Tunnels, t)
+		if t.Status == "running" || t.Status == "starting" {
+			groups[idx].ActiveCount++
+		}
+	}
+
+	// Sort groups by project ID
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ProjectID < groups[j].ProjectID
+	})
+
+	return groups
 }
 
 // GetActiveTunnels returns only running or starting tunnels
 func (a *App) GetActiveTunnels() []TunnelInfo {
-	a.tunnelsMu.RLock()
-	defer a.tunnelsMu.RUnlock()
-
 	var tunnels []TunnelInfo
+	for _, t := range a.agentTunnels() {
+		if t.Status == "running" || t.Status == "starting" {
+			tunnels = append(tunnels, t)
+		}
+	}
+
+	a.tunnelsMu.RLock()
 	for _, t := range a.tunnels {
 		if t.Status == "running" || t.Status == "starting" {
 			tunnels = append(tunnels, *t.toInfo())
 		}
 	}
+	a.tunnelsMu.RUnlock()
 
 	// Sort by start time (newest first)
 	sort.Slice(tunnels, func(i, j int) bool {
 		return tunnels[i].StartedAt > tunnels[j].StartedAt
 	})
 
-	return tunnels
+	return a.withDisplayTimes(tunnels)
 }
 
 // RemoveTunnel removes a stopped tunnel from the list
@@ -1205,6 +2080,9 @@ func (a *App) RemoveTunnel(tunnelID string) error {
 
 	tunnel, ok := a.tunnels[tunnelID]
 	if !ok {
+		if a.agentAttached() {
+			return a.agentCall("RemoveTunnel", nil, tunnelID)
+		}
 		return fmt.Errorf("tunnel not found")
 	}
 
@@ -1214,6 +2092,7 @@ func (a *App) RemoveTunnel(tunnelID string) error {
 	}
 
 	delete(a.tunnels, tunnelID)
+	a.closeTunnelViews(tunnelID)
 	return nil
 }
 
@@ -1226,6 +2105,7 @@ func (a *App) ClearStoppedTunnels() int {
 	for id, t := range a.tunnels {
 		if t.Status == "stopped" || t.Status == "error" {
 			delete(a.tunnels, id)
+			a.closeTunnelViews(id)
 			count++
 		}
 	}
@@ -1235,17 +2115,24 @@ func (a *App) ClearStoppedTunnels() int {
 // GetTunnel returns a specific tunnel
 func (a *App) GetTunnel(tunnelID string) (*TunnelInfo, error) {
 	a.tunnelsMu.RLock()
-	defer a.tunnelsMu.RUnlock()
-
 	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
 	if !ok {
+		if a.agentAttached() {
+			var info TunnelInfo
+			if err := a.agentCall("GetTunnel", &info, tunnelID); err != nil {
+				return nil, err
+			}
+			info.Agent = true
+			return &a.withDisplayTimes([]TunnelInfo{info})[0], nil
+		}
 		return nil, fmt.Errorf("tunnel not found")
 	}
-	return tunnel.toInfo(), nil
+	return &a.withDisplayTimes([]TunnelInfo{*tunnel.toInfo()})[0], nil
 }
 
-// CheckWindowsApp checks if Windows App is installed on macOS
-func (a *App) CheckWindowsApp() WindowsAppStatus {
+// probeWindowsApp checks if Windows App is installed on macOS
+func probeWindowsApp() WindowsAppStatus {
 	_, err := os.Stat(WindowsAppPath)
 	if os.IsNotExist(err) {
 		return WindowsAppStatus{
@@ -1279,7 +2166,11 @@ func (a *App) CheckWindowsApp() WindowsAppStatus {
 // Uses a hash of project+vm+zone to ensure the same VM always gets the same bookmark ID
 func (a *App) GenerateBookmarkID(projectID, vmName, zone string) string {
 	// Create a deterministic ID based on the connection parameters
-	data := fmt.Sprintf("%s:%s:%s", projectID, vmName, zone)
+	return numericBookmarkID(fmt.Sprintf("%s:%s:%s", projectID, vmName, zone))
+}
+
+// numericBookmarkID hashes data to the 7-digit IDs Windows App bookmarks use
+func numericBookmarkID(data string) string {
 	hash := sha256.Sum256([]byte(data))
 	// Use first 8 bytes (16 hex chars) for a shorter but still unique ID
 	// Convert to numeric string for Windows App compatibility
@@ -1314,8 +2205,12 @@ func (a *App) CreateWindowsAppBookmark(projectID, vmName, zone string, localPort
 		}
 	}
 
-	// Generate stable bookmark ID
+	// A saved VM keeps the bookmark of its favorite, others get one derived from the VM
+	fav := a.favoriteFor(projectID, vmName, zone, 0, localPort)
 	bookmarkID := a.GenerateBookmarkID(projectID, vmName, zone)
+	if fav != nil {
+		bookmarkID = fav.BookmarkID
+	}
 
 	// Build the friendly name with IAP prefix for identification
 	friendlyName := fmt.Sprintf("IAP: %s (%s)", vmName, zone)
@@ -1323,22 +2218,23 @@ func (a *App) CreateWindowsAppBookmark(projectID, vmName, zone string, localPort
 	// Build the hostname (localhost with port)
 	hostname := fmt.Sprintf("localhost:%d", localPort)
 
+	// Use the favorite's group override if this VM is saved
+	group := a.bookmarkGroupFor(fav)
+
 	// Execute Windows App CLI to create/update bookmark
-	cmd := exec.Command(WindowsAppCLI,
+	output, err := a.runBookmarkCLI(bookmarkCall{args: []string{
 		"--script", "bookmark", "write", bookmarkID,
 		"--hostname", hostname,
 		"--friendlyname", friendlyName,
-		"--group", BookmarkGroup,
+		"--group", group,
 		"--fullscreen", "false",
 		"--autoreconnect", "true",
-	)
-
-	output, err := cmd.CombinedOutput()
+	}})
 	if err != nil {
 		return BookmarkResult{
 			Success:    false,
 			BookmarkID: bookmarkID,
-			Error:      fmt.Sprintf("Failed to create bookmark: %v - %s", err, string(output)),
+			Error:      fmt.Sprintf("Failed to create bookmark: %v - %s", err, redact(string(output))),
 		}
 	}
 
@@ -1360,16 +2256,14 @@ func (a *App) DeleteWindowsAppBookmark(bookmarkID string) BookmarkResult {
 	}
 
 	// Execute Windows App CLI to delete bookmark
-	cmd := exec.Command(WindowsAppCLI,
+	output, err := a.runBookmarkCLI(bookmarkCall{args: []string{
 		"--script", "bookmark", "delete", bookmarkID,
-	)
-
-	output, err := cmd.CombinedOutput()
+	}})
 	if err != nil {
 		return BookmarkResult{
 			Success:    false,
 			BookmarkID: bookmarkID,
-			Error:      fmt.Sprintf("Failed to delete bookmark: %v - %s", err, string(output)),
+			Error:      fmt.Sprintf("Failed to delete bookmark: %v - %s", err, redact(string(output))),
 		}
 	}
 
@@ -1416,8 +2310,8 @@ func (a *App) OpenWindowsApp() error {
 		return fmt.Errorf(status.Error)
 	}
 
-	cmd := exec.Command("open", "-a", "Windows App")
-	return cmd.Run()
+	_, err := runCommand("open", "-a", "Windows App")
+	return err
 }
 
 // UpdateBookmarkPort updates an existing bookmark with a new port
@@ -1429,15 +2323,35 @@ func (a *App) UpdateBookmarkPort(bookmarkID string, projectID, vmName, zone stri
 // StopAllTunnels stops all running tunnels
 func (a *App) StopAllTunnels() int {
 	a.tunnelsMu.Lock()
-	defer a.tunnelsMu.Unlock()
+	count := 0
+	for _, t := range a.tunnels {
+		if t.Status == "running" || t.Status == "starting" {
+			a.stopTunnelInternal(t)
+			count++
+		}
+	}
+	a.tunnelsMu.Unlock()
+
+	a.saveOpenTunnels()
+	return count
+}
 
+// StopTunnelsForProject stops all running tunnels for the given project
+func (a *App) StopTunnelsForProject(projectID string) int {
+	a.tunnelsMu.Lock()
 	count := 0
 	for _, t := range a.tunnels {
+		if t.ProjectID != projectID {
+			continue
+		}
 		if t.Status == "running" || t.Status == "starting" {
 			a.stopTunnelInternal(t)
 			count++
 		}
 	}
+	a.tunnelsMu.Unlock()
+
+	a.saveOpenTunnels()
 	return count
 }
 
@@ -1457,6 +2371,8 @@ func (a *App) StopTunnelAndDeleteBookmark(tunnelID string) error {
 	a.stopTunnelInternal(tunnel)
 	a.tunnelsMu.Unlock()
 
+	a.saveOpenTunnels()
+
 	// Delete the bookmark if it exists
 	if bookmarkID != "" {
 		a.DeleteWindowsAppBookmark(bookmarkID)
@@ -1470,19 +2386,96 @@ func (a *App) StopTunnelAndDeleteBookmark(tunnelID string) error {
 func (t *Tunnel) addLog(msg string) {
 	t.logsMu.Lock()
 	defer t.logsMu.Unlock()
-	timestamp := time.Now().Format("15:04:05")
-	t.Logs = append(t.Logs, fmt.Sprintf("[%s] %s", timestamp, msg))
-	// Keep only last 100 logs
-	if len(t.Logs) > 100 {
-		t.Logs = t.Logs[len(t.Logs)-100:]
+	t.logSeq++
+	entry := TunnelLogEntry{
+		Seq:     t.logSeq,
+		Time:    time.Now().Format("15:04:05"),
+		Message: redact(msg),
+	}
+	t.logs = append(t.logs, entry)
+	if len(t.logs) > maxTunnelLogEntries {
+		t.logs = t.logs[len(t.logs)-maxTunnelLogEntries:]
+	}
+	t.queueLogEvent(entry)
+}
+
+// addStopHook registers a function run when the tunnel stops
+func (t *Tunnel) addStopHook(fn func()) {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	t.stopHooks = append(t.stopHooks, fn)
+}
+
+// runStopHooks runs and clears the stop hooks. They run in the background since callers hold tunnelsMu.
+func (t *Tunnel) runStopHooks() {
+	t.logsMu.Lock()
+	hooks := t.stopHooks
+	t.stopHooks = nil
+	t.logsMu.Unlock()
+
+	for _, fn := range hooks {
+		go fn()
+	}
+}
+
+// recordDialAttempt counts dials made while the tunnel is recovering from failures
+func (t *Tunnel) recordDialAttempt() {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	if t.dialFailures > 0 {
+		t.reconnectAttempts++
+	}
+}
+
+// recordDialSuccess resets the consecutive failure count after a successful dial
+func (t *Tunnel) recordDialSuccess() {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	t.lastDialOK = time.Now()
+	t.dialFailures = 0
+}
+
+// recordDialFailure counts a failed IAP dial
+func (t *Tunnel) recordDialFailure(err error) {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	t.dialFailures++
+	t.lastError = err.Error()
+	t.metrics.dialErrors.Add(1)
+}
+
+// recordError remembers a tunnel error that is not a dial failure
+func (t *Tunnel) recordError(err error) {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	t.lastError = err.Error()
+}
+
+// health classifies the tunnel from its dial metrics; callers must hold logsMu
+func (t *Tunnel) health() string {
+	switch {
+	case t.dialFailures >= failingDialThreshold:
+		return TunnelFailing
+	case t.stalledConns > 0:
+		return TunnelStalled
+	case t.dialFailures > 0:
+		return TunnelDegraded
+	default:
+		return TunnelHealthy
 	}
 }
 
 func (t *Tunnel) toInfo() *TunnelInfo {
 	t.logsMu.Lock()
 	defer t.logsMu.Unlock()
-	logs := make([]string, len(t.Logs))
-	copy(logs, t.Logs)
+	expiresAt := ""
+	if !t.ExpiresAt.IsZero() {
+		expiresAt = t.ExpiresAt.Format(time.RFC3339)
+	}
+	lastDialOK := ""
+	if !t.lastDialOK.IsZero() {
+		lastDialOK = t.lastDialOK.Format(time.RFC3339)
+	}
 	return &TunnelInfo{
 		ID:         t.ID,
 		ProjectID:  t.ProjectID,
@@ -1492,8 +2485,23 @@ func (t *Tunnel) toInfo() *TunnelInfo {
 		RemotePort: t.RemotePort,
 		Status:     t.Status,
 		StartedAt:  t.StartedAt.Format(time.RFC3339),
-		Logs:       logs,
 		BookmarkID: t.BookmarkID,
+		ExpiresAt:  expiresAt,
+
+		InstanceGroup: t.InstanceGroup,
+		Host:          t.Host,
+
+		FavoriteID:       t.FavoriteID,
+		Environment:      t.Environment,
+		EnvironmentColor: environmentColor(t.Environment),
+
+		Health:            t.health(),
+		LastDialOK:        lastDialOK,
+		DialFailures:      t.dialFailures,
+		LastError:         t.lastError,
+		ReconnectAttempts: t.reconnectAttempts,
+
+		Simulated: t.target != nil && t.target.provider.Kind() == TargetKindSimulated,
 	}
 }
 
@@ -1501,15 +2509,22 @@ func (t *Tunnel) toInfo() *TunnelInfo {
 
 // GenerateWindowsPassword generates or rotates the Windows password for a VM
 func (a *App) GenerateWindowsPassword(req WindowsPasswordRequest) WindowsPasswordResult {
-	// Find the connection
-	a.configMu.RLock()
-	var conn *Favorite
-	for i := range a.config.Favorites {
-		if a.config.Favorites[i].ID == req.ConnectionID {
-			conn = &a.config.Favorites[i]
-			break
+	if !req.DryRun {
+		if err := a.requireOperatorUnlock(OperatorActionPasswordReset); err != nil {
+			return WindowsPasswordResult{Success: false, Error: err.Error()}
 		}
 	}
+	return a.generateWindowsPassword(req)
+}
+
+// generateWindowsPassword resets the password without the operator mode check. Just-in-time
+// sessions, which operators use to connect, and batch rotations, which check their own action,
+// call it directly.
+func (a *App) generateWindowsPassword(req WindowsPasswordRequest) WindowsPasswordResult {
+	a.countFeature("windows_password")
+	// Find the connection
+	a.configMu.RLock()
+	conn := favoriteIn(a.config, req.ConnectionID)
 	a.configMu.RUnlock()
 
 	if conn == nil {
@@ -1525,22 +2540,47 @@ func (a *App) GenerateWindowsPassword(req WindowsPasswordRequest) WindowsPasswor
 		username = "Administrator"
 	}
 
-	// Generate RSA keypair
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
+	if req.DryRun {
+		return WindowsPasswordResult{Success: true, Username: username, Plan: passwordResetPlan(conn, req, username)}
+	}
+	if err := a.requireMutationConfirmation(req.Confirmed); err != nil {
+		return WindowsPasswordResult{Success: false, Error: err.Error()}
+	}
+
+	// Register the reset so it can be cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if !a.beginPasswordReset(req.ConnectionID, cancel) {
 		return WindowsPasswordResult{
 			Success: false,
-			Error:   fmt.Sprintf("Failed to generate RSA key: %v", err),
+			Error:   "A password reset is already in progress for this connection",
+		}
+	}
+	defer a.endPasswordReset(req.ConnectionID)
+
+	progress := func(stage, message string, attempt int) {
+		a.emitPasswordProgress(PasswordProgress{ConnectionID: req.ConnectionID, Stage: stage, Message: message, Attempt: attempt})
+	}
+	fail := func(message string) WindowsPasswordResult {
+		if ctx.Err() != nil {
+			progress("cancelled", "Password reset cancelled", 0)
+			return WindowsPasswordResult{Success: false, Error: errPasswordResetCancelled.Error()}
 		}
+		progress("error", message, 0)
+		return WindowsPasswordResult{Success: false, Error: message}
+	}
+
+	// Generate RSA keypair
+	progress("key", "Generating key pair", 0)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fail(fmt.Sprintf("Failed to generate RSA key: %v", err))
 	}
 
 	// Create compute service
 	computeService, err := compute.NewService(a.ctx, option.WithTokenSource(a.tokenSource))
 	if err != nil {
-		return WindowsPasswordResult{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to create compute service: %v", err),
-		}
+		return fail(fmt.Sprintf("Failed to create compute service: %v", err))
 	}
 
 	// Extract zone name from full zone path if needed
@@ -1550,24 +2590,17 @@ func (a *App) GenerateWindowsPassword(req WindowsPasswordRequest) WindowsPasswor
 		zoneName = parts[len(parts)-1]
 	}
 
-	// Get current instance metadata
-	instance, err := computeService.Instances.Get(conn.ProjectID, zoneName, conn.InstanceName).Do()
-	if err != nil {
+	// Make sure the instance exists and is accessible
+	if _, err := computeService.Instances.Get(conn.ProjectID, zoneName, conn.InstanceName).Context(ctx).Do(); err != nil {
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "403") || strings.Contains(errMsg, "forbidden") {
-			return WindowsPasswordResult{
-				Success: false,
-				Error:   "Permission denied. Ensure you have compute.instances.setMetadata permission.",
-			}
-		}
-		return WindowsPasswordResult{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to get instance: %v", err),
+			return fail("Permission denied. Ensure you have compute.instances.setMetadata permission.")
 		}
+		return fail(fmt.Sprintf("Failed to get instance: %v", err))
 	}
 
 	// Prepare the windows-keys metadata
-	expireTime := time.Now().Add(5 * time.Minute).UTC().Format(time.RFC3339)
+	expireTime := time.Now().Add(windowsKeyExpiry).UTC().Format(time.RFC3339)
 	modulus := base64.StdEncoding.EncodeToString(privateKey.PublicKey.N.Bytes())
 	exponent := base64.StdEncoding.EncodeToString(big.NewInt(int64(privateKey.PublicKey.E)).Bytes())
 
@@ -1580,57 +2613,39 @@ func (a *App) GenerateWindowsPassword(req WindowsPasswordRequest) WindowsPasswor
 
 	keyMetaJSON, err := json.Marshal(keyMeta)
 	if err != nil {
-		return WindowsPasswordResult{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to marshal key metadata: %v", err),
-		}
-	}
-
-	// Update instance metadata
-	metadata := instance.Metadata
-	if metadata == nil {
-		metadata = &compute.Metadata{}
+		return fail(fmt.Sprintf("Failed to marshal key metadata: %v", err))
 	}
 
-	// Find or create windows-keys item
-	found := false
-	for i, item := range metadata.Items {
-		if item.Key == "windows-keys" {
-			metadata.Items[i].Value = stringPtr(string(keyMetaJSON))
-			found = true
-			break
+	// Add our key to windows-keys, retrying if metadata changes underneath us
+	progress("metadata", "Setting windows-keys metadata", 0)
+	err = updateInstanceMetadata(ctx, computeService, conn.ProjectID, zoneName, conn.InstanceName, "", func(metadata *compute.Metadata) error {
+		current := ""
+		for _, item := range metadata.Items {
+			if item.Key == "windows-keys" && item.Value != nil {
+				current = *item.Value
+				break
+			}
 		}
-	}
-	if !found {
-		metadata.Items = append(metadata.Items, &compute.MetadataItems{
-			Key:   "windows-keys",
-			Value: stringPtr(string(keyMetaJSON)),
-		})
-	}
-
-	// Set metadata
-	_, err = computeService.Instances.SetMetadata(conn.ProjectID, zoneName, conn.InstanceName, metadata).Do()
+		setMetadataItem(metadata, "windows-keys", appendWindowsKey(current, string(keyMetaJSON), time.Now()))
+		return nil
+	})
 	if err != nil {
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "403") || strings.Contains(errMsg, "forbidden") {
-			return WindowsPasswordResult{
-				Success: false,
-				Error:   "Permission denied. Ensure you have compute.instances.setMetadata permission.",
-			}
-		}
-		return WindowsPasswordResult{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to set metadata: %v", err),
+			return fail("Permission denied. Ensure you have compute.instances.setMetadata permission.")
 		}
+		return fail(fmt.Sprintf("Failed to set metadata: %v", err))
 	}
 
 	// Poll serial port output for the encrypted password
-	password, err := a.pollForWindowsPassword(computeService, conn.ProjectID, zoneName, conn.InstanceName, privateKey, modulus)
+	progress("waiting", "Waiting for the Windows guest agent", 0)
+	password, err := a.pollForWindowsPassword(ctx, computeService, conn.ProjectID, zoneName, conn.InstanceName, privateKey, modulus, func(attempt int) {
+		progress("scanning", fmt.Sprintf("Scanned serial output %d time(s)", attempt), attempt)
+	}, func() {
+		progress("decrypting", "Decrypting password", 0)
+	})
 	if err != nil {
-		return WindowsPasswordResult{
-			Success: false,
-			Error:   err.Error(),
-		}
+		return fail(err.Error())
 	}
 
 	result := WindowsPasswordResult{
@@ -1639,23 +2654,24 @@ func (a *App) GenerateWindowsPassword(req WindowsPasswordRequest) WindowsPasswor
 		Password: password,
 	}
 
+	progress("saving", "Saving credentials", 0)
+
 	// Save username to connection config
-	a.configMu.Lock()
-	for i := range a.config.Favorites {
-		if a.config.Favorites[i].ID == req.ConnectionID {
-			a.config.Favorites[i].Username = username
-			break
-		}
-	}
-	a.configMu.Unlock()
-	a.saveConfig()
+	a.updateFavorite(req.ConnectionID, func(f *Favorite) error {
+		f.Username = username
+		return nil
+	})
+
+	// The cached password, if any, is the old one
+	a.forgetCachedPassword(conn.ProjectID, zoneName, conn.InstanceName, username)
 
 	// Save to Keychain if requested
 	if req.SaveToKeychain {
-		keychainAccount := fmt.Sprintf("%s/%s/%s/%s", conn.ProjectID, zoneName, conn.InstanceName, username)
-		err := a.saveToKeychain(KeychainService, keychainAccount, password)
+		err := saveToKeychain(KeychainService, keychainAccountFor(conn.ProjectID, zoneName, conn.InstanceName, username), password)
 		if err == nil {
 			result.KeychainSaved = true
+		} else {
+			a.reportError(ErrorSourceKeychain, conn.InstanceName, err)
 		}
 	}
 
@@ -1664,29 +2680,88 @@ func (a *App) GenerateWindowsPassword(req WindowsPasswordRequest) WindowsPasswor
 		status := a.CheckWindowsApp()
 		if status.Installed && conn.LocalPort > 0 {
 			// Use the connection's fixed port
-			bookmarkResult := a.createOrUpdateBookmarkWithCreds(conn, conn.LocalPort, username, password)
+			bookmarkResult := a.createOrUpdateBookmarkWithCreds(conn, conn.LocalPort, "", username, password)
 			if bookmarkResult.Success {
 				result.BookmarkUpdated = true
 				// Update connection to reflect bookmark has credentials
-				a.configMu.Lock()
-				for i := range a.config.Favorites {
-					if a.config.Favorites[i].ID == req.ConnectionID {
-						a.config.Favorites[i].HasBookmark = true
-						a.config.Favorites[i].BookmarkHasCreds = true
-						break
-					}
-				}
-				a.configMu.Unlock()
-				a.saveConfig()
+				a.UpdateConnectionBookmarkStatus(req.ConnectionID, true, true)
 			}
 		}
 	}
 
+	progress("done", "Password reset complete", 0)
 	return result
 }
 
+// CancelWindowsPasswordReset aborts an in-flight password reset for a connection
+func (a *App) CancelWindowsPasswordReset(connectionID string) error {
+	a.passwordResetsMu.Lock()
+	defer a.passwordResetsMu.Unlock()
+
+	cancel, ok := a.passwordResets[connectionID]
+	if !ok {
+		return fmt.Errorf("no password reset in progress")
+	}
+	cancel()
+	return nil
+}
+
+// beginPasswordReset registers a reset, returning false if one is already running for the connection
+func (a *App) beginPasswordReset(connectionID string, cancel context.CancelFunc) bool {
+	a.passwordResetsMu.Lock()
+	defer a.passwordResetsMu.Unlock()
+
+	if a.passwordResets == nil {
+		a.passwordResets = make(map[string]context.CancelFunc)
+	}
+	if _, busy := a.passwordResets[connectionID]; busy {
+		return false
+	}
+	a.passwordResets[connectionID] = cancel
+	return true
+}
+
+// endPasswordReset unregisters a finished reset
+func (a *App) endPasswordReset(connectionID string) {
+	a.passwordResetsMu.Lock()
+	defer a.passwordResetsMu.Unlock()
+	delete(a.passwordResets, connectionID)
+}
+
+// emitPasswordProgress sends a password reset progress event to the frontend
+func (a *App) emitPasswordProgress(p PasswordProgress) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, PasswordProgressEvent, p)
+	}
+}
+
+// appendWindowsKey appends a key entry to the newline-separated windows-keys value,
+// dropping entries that have expired. Lines that can't be parsed are kept as-is.
+func appendWindowsKey(current, entry string, now time.Time) string {
+	var lines []string
+	for _, line := range strings.Split(current, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var key windowsKeyMetadata
+		if err := json.Unmarshal([]byte(line), &key); err == nil {
+			if expireOn, err := time.Parse(time.RFC3339, key.ExpireOn); err == nil && expireOn.Before(now) {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, entry)
+	return strings.Join(lines, "\n")
+}
+
 // pollForWindowsPassword polls the serial port for the encrypted password response
-func (a *App) pollForWindowsPassword(svc *compute.Service, projectID, zone, instance string, privateKey *rsa.PrivateKey, expectedModulus string) (string, error) {
+// onScan is called after every serial output read, onDecrypt when our response was found.
+func (a *App) pollForWindowsPassword(ctx context.Context, svc *compute.Service, projectID, zone, instance string, privateKey *rsa.PrivateKey, expectedModulus string, onScan func(attempt int), onDecrypt func()) (string, error) {
+	// App Nap would stretch the poll interval past the time the agent keeps the response
+	defer beginActivity("Waiting for the Windows password")()
+
 	timeout := 90 * time.Second
 	interval := 2 * time.Second
 	maxInterval := 5 * time.Second
@@ -1695,13 +2770,28 @@ func (a *App) pollForWindowsPassword(svc *compute.Service, projectID, zone, inst
 	// Pattern to find JSON responses in serial output
 	jsonPattern := regexp.MustCompile(`\{[^{}]*"encryptedPassword"[^{}]*\}`)
 
+	// sleep waits for the interval, returning false if the reset was cancelled
+	sleep := func() bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+			return true
+		}
+	}
+
+	attempt := 0
 	for time.Since(startTime) < timeout {
 		// Get serial port output (port 4 is for Windows agent)
-		output, err := svc.Instances.GetSerialPortOutput(projectID, zone, instance).Port(4).Do()
+		output, err := svc.Instances.GetSerialPortOutput(projectID, zone, instance).Port(4).Context(ctx).Do()
 		if err != nil {
-			time.Sleep(interval)
+			if !sleep() {
+				return "", errPasswordResetCancelled
+			}
 			continue
 		}
+		attempt++
+		onScan(attempt)
 
 		// Look for password response in serial output
 		matches := jsonPattern.FindAllString(output.Contents, -1)
@@ -1714,6 +2804,7 @@ func (a *App) pollForWindowsPassword(svc *compute.Service, projectID, zone, inst
 			// Check if this response matches our request (same modulus)
 			if resp.Modulus == expectedModulus && resp.EncryptedPassword != "" {
 				// Decrypt the password
+				onDecrypt()
 				password, err := decryptWindowsPassword(resp.EncryptedPassword, privateKey)
 				if err != nil {
 					return "", fmt.Errorf("failed to decrypt password: %v", err)
@@ -1727,7 +2818,9 @@ func (a *App) pollForWindowsPassword(svc *compute.Service, projectID, zone, inst
 			}
 		}
 
-		time.Sleep(interval)
+		if !sleep() {
+			return "", errPasswordResetCancelled
+		}
 		// Backoff
 		if interval < maxInterval {
 			interval += time.Second
@@ -1766,33 +2859,36 @@ func (a *App) getRunningTunnelPort(projectID, vmName, zone string) int {
 	return 0
 }
 
-// createOrUpdateBookmarkWithCreds creates or updates a Windows App bookmark with credentials
-func (a *App) createOrUpdateBookmarkWithCreds(conn *Favorite, localPort int, username, password string) BookmarkResult {
-	bookmarkID := conn.ID
-	friendlyName := fmt.Sprintf("IAP:%s/%s", conn.ProjectID, conn.InstanceName)
+// createOrUpdateBookmarkWithCreds creates or updates a Windows App bookmark with credentials. An
+// empty friendlyName uses the generated one.
+func (a *App) createOrUpdateBookmarkWithCreds(conn *Favorite, localPort int, friendlyName, username, password string) BookmarkResult {
+	bookmarkID := conn.BookmarkID
+	if friendlyName == "" {
+		friendlyName = favoriteBookmarkName(conn)
+	}
 	hostname := fmt.Sprintf("localhost:%d", localPort)
 
-	cmd := exec.Command(WindowsAppCLI,
+	call, warning := a.bookmarkCommand([]string{
 		"--script", "bookmark", "write", bookmarkID,
 		"--hostname", hostname,
-		"--username", username,
-		"--password", password,
 		"--friendlyname", friendlyName,
-		"--group", BookmarkGroup,
-	)
+		"--group", a.bookmarkGroupFor(conn),
+	}, username, password)
 
-	output, err := cmd.CombinedOutput()
+	output, err := a.runBookmarkCLI(call)
 	if err != nil {
+		a.reportError(ErrorSourceBookmark, friendlyName, commandError("failed to create bookmark", err, output, password))
 		return BookmarkResult{
 			Success:    false,
 			BookmarkID: bookmarkID,
-			Error:      fmt.Sprintf("Failed to create bookmark: %v - %s", err, string(output)),
+			Error:      fmt.Sprintf("Failed to create bookmark: %v - %s", err, redact(string(output), password)),
 		}
 	}
 
 	return BookmarkResult{
 		Success:    true,
 		BookmarkID: bookmarkID,
+		Warning:    warning,
 	}
 }
 
@@ -1800,14 +2896,22 @@ func (a *App) createOrUpdateBookmarkWithCreds(conn *Favorite, localPort int, use
 
 // LaunchFreeRDP launches FreeRDP with the connection details
 func (a *App) LaunchFreeRDP(connectionID string) error {
-	a.configMu.RLock()
-	var conn *Favorite
-	for i := range a.config.Favorites {
-		if a.config.Favorites[i].ID == connectionID {
-			conn = &a.config.Favorites[i]
-			break
+	conn := a.GetConnectionInfo(connectionID)
+	if conn == nil {
+		return fmt.Errorf("connection not found")
+	}
+	if tunnel := a.findActiveTunnel(conn); tunnel != nil {
+		if probe := a.ProbeTunnel(tunnel.ID); !probe.Reachable {
+			return errors.New(probe.Error)
 		}
 	}
+	return a.launchFreeRDP(connectionID)
+}
+
+// launchFreeRDP launches FreeRDP without checking that the VM accepts connections
+func (a *App) launchFreeRDP(connectionID string) error {
+	a.configMu.RLock()
+	conn := favoriteIn(a.config, connectionID)
 	a.configMu.RUnlock()
 	if conn == nil {
 		return fmt.Errorf("connection not found")
@@ -1818,7 +2922,7 @@ func (a *App) LaunchFreeRDP(connectionID string) error {
 		return fmt.Errorf("tunnel is not running for this connection")
 	}
 
-	password, _ := a.GetPasswordFromKeychain(conn.ProjectID, conn.Zone, conn.InstanceName, conn.Username)
+	password, _ := a.cachedPassword(conn.ProjectID, conn.Zone, conn.InstanceName, conn.Username)
 	password = strings.TrimRight(password, "\r\n")
 
 	userSpec := conn.Username
@@ -1863,7 +2967,6 @@ func (a *App) LaunchFreeRDP(connectionID string) error {
 	args := []string{
 		fmt.Sprintf("/v:127.0.0.1:%d", localPort),
 		fmt.Sprintf("/u:%s", userSpec),
-		fmt.Sprintf("/p:%s", password),
 		fmt.Sprintf("/title:IAP: %s ->%s:%s", conn.ProjectID, conn.InstanceName, userSpec),
 		"/dynamic-resolution",
 		"/clipboard",
@@ -1875,8 +2978,24 @@ func (a *App) LaunchFreeRDP(connectionID string) error {
 		"/cert:ignore",
 	}
 
+	// Hand the password over stdin so it isn't visible in the process list
+	var stdin string
+	if password != "" {
+		args = append(args, "/from-stdin:force")
+		if !strings.Contains(userSpec, "\\") {
+			// FreeRDP asks for the domain first when the username has none (UPN style)
+			stdin = "\n"
+		}
+		stdin += password + "\n"
+	} else {
+		args = append(args, "/p:")
+	}
+
 	cmd := exec.Command(freerdpPath, args...)
 	cmd.Env = os.Environ()
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
 
 	var stdout io.ReadCloser
 	var stderr io.ReadCloser
@@ -1954,40 +3073,40 @@ func (a *App) CheckFreeRDP() FreeRDPStatus {
 }
 
 // saveToKeychain saves a password to the macOS Keychain
-func (a *App) saveToKeychain(service, account, password string) error {
-	// First try to delete any existing entry
-	deleteCmd := exec.Command("security", "delete-generic-password",
+func saveToKeychain(service, account, password string) error {
+	// First try to delete any existing entry, it's fine if there is none
+	runCommand("security", "delete-generic-password",
 		"-s", service,
 		"-a", account,
 	)
-	_ = deleteCmd.Run() // Ignore error if not found
 
-	// Add new entry
-	cmd := exec.Command("security", "add-generic-password",
+	// Add new entry, through stdin so the password isn't visible in the process list
+	output, err := runSecurity("add-generic-password",
 		"-s", service,
 		"-a", account,
 		"-w", password,
 		"-U", // Update if exists
 	)
-
-	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("failed to save to Keychain: %v - %s", err, string(output))
+		return commandError("failed to save to Keychain", err, output, password)
+	}
+	// Interactive mode doesn't report command failures in its exit status, read the entry back
+	stored, err := runCommand("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	if err != nil || strings.TrimRight(string(stored), "\n") != password {
+		return fmt.Errorf("failed to save to Keychain: %s", redact(strings.TrimSpace(string(output)), password))
 	}
 	return nil
 }
 
 // GetPasswordFromKeychain retrieves a password from the macOS Keychain
 func (a *App) GetPasswordFromKeychain(projectID, zone, instance, username string) (string, error) {
-	account := fmt.Sprintf("%s/%s/%s/%s", projectID, zone, instance, username)
+	account := keychainAccountFor(projectID, zone, instance, username)
 
-	cmd := exec.Command("security", "find-generic-password",
+	output, err := runCommand("security", "find-generic-password",
 		"-s", KeychainService,
 		"-a", account,
 		"-w", // Output password only
 	)
-
-	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("password not found in Keychain")
 	}
@@ -1996,14 +3115,13 @@ func (a *App) GetPasswordFromKeychain(projectID, zone, instance, username string
 
 // DeletePasswordFromKeychain removes a password from the macOS Keychain
 func (a *App) DeletePasswordFromKeychain(projectID, zone, instance, username string) error {
-	account := fmt.Sprintf("%s/%s/%s/%s", projectID, zone, instance, username)
+	a.forgetCachedPassword(projectID, zone, instance, username)
+	account := keychainAccountFor(projectID, zone, instance, username)
 
-	cmd := exec.Command("security", "delete-generic-password",
+	_, err := runCommand("security", "delete-generic-password",
 		"-s", KeychainService,
 		"-a", account,
 	)
-
-	_, err := cmd.CombinedOutput()
 	return err
 }
 
@@ -2017,45 +3135,29 @@ func (a *App) GetConnectionInfo(connectionID string) *Favorite {
 	a.configMu.RLock()
 	defer a.configMu.RUnlock()
 
-	for _, f := range a.config.Favorites {
-		if f.ID == connectionID {
-			// Return a copy
-			copy := f
-			return &copy
-		}
+	if f := favoriteIn(a.config, connectionID); f != nil {
+		// Return a copy
+		copy := *f
+		return &copy
 	}
 	return nil
 }
 
 // UpdateConnectionBookmarkStatus updates the bookmark status for a connection
 func (a *App) UpdateConnectionBookmarkStatus(connectionID string, hasBookmark, hasCreds bool) error {
-	a.configMu.Lock()
-	defer a.configMu.Unlock()
-
-	for i := range a.config.Favorites {
-		if a.config.Favorites[i].ID == connectionID {
-			a.config.Favorites[i].HasBookmark = hasBookmark
-			a.config.Favorites[i].BookmarkHasCreds = hasCreds
-			return a.saveConfigLocked()
+	// Bookmark state is local and doesn't travel with sync, so UpdatedAt is left alone
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		fav := favoriteIn(cfg, connectionID)
+		if fav == nil {
+			return fmt.Errorf("connection not found")
 		}
-	}
-	return fmt.Errorf("connection not found")
-}
-
-// saveConfigLocked saves config without acquiring lock (caller must hold lock)
-func (a *App) saveConfigLocked() error {
-	data, err := json.MarshalIndent(a.config, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	// Ensure directory exists
-	dir := filepath.Dir(a.configPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	return os.WriteFile(a.configPath, data, 0644)
+		fav.HasBookmark = hasBookmark
+		fav.BookmarkHasCreds = hasCreds
+		if hasBookmark {
+			fav.BookmarkUnavailable = false
+		}
+		return nil
+	})
 }
 
 func ScaleWH(screenW, screenH int, scale float64) (Size, error) {
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxIconLength bounds an icon name, SF Symbol names are well below it
+const maxIconLength = 64
+
+// colorPattern matches the colors favorites and groups accept, "#RRGGBB"
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Appearance is the icon and color a favorite or group is shown with. Icon is an SF Symbol name
+// (e.g. "server.rack") or an emoji, Color is "#RRGGBB"; empty values use the default look.
+type Appearance struct {
+	Icon  string `json:"icon,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+// normalize trims the appearance and lower-cases its color
+func (ap Appearance) normalize() Appearance {
+	return Appearance{Icon: strings.TrimSpace(ap.Icon), Color: strings.ToLower(strings.TrimSpace(ap.Color))}
+}
+
+// validate checks the icon and color
+func (ap Appearance) validate() error {
+	if len(ap.Icon) > maxIconLength {
+		return fmt.Errorf("icon is too long (max %d bytes)", maxIconLength)
+	}
+	for _, r := range ap.Icon {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("invalid icon: %q", ap.Icon)
+		}
+	}
+	if ap.Color != "" && !colorPattern.MatchString(ap.Color) {
+		return fmt.Errorf("invalid color: %s, expected #RRGGBB", ap.Color)
+	}
+	return nil
+}
+
+// SetFavoriteAppearance sets the icon and color of a favorite, empty values reset them
+func (a *App) SetFavoriteAppearance(favoriteID, icon, color string) error {
+	ap := Appearance{Icon: icon, Color: color}.normalize()
+	return a.UpdateFavoriteFields(favoriteID, FavoritePatch{Icon: &ap.Icon, Color: &ap.Color})
+}
+
+// GetGroupAppearances returns the icon and color of each favorite group that has one, by group
+// name. Groups are the Windows App bookmark groups favorites are filed under.
+func (a *App) GetGroupAppearances() map[string]Appearance {
+	a.configMu.RLock()
+	defer a.configMu.RUnlock()
+	appearances := make(map[string]Appearance)
+	if a.config != nil {
+		for group, ap := range a.config.GroupAppearances {
+			appearances[group] = ap
+		}
+	}
+	return appearances
+}
+
+// SetGroupAppearance sets the icon and color of a favorite group, empty values reset them
+func (a *App) SetGroupAppearance(group, icon, color string) error {
+	group = strings.TrimSpace(group)
+	if group == "" {
+		return fmt.Errorf("group name is required")
+	}
+	ap := Appearance{Icon: icon, Color: color}.normalize()
+	if err := ap.validate(); err != nil {
+		return err
+	}
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		if cfg.GroupAppearances[group] == ap {
+			return errConfigUnchanged
+		}
+		if ap == (Appearance{}) {
+			delete(cfg.GroupAppearances, group)
+			return nil
+		}
+		if cfg.GroupAppearances == nil {
+			cfg.GroupAppearances = make(map[string]Appearance)
+		}
+		cfg.GroupAppearances[group] = ap
+		return nil
+	})
+}
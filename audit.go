@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// AuditLogFileName is the name of the audit log in the config directory
+	AuditLogFileName = "audit.log"
+	// maxAuditEntries bounds the number of entries returned by GetAuditLog
+	maxAuditEntries = 1000
+)
+
+// auditMu serializes writes to the audit log
+var auditMu sync.Mutex
+
+// AuditEntry is a single line of the audit log
+type AuditEntry struct {
+	Time         string `json:"time"`
+	Action       string `json:"action"`
+	ConnectionID string `json:"connectionId,omitempty"`
+	Target       string `json:"target,omitempty"` // project/zone/instance
+	Message      string `json:"message,omitempty"`
+	// TimeDisplay is Time formatted for the user, set by GetAuditLog
+	TimeDisplay *DisplayTime `json:"timeDisplay,omitempty"`
+}
+
+// audit appends an entry to the audit log. Failures are ignored so auditing never blocks an operation.
+func (a *App) audit(action, connectionID, target, message string) {
+	dir := a.getConfigDir()
+	if dir == "" {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:         time.Now().Format(time.RFC3339),
+		Action:       action,
+		ConnectionID: connectionID,
+		Target:       target,
+		Message:      redact(message),
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	if err := os.MkdirAll(dir, configDirMode); err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, AuditLogFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// GetAuditLog returns the most recent audit entries, newest first
+func (a *App) GetAuditLog(limit int) ([]AuditEntry, error) {
+	if limit <= 0 || limit > maxAuditEntries {
+		limit = maxAuditEntries
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.Open(filepath.Join(a.getConfigDir(), AuditLogFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditEntry{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Newest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	for i := range entries {
+		entries[i].TimeDisplay = a.displayTime(entries[i].Time)
+	}
+	return entries, nil
+}
+
+// auditTarget formats a VM as project/zone/instance for audit entries
+func auditTarget(projectID, zone, instanceName string) string {
+	return projectID + "/" + zone + "/" + instanceName
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TargetKindAWSSSM is an EC2 instance reached through AWS Systems Manager port forwarding.
+// The AWS CLI and its Session Manager plugin do the forwarding.
+const TargetKindAWSSSM = "aws-ssm"
+
+// awsProjectPrefix starts the project ID of AWS favorites: "aws:REGION" or "aws:PROFILE:REGION"
+const awsProjectPrefix = "aws:"
+
+// AWSTarget locates an instance managed by AWS Systems Manager
+type AWSTarget struct {
+	InstanceID string `json:"instanceId"` // e.g. "i-0123456789abcdef0"
+	Region     string `json:"region"`
+	// Profile is the AWS CLI profile to use, empty for the default one
+	Profile string `json:"profile,omitempty"`
+}
+
+func init() {
+	targetProviders[TargetKindAWSSSM] = func(a *App) TargetProvider { return awsSSMTargets{a} }
+	cloudAuths[TargetKindAWSSSM] = func(a *App) cloudAuth { return awsSSMTargets{a} }
+	experimentalTargetKinds[TargetKindAWSSSM] = true
+}
+
+// awsProjectID returns the project ID AWS favorites of a profile and region are grouped under
+func awsProjectID(profile, region string) string {
+	if profile == "" {
+		return awsProjectPrefix + region
+	}
+	return awsProjectPrefix + profile + ":" + region
+}
+
+// parseAWSProjectID returns the profile and region of an awsProjectID
+func parseAWSProjectID(projectID string) (profile, region string, err error) {
+	rest, ok := strings.CutPrefix(projectID, awsProjectPrefix)
+	if !ok || rest == "" {
+		return "", "", fmt.Errorf("not an AWS project: %s", projectID)
+	}
+	if profile, region, ok = strings.Cut(rest, ":"); ok {
+		return profile, region, nil
+	}
+	return "", rest, nil
+}
+
+// AddAWSFavorite adds a favorite for an instance reached through AWS Systems Manager
+func (a *App) AddAWSFavorite(displayName string, target AWSTarget, remotePort int) (*Favorite, error) {
+	if _, err := a.targetProvider(TargetKindAWSSSM); err != nil {
+		return nil, err
+	}
+	target.InstanceID = strings.TrimSpace(target.InstanceID)
+	target.Region = strings.TrimSpace(target.Region)
+	target.Profile = strings.TrimSpace(target.Profile)
+	if target.InstanceID == "" || target.Region == "" {
+		return nil, fmt.Errorf("an instance ID and region are required")
+	}
+	if displayName == "" {
+		displayName = target.InstanceID
+	}
+	projectID := awsProjectID(target.Profile, target.Region)
+	return a.addFavorite(displayName, projectID, "AWS "+target.Region, target.InstanceID, "", remotePort, func(f *Favorite) {
+		f.TargetKind = TargetKindAWSSSM
+		f.AWS = &target
+	})
+}
+
+// awsSSMTargets provides instances managed by AWS Systems Manager, and signs in to the AWS CLI
+type awsSSMTargets struct{ a *App }
+
+func (p awsSSMTargets) Kind() string { return TargetKindAWSSSM }
+
+// awsArgs adds the profile option to AWS CLI arguments
+func awsArgs(profile string, args ...string) []string {
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	return args
+}
+
+// List returns the managed instances of the region in projectID, see awsProjectID
+func (p awsSSMTargets) List(ctx context.Context, projectID string) ([]Target, error) {
+	profile, region, err := parseAWSProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	aws, err := findCLI("aws")
+	if err != nil {
+		return nil, err
+	}
+	output, err := runCommandSpec(commandSpec{
+		name: aws,
+		args: awsArgs(profile, "ssm", "describe-instance-information", "--region", region, "--output", "json"),
+		ctx:  ctx,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list managed instances: %w", err)
+	}
+
+	var result struct {
+		InstanceInformationList []struct {
+			InstanceId   string
+			ComputerName string
+			PingStatus   string // "Online", "ConnectionLost" or "Inactive"
+		}
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse managed instances: %w", err)
+	}
+	targets := make([]Target, 0, len(result.InstanceInformationList))
+	for _, info := range result.InstanceInformationList {
+		name := info.ComputerName
+		if name == "" {
+			name = info.InstanceId
+		}
+		targets = append(targets, Target{
+			Kind:     TargetKindAWSSSM,
+			Name:     name,
+			ID:       info.InstanceId,
+			Location: region,
+			Status:   info.PingStatus,
+		})
+	}
+	return targets, nil
+}
+
+func (p awsSSMTargets) Resolve(ctx context.Context, fav *Favorite) (*targetEndpoint, error) {
+	if fav.AWS == nil {
+		return nil, fmt.Errorf("favorite has no AWS target")
+	}
+	target := *fav.AWS
+	return &targetEndpoint{
+		provider:  p,
+		ProjectID: fav.ProjectID,
+		Name:      target.InstanceID,
+		cloud:     target,
+	}, nil
+}
+
+// Dial connects through "aws ssm start-session" port forwarding to the instance
+func (p awsSSMTargets) Dial(ctx context.Context, ep *targetEndpoint, port int) (io.ReadWriteCloser, error) {
+	target, ok := ep.cloud.(AWSTarget)
+	if !ok {
+		return nil, fmt.Errorf("not an AWS endpoint: %s", ep)
+	}
+	aws, err := findCLI("aws")
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s/%d", TargetKindAWSSSM, target.Profile, target.Region, target.InstanceID, port)
+	return p.a.cloudForward(ctx, key, func(localPort int) (string, []string) {
+		return aws, awsArgs(target.Profile,
+			"ssm", "start-session",
+			"--region", target.Region,
+			"--target", target.InstanceID,
+			"--document-name", "AWS-StartPortForwardingSession",
+			"--parameters", fmt.Sprintf("portNumber=%d,localPortNumber=%d", port, localPort),
+		)
+	})
+}
+
+// status checks the AWS CLI, its Session Manager plugin and the profile's credentials
+func (p awsSSMTargets) status(ctx context.Context, profile string) CloudAuthStatus {
+	status := CloudAuthStatus{Kind: TargetKindAWSSSM}
+	aws, err := findCLI("aws")
+	if err != nil {
+		status.Error = "AWS CLI not found. Please install it from https://aws.amazon.com/cli/"
+		return status
+	}
+	status.CLIFound = true
+	status.CLIPath = aws
+	if _, err := findCLI("session-manager-plugin"); err != nil {
+		status.Error = "Session Manager plugin not found. Please install it (e.g., 'brew install --cask session-manager-plugin')"
+		return status
+	}
+
+	output, err := runCommandSpec(commandSpec{
+		name: aws,
+		args: awsArgs(profile, "sts", "get-caller-identity", "--output", "json"),
+		ctx:  ctx,
+	})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	var identity struct{ Arn string }
+	if err := json.Unmarshal(output, &identity); err != nil {
+		status.Error = fmt.Sprintf("failed to parse caller identity: %v", err)
+		return status
+	}
+	status.SignedIn = true
+	status.Account = identity.Arn
+	return status
+}
+
+// login signs in to AWS IAM Identity Center (SSO), the profile must be configured for it
+func (p awsSSMTargets) login(ctx context.Context, profile string) error {
+	aws, err := findCLI("aws")
+	if err != nil {
+		return err
+	}
+	_, err = runCommandSpec(commandSpec{
+		name:    aws,
+		args:    awsArgs(profile, "sso", "login"),
+		ctx:     ctx,
+		timeout: cloudLoginTimeout,
+	})
+	return err
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TargetKindAzureBastion is an Azure VM reached through an Azure Bastion native client tunnel.
+// The Azure CLI with its bastion extension does the forwarding; the bastion needs the Standard SKU.
+const TargetKindAzureBastion = "azure-bastion"
+
+// azureProjectPrefix starts the project ID of Azure favorites: "azure:SUBSCRIPTION"
+const azureProjectPrefix = "azure:"
+
+// AzureTarget locates a VM behind an Azure Bastion host
+type AzureTarget struct {
+	Subscription string `json:"subscription"`
+	// ResourceGroup and Bastion name the bastion host
+	ResourceGroup string `json:"resourceGroup"`
+	Bastion       string `json:"bastion"`
+	// ResourceID is the VM's resource ID, e.g. "/subscriptions/.../virtualMachines/web-1"
+	ResourceID string `json:"resourceId"`
+}
+
+func init() {
+	targetProviders[TargetKindAzureBastion] = func(a *App) TargetProvider { return azureBastionTargets{a} }
+	cloudAuths[TargetKindAzureBastion] = func(a *App) cloudAuth { return azureBastionTargets{a} }
+	experimentalTargetKinds[TargetKindAzureBastion] = true
+}
+
+// AddAzureFavorite adds a favorite for a VM reached through Azure Bastion
+func (a *App) AddAzureFavorite(displayName string, target AzureTarget, remotePort int) (*Favorite, error) {
+	if _, err := a.targetProvider(TargetKindAzureBastion); err != nil {
+		return nil, err
+	}
+	target.Subscription = strings.TrimSpace(target.Subscription)
+	target.ResourceGroup = strings.TrimSpace(target.ResourceGroup)
+	target.Bastion = strings.TrimSpace(target.Bastion)
+	target.ResourceID = strings.TrimSpace(target.ResourceID)
+	if target.Subscription == "" || target.ResourceGroup == "" || target.Bastion == "" || target.ResourceID == "" {
+		return nil, fmt.Errorf("a subscription, bastion resource group, bastion name and VM resource ID are required")
+	}
+	vmName := target.ResourceID[strings.LastIndex(target.ResourceID, "/")+1:]
+	if displayName == "" {
+		displayName = vmName
+	}
+	return a.addFavorite(displayName, azureProjectPrefix+target.Subscription, "Azure "+target.ResourceGroup, vmName, "", remotePort, func(f *Favorite) {
+		f.TargetKind = TargetKindAzureBastion
+		f.Azure = &target
+	})
+}
+
+// azureBastionTargets provides VMs behind Azure Bastion hosts, and signs in to the Azure CLI
+type azureBastionTargets struct{ a *App }
+
+func (p azureBastionTargets) Kind() string { return TargetKindAzureBastion }
+
+// List returns the VMs of the subscription in projectID, "azure:SUBSCRIPTION"
+func (p azureBastionTargets) List(ctx context.Context, projectID string) ([]Target, error) {
+	subscription, ok := strings.CutPrefix(projectID, azureProjectPrefix)
+	if !ok || subscription == "" {
+		return nil, fmt.Errorf("not an Azure subscription: %s", projectID)
+	}
+	az, err := findCLI("az")
+	if err != nil {
+		return nil, err
+	}
+	output, err := runCommandSpec(commandSpec{
+		name: az,
+		args: []string{"vm", "list", "--subscription", subscription, "--output", "json"},
+		ctx:  ctx,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	var vms []struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Location string `json:"location"`
+	}
+	if err := json.Unmarshal(output, &vms); err != nil {
+		return nil, fmt.Errorf("failed to parse VMs: %w", err)
+	}
+	targets := make([]Target, 0, len(vms))
+	for _, vm := range vms {
+		targets = append(targets, Target{Kind: TargetKindAzureBastion, Name: vm.Name, ID: vm.ID, Location: vm.Location})
+	}
+	return targets, nil
+}
+
+func (p azureBastionTargets) Resolve(ctx context.Context, fav *Favorite) (*targetEndpoint, error) {
+	if fav.Azure == nil {
+		return nil, fmt.Errorf("favorite has no Azure target")
+	}
+	target := *fav.Azure
+	return &targetEndpoint{
+		provider:  p,
+		ProjectID: fav.ProjectID,
+		Name:      fav.InstanceName,
+		cloud:     target,
+	}, nil
+}
+
+// Dial connects through "az network bastion tunnel" to the VM
+func (p azureBastionTargets) Dial(ctx context.Context, ep *targetEndpoint, port int) (io.ReadWriteCloser, error) {
+	target, ok := ep.cloud.(AzureTarget)
+	if !ok {
+		return nil, fmt.Errorf("not an Azure endpoint: %s", ep)
+	}
+	az, err := findCLI("az")
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s/%s/%s/%d", TargetKindAzureBastion, target.Bastion, target.ResourceID, port)
+	return p.a.cloudForward(ctx, key, func(localPort int) (string, []string) {
+		return az, []string{
+			"network", "bastion", "tunnel",
+			"--subscription", target.Subscription,
+			"--resource-group", target.ResourceGroup,
+			"--name", target.Bastion,
+			"--target-resource-id", target.ResourceID,
+			"--resource-port", fmt.Sprintf("%d", port),
+			"--port", fmt.Sprintf("%d", localPort),
+		}
+	})
+}
+
+// status checks the Azure CLI and its signed in account. profile selects a subscription.
+func (p azureBastionTargets) status(ctx context.Context, profile string) CloudAuthStatus {
+	status := CloudAuthStatus{Kind: TargetKindAzureBastion}
+	az, err := findCLI("az")
+	if err != nil {
+		status.Error = "Azure CLI not found. Please install it (e.g., 'brew install azure-cli')"
+		return status
+	}
+	status.CLIFound = true
+	status.CLIPath = az
+
+	args := []string{"account", "show", "--output", "json"}
+	if profile != "" {
+		args = append(args, "--subscription", profile)
+	}
+	output, err := runCommandSpec(commandSpec{name: az, args: args, ctx: ctx})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	var account struct {
+		Name string `json:"name"`
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(output, &account); err != nil {
+		status.Error = fmt.Sprintf("failed to parse account: %v", err)
+		return status
+	}
+	status.SignedIn = true
+	status.Account = fmt.Sprintf("%s (%s)", account.User.Name, account.Name)
+	return status
+}
+
+// login runs the Azure CLI browser sign-in
+func (p azureBastionTargets) login(ctx context.Context, profile string) error {
+	az, err := findCLI("az")
+	if err != nil {
+		return err
+	}
+	_, err = runCommandSpec(commandSpec{
+		name:    az,
+		args:    []string{"login", "--output", "none"},
+		ctx:     ctx,
+		timeout: cloudLoginTimeout,
+	})
+	return err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// bookmarkCLITimeout is how long a Windows App CLI call may run before it is killed
+	bookmarkCLITimeout = 20 * time.Second
+	// bookmarkCLIMinGap spaces consecutive CLI calls, the CLI races with itself when called back to back
+	bookmarkCLIMinGap = 200 * time.Millisecond
+	// bookmarkWriteTTL is how long a successful write makes an identical write a no-op
+	bookmarkWriteTTL = time.Minute
+)
+
+// bookmarkCall is a single Windows App CLI invocation
+type bookmarkCall struct {
+	args  []string
+	stdin string
+}
+
+// key identifies identical calls without keeping passwords from stdin around
+func (c bookmarkCall) key() string {
+	sum := sha256.Sum256([]byte(strings.Join(c.args, "\x00") + "\x00" + c.stdin))
+	return hex.EncodeToString(sum[:])
+}
+
+// bookmarkID returns the bookmark a write or delete call targets, if any
+func (c bookmarkCall) bookmarkID() (string, string) {
+	for i := 0; i+2 < len(c.args); i++ {
+		if c.args[i] == "bookmark" && (c.args[i+1] == "write" || c.args[i+1] == "delete") {
+			if strings.HasPrefix(c.args[i+2], "-") {
+				return "", ""
+			}
+			return c.args[i+1], c.args[i+2]
+		}
+	}
+	return "", ""
+}
+
+// bookmarkJob is a queued call, shared by every caller that asked for the same thing
+type bookmarkJob struct {
+	call   bookmarkCall
+	key    string
+	done   chan struct{}
+	output []byte
+	err    error
+}
+
+// recentBookmarkWrite is the last successful write of a bookmark
+type recentBookmarkWrite struct {
+	key string
+	at  time.Time
+}
+
+// bookmarkExecutor runs Windows App CLI calls one at a time. The queue and the worker start with
+// the first call.
+type bookmarkExecutor struct {
+	startOnce sync.Once
+	queue     chan *bookmarkJob
+
+	mu      sync.Mutex
+	pending map[string]*bookmarkJob
+	written map[string]recentBookmarkWrite
+	lastRun time.Time
+}
+
+// runBookmarkCLI runs a Windows App CLI call through the app's executor and returns its combined output
+func (a *App) runBookmarkCLI(call bookmarkCall) ([]byte, error) {
+	return a.bookmarkCLI.run(call)
+}
+
+// run queues a call, joining an identical queued or running call, and waits for its result
+func (e *bookmarkExecutor) run(call bookmarkCall) ([]byte, error) {
+	e.startOnce.Do(func() {
+		e.queue = make(chan *bookmarkJob, 64)
+		e.mu.Lock()
+		e.pending = make(map[string]*bookmarkJob)
+		e.written = make(map[string]recentBookmarkWrite)
+		e.mu.Unlock()
+		go e.worker()
+	})
+
+	key := call.key()
+	op, id := call.bookmarkID()
+
+	e.mu.Lock()
+	if op == "write" {
+		if w, ok := e.written[id]; ok && w.key == key && time.Since(w.at) < bookmarkWriteTTL {
+			e.mu.Unlock()
+			return nil, nil
+		}
+	}
+	job, ok := e.pending[key]
+	if !ok {
+		job = &bookmarkJob{call: call, key: key, done: make(chan struct{})}
+		e.pending[key] = job
+	}
+	e.mu.Unlock()
+
+	if !ok {
+		e.queue <- job
+	}
+	<-job.done
+	return job.output, job.err
+}
+
+// worker runs queued calls in order
+func (e *bookmarkExecutor) worker() {
+	for job := range e.queue {
+		e.mu.Lock()
+		wait := bookmarkCLIMinGap - time.Since(e.lastRun)
+		e.mu.Unlock()
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+
+		job.output, job.err = execBookmarkCall(job.call)
+
+		e.mu.Lock()
+		e.lastRun = time.Now()
+		delete(e.pending, job.key)
+		switch op, id := job.call.bookmarkID(); {
+		case op == "write" && job.err == nil:
+			e.written[id] = recentBookmarkWrite{key: job.key, at: e.lastRun}
+		case id != "":
+			// Deleted, or in an unknown state after a failed write
+			delete(e.written, id)
+		}
+		e.mu.Unlock()
+
+		close(job.done)
+	}
+}
+
+// execBookmarkCall runs the CLI, killing it if it hangs
+func execBookmarkCall(call bookmarkCall) ([]byte, error) {
+	return runCommandSpec(commandSpec{
+		name:     WindowsAppCLI,
+		args:     call.args,
+		stdin:    call.stdin,
+		timeout:  bookmarkCLITimeout,
+		combined: true,
+	})
+}
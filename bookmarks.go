@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BookmarkVerification reports how a favorite's Windows App bookmark compares to the config
+type BookmarkVerification struct {
+	ConnectionID string `json:"connectionId"`
+	BookmarkID   string `json:"bookmarkId"`
+	Exists       bool   `json:"exists"`
+	Hostname     string `json:"hostname,omitempty"`
+	Port         int    `json:"port,omitempty"`
+	ExpectedPort int    `json:"expectedPort"`
+	Username     string `json:"username,omitempty"`
+	FriendlyName string `json:"friendlyName,omitempty"`
+	Group        string `json:"group,omitempty"`
+	HasCreds     bool   `json:"hasCreds"`
+	// Drift lists human-readable differences, empty when the bookmark matches
+	Drift []string `json:"drift"`
+	// Updated is true when HasBookmark/BookmarkHasCreds were corrected in the config
+	Updated bool   `json:"updated"`
+	Error   string `json:"error,omitempty"`
+}
+
+// windowsAppBookmark is a bookmark as read back from the Windows App CLI
+type windowsAppBookmark struct {
+	Hostname     string
+	Username     string
+	FriendlyName string
+	Group        string
+}
+
+// VerifyBookmark reads a favorite's bookmark back from Windows App, reports drift
+// (wrong port, missing credentials, deleted by the user) and corrects the config flags
+func (a *App) VerifyBookmark(connectionID string) BookmarkVerification {
+	conn := a.GetConnectionInfo(connectionID)
+	if conn == nil {
+		return BookmarkVerification{ConnectionID: connectionID, Error: "connection not found"}
+	}
+	result := BookmarkVerification{
+		ConnectionID: conn.ID,
+		BookmarkID:   conn.BookmarkID,
+		ExpectedPort: conn.LocalPort,
+		Drift:        []string{},
+	}
+
+	if status := a.CheckWindowsApp(); !status.Installed {
+		result.Error = status.Error
+		return result
+	}
+
+	bookmark, found, err := a.readWindowsAppBookmark(conn.BookmarkID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Exists = found
+	if found {
+		result.Hostname = bookmark.Hostname
+		result.Username = bookmark.Username
+		result.FriendlyName = bookmark.FriendlyName
+		result.Group = bookmark.Group
+		result.HasCreds = bookmark.Username != ""
+		if _, portStr, err := net.SplitHostPort(bookmark.Hostname); err == nil {
+			result.Port, _ = strconv.Atoi(portStr)
+		}
+
+		if result.Port != conn.LocalPort {
+			result.Drift = append(result.Drift, fmt.Sprintf("bookmark points at port %d, connection uses %d", result.Port, conn.LocalPort))
+		}
+		if conn.BookmarkHasCreds && !result.HasCreds {
+			result.Drift = append(result.Drift, "bookmark credentials are missing")
+		}
+		if group := a.bookmarkGroupFor(conn); bookmark.Group != "" && bookmark.Group != group {
+			result.Drift = append(result.Drift, fmt.Sprintf("bookmark is in group %q instead of %q", bookmark.Group, group))
+		}
+	} else if conn.HasBookmark {
+		result.Drift = append(result.Drift, "bookmark was deleted in Windows App")
+	}
+
+	if found != conn.HasBookmark || result.HasCreds != conn.BookmarkHasCreds {
+		if err := a.UpdateConnectionBookmarkStatus(conn.ID, found, result.HasCreds); err == nil {
+			result.Updated = true
+		}
+	}
+	return result
+}
+
+// readWindowsAppBookmark reads a bookmark with "bookmark read". found is false when it doesn't exist.
+func (a *App) readWindowsAppBookmark(bookmarkID string) (windowsAppBookmark, bool, error) {
+	var bookmark windowsAppBookmark
+
+	output, err := a.runBookmarkCLI(bookmarkCall{args: []string{"--script", "bookmark", "read", bookmarkID}})
+	if err != nil {
+		lower := strings.ToLower(string(output))
+		if strings.Contains(lower, "not found") || strings.Contains(lower, "does not exist") || strings.Contains(lower, "no bookmark") {
+			return bookmark, false, nil
+		}
+		return bookmark, false, commandError("failed to read bookmark", err, output)
+	}
+
+	fields := parseBookmarkOutput(output)
+	bookmark = windowsAppBookmark{
+		Hostname:     fields["hostname"],
+		Username:     fields["username"],
+		FriendlyName: fields["friendlyname"],
+		Group:        fields["group"],
+	}
+	if bookmark.Hostname == "" {
+		return bookmark, false, nil
+	}
+	return bookmark, true, nil
+}
+
+// parseBookmarkOutput parses CLI output as JSON or "key: value" / "key=value" lines.
+// Keys are lowercased with spaces, dashes and underscores removed.
+func parseBookmarkOutput(output []byte) map[string]string {
+	fields := make(map[string]string)
+	normalize := func(key string) string {
+		return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(key)))
+	}
+
+	trimmed := strings.TrimSpace(string(output))
+	if strings.HasPrefix(trimmed, "{") {
+		var raw map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &raw); err == nil {
+			for k, v := range raw {
+				fields[normalize(k)] = fmt.Sprint(v)
+			}
+			return fields
+		}
+	}
+
+	for _, line := range strings.Split(trimmed, "\n") {
+		sep := strings.IndexAny(line, ":=")
+		if sep <= 0 {
+			continue
+		}
+		fields[normalize(line[:sep])] = strings.TrimSpace(line[sep+1:])
+	}
+	return fields
+}
+
+// BookmarkReconcileResult summarizes a bookmark reconciliation run
+type BookmarkReconcileResult struct {
+	Created  []string `json:"created"`  // Favorite IDs that got a new bookmark
+	Updated  []string `json:"updated"`  // Favorite IDs whose bookmark port was fixed
+	Orphaned []string `json:"orphaned"` // Bookmark IDs without a favorite, see DeleteOrphanedBookmarks
+	Deleted  []string `json:"deleted"`  // Orphaned bookmark IDs removed by DeleteOrphanedBookmarks
+	Renamed  []string `json:"renamed"`  // Favorite IDs whose DisplayName was imported from Windows App
+	Failures []string `json:"failures"` // Human-readable errors, the run continues past them
+	Warnings []string `json:"warnings"` // Human-readable warnings of bookmarks that were written
+	Error    string   `json:"error,omitempty"`
+}
+
+// BookmarksReconciledEvent is emitted with the BookmarkReconcileResult of a startup reconciliation
+const BookmarksReconciledEvent = "bookmarks:reconciled"
+
+// bookmarkIDPattern matches the numeric IDs produced by GenerateBookmarkID and favoriteBookmarkID
+var bookmarkIDPattern = regexp.MustCompile(`\b\d{7}\b`)
+
+// ReconcileBookmarks makes the Windows App bookmarks in the IAP groups match the favorites:
+// missing bookmarks are created, stale ports fixed, and friendly names edited in Windows App
+// imported as display names. Orphans are only reported, the user confirms deleting them with
+// DeleteOrphanedBookmarks.
+func (a *App) ReconcileBookmarks() BookmarkReconcileResult {
+	a.countFeature("reconcile_bookmarks")
+	result := newBookmarkReconcileResult()
+	if status := a.CheckWindowsApp(); !status.Installed {
+		result.Error = status.Error
+		return result
+	}
+	// The favorites may be incomplete until the user resolved the startup issues
+	if len(a.GetStartupIssues()) > 0 {
+		result.Error = "resolve the startup issues before reconciling bookmarks"
+		return result
+	}
+
+	favorites := a.GetFavorites()
+	orphaned, err := a.orphanedBookmarkIDs(favorites)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Orphaned = orphaned
+
+	for _, f := range favorites {
+		if f.ConnectionType != "" && f.ConnectionType != ConnectionTypeRDP {
+			continue
+		}
+		bookmark, found, err := a.readWindowsAppBookmark(f.BookmarkID)
+		if err != nil {
+			result.Failures = append(result.Failures, fmt.Sprintf("%s: %v", favoriteLabel(f), err))
+			continue
+		}
+
+		if !found {
+			hasCreds, warning, err := a.writeFavoriteBookmark(&f, "")
+			if err != nil {
+				result.Failures = append(result.Failures, fmt.Sprintf("%s: %v", favoriteLabel(f), err))
+				continue
+			}
+			if warning != "" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", favoriteLabel(f), warning))
+			}
+			a.UpdateConnectionBookmarkStatus(f.ID, true, hasCreds)
+			result.Created = append(result.Created, f.ID)
+			continue
+		}
+
+		// Import names the user gave the bookmark in Windows App
+		if name := bookmark.FriendlyName; name != "" && !isGeneratedBookmarkName(name) && name != f.DisplayName {
+			if err := a.UpdateFavoriteFields(f.ID, FavoritePatch{DisplayName: &name}); err == nil {
+				result.Renamed = append(result.Renamed, f.ID)
+			}
+		}
+
+		if bookmark.Hostname != fmt.Sprintf("localhost:%d", f.LocalPort) {
+			hasCreds, warning, err := a.writeFavoriteBookmark(&f, bookmark.FriendlyName)
+			if err != nil {
+				result.Failures = append(result.Failures, fmt.Sprintf("%s: %v", favoriteLabel(f), err))
+				continue
+			}
+			if warning != "" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", favoriteLabel(f), warning))
+			}
+			a.UpdateConnectionBookmarkStatus(f.ID, true, hasCreds)
+			result.Updated = append(result.Updated, f.ID)
+		} else if !f.HasBookmark || f.BookmarkHasCreds != (bookmark.Username != "") {
+			a.UpdateConnectionBookmarkStatus(f.ID, true, bookmark.Username != "")
+		}
+	}
+	return result
+}
+
+// DeleteOrphanedBookmarks deletes bookmarks ReconcileBookmarks reported as orphaned, after the
+// user confirmed them. IDs that got a favorite since are kept.
+func (a *App) DeleteOrphanedBookmarks(bookmarkIDs []string) BookmarkReconcileResult {
+	result := newBookmarkReconcileResult()
+	orphaned, err := a.orphanedBookmarkIDs(a.GetFavorites())
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for _, id := range bookmarkIDs {
+		if !containsString(orphaned, id) {
+			continue
+		}
+		if res := a.DeleteWindowsAppBookmark(id); res.Success {
+			result.Deleted = append(result.Deleted, id)
+		} else {
+			result.Failures = append(result.Failures, res.Error)
+		}
+	}
+	a.audit("bookmarks.orphans_deleted", "", "", strings.Join(result.Deleted, ", "))
+	return result
+}
+
+// newBookmarkReconcileResult returns a result with empty lists, so the frontend gets arrays
+func newBookmarkReconcileResult() BookmarkReconcileResult {
+	return BookmarkReconcileResult{
+		Created:  []string{},
+		Updated:  []string{},
+		Orphaned: []string{},
+		Deleted:  []string{},
+		Renamed:  []string{},
+		Failures: []string{},
+		Warnings: []string{},
+	}
+}
+
+// orphanedBookmarkIDs returns the IDs of our bookmarks in an IAP group without a favorite
+func (a *App) orphanedBookmarkIDs(favorites []Favorite) ([]string, error) {
+	groups := map[string]bool{a.bookmarkGroupFor(nil): true}
+	bookmarkIDs := make(map[string]bool, len(favorites))
+	for _, f := range favorites {
+		bookmarkIDs[f.BookmarkID] = true
+		groups[a.bookmarkGroupFor(&f)] = true
+	}
+
+	ids, err := a.listWindowsAppBookmarkIDs()
+	if err != nil {
+		return nil, err
+	}
+	orphaned := []string{}
+	for _, id := range ids {
+		if bookmarkIDs[id] {
+			continue
+		}
+		bookmark, found, err := a.readWindowsAppBookmark(id)
+		if err != nil || !found || !groups[bookmark.Group] || !strings.HasPrefix(bookmark.Hostname, "localhost:") {
+			continue
+		}
+		orphaned = append(orphaned, id)
+	}
+	return orphaned, nil
+}
+
+// reconcileBookmarksOnStartup runs ReconcileBookmarks in the background and reports the result.
+// It waits for another launch while startup issues are open.
+func (a *App) reconcileBookmarksOnStartup() {
+	if !a.CheckWindowsApp().Installed || len(a.GetStartupIssues()) > 0 {
+		return
+	}
+	go func() {
+		result := a.ReconcileBookmarks()
+		a.emitEvent(BookmarksReconciledEvent, result)
+	}()
+}
+
+// writeFavoriteBookmark writes a favorite's bookmark, including credentials from Keychain when
+// available. An empty friendlyName uses the generated one. It reports whether credentials were
+// written and the warning of the write, e.g. that the password was passed on the command line.
+func (a *App) writeFavoriteBookmark(conn *Favorite, friendlyName string) (bool, string, error) {
+	var username, password string
+	if conn.Username != "" {
+		if p, err := a.cachedPassword(conn.ProjectID, conn.Zone, conn.InstanceName, conn.Username); err == nil {
+			username, password = conn.Username, p
+		}
+	}
+
+	res := a.createOrUpdateBookmarkWithCreds(conn, conn.LocalPort, friendlyName, username, password)
+	if !res.Success {
+		return false, "", errors.New(res.Error)
+	}
+	return username != "", res.Warning, nil
+}
+
+// favoriteBookmarkName is the friendly name the app gives a favorite's bookmark. The profile tells
+// apart the bookmarks of favorites for the same VM.
+func favoriteBookmarkName(conn *Favorite) string {
+	name := fmt.Sprintf("IAP:%s/%s", conn.ProjectID, conn.InstanceName)
+	if conn.Profile != "" {
+		name += " (" + conn.Profile + ")"
+	}
+	return name
+}
+
+// isGeneratedBookmarkName reports whether a friendly name was written by this app
+func isGeneratedBookmarkName(name string) bool {
+	return strings.HasPrefix(name, "IAP:")
+}
+
+// listWindowsAppBookmarkIDs returns the IDs of bookmarks created by this app
+func (a *App) listWindowsAppBookmarkIDs() ([]string, error) {
+	output, err := a.runBookmarkCLI(bookmarkCall{args: []string{"--script", "bookmark", "list"}})
+	if err != nil {
+		return nil, commandError("failed to list bookmarks", err, output)
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range bookmarkIDPattern.FindAllString(string(output), -1) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+const (
+	// BootProgressEvent is emitted with a BootProgress update while a VM boots
+	BootProgressEvent = "vm:boot-progress"
+
+	bootWatchTimeout   = 5 * time.Minute
+	bootPollInterval   = 3 * time.Second
+	bootTypicalReadyIn = 90 * time.Second // Typical time from RUNNING until Windows accepts RDP
+)
+
+// Boot stages reported in BootProgress.Stage
+const (
+	BootStageStarting = "starting"
+	BootStageRunning  = "running"
+	BootStageAgent    = "agent"
+	BootStageScripts  = "scripts"
+	BootStageReady    = "ready"
+	BootStageTimeout  = "timeout"
+	BootStageError    = "error"
+)
+
+// bootMilestone maps a serial console line to a boot stage
+type bootMilestone struct {
+	stage   string
+	percent int
+	markers []string
+	message string
+}
+
+// bootMilestones are checked in order; later milestones imply the earlier ones
+var bootMilestones = []bootMilestone{
+	{BootStageAgent, 60, []string{"GCEGuestAgent: GCE Agent Started", "GCE Agent Started", "google_guest_agent"}, "Guest agent started"},
+	{BootStageScripts, 80, []string{"Finished running startup scripts", "Starting startup scripts"}, "Running startup scripts"},
+	{BootStageReady, 100, []string{"is ready to use", "Windows is ready"}, "Windows is ready"},
+}
+
+// BootProgress represents the boot progress of a VM
+type BootProgress struct {
+	ProjectID    string `json:"projectId"`
+	Zone         string `json:"zone"`
+	InstanceName string `json:"instanceName"`
+	Stage        string `json:"stage"`
+	Message      string `json:"message"`
+	Percent      int    `json:"percent"`
+	Elapsed      int    `json:"elapsed"`           // Seconds since the start request
+	ReadyIn      int    `json:"readyIn,omitempty"` // Estimated seconds until RDP is available
+	Done         bool   `json:"done"`
+	Error        string `json:"error,omitempty"`
+}
+
+// StartVM starts a stopped VM and streams BootProgress events until Windows is ready.
+// confirmed must be set when the ConfirmCloudMutations setting is on, see PreviewStartVM.
+func (a *App) StartVM(projectID, zone, instanceName string, confirmed bool) error {
+	if a.tokenSource == nil {
+		return fmt.Errorf("not authenticated")
+	}
+	if err := a.requireMutationConfirmation(confirmed); err != nil {
+		return err
+	}
+	if err := a.requireOperatorUnlock(OperatorActionVMStart); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return fmt.Errorf("failed to create compute client: %w", err)
+	}
+
+	if _, err := computeService.Instances.Start(projectID, zone, instanceName).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to start VM: %w", err)
+	}
+
+	go a.watchBootProgress(ctx, computeService, projectID, zone, instanceName, nil)
+	return nil
+}
+
+// startVM starts a VM and waits until Windows is ready, for StartVMAsync.
+// onProgress, if set, receives every BootProgress update.
+func (a *App) startVM(ctx context.Context, projectID, zone, instanceName string, onProgress func(BootProgress)) error {
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return fmt.Errorf("failed to create compute client: %w", err)
+	}
+
+	if _, err := computeService.Instances.Start(projectID, zone, instanceName).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to start VM: %w", err)
+	}
+
+	return a.watchBootProgress(ctx, computeService, projectID, zone, instanceName, onProgress)
+}
+
+// watchBootProgress polls instance status and serial port 1 for boot milestones. It returns an
+// error when the boot times out or ctx is canceled.
+func (a *App) watchBootProgress(parent context.Context, svc *compute.Service, projectID, zone, instanceName string, onProgress func(BootProgress)) error {
+	ctx, cancel := context.WithTimeout(parent, bootWatchTimeout)
+	defer cancel()
+
+	started := time.Now()
+	var runningSince time.Time
+	progress := BootProgress{
+		ProjectID:    projectID,
+		Zone:         zone,
+		InstanceName: instanceName,
+		Stage:        BootStageStarting,
+		Message:      "Starting VM",
+		Percent:      10,
+	}
+
+	emit := func() {
+		progress.Elapsed = int(time.Since(started).Seconds())
+		progress.ReadyIn = 0
+		if !runningSince.IsZero() && !progress.Done {
+			if remaining := bootTypicalReadyIn - time.Since(runningSince); remaining > 0 {
+				progress.ReadyIn = int(remaining.Seconds())
+			}
+		}
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, BootProgressEvent, progress)
+		}
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	emit()
+
+	var serialOffset int64
+	stageIndex := -1
+	ticker := time.NewTicker(bootPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if parent.Err() != nil {
+				progress.Stage = BootStageError
+				progress.Message = "Stopped watching the boot"
+				progress.Done = true
+				emit()
+				return parent.Err()
+			}
+			progress.Stage = BootStageTimeout
+			progress.Message = "Timed out waiting for Windows to finish booting"
+			progress.Done = true
+			emit()
+			return fmt.Errorf("timed out waiting for Windows to finish booting")
+		case <-ticker.C:
+		}
+
+		if runningSince.IsZero() {
+			instance, err := svc.Instances.Get(projectID, zone, instanceName).Context(ctx).Do()
+			if err != nil {
+				continue
+			}
+			if instance.Status != "RUNNING" {
+				progress.Message = fmt.Sprintf("VM is %s", strings.ToLower(instance.Status))
+				emit()
+				continue
+			}
+			runningSince = time.Now()
+			progress.Stage = BootStageRunning
+			progress.Message = fmt.Sprintf("Booting… RDP usually ready in ~%ds", int(bootTypicalReadyIn.Seconds()))
+			progress.Percent = 30
+			emit()
+		}
+
+		output, err := svc.Instances.GetSerialPortOutput(projectID, zone, instanceName).Port(1).Start(serialOffset).Context(ctx).Do()
+		if err != nil {
+			continue
+		}
+		serialOffset = output.Next
+
+		// Advance to the furthest milestone seen in the new output
+		newIndex := stageIndex
+		for i := stageIndex + 1; i < len(bootMilestones); i++ {
+			for _, marker := range bootMilestones[i].markers {
+				if strings.Contains(output.Contents, marker) {
+					newIndex = i
+					break
+				}
+			}
+		}
+		if newIndex == stageIndex {
+			emit()
+			continue
+		}
+
+		stageIndex = newIndex
+		milestone := bootMilestones[stageIndex]
+		progress.Stage = milestone.stage
+		progress.Message = milestone.message
+		progress.Percent = milestone.percent
+		if milestone.stage == BootStageReady {
+			progress.Done = true
+			emit()
+			return nil
+		}
+		emit()
+	}
+}
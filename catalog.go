@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// connectionCatalogVersion is the format version of exported connection catalogs
+const connectionCatalogVersion = 1
+
+// Armor headers of encrypted catalogs, used to pick the tool that decrypts them
+const (
+	ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+	pgpArmorHeader = "-----BEGIN PGP MESSAGE-----"
+)
+
+// ConnectionCatalog is a set of favorites exported for a teammate. It describes targets only:
+// IDs, local ports, usernames, usage, bookmarks and on-connect commands stay on this Mac.
+type ConnectionCatalog struct {
+	Version     int        `json:"version"`
+	ExportedAt  string     `json:"exportedAt"`
+	Connections []Favorite `json:"connections"`
+	// Groups are the appearances of the groups the connections are filed under
+	Groups map[string]Appearance `json:"groups,omitempty"`
+}
+
+// CatalogImportResult is the outcome of importing a connection catalog
+type CatalogImportResult struct {
+	Imported int `json:"imported"`
+	// Skipped are the display names of connections already saved here
+	Skipped []string `json:"skipped"`
+}
+
+// catalogFavorite strips a favorite down to what a catalog carries
+func catalogFavorite(f Favorite) Favorite {
+	return Favorite{
+		DisplayName:        f.DisplayName,
+		ProjectID:          f.ProjectID,
+		ProjectName:        f.ProjectName,
+		InstanceName:       f.InstanceName,
+		Zone:               f.Zone,
+		RemotePort:         f.RemotePort,
+		Profile:            f.Profile,
+		Notes:              f.Notes,
+		BookmarkGroup:      f.BookmarkGroup,
+		MaxDurationMinutes: f.MaxDurationMinutes,
+		IdleTimeoutMinutes: f.IdleTimeoutMinutes,
+		MemberSelection:    f.MemberSelection,
+		TargetKind:         f.TargetKind,
+		AWS:                f.AWS,
+		Azure:              f.Azure,
+		Kubernetes:         f.Kubernetes,
+		Hostname:           f.Hostname,
+		Region:             f.Region,
+		Network:            f.Network,
+		DestGroup:          f.DestGroup,
+		ConnectionType:     f.ConnectionType,
+		VNC:                f.VNC,
+		Web:                f.Web,
+		Database:           f.Database,
+		RemoteApps:         f.RemoteApps,
+		Environment:        f.Environment,
+		Preamble:           f.Preamble,
+		Icon:               f.Icon,
+		Color:              f.Color,
+	}
+}
+
+// ExportConnectionCatalog saves favorites (all when none are given) as a catalog for teammates.
+// A catalog reveals internal topology, so with a recipient it is encrypted to their public key
+// before it touches the disk: an age recipient ("age1..." or an ssh public key) through age, or
+// a GnuPG key ID, fingerprint or email through gpg.
+func (a *App) ExportConnectionCatalog(connectionIDs []string, recipient string) ExportResult {
+	a.countFeature("export_catalog")
+	wanted := make(map[string]bool, len(connectionIDs))
+	for _, id := range connectionIDs {
+		wanted[id] = true
+	}
+	catalog := ConnectionCatalog{
+		Version:     connectionCatalogVersion,
+		ExportedAt:  time.Now().Format(time.RFC3339),
+		Connections: []Favorite{},
+	}
+	groups := a.GetGroupAppearances()
+	for _, f := range a.GetFavorites() {
+		if len(wanted) == 0 || wanted[f.ID] {
+			catalog.Connections = append(catalog.Connections, catalogFavorite(f))
+			if ap, ok := groups[f.BookmarkGroup]; ok {
+				if catalog.Groups == nil {
+					catalog.Groups = make(map[string]Appearance)
+				}
+				catalog.Groups[f.BookmarkGroup] = ap
+			}
+		}
+	}
+	if len(catalog.Connections) == 0 {
+		return ExportResult{Error: "no connections to export"}
+	}
+
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return ExportResult{Error: fmt.Sprintf("failed to encode catalog: %v", err)}
+	}
+	name, filter := "connections.json", runtime.FileFilter{DisplayName: "Connection Catalog (*.json)", Pattern: "*.json"}
+	if recipient = strings.TrimSpace(recipient); recipient != "" {
+		if data, err = encryptForRecipient(data, recipient); err != nil {
+			return ExportResult{Error: err.Error()}
+		}
+		name, filter = "connections.json.asc", runtime.FileFilter{DisplayName: "Encrypted Connection Catalog (*.asc, *.age)", Pattern: "*.asc;*.age"}
+		if isAgeRecipient(recipient) {
+			name = "connections.json.age"
+		}
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Connection Catalog",
+		DefaultFilename: name,
+		Filters:         []runtime.FileFilter{filter},
+	})
+	if err != nil || path == "" {
+		return ExportResult{Error: "export cancelled"}
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return ExportResult{Error: fmt.Sprintf("failed to write %s: %v", path, err)}
+	}
+	a.audit("catalog.exported", "", "", fmt.Sprintf("%d connections, encrypted: %t", len(catalog.Connections), recipient != ""))
+	return ExportResult{Success: true, Path: path, Count: len(catalog.Connections)}
+}
+
+// ImportConnectionCatalog adds the connections of a catalog chosen in a file dialog. Encrypted
+// catalogs are decrypted with gpg, which asks for the passphrase itself, or with age and the
+// identity file ageIdentity (e.g. ~/.config/age/keys.txt).
+func (a *App) ImportConnectionCatalog(ageIdentity string) (*CatalogImportResult, error) {
+	a.countFeature("import_catalog")
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Import Connection Catalog",
+		Filters: []runtime.FileFilter{{DisplayName: "Connection Catalog (*.json, *.asc, *.age)", Pattern: "*.json;*.asc;*.age"}},
+	})
+	if err != nil || path == "" {
+		return nil, fmt.Errorf("import cancelled")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	if data, err = decryptCatalog(data, ageIdentity); err != nil {
+		return nil, err
+	}
+
+	var catalog ConnectionCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+	if catalog.Version > connectionCatalogVersion {
+		return nil, fmt.Errorf("catalog version %d is newer than this app supports", catalog.Version)
+	}
+
+	saved := make(map[string]bool)
+	for _, f := range a.GetFavorites() {
+		saved[favoriteVMKey(f)] = true
+	}
+	result := &CatalogImportResult{Skipped: []string{}}
+	for _, entry := range catalog.Connections {
+		entry = catalogFavorite(entry)
+		if (Appearance{Icon: entry.Icon, Color: entry.Color}).normalize().validate() != nil {
+			entry.Icon, entry.Color = "", ""
+		}
+		if entry.Preamble != nil && entry.Preamble.validate() != nil {
+			entry.Preamble = nil
+		}
+		if saved[favoriteVMKey(entry)] {
+			result.Skipped = append(result.Skipped, favoriteLabel(entry))
+			continue
+		}
+		_, err := a.addFavorite(entry.DisplayName, entry.ProjectID, entry.ProjectName, entry.InstanceName, entry.Zone, entry.RemotePort, func(f *Favorite) {
+			local := *f
+			*f = entry
+			f.ID, f.BookmarkID, f.LocalPort, f.SortIndex = local.ID, local.BookmarkID, local.LocalPort, local.SortIndex
+			f.CreatedAt, f.UpdatedAt, f.Username = local.CreatedAt, local.UpdatedAt, local.Username
+			a.applyProjectDefaultsLocked(f)
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to import %s: %w", favoriteLabel(entry), err)
+		}
+		saved[favoriteVMKey(entry)] = true
+		result.Imported++
+	}
+	// Groups keep the look they have here
+	a.mutateConfig(func(cfg *AppConfig) error {
+		changed := false
+		for group, ap := range catalog.Groups {
+			ap = ap.normalize()
+			if _, ok := cfg.GroupAppearances[group]; ok || ap.validate() != nil {
+				continue
+			}
+			if cfg.GroupAppearances == nil {
+				cfg.GroupAppearances = make(map[string]Appearance)
+			}
+			cfg.GroupAppearances[group] = ap
+			changed = true
+		}
+		if !changed {
+			return errConfigUnchanged
+		}
+		return nil
+	})
+	a.audit("catalog.imported", "", "", fmt.Sprintf("%d connections imported, %d skipped", result.Imported, len(result.Skipped)))
+	return result, nil
+}
+
+// isAgeRecipient reports whether a recipient is an age or ssh public key rather than a GnuPG key
+func isAgeRecipient(recipient string) bool {
+	return strings.HasPrefix(recipient, "age1") || strings.HasPrefix(recipient, "ssh-")
+}
+
+// encryptForRecipient encrypts data to a public key with age or gpg, ASCII armored for email
+func encryptForRecipient(data []byte, recipient string) ([]byte, error) {
+	spec := commandSpec{stdin: string(data)}
+	if isAgeRecipient(recipient) {
+		age, err := findCLI("age")
+		if err != nil {
+			return nil, fmt.Errorf("age not found. Please install it (e.g., 'brew install age')")
+		}
+		spec.name, spec.args = age, []string{"--encrypt", "--armor", "--recipient", recipient}
+	} else {
+		gpg, err := findCLI("gpg")
+		if err != nil {
+			return nil, fmt.Errorf("gpg not found. Please install GnuPG (e.g., 'brew install gnupg')")
+		}
+		// The recipient was named explicitly, so an unsigned key in the keyring is accepted
+		spec.name, spec.args = gpg, []string{"--batch", "--yes", "--trust-model", "always", "--armor", "--encrypt", "--recipient", recipient}
+	}
+	out, err := runCommandSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt catalog: %w", err)
+	}
+	return out, nil
+}
+
+// decryptCatalog decrypts an armored catalog, plain JSON is returned as is
+func decryptCatalog(data []byte, ageIdentity string) ([]byte, error) {
+	text := strings.TrimSpace(string(data))
+	var spec commandSpec
+	switch {
+	case strings.HasPrefix(text, ageArmorHeader):
+		if ageIdentity == "" {
+			return nil, fmt.Errorf("the catalog is encrypted with age, choose your identity file")
+		}
+		age, err := findCLI("age")
+		if err != nil {
+			return nil, fmt.Errorf("age not found. Please install it (e.g., 'brew install age')")
+		}
+		spec = commandSpec{name: age, args: []string{"--decrypt", "--identity", ageIdentity}}
+	case strings.HasPrefix(text, pgpArmorHeader):
+		gpg, err := findCLI("gpg")
+		if err != nil {
+			return nil, fmt.Errorf("gpg not found. Please install GnuPG (e.g., 'brew install gnupg')")
+		}
+		// Leaves the passphrase prompt to gpg-agent's pinentry
+		spec = commandSpec{name: gpg, args: []string{"--decrypt"}, timeout: cloudLoginTimeout}
+	default:
+		return data, nil
+	}
+	spec.stdin = text
+	out, err := runCommandSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt catalog: %w", err)
+	}
+	return out, nil
+}
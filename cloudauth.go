@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// cloudLoginTimeout bounds a CLI sign-in, which waits for the user to finish in the browser
+const cloudLoginTimeout = 5 * time.Minute
+
+// CloudAuthStatus is whether the CLI of another cloud is installed and signed in
+type CloudAuthStatus struct {
+	Kind     string `json:"kind"` // Target kind the CLI serves
+	CLIFound bool   `json:"cliFound"`
+	CLIPath  string `json:"cliPath,omitempty"`
+	SignedIn bool   `json:"signedIn"`
+	Account  string `json:"account,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// cloudAuth signs in to the CLI a provider for another cloud forwards through. Each such
+// provider registers its own in cloudAuths; profile selects one of the CLI's named accounts.
+type cloudAuth interface {
+	status(ctx context.Context, profile string) CloudAuthStatus
+	login(ctx context.Context, profile string) error
+}
+
+// cloudAuths are the auth modules of the providers for other clouds, by target kind
+var cloudAuths = map[string]func(*App) cloudAuth{}
+
+// cloudAuthFor returns the auth module of a target kind
+func (a *App) cloudAuthFor(kind string) (cloudAuth, error) {
+	if _, err := a.targetProvider(kind); err != nil {
+		return nil, err
+	}
+	newAuth, ok := cloudAuths[kind]
+	if !ok {
+		return nil, fmt.Errorf("%s targets don't need a separate sign-in", kind)
+	}
+	return newAuth(a), nil
+}
+
+// GetCloudAuthStatus checks the CLI of a provider for another cloud
+func (a *App) GetCloudAuthStatus(kind, profile string) CloudAuthStatus {
+	auth, err := a.cloudAuthFor(kind)
+	if err != nil {
+		return CloudAuthStatus{Kind: kind, Error: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return auth.status(ctx, profile)
+}
+
+// CloudLogin signs the CLI of a provider for another cloud in, opening the browser
+func (a *App) CloudLogin(kind, profile string) (CloudAuthStatus, error) {
+	a.countFeature("cloud_login")
+	auth, err := a.cloudAuthFor(kind)
+	if err != nil {
+		return CloudAuthStatus{Kind: kind}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cloudLoginTimeout)
+	defer cancel()
+	if err := auth.login(ctx, profile); err != nil {
+		return CloudAuthStatus{Kind: kind}, fmt.Errorf("failed to sign in: %w", err)
+	}
+	return a.GetCloudAuthStatus(kind, profile), nil
+}
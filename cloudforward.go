@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cloudForwardStartTimeout bounds waiting for a cloud CLI to listen on its local port
+	cloudForwardStartTimeout = 45 * time.Second
+	// cloudForwardIdleTimeout stops a forwarder this long after its last connection closed
+	cloudForwardIdleTimeout = 5 * time.Minute
+	// cloudForwardPollInterval is how often the local port of a starting forwarder is tried
+	cloudForwardPollInterval = 250 * time.Millisecond
+)
+
+// cloudForwarder is a cloud CLI process forwarding a loopback port to a remote target, such as
+// "aws ssm start-session" or "az network bastion tunnel". Tunnels to those targets relay their
+// connections through it; it is shared by connections to the same target and port.
+type cloudForwarder struct {
+	key    string
+	port   int // Loopback port the CLI listens on
+	cmd    *exec.Cmd
+	stderr bytes.Buffer  // Read only after exited is closed
+	exited chan struct{} // Closed when the process ended
+
+	mu        sync.Mutex
+	conns     int
+	connected bool        // Set once a connection went through, the CLI is known to work
+	idle      *time.Timer // Stops the process after cloudForwardIdleTimeout without connections
+}
+
+// cloudForwardersState holds the running forwarders by key
+type cloudForwardersState struct {
+	mu    sync.Mutex
+	byKey map[string]*cloudForwarder
+}
+
+// cloudCommand builds the CLI invocation forwarding localPort
+type cloudCommand func(localPort int) (name string, args []string)
+
+// cloudForward connects through the forwarder for key, starting it with command when none runs
+func (a *App) cloudForward(ctx context.Context, key string, command cloudCommand) (net.Conn, error) {
+	f, err := a.cloudForwarder(key, command)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cloudForwardStartTimeout)
+	defer cancel()
+	addr := fmt.Sprintf("127.0.0.1:%d", f.port)
+	var dialer net.Dialer
+	for {
+		// A freshly started CLI takes a few seconds to sign in and listen
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			f.acquire()
+			return &forwardedConn{Conn: conn, release: f.release}, nil
+		}
+		select {
+		case <-f.exited:
+			return nil, fmt.Errorf("%s exited: %s", filepath.Base(f.cmd.Path), redact(strings.TrimSpace(f.stderr.String())))
+		case <-ctx.Done():
+			// A CLI that never listened is stuck, e.g. waiting for a sign-in, the next connect starts over
+			a.stopCloudForwarderIfUnused(f)
+			return nil, fmt.Errorf("%s didn't open port %d: %w", filepath.Base(f.cmd.Path), f.port, ctx.Err())
+		case <-time.After(cloudForwardPollInterval):
+		}
+	}
+}
+
+// cloudForwarder returns the running forwarder for key or starts one
+func (a *App) cloudForwarder(key string, command cloudCommand) (*cloudForwarder, error) {
+	a.cloudForwarders.mu.Lock()
+	defer a.cloudForwarders.mu.Unlock()
+	if f, ok := a.cloudForwarders.byKey[key]; ok {
+		return f, nil
+	}
+
+	port, err := freeLoopbackPort()
+	if err != nil {
+		return nil, err
+	}
+	name, args := command(port)
+	// Shutdown kills the forwarders with the other helper commands
+	f := &cloudForwarder{key: key, port: port, cmd: exec.CommandContext(commandsCtx, name, args...), exited: make(chan struct{})}
+	f.cmd.Stderr = &f.stderr
+	f.cmd.WaitDelay = commandWaitDelay
+	if err := f.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	if a.cloudForwarders.byKey == nil {
+		a.cloudForwarders.byKey = make(map[string]*cloudForwarder)
+	}
+	a.cloudForwarders.byKey[key] = f
+
+	go func() {
+		f.cmd.Wait()
+		close(f.exited)
+		a.cloudForwarders.mu.Lock()
+		if a.cloudForwarders.byKey[key] == f {
+			delete(a.cloudForwarders.byKey, key)
+		}
+		a.cloudForwarders.mu.Unlock()
+	}()
+	return f, nil
+}
+
+// stopCloudForwarderIfUnused kills a forwarder no connection went through yet and forgets it
+func (a *App) stopCloudForwarderIfUnused(f *cloudForwarder) {
+	f.mu.Lock()
+	connected := f.connected
+	f.mu.Unlock()
+	if connected {
+		return
+	}
+	a.cloudForwarders.mu.Lock()
+	if a.cloudForwarders.byKey[f.key] == f {
+		delete(a.cloudForwarders.byKey, f.key)
+	}
+	a.cloudForwarders.mu.Unlock()
+	f.cmd.Process.Kill()
+}
+
+// acquire counts a connection, holding off the idle stop
+func (f *cloudForwarder) acquire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conns++
+	f.connected = true
+	if f.idle != nil {
+		f.idle.Stop()
+		f.idle = nil
+	}
+}
+
+// release uncounts a connection and stops the process once it was idle long enough
+func (f *cloudForwarder) release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conns--
+	if f.conns > 0 {
+		return
+	}
+	f.idle = time.AfterFunc(cloudForwardIdleTimeout, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.conns == 0 {
+			f.cmd.Process.Kill()
+		}
+	})
+}
+
+// forwardedConn is a connection through a cloudForwarder, released once on Close
+type forwardedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *forwardedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// freeLoopbackPort returns a loopback port that is free right now
+func freeLoopbackPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// findCLI looks for a command line tool in PATH and where Homebrew and the vendor installers
+// put it, since apps started from Finder don't get the shell's PATH
+func findCLI(name string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	paths := []string{"/opt/homebrew/bin/" + name, "/usr/local/bin/" + name}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(homeDir, ".local", "bin", name))
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found", name)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ConfigDirEnv relocates the config directory, e.g. on managed devices that redirect Application Support
+	ConfigDirEnv = "IAP_TUNNEL_MANAGER_CONFIG_DIR"
+
+	// The config contains usernames and infrastructure inventory, keep it private to the user
+	configDirMode  os.FileMode = 0700
+	configFileMode os.FileMode = 0600
+)
+
+// configDirFromEnv returns the config directory set through ConfigDirEnv, if any
+func configDirFromEnv() string {
+	dir := os.Getenv(ConfigDirEnv)
+	if dir == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return dir
+}
+
+// writePrivateFile atomically replaces path with data, readable only by the user
+func writePrivateFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, configDirMode); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(configFileMode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// repairConfigPermissions tightens the config directory and the files in it if group or others have access
+func (a *App) repairConfigPermissions() {
+	dir := a.getConfigDir()
+	if dir == "" {
+		return
+	}
+
+	if info, err := os.Stat(dir); err == nil && info.IsDir() && info.Mode().Perm()&0077 != 0 {
+		os.Chmod(dir, configDirMode)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Mode().Perm()&0077 == 0 {
+			continue
+		}
+		os.Chmod(filepath.Join(dir, entry.Name()), configFileMode)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FavoriteConflictsEvent is emitted with []FavoriteConflict when a sync leaves conflicting favorites
+const FavoriteConflictsEvent = "favorites:conflicts"
+
+// Favorite conflict kinds
+const (
+	ConflictDuplicateVM   = "duplicate_vm"
+	ConflictPortCollision = "port_collision"
+)
+
+// Conflict resolution actions
+const (
+	// ResolveMerge keeps one favorite of a duplicate VM and folds the others' usage into it
+	ResolveMerge = "merge"
+	// ResolveReassignPort keeps the port for one favorite and gives the others new ports
+	ResolveReassignPort = "reassign_port"
+)
+
+// FavoriteConflict is a set of favorites that can't coexist as they are
+type FavoriteConflict struct {
+	ID          string               `json:"id"` // Stable while the conflict exists, e.g. "port:3390"
+	Kind        string               `json:"kind"`
+	Description string               `json:"description"`
+	FavoriteIDs []string             `json:"favoriteIds"`
+	Resolutions []ConflictResolution `json:"resolutions"`
+}
+
+// ConflictResolution is a proposed fix, passed back to ResolveFavoriteConflicts to apply it
+type ConflictResolution struct {
+	ConflictID  string `json:"conflictId"`
+	Action      string `json:"action"`
+	KeepID      string `json:"keepId"` // The favorite that stays as it is
+	Description string `json:"description,omitempty"`
+}
+
+// GetFavoriteConflicts returns favorites that point at the same VM or share a local port,
+// each with the resolutions that would fix it
+func (a *App) GetFavoriteConflicts() []FavoriteConflict {
+	return findFavoriteConflicts(a.GetFavorites())
+}
+
+// findFavoriteConflicts detects duplicate VMs first; port collisions among duplicates of the same VM
+// are left to the merge
+func findFavoriteConflicts(favorites []Favorite) []FavoriteConflict {
+	conflicts := []FavoriteConflict{}
+	byID := make(map[string]Favorite, len(favorites))
+	byVM := make(map[string][]string)
+	var vmKeys []string
+	for _, f := range favorites {
+		byID[f.ID] = f
+		key := favoriteVMKey(f)
+		if _, ok := byVM[key]; !ok {
+			vmKeys = append(vmKeys, key)
+		}
+		byVM[key] = append(byVM[key], f.ID)
+	}
+
+	vmOf := make(map[string]string)
+	for _, key := range vmKeys {
+		ids := byVM[key]
+		for _, id := range ids {
+			vmOf[id] = key
+		}
+		if len(ids) < 2 {
+			continue
+		}
+		// Copies of one favorite share an ID, any of them can be kept
+		ids = uniqueStrings(ids)
+		first := byID[ids[0]]
+		conflict := FavoriteConflict{
+			ID:          "vm:" + key,
+			Kind:        ConflictDuplicateVM,
+			Description: fmt.Sprintf("%d favorites connect to %s", len(ids), first.InstanceName),
+			FavoriteIDs: ids,
+		}
+		for _, id := range ids {
+			conflict.Resolutions = append(conflict.Resolutions, ConflictResolution{
+				ConflictID:  conflict.ID,
+				Action:      ResolveMerge,
+				KeepID:      id,
+				Description: fmt.Sprintf("Keep %s and merge the others into it", favoriteLabel(byID[id])),
+			})
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	byPort := make(map[int][]string)
+	for _, f := range favorites {
+		if f.LocalPort > 0 {
+			byPort[f.LocalPort] = append(byPort[f.LocalPort], f.ID)
+		}
+	}
+	ports := make([]int, 0, len(byPort))
+	for port := range byPort {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	for _, port := range ports {
+		// One favorite per duplicate group, the duplicate VM conflict handles the rest
+		var ids []string
+		seen := make(map[string]bool)
+		for _, id := range byPort[port] {
+			if !seen[vmOf[id]] {
+				seen[vmOf[id]] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) < 2 {
+			continue
+		}
+		conflict := FavoriteConflict{
+			ID:          fmt.Sprintf("port:%d", port),
+			Kind:        ConflictPortCollision,
+			Description: fmt.Sprintf("%d favorites use local port %d", len(ids), port),
+			FavoriteIDs: ids,
+		}
+		for _, id := range ids {
+			conflict.Resolutions = append(conflict.Resolutions, ConflictResolution{
+				ConflictID:  conflict.ID,
+				Action:      ResolveReassignPort,
+				KeepID:      id,
+				Description: fmt.Sprintf("Keep port %d for %s and give the others new ports", port, favoriteLabel(byID[id])),
+			})
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
+
+// ResolveFavoriteConflicts applies the chosen resolutions together: either all of them are saved
+// or none. It fails if a conflict changed since GetFavoriteConflicts.
+func (a *App) ResolveFavoriteConflicts(resolutions []ConflictResolution) error {
+	if len(resolutions) == 0 {
+		return nil
+	}
+
+	// Ports are allocated before locking, like AddFavorite
+	needed := 0
+	for _, c := range a.GetFavoriteConflicts() {
+		if c.Kind == ConflictPortCollision {
+			needed += len(c.FavoriteIDs) - 1
+		}
+	}
+	freePorts, err := a.freePortsForFavorites(needed)
+	if err != nil {
+		return err
+	}
+
+	bookmarksAffected := false
+	err = a.mutateConfig(func(cfg *AppConfig) error {
+		conflicts := make(map[string]FavoriteConflict)
+		for _, c := range findFavoriteConflicts(cfg.Favorites) {
+			conflicts[c.ID] = c
+		}
+
+		keepFor := make(map[string]string) // VM key -> favorite ID kept for it
+		reassign := make(map[string]bool)
+		for _, r := range resolutions {
+			c, ok := conflicts[r.ConflictID]
+			if !ok {
+				return fmt.Errorf("conflict %s no longer exists, reload the conflicts", r.ConflictID)
+			}
+			if !containsString(c.FavoriteIDs, r.KeepID) {
+				return fmt.Errorf("favorite %s is not part of conflict %s", r.KeepID, r.ConflictID)
+			}
+			switch {
+			case r.Action == ResolveMerge && c.Kind == ConflictDuplicateVM:
+				keepFor[strings.TrimPrefix(c.ID, "vm:")] = r.KeepID
+			case r.Action == ResolveReassignPort && c.Kind == ConflictPortCollision:
+				for _, id := range c.FavoriteIDs {
+					if id != r.KeepID {
+						reassign[id] = true
+					}
+				}
+			default:
+				return fmt.Errorf("%s does not resolve conflict %s", r.Action, r.ConflictID)
+			}
+		}
+
+		// Work on a copy so a failed resolution leaves the config untouched
+		favorites := make([]Favorite, len(cfg.Favorites))
+		copy(favorites, cfg.Favorites)
+		keepIndex := make(map[string]int) // VM key -> index of the kept favorite
+		for i, f := range favorites {
+			key := favoriteVMKey(f)
+			if keepFor[key] == f.ID {
+				if _, ok := keepIndex[key]; !ok {
+					keepIndex[key] = i
+				}
+			}
+		}
+
+		var merged []Favorite
+		kept := make([]Favorite, 0, len(favorites))
+		for i, f := range favorites {
+			if k, ok := keepIndex[favoriteVMKey(f)]; ok && k != i {
+				mergeFavoriteUsage(&favorites[k], f)
+				merged = append(merged, f)
+				bookmarksAffected = bookmarksAffected || f.HasBookmark
+			}
+		}
+		for i, f := range favorites {
+			if k, ok := keepIndex[favoriteVMKey(f)]; ok && k != i {
+				continue
+			}
+			if reassign[f.ID] {
+				if len(freePorts) == 0 {
+					return fmt.Errorf("conflicts changed while resolving, please retry")
+				}
+				f.LocalPort, freePorts = freePorts[0], freePorts[1:]
+				f.UpdatedAt = time.Now().Format(time.RFC3339)
+				bookmarksAffected = bookmarksAffected || f.HasBookmark
+			}
+			kept = append(kept, f)
+		}
+
+		deleted := make(map[string]string, len(cfg.DeletedFavorites)+len(merged))
+		for id, at := range cfg.DeletedFavorites {
+			deleted[id] = at
+		}
+		now := time.Now().Format(time.RFC3339)
+		for _, f := range merged {
+			// Copies sharing the kept ID must not be tombstoned, sync would delete the kept one
+			if !favoritesContainID(kept, f.ID) {
+				deleted[f.ID] = now
+			}
+		}
+
+		cfg.Favorites = kept
+		cfg.DeletedFavorites = deleted
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Bookmarks still point at old ports or merged favorites
+	if bookmarksAffected {
+		go a.ReconcileBookmarks()
+	}
+	return nil
+}
+
+// notifyFavoriteConflicts emits FavoriteConflictsEvent when favorites conflict, e.g. after a sync
+func (a *App) notifyFavoriteConflicts() {
+	if conflicts := a.GetFavoriteConflicts(); len(conflicts) > 0 {
+		a.emitEvent(FavoriteConflictsEvent, conflicts)
+	}
+}
+
+// freePortsForFavorites allocates n free ports that no favorite uses
+func (a *App) freePortsForFavorites(n int) ([]int, error) {
+	used := make(map[int]bool)
+	for _, f := range a.GetFavorites() {
+		used[f.LocalPort] = true
+	}
+
+	ports := make([]int, 0, n)
+	for attempts := 0; len(ports) < n; attempts++ {
+		if attempts >= n+10 {
+			return nil, fmt.Errorf("failed to allocate local ports")
+		}
+		port, err := a.GetFreePort()
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate local port: %w", err)
+		}
+		if !used[port] {
+			used[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// mergeFavoriteUsage folds the usage statistics and missing settings of a duplicate into the kept favorite
+func mergeFavoriteUsage(keep *Favorite, dup Favorite) {
+	keep.ConnectCount += dup.ConnectCount
+	if len(dup.HourCounts) == 24 {
+		if len(keep.HourCounts) != 24 {
+			keep.HourCounts = make([]int, 24)
+		}
+		for h, n := range dup.HourCounts {
+			keep.HourCounts[h] += n
+		}
+	}
+	if parseTimestamp(dup.LastConnectedAt).After(parseTimestamp(keep.LastConnectedAt)) {
+		keep.LastConnectedAt = dup.LastConnectedAt
+	}
+	if keep.Notes == "" {
+		keep.Notes = dup.Notes
+	}
+	if keep.Username == "" {
+		keep.Username = dup.Username
+	}
+	if keep.Environment == "" {
+		keep.Environment = dup.Environment
+	}
+	keep.UpdatedAt = time.Now().Format(time.RFC3339)
+}
+
+// favoriteVMKey identifies the VM, remote port and profile a favorite connects with, ignoring
+// case. Favorites for one VM that differ in port or profile are not duplicates.
+func favoriteVMKey(f Favorite) string {
+	target := auditTarget(f.ProjectID, f.Zone, f.InstanceName)
+	if f.Hostname != "" {
+		target = f.ProjectID + "/" + f.Hostname
+	}
+	return strings.ToLower(fmt.Sprintf("%s:%d/%s", target, f.RemotePort, f.Profile))
+}
+
+// duplicateFavoriteError is returned when f would have the same favoriteVMKey as another favorite
+func duplicateFavoriteError(f Favorite) error {
+	if f.Profile != "" {
+		return fmt.Errorf("connection with profile %q already exists for this VM and port", f.Profile)
+	}
+	return fmt.Errorf("connection already exists for this VM and port, set a profile to save another")
+}
+
+// uniqueStrings returns values without repeats, in order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := values[:0:0]
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// favoritesContainID reports whether a favorite with the ID is in the list, legacy IDs find the
+// favorite they were migrated to
+func favoritesContainID(favorites []Favorite, id string) bool {
+	id = canonicalFavoriteID(id)
+	for _, f := range favorites {
+		if f.ID == id {
+			return true
+		}
+	}
+	return false
+}
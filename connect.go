@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConnectResult represents the outcome of a one-step connect (tunnel + RDP client)
+type ConnectResult struct {
+	Success bool        `json:"success"`
+	Tunnel  *TunnelInfo `json:"tunnel,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	// Code identifies the error message independently of the language, see the Msg constants
+	Code string `json:"code,omitempty"`
+	// RetryAfter is set (in seconds) when the VM is not accepting connections yet
+	RetryAfter int `json:"retryAfter,omitempty"`
+	// URL is the address opened in the browser for web previews
+	URL string `json:"url,omitempty"`
+	// DisabledService is the API to enable with EnableService when Code is service_disabled
+	DisabledService string `json:"disabledService,omitempty"`
+	// Resolution is set when the VM moved to another zone and the new zone needs confirming
+	Resolution *InstanceResolution `json:"resolution,omitempty"`
+	// RDPFile is the .rdp file opened instead of Windows App when it isn't installed
+	RDPFile string `json:"rdpFile,omitempty"`
+	// ConfirmProduction is set when the favorite is a production VM and ConfirmProductionConnect must be called first
+	ConfirmProduction bool `json:"confirmProduction,omitempty"`
+	// FavoriteID is the favorite to confirm, for connects the user didn't start from it (e.g. the reconnect shortcut)
+	FavoriteID string `json:"favoriteId,omitempty"`
+}
+
+// confirmProductionResult asks to confirm connecting to a production favorite
+func (a *App) confirmProductionResult(fav *Favorite) ConnectResult {
+	result := a.connectError(nil, MsgConfirmProduction, favoriteLabel(*fav))
+	result.ConfirmProduction = true
+	result.FavoriteID = fav.ID
+	return result
+}
+
+// ConnectFavorite starts the favorite's tunnel (unless one is running) and opens the RDP client
+func (a *App) ConnectFavorite(favoriteID string) (result ConnectResult) {
+	fav := a.GetConnectionInfo(favoriteID)
+	if fav == nil {
+		return a.connectError(nil, MsgConnectionNotFound)
+	}
+
+	feature := "connect_" + ConnectionTypeRDP
+	if fav.ConnectionType != "" {
+		feature = "connect_" + fav.ConnectionType
+	}
+	a.countFeature(feature)
+	defer func() {
+		if result.Code != "" {
+			a.countError(feature, result.Code)
+		} else {
+			a.countError(feature, result.Error)
+		}
+	}()
+
+	if a.needsProductionConfirmation(fav) {
+		return a.confirmProductionResult(fav)
+	}
+
+	tunnel := a.findActiveTunnel(fav)
+	if tunnel == nil && favoriteTargetKind(fav) == TargetKindInstance {
+		// Catch VMs recreated in another zone before dialing the stale one
+		resolution := a.ResolveInstance(fav.ID)
+		if resolution.Moved {
+			result := a.connectError(nil, MsgInstanceMoved, fav.InstanceName, fav.Zone)
+			result.Resolution = &resolution
+			return result
+		}
+		if resolution.DisabledService != "" {
+			result := a.connectError(nil, MsgServiceDisabled, serviceTitle(resolution.DisabledService), fav.ProjectID)
+			result.DisabledService = resolution.DisabledService
+			return result
+		}
+		if resolution.Error == "" && !resolution.Found && resolution.InstanceGroup == "" {
+			return a.connectError(nil, MsgInstanceNotFound, fav.InstanceName, fav.ProjectID)
+		}
+	}
+	if tunnel == nil {
+		var err error
+		tunnel, err = a.StartTunnelForConnection(fav.ID)
+		if err != nil {
+			return a.connectError(nil, MsgTunnelStartFailed, err)
+		}
+	}
+	return a.openClientForTunnel(fav, tunnel)
+}
+
+// openClientForTunnel opens the favorite's launcher, or the client matching its connection type
+func (a *App) openClientForTunnel(fav *Favorite, tunnel *TunnelInfo) ConnectResult {
+	if fav.OnConnect != nil {
+		return a.openLauncherForTunnel(fav, tunnel)
+	}
+	switch fav.ConnectionType {
+	case ConnectionTypeVNC:
+		return a.openVNCForTunnel(fav, tunnel)
+	case ConnectionTypeWeb:
+		var settings WebSettings
+		if fav.Web != nil {
+			settings = *fav.Web
+		}
+		return a.openWebForTunnel(settings, tunnel)
+	case ConnectionTypeDatabase:
+		return a.openDatabaseForTunnel(fav, tunnel)
+	default:
+		return a.openRDPForTunnel(fav, tunnel)
+	}
+}
+
+// openRDPForTunnel waits for the tunnel to listen and hands off to FreeRDP (for favorites) or Windows App
+func (a *App) openRDPForTunnel(fav *Favorite, tunnel *TunnelInfo) ConnectResult {
+	// Wait for the listener to come up before handing off to the RDP client
+	if !a.waitForTunnelRunning(tunnel.ID, 2*time.Second) {
+		return a.connectError(tunnel, MsgTunnelStartTimeout)
+	}
+
+	// Don't hand off to the RDP client until the VM answers on the remote port
+	if probe := a.ProbeTunnel(tunnel.ID); !probe.Reachable {
+		return ConnectResult{Tunnel: tunnel, Error: probe.Error, Code: probe.Code, RetryAfter: probe.RetryAfter}
+	}
+
+	var err error
+	var rdpFile string
+	switch {
+	case fav != nil && a.CheckFreeRDP().Installed:
+		err = a.launchFreeRDP(fav.ID)
+	case a.CheckWindowsApp().Installed:
+		err = a.OpenWindowsApp()
+	default:
+		// Windows App was removed, hand an .rdp file to any other client
+		if rdpFile, err = a.openRDPFileForTunnel(fav, tunnel); err != nil {
+			a.logToTunnel(tunnel.ID, fmt.Sprintf("Failed to open .rdp file: %v", err))
+			return a.connectError(tunnel, MsgNoRDPClient)
+		}
+	}
+	if err != nil {
+		return a.connectError(tunnel, MsgRDPClientFailed, err)
+	}
+
+	if info, err := a.GetTunnel(tunnel.ID); err == nil {
+		tunnel = info
+	}
+	return ConnectResult{Success: true, Tunnel: tunnel, RDPFile: rdpFile}
+}
+
+// findActiveTunnel returns a running or starting tunnel of the favorite, if any. Tunnels started
+// for another favorite, e.g. SSH next to RDP on the same VM, don't count. Tunnels started without
+// one (from the VM list, restored sessions) match on the VM, remote port and local port.
+func (a *App) findActiveTunnel(fav *Favorite) *TunnelInfo {
+	for _, t := range a.GetActiveTunnels() {
+		if t.FavoriteID != "" {
+			if t.FavoriteID == fav.ID {
+				return &t
+			}
+			continue
+		}
+		if t.ProjectID != fav.ProjectID || t.RemotePort != fav.RemotePort {
+			continue
+		}
+		if fav.LocalPort != 0 && t.LocalPort != fav.LocalPort {
+			continue
+		}
+		if (t.VMName == fav.InstanceName && t.Zone == fav.Zone) || t.InstanceGroup == fav.InstanceName {
+			return &t
+		}
+		// Destination group tunnels are keyed by host
+		if fav.Hostname != "" && t.Host == fav.Hostname {
+			return &t
+		}
+	}
+	return nil
+}
+
+// waitForTunnelRunning polls until the tunnel is running or the timeout expires
+func (a *App) waitForTunnelRunning(tunnelID string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		info, err := a.GetTunnel(tunnelID)
+		if err != nil || info.Status == "error" || info.Status == "stopped" {
+			return false
+		}
+		if info.Status == "running" {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
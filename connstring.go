@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Connection string flavors accepted by GetConnectionString
+const (
+	ConnectionStringAddress = "address" // localhost:port, as typed into mstsc or Windows App
+	ConnectionStringSSH     = "ssh"
+	ConnectionStringPSQL    = "psql"
+	ConnectionStringMySQL   = "mysql"
+	ConnectionStringSQLCmd  = "sqlcmd"
+	ConnectionStringRDP     = "rdp" // rdp:// URI understood by Windows App
+)
+
+// ConnectionStringFlavors lists the supported flavors in display order
+var ConnectionStringFlavors = []string{
+	ConnectionStringAddress,
+	ConnectionStringSSH,
+	ConnectionStringPSQL,
+	ConnectionStringMySQL,
+	ConnectionStringSQLCmd,
+	ConnectionStringRDP,
+}
+
+// GetConnectionStringFlavors returns the flavors accepted by GetConnectionString
+func (a *App) GetConnectionStringFlavors() []string {
+	return ConnectionStringFlavors
+}
+
+// GetConnectionString returns a ready-to-paste string for a connection's active tunnel
+func (a *App) GetConnectionString(connectionID, flavor string) (string, error) {
+	a.countFeature("connection_string")
+	fav := a.GetConnectionInfo(connectionID)
+	if fav == nil {
+		return "", fmt.Errorf("connection not found")
+	}
+	tunnel := a.findActiveTunnel(fav)
+	if tunnel == nil {
+		return "", fmt.Errorf("no running tunnel for this connection")
+	}
+
+	port := tunnel.LocalPort
+	username := fav.Username
+	database := ""
+	if fav.Database != nil {
+		username = databaseUsername(fav)
+		database = fav.Database.Database
+	}
+
+	switch flavor {
+	case ConnectionStringAddress:
+		return fmt.Sprintf("localhost:%d", port), nil
+	case ConnectionStringSSH:
+		if username != "" {
+			return fmt.Sprintf("ssh -p %d %s@localhost", port, shellQuote(username)), nil
+		}
+		return fmt.Sprintf("ssh -p %d localhost", port), nil
+	case ConnectionStringPSQL:
+		return databaseURL(DatabaseSettings{Engine: DatabasePostgres, Database: database}, username, port), nil
+	case ConnectionStringMySQL:
+		return databaseURL(DatabaseSettings{Engine: DatabaseMySQL, Database: database}, username, port), nil
+	case ConnectionStringSQLCmd:
+		parts := []string{"sqlcmd", "-S", fmt.Sprintf("localhost,%d", port)}
+		if username != "" {
+			parts = append(parts, "-U", shellQuote(username))
+		}
+		if database != "" {
+			parts = append(parts, "-d", shellQuote(database))
+		}
+		return strings.Join(parts, " "), nil
+	case ConnectionStringRDP:
+		params := []string{"full%20address=s:" + url.QueryEscape(fmt.Sprintf("localhost:%d", port))}
+		if username != "" {
+			params = append(params, "username=s:"+url.QueryEscape(username))
+		}
+		return "rdp://" + strings.Join(params, "&"), nil
+	default:
+		return "", fmt.Errorf("unknown connection string flavor: %s", flavor)
+	}
+}
+
+// shellQuote quotes a value for pasting into a shell when it contains special characters
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.@", r))
+	}) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
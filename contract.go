@@ -0,0 +1,315 @@
+package main
+
+//go:generate go run . --contract ts frontend/src/contract.d.ts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContractArg prints the frontend contract without starting the UI: "--contract [ts|json] [file]"
+const ContractArg = "--contract"
+
+// eventPayloads maps every event the backend emits to the type of its payload, nil for events
+// without one. Together with the bound methods of App it is the source of the contract, so an
+// event added without an entry here is missing from the generated definitions.
+var eventPayloads = map[string]interface{}{
+	BookmarksReconciledEvent: BookmarkReconcileResult{},
+	BootProgressEvent:        BootProgress{},
+	ConfigSyncedEvent:        nil,
+	ErrorReportedEvent:       RecentError{},
+	FavoriteConflictsEvent:   []FavoriteConflict{},
+	FavoriteStatusEvent:      []VMPowerState{},
+	GcloudUpdateEvent:        GcloudUpdateOutput{},
+	JITSessionEndedEvent:     "", // Session ID
+	LaunchConnectEvent:       ConnectResult{},
+	OperationEvent:           Operation{},
+	PasswordProgressEvent:    PasswordProgress{},
+	PowerModeEvent:           PowerStatus{},
+	QueuedNotificationsEvent: []QueuedNotification{},
+	ReconnectEvent:           ConnectResult{},
+	StartupIssuesEvent:       []StartupIssue{},
+	TunnelExpiredEvent:       TunnelExpiryNotice{},
+	TunnelExpiryWarningEvent: TunnelExpiryNotice{},
+	TunnelIdleStoppedEvent:   "", // Tunnel ID
+	TunnelImportEvent:        SharedTunnel{},
+	TunnelLogEvent:           TunnelLogBatch{},
+	TunnelStalledEvent:       TunnelStall{},
+	TunnelsAdoptedEvent:      []TunnelInfo{},
+	TunnelsPausedEvent:       TunnelPauseStatus{},
+	VMChangesEvent:           VMChanges{},
+	WindowAttachedEvent:      "", // View ID
+	WindowDetachedEvent:      DetachedView{},
+	WindowsAppStatusEvent:    WindowsAppStatus{},
+}
+
+// Contract describes the API the frontend, or any other client, uses: the bound methods, the
+// events and the types they carry. Types are written in TypeScript notation.
+type Contract struct {
+	// Version changes whenever anything else in the contract does
+	Version string           `json:"version"`
+	Methods []ContractMethod `json:"methods"`
+	Events  []ContractEvent  `json:"events"`
+	Types   []ContractType   `json:"types"`
+}
+
+// ContractMethod is a method bound to the frontend
+type ContractMethod struct {
+	Name   string   `json:"name"`
+	Params []string `json:"params"`
+	// Returns is the type the promise resolves to, "void" for none
+	Returns string `json:"returns"`
+	// CanFail is set when the promise rejects with the error of the Go method
+	CanFail bool `json:"canFail"`
+}
+
+// ContractEvent is an event with its payload type, "void" when it has none
+type ContractEvent struct {
+	Name    string `json:"name"`
+	Payload string `json:"payload"`
+}
+
+// ContractType is a struct that appears in a method or event, by its JSON encoding
+type ContractType struct {
+	Name   string          `json:"name"`
+	Fields []ContractField `json:"fields"`
+}
+
+// ContractField is a field of a ContractType
+type ContractField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Optional bool   `json:"optional"` // omitempty or a pointer, may be missing
+}
+
+var (
+	contractOnce sync.Once
+	contract     Contract
+)
+
+// GetContract returns the contract of this build, clients compare its version to detect a mismatch
+func (a *App) GetContract() Contract {
+	contractOnce.Do(func() { contract = buildContract() })
+	return contract
+}
+
+// runContractMode handles "--contract [ts|json] [file]", writing the contract to file or stdout.
+// It reports whether the arguments requested contract mode and the exit code.
+func runContractMode(args []string) (int, bool) {
+	if len(args) == 0 || args[0] != ContractArg {
+		return 0, false
+	}
+	format := "ts"
+	if len(args) > 1 {
+		format = args[1]
+	}
+
+	c := buildContract()
+	var out []byte
+	switch format {
+	case "ts":
+		out = []byte(contractTypeScript(c))
+	case "json":
+		data, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1, true
+		}
+		out = append(data, '\n')
+	default:
+		fmt.Fprintf(os.Stderr, "usage: %s [ts|json] [file]\n", ContractArg)
+		return 2, true
+	}
+
+	if len(args) > 2 {
+		if err := os.WriteFile(args[2], out, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1, true
+		}
+		return 0, true
+	}
+	os.Stdout.Write(out)
+	return 0, true
+}
+
+// buildContract collects the contract from the methods of App and eventPayloads
+func buildContract() Contract {
+	g := &contractGen{types: make(map[string]*ContractType)}
+	c := Contract{Methods: []ContractMethod{}, Events: []ContractEvent{}, Types: []ContractType{}}
+
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	appType := reflect.TypeOf(&App{})
+	for i := 0; i < appType.NumMethod(); i++ {
+		m := appType.Method(i)
+		method := ContractMethod{Name: m.Name, Params: []string{}, Returns: "void"}
+		// In(0) is the receiver
+		for j := 1; j < m.Type.NumIn(); j++ {
+			method.Params = append(method.Params, g.tsType(m.Type.In(j)))
+		}
+		for j := 0; j < m.Type.NumOut(); j++ {
+			if out := m.Type.Out(j); out == errorType {
+				method.CanFail = true
+			} else {
+				method.Returns = g.tsType(out)
+			}
+		}
+		c.Methods = append(c.Methods, method)
+	}
+
+	for name, payload := range eventPayloads {
+		event := ContractEvent{Name: name, Payload: "void"}
+		if payload != nil {
+			event.Payload = g.tsType(reflect.TypeOf(payload))
+		}
+		c.Events = append(c.Events, event)
+	}
+	sort.Slice(c.Events, func(i, j int) bool { return c.Events[i].Name < c.Events[j].Name })
+
+	for _, t := range g.types {
+		c.Types = append(c.Types, *t)
+	}
+	sort.Slice(c.Types, func(i, j int) bool { return c.Types[i].Name < c.Types[j].Name })
+
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	c.Version = hex.EncodeToString(sum[:6])
+	return c
+}
+
+// contractGen collects the struct types reached from methods and events
+type contractGen struct {
+	types map[string]*ContractType
+}
+
+// tsType returns the TypeScript type of the JSON encoding of t
+func (g *contractGen) tsType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.tsType(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // base64
+		}
+		return g.tsType(t.Elem()) + "[]"
+	case reflect.Map:
+		return fmt.Sprintf("Record<string, %s>", g.tsType(t.Elem()))
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return "string"
+		}
+		if t.Name() == "" {
+			return g.inlineStruct(t)
+		}
+		return g.namedStruct(t)
+	default:
+		return "any"
+	}
+}
+
+// namedStruct registers a named struct and returns its name
+func (g *contractGen) namedStruct(t reflect.Type) string {
+	name := t.Name()
+	if pkg := t.PkgPath(); pkg != "main" {
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "_" + name
+	}
+	if _, ok := g.types[name]; ok {
+		return name
+	}
+	// Registered before its fields so recursive types terminate
+	ct := &ContractType{Name: name}
+	g.types[name] = ct
+	ct.Fields = g.fields(t)
+	return name
+}
+
+// inlineStruct writes an anonymous struct as an object literal type
+func (g *contractGen) inlineStruct(t reflect.Type) string {
+	var parts []string
+	for _, f := range g.fields(t) {
+		parts = append(parts, contractFieldTS(f))
+	}
+	return "{ " + strings.Join(parts, " ") + " }"
+}
+
+// fields lists the JSON fields of a struct, flattening embedded structs like encoding/json
+func (g *contractGen) fields(t reflect.Type) []ContractField {
+	fields := []ContractField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, g.fields(f.Type)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, ContractField{
+			Name:     name,
+			Type:     g.tsType(f.Type),
+			Optional: strings.Contains(opts, "omitempty") || f.Type.Kind() == reflect.Pointer,
+		})
+	}
+	return fields
+}
+
+// contractFieldTS writes a field as a TypeScript property
+func contractFieldTS(f ContractField) string {
+	optional := ""
+	if f.Optional {
+		optional = "?"
+	}
+	return fmt.Sprintf("%s%s: %s;", f.Name, optional, f.Type)
+}
+
+// contractTypeScript writes the contract as TypeScript definitions
+func contractTypeScript(c Contract) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by %s ts. DO NOT EDIT.\n", ContractArg)
+	fmt.Fprintf(&b, "// Contract version %s\n", c.Version)
+
+	for _, t := range c.Types {
+		fmt.Fprintf(&b, "\nexport interface %s {\n", t.Name)
+		for _, f := range t.Fields {
+			fmt.Fprintf(&b, "\t%s\n", contractFieldTS(f))
+		}
+		b.WriteString("}\n")
+	}
+
+	b.WriteString("\n// App is the API bound as window.go.main.App\nexport interface App {\n")
+	for _, m := range c.Methods {
+		params := make([]string, len(m.Params))
+		for i, p := range m.Params {
+			params[i] = fmt.Sprintf("arg%d: %s", i+1, p)
+		}
+		fmt.Fprintf(&b, "\t%s(%s): Promise<%s>;\n", m.Name, strings.Join(params, ", "), m.Returns)
+	}
+	b.WriteString("}\n")
+
+	b.WriteString("\n// Events maps event names to the payload passed to EventsOn handlers\nexport interface Events {\n")
+	for _, e := range c.Events {
+		fmt.Fprintf(&b, "\t%q: %s;\n", e.Name, e.Payload)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/cloudbilling/v1"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+const (
+	// computeBillingService is the Cloud Billing Catalog ID of Compute Engine
+	computeBillingService = "services/6F81-5844-456A"
+	// costCatalogTTL is how long the downloaded price list is reused
+	costCatalogTTL = 24 * time.Hour
+	// costCurrency is the currency prices are requested in
+	costCurrency = "USD"
+)
+
+// sharedCoreFractions is the share of each vCPU that shared-core E2 machine types are billed for
+var sharedCoreFractions = map[string]float64{
+	"e2-micro":  0.25,
+	"e2-small":  0.5,
+	"e2-medium": 1,
+}
+
+// CostItem is one billed resource of a VM
+type CostItem struct {
+	Description string  `json:"description"` // Catalog SKU description
+	Quantity    float64 `json:"quantity"`
+	Unit        string  `json:"unit"` // e.g. "h" for vCPUs and GPUs, "GiBy.h" for memory
+	UnitPrice   float64 `json:"unitPrice"`
+	Hourly      float64 `json:"hourly"`
+}
+
+// VMCostEstimate is the approximate on-demand price of running a machine type
+type VMCostEstimate struct {
+	ProjectID   string     `json:"projectId"`
+	Zone        string     `json:"zone"`
+	Region      string     `json:"region"`
+	MachineType string     `json:"machineType"`
+	Currency    string     `json:"currency"`
+	Hourly      float64    `json:"hourly"`
+	Monthly     float64    `json:"monthly"` // Running 730 hours, without sustained use discounts
+	Items       []CostItem `json:"items"`
+	// Note lists what the estimate leaves out
+	Note string `json:"note"`
+}
+
+// costCatalogState caches the on-demand Compute Engine SKUs
+type costCatalogState struct {
+	mu        sync.Mutex
+	fetchedAt time.Time
+	skus      []*cloudbilling.Sku
+}
+
+// GetVMCostEstimate estimates the hourly on-demand cost of a machine type from the Cloud Billing
+// Catalog. vCPUs, memory and bundled GPUs are priced; licenses, disks, network and discounts are not.
+func (a *App) GetVMCostEstimate(projectID, zone, machineType string) (*VMCostEstimate, error) {
+	if a.tokenSource == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	if i := strings.LastIndex(machineType, "/"); i != -1 {
+		machineType = machineType[i+1:]
+	}
+
+	ctx := context.Background()
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %w", err)
+	}
+	mt, err := computeService.MachineTypes.Get(projectID, zone, machineType).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machine type: %w", err)
+	}
+
+	skus, err := a.computeSkus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	region := zoneRegion(zone)
+	estimate := &VMCostEstimate{
+		ProjectID:   projectID,
+		Zone:        zone,
+		Region:      region,
+		MachineType: machineType,
+		Currency:    costCurrency,
+		Items:       []CostItem{},
+		Note:        "On-demand price of vCPUs, memory and bundled GPUs. Excludes OS licenses, disks, network and discounts.",
+	}
+
+	cpus := float64(mt.GuestCpus)
+	if mt.IsSharedCpu {
+		fraction, ok := sharedCoreFractions[machineType]
+		if !ok {
+			return nil, fmt.Errorf("cost estimates are not available for shared-core machine type %s", machineType)
+		}
+		cpus *= fraction
+	}
+
+	prefix := machineSkuPrefix(machineType)
+	cpuSku := findSku(skus, region, "CPU", func(d string) bool { return strings.HasPrefix(d, prefix+" core running in") })
+	ramSku := findSku(skus, region, "RAM", func(d string) bool { return strings.HasPrefix(d, prefix+" ram running in") })
+	if cpuSku == nil || ramSku == nil {
+		return nil, fmt.Errorf("no price found for %s in %s", machineType, region)
+	}
+	estimate.addItem(cpuSku, cpus)
+	estimate.addItem(ramSku, float64(mt.MemoryMb)/1024)
+
+	for _, acc := range mt.Accelerators {
+		name := strings.ReplaceAll(strings.TrimPrefix(acc.GuestAcceleratorType, "nvidia-"), "-", " ")
+		gpuSku := findSku(skus, region, "GPU", func(d string) bool {
+			return strings.HasPrefix(d, "nvidia "+name+" gpu running in")
+		})
+		if gpuSku == nil {
+			return nil, fmt.Errorf("no price found for %s GPUs in %s", acc.GuestAcceleratorType, region)
+		}
+		estimate.addItem(gpuSku, float64(acc.GuestAcceleratorCount))
+	}
+
+	estimate.Monthly = estimate.Hourly * 730
+	return estimate, nil
+}
+
+// addItem prices quantity units of a SKU and adds it to the total
+func (e *VMCostEstimate) addItem(sku *cloudbilling.Sku, quantity float64) {
+	unit, price := skuUnitPrice(sku)
+	item := CostItem{
+		Description: sku.Description,
+		Quantity:    quantity,
+		Unit:        unit,
+		UnitPrice:   price,
+		Hourly:      quantity * price,
+	}
+	e.Items = append(e.Items, item)
+	e.Hourly += item.Hourly
+}
+
+// machineSkuPrefix returns the lowercase SKU description prefix of a machine family,
+// e.g. "n2 instance" for n2-standard-8
+func machineSkuPrefix(machineType string) string {
+	family := strings.SplitN(machineType, "-", 2)[0]
+	custom := strings.Contains(machineType, "custom")
+	switch {
+	case family == "n1" && custom:
+		return "custom instance"
+	case family == "n1":
+		return "n1 predefined instance"
+	case family == "c2":
+		return "compute optimized"
+	case family == "m1":
+		return "memory-optimized instance"
+	case custom:
+		return family + " custom instance"
+	}
+	return family + " instance"
+}
+
+// findSku returns the first on-demand SKU of a resource group in the region whose lowercase
+// description matches
+func findSku(skus []*cloudbilling.Sku, region, group string, match func(description string) bool) *cloudbilling.Sku {
+	for _, sku := range skus {
+		if sku.Category == nil || sku.Category.ResourceGroup != group {
+			continue
+		}
+		if !match(strings.ToLower(sku.Description)) {
+			continue
+		}
+		for _, r := range sku.ServiceRegions {
+			if r == region {
+				return sku
+			}
+		}
+	}
+	return nil
+}
+
+// skuUnitPrice returns the usage unit and the first non-zero tier price of a SKU
+func skuUnitPrice(sku *cloudbilling.Sku) (string, float64) {
+	if len(sku.PricingInfo) == 0 || sku.PricingInfo[0].PricingExpression == nil {
+		return "", 0
+	}
+	expr := sku.PricingInfo[0].PricingExpression
+	for _, rate := range expr.TieredRates {
+		if rate.UnitPrice == nil {
+			continue
+		}
+		price := float64(rate.UnitPrice.Units) + float64(rate.UnitPrice.Nanos)/1e9
+		if price > 0 {
+			return expr.UsageUnit, price
+		}
+	}
+	return expr.UsageUnit, 0
+}
+
+// computeSkus returns the on-demand Compute Engine SKUs, downloading the catalog at most once a day
+func (a *App) computeSkus(ctx context.Context) ([]*cloudbilling.Sku, error) {
+	a.costCatalog.mu.Lock()
+	defer a.costCatalog.mu.Unlock()
+	if a.costCatalog.skus != nil && time.Since(a.costCatalog.fetchedAt) < costCatalogTTL {
+		return a.costCatalog.skus, nil
+	}
+
+	billingService, err := cloudbilling.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create billing client: %w", err)
+	}
+	a.recordNetActivity(hostBilling)
+
+	var skus []*cloudbilling.Sku
+	err = billingService.Services.Skus.List(computeBillingService).CurrencyCode(costCurrency).Pages(ctx, func(page *cloudbilling.ListSkusResponse) error {
+		for _, sku := range page.Skus {
+			if sku.Category != nil && sku.Category.UsageType == "OnDemand" {
+				skus = append(skus, sku)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prices: %w", err)
+	}
+
+	a.costCatalog.skus = skus
+	a.costCatalog.fetchedAt = time.Now()
+	return skus, nil
+}
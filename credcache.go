@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// credentialCacheTTL is how long a password read from Keychain is reused after its last use
+	credentialCacheTTL = 2 * time.Minute
+	// credentialCacheMaxAge bounds the reuse of a password however often it is used
+	credentialCacheMaxAge = 10 * time.Minute
+)
+
+// cachedCredential is a password read from Keychain
+type cachedCredential struct {
+	password string
+	readAt   time.Time
+	usedAt   time.Time
+}
+
+// credentialCache keeps passwords read from Keychain for a short while, so writing several
+// bookmarks or reconnecting doesn't run security(1) each time. Passwords live nowhere else
+// in memory: not in favorites, tunnels or sessions.
+type credentialCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedCredential // By Keychain account
+	sweep   *time.Timer
+}
+
+// keychainAccountFor returns the Keychain account of a VM user's password
+func keychainAccountFor(projectID, zone, instance, username string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", projectID, zone, instance, username)
+}
+
+// cachedPassword returns a VM user's password from the cache, reading Keychain on a miss
+func (a *App) cachedPassword(projectID, zone, instance, username string) (string, error) {
+	account := keychainAccountFor(projectID, zone, instance, username)
+	c := &a.credentials
+	now := time.Now()
+
+	c.mu.Lock()
+	if entry, ok := c.entries[account]; ok && !entry.expired(now) {
+		entry.usedAt = now
+		c.mu.Unlock()
+		return entry.password, nil
+	}
+	c.mu.Unlock()
+
+	password, err := a.GetPasswordFromKeychain(projectID, zone, instance, username)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*cachedCredential)
+	}
+	c.entries[account] = &cachedCredential{password: password, readAt: now, usedAt: now}
+	if c.sweep == nil {
+		c.sweep = time.AfterFunc(credentialCacheTTL, a.sweepCredentials)
+	}
+	return password, nil
+}
+
+// expired reports whether the entry may no longer be used
+func (e *cachedCredential) expired(now time.Time) bool {
+	return now.Sub(e.usedAt) >= credentialCacheTTL || now.Sub(e.readAt) >= credentialCacheMaxAge
+}
+
+// sweepCredentials drops expired passwords, rescheduling itself while any are left
+func (a *App) sweepCredentials() {
+	c := &a.credentials
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for account, entry := range c.entries {
+		if entry.expired(now) {
+			delete(c.entries, account)
+		}
+	}
+	c.sweep = nil
+	if len(c.entries) > 0 {
+		c.sweep = time.AfterFunc(credentialCacheTTL, a.sweepCredentials)
+	}
+}
+
+// forgetCachedPassword drops a VM user's password, e.g. after it was reset
+func (a *App) forgetCachedPassword(projectID, zone, instance, username string) {
+	c := &a.credentials
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, keychainAccountFor(projectID, zone, instance, username))
+}
+
+// FlushCredentialCache drops every cached password and returns how many there were
+func (a *App) FlushCredentialCache() int {
+	return a.flushCredentials("flushed by user")
+}
+
+// flushCredentials empties the cache, reason is audited when it held passwords
+func (a *App) flushCredentials(reason string) int {
+	c := &a.credentials
+	c.mu.Lock()
+	n := len(c.entries)
+	c.entries = nil
+	if c.sweep != nil {
+		c.sweep.Stop()
+		c.sweep = nil
+	}
+	c.mu.Unlock()
+
+	if n > 0 {
+		a.audit("credentials.flushed", "", "", fmt.Sprintf("%d cached passwords dropped: %s", n, reason))
+	}
+	return n
+}
+
+// startCredentialFlusher empties the cache whenever the Mac sleeps, locks its screen or
+// switches to another user
+func (a *App) startCredentialFlusher() {
+	watchSystemLock(func(reason string) {
+		a.flushCredentials(reason)
+	})
+}
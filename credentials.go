@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// windowsAppPasswordStdinFlag makes the Windows App CLI read the bookmark password from stdin,
+// on versions that support it
+const windowsAppPasswordStdinFlag = "--password-stdin"
+
+var (
+	windowsAppStdinOnce      sync.Once
+	windowsAppStdinSupported bool
+)
+
+// passwordInArgsWarning is reported when a password had to be passed on the command line
+const passwordInArgsWarning = "This Windows App version only accepts the password as a command line argument, it was briefly visible to other processes"
+
+// windowsAppSupportsPasswordStdin checks once whether the Windows App CLI can read passwords from stdin
+func (a *App) windowsAppSupportsPasswordStdin() bool {
+	windowsAppStdinOnce.Do(func() {
+		output, _ := a.runBookmarkCLI(bookmarkCall{args: []string{"--script", "bookmark", "write", "--help"}})
+		windowsAppStdinSupported = strings.Contains(string(output), windowsAppPasswordStdinFlag)
+	})
+	return windowsAppStdinSupported
+}
+
+// bookmarkCommand builds a Windows App bookmark write call that hands the password over stdin
+// when the CLI supports it. Otherwise the password goes into the arguments and a warning is returned.
+func (a *App) bookmarkCommand(args []string, username, password string) (bookmarkCall, string) {
+	if username == "" {
+		return bookmarkCall{args: args}, ""
+	}
+	args = append(args, "--username", username)
+
+	if a.windowsAppSupportsPasswordStdin() {
+		return bookmarkCall{args: append(args, windowsAppPasswordStdinFlag), stdin: password + "\n"}, ""
+	}
+
+	a.logWarningf("Passing bookmark password for %s as a command line argument", username)
+	return bookmarkCall{args: append(args, "--password", password)}, passwordInArgsWarning
+}
+
+// runSecurity runs a security(1) command through its interactive mode, so arguments such as
+// passwords are read from stdin instead of being visible in the process list
+func runSecurity(args ...string) ([]byte, error) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+	}
+	return runCommandSpec(commandSpec{
+		name:     "security",
+		args:     []string{"-i"},
+		stdin:    strings.Join(quoted, " ") + "\n",
+		combined: true,
+	})
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// credentialMappingVersion is the format version of exported credential mappings
+const credentialMappingVersion = 1
+
+// CredentialMappingFile links favorites to the Keychain accounts of their Windows users. It
+// carries no passwords: on a new Mac, ImportCredentialMappings finds the Keychain items that
+// arrived there, e.g. through iCloud Keychain, instead of the passwords being generated again.
+type CredentialMappingFile struct {
+	Version    int                 `json:"version"`
+	ExportedAt string              `json:"exportedAt"`
+	Service    string              `json:"service"` // Keychain service of the accounts
+	Mappings   []CredentialMapping `json:"mappings"`
+}
+
+// CredentialMapping is a favorite with the Keychain accounts of its users
+type CredentialMapping struct {
+	FavoriteID   string `json:"favoriteId"`
+	DisplayName  string `json:"displayName"`
+	ProjectID    string `json:"projectId"`
+	Zone         string `json:"zone"`
+	InstanceName string `json:"instanceName"`
+	Hostname     string `json:"hostname,omitempty"`
+	RemotePort   int    `json:"remotePort"`
+	Profile      string `json:"profile,omitempty"`
+	// Username is the favorite's user, Accounts also lists other users of the VM with a password
+	Username string              `json:"username,omitempty"`
+	Accounts []CredentialAccount `json:"accounts"`
+}
+
+// CredentialAccount is a VM user and the Keychain account its password is saved under
+type CredentialAccount struct {
+	Username string `json:"username"`
+	Account  string `json:"account"`
+}
+
+// CredentialLinkResult is the outcome of importing credential mappings
+type CredentialLinkResult struct {
+	// Linked counts the favorites whose user now finds its password in Keychain
+	Linked int `json:"linked"`
+	// Missing are Keychain accounts not on this Mac (yet), iCloud Keychain may still be syncing
+	Missing []string `json:"missing"`
+	// Unmatched are the display names of mappings without a favorite here, import the config first
+	Unmatched []string `json:"unmatched"`
+}
+
+// favoriteVMKey returns the VM key of the favorite a mapping was exported for
+func (m CredentialMapping) favoriteVMKey() string {
+	return favoriteVMKey(Favorite{
+		ProjectID:    m.ProjectID,
+		Zone:         m.Zone,
+		InstanceName: m.InstanceName,
+		Hostname:     m.Hostname,
+		RemotePort:   m.RemotePort,
+		Profile:      m.Profile,
+	})
+}
+
+// keychainAccounts lists the accounts of the app's Keychain items. security dump-keychain only
+// prints attributes without -d, so no password is read and nothing asks for access.
+func keychainAccounts() ([]string, error) {
+	output, err := runCommand("security", "dump-keychain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Keychain items: %w", err)
+	}
+	var accounts []string
+	var account, service string
+	flush := func() {
+		if service == KeychainService && account != "" {
+			accounts = append(accounts, account)
+		}
+		account, service = "", ""
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "keychain:"):
+			flush()
+		case strings.HasPrefix(line, `"acct"<blob>="`):
+			account = strings.TrimSuffix(strings.TrimPrefix(line, `"acct"<blob>="`), `"`)
+		case strings.HasPrefix(line, `"svce"<blob>="`):
+			service = strings.TrimSuffix(strings.TrimPrefix(line, `"svce"<blob>="`), `"`)
+		}
+	}
+	flush()
+	return uniqueStrings(accounts), nil
+}
+
+// keychainHasAccount reports whether the app has a Keychain item for account, without reading it
+func keychainHasAccount(account string) bool {
+	_, err := runCommand("security", "find-generic-password", "-s", KeychainService, "-a", account)
+	return err == nil
+}
+
+// ExportCredentialMappings saves which Keychain accounts the favorites' users (all favorites when
+// none are given) have passwords under, without the passwords
+func (a *App) ExportCredentialMappings(connectionIDs []string) ExportResult {
+	a.countFeature("export_credential_mappings")
+	accounts, err := keychainAccounts()
+	if err != nil {
+		return ExportResult{Error: err.Error()}
+	}
+	wanted := make(map[string]bool, len(connectionIDs))
+	for _, id := range connectionIDs {
+		wanted[id] = true
+	}
+
+	file := CredentialMappingFile{
+		Version:    credentialMappingVersion,
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Service:    KeychainService,
+		Mappings:   []CredentialMapping{},
+	}
+	for _, f := range a.GetFavorites() {
+		if len(wanted) > 0 && !wanted[f.ID] {
+			continue
+		}
+		mapping := CredentialMapping{
+			FavoriteID:   f.ID,
+			DisplayName:  favoriteLabel(f),
+			ProjectID:    f.ProjectID,
+			Zone:         f.Zone,
+			InstanceName: f.InstanceName,
+			Hostname:     f.Hostname,
+			RemotePort:   f.RemotePort,
+			Profile:      f.Profile,
+			Username:     f.Username,
+			Accounts:     []CredentialAccount{},
+		}
+		prefix := keychainAccountFor(f.ProjectID, f.Zone, f.InstanceName, "")
+		for _, account := range accounts {
+			if username, ok := strings.CutPrefix(account, prefix); ok && username != "" {
+				mapping.Accounts = append(mapping.Accounts, CredentialAccount{Username: username, Account: account})
+			}
+		}
+		if len(mapping.Accounts) == 0 {
+			continue
+		}
+		sort.Slice(mapping.Accounts, func(i, j int) bool {
+			return mapping.Accounts[i].Username < mapping.Accounts[j].Username
+		})
+		file.Mappings = append(file.Mappings, mapping)
+	}
+	if len(file.Mappings) == 0 {
+		return ExportResult{Error: "no saved passwords to map"}
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return ExportResult{Error: fmt.Sprintf("failed to encode credential mappings: %v", err)}
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Credential Mappings",
+		DefaultFilename: "credential-mappings.json",
+		Filters:         []runtime.FileFilter{{DisplayName: "Credential Mappings (*.json)", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return ExportResult{Error: "export cancelled"}
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return ExportResult{Error: fmt.Sprintf("failed to write %s: %v", path, err)}
+	}
+	a.audit("credentials.mappings_exported", "", "", fmt.Sprintf("%d favorites", len(file.Mappings)))
+	return ExportResult{Success: true, Path: path, Count: len(file.Mappings)}
+}
+
+// ImportCredentialMappings re-links favorites to the Keychain items of an exported mapping,
+// chosen in a file dialog. Favorites are matched by ID, then by VM. A favorite without a user
+// gets the exported one, and when its VM key changed (e.g. a hostname favorite now resolves to
+// another zone) the password is copied to the account the favorite uses here.
+func (a *App) ImportCredentialMappings() (*CredentialLinkResult, error) {
+	a.countFeature("import_credential_mappings")
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Import Credential Mappings",
+		Filters: []runtime.FileFilter{{DisplayName: "Credential Mappings (*.json)", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return nil, fmt.Errorf("import cancelled")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential mappings: %w", err)
+	}
+	var file CredentialMappingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse credential mappings: %w", err)
+	}
+	if file.Version > credentialMappingVersion {
+		return nil, fmt.Errorf("credential mappings version %d is newer than this app supports", file.Version)
+	}
+	if file.Service != "" && file.Service != KeychainService {
+		return nil, fmt.Errorf("credential mappings are for Keychain service %q, not %q", file.Service, KeychainService)
+	}
+
+	favorites := a.GetFavorites()
+	byID := make(map[string]Favorite, len(favorites))
+	byKey := make(map[string]Favorite, len(favorites))
+	for _, f := range favorites {
+		byID[f.ID] = f
+		byKey[favoriteVMKey(f)] = f
+	}
+
+	result := &CredentialLinkResult{Missing: []string{}, Unmatched: []string{}}
+	for _, mapping := range file.Mappings {
+		fav, ok := byID[mapping.FavoriteID]
+		if !ok {
+			if fav, ok = byKey[mapping.favoriteVMKey()]; !ok {
+				result.Unmatched = append(result.Unmatched, mapping.DisplayName)
+				continue
+			}
+		}
+		// The favorite's own user wins over the exported one
+		username := fav.Username
+		if username == "" {
+			username = mapping.Username
+		}
+		linked := false
+		for _, acct := range mapping.Accounts {
+			if !keychainHasAccount(acct.Account) {
+				result.Missing = append(result.Missing, acct.Account)
+				continue
+			}
+			local := keychainAccountFor(fav.ProjectID, fav.Zone, fav.InstanceName, acct.Username)
+			if local != acct.Account && !keychainHasAccount(local) {
+				if err := relinkKeychainAccount(acct.Account, local); err != nil {
+					a.reportError(ErrorSourceKeychain, local, err)
+					continue
+				}
+			}
+			if username != "" && acct.Username == username {
+				linked = true
+			}
+		}
+		if !linked {
+			continue
+		}
+		if fav.Username == "" {
+			err := a.updateFavorite(fav.ID, func(f *Favorite) error {
+				f.Username = username
+				return nil
+			})
+			if err != nil {
+				return result, err
+			}
+		}
+		a.forgetCachedPassword(fav.ProjectID, fav.Zone, fav.InstanceName, username)
+		result.Linked++
+	}
+	a.audit("credentials.mappings_imported", "", "", fmt.Sprintf("%d linked, %d missing, %d unmatched", result.Linked, len(result.Missing), len(result.Unmatched)))
+	return result, nil
+}
+
+// relinkKeychainAccount copies the password of one Keychain account to another of the app
+func relinkKeychainAccount(from, to string) error {
+	output, err := runCommand("security", "find-generic-password", "-s", KeychainService, "-a", from, "-w")
+	if err != nil {
+		return fmt.Errorf("failed to read %s from Keychain: %w", from, err)
+	}
+	return saveToKeychain(KeychainService, to, strings.TrimRight(string(output), "\n"))
+}
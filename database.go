@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ConnectionTypeDatabase hands a SQL tunnel off to a local database client
+const ConnectionTypeDatabase = "database"
+
+// Database engines
+const (
+	DatabaseSQLServer = "sqlserver"
+	DatabasePostgres  = "postgres"
+	DatabaseMySQL     = "mysql"
+)
+
+// Database clients supported for hand-off
+const (
+	DatabaseClientTablePlus       = "tableplus"
+	DatabaseClientDBeaver         = "dbeaver"
+	DatabaseClientAzureDataStudio = "azuredatastudio"
+	DatabaseClientNone            = "none"
+)
+
+// databaseClientApps maps clients to their application bundles
+var databaseClientApps = map[string]string{
+	DatabaseClientTablePlus:       "/Applications/TablePlus.app",
+	DatabaseClientDBeaver:         "/Applications/DBeaver.app",
+	DatabaseClientAzureDataStudio: "/Applications/Azure Data Studio.app",
+}
+
+// DatabaseSettings holds the per-favorite database hand-off settings
+type DatabaseSettings struct {
+	Engine   string `json:"engine"`             // sqlserver, postgres or mysql
+	Database string `json:"database,omitempty"` // Initial database
+	Username string `json:"username,omitempty"` // Falls back to the favorite's username
+	Client   string `json:"client,omitempty"`   // Client opened once the tunnel is up, "none" to only start the tunnel
+}
+
+// DatabaseClient describes a locally installed database client
+type DatabaseClient struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Installed bool   `json:"installed"`
+}
+
+// validate checks the database settings
+func (s DatabaseSettings) validate() error {
+	switch s.Engine {
+	case DatabaseSQLServer, DatabasePostgres, DatabaseMySQL:
+	default:
+		return fmt.Errorf("invalid database engine: %s", s.Engine)
+	}
+	switch s.Client {
+	case "", DatabaseClientNone, DatabaseClientTablePlus, DatabaseClientDBeaver:
+	case DatabaseClientAzureDataStudio:
+		if s.Engine != DatabaseSQLServer {
+			return fmt.Errorf("Azure Data Studio only supports SQL Server")
+		}
+	default:
+		return fmt.Errorf("invalid database client: %s", s.Client)
+	}
+	return nil
+}
+
+// GetDatabaseClients returns the supported database clients and whether they are installed
+func (a *App) GetDatabaseClients() []DatabaseClient {
+	clients := []DatabaseClient{
+		{ID: DatabaseClientTablePlus, Name: "TablePlus"},
+		{ID: DatabaseClientDBeaver, Name: "DBeaver"},
+		{ID: DatabaseClientAzureDataStudio, Name: "Azure Data Studio"},
+	}
+	for i := range clients {
+		_, err := os.Stat(databaseClientApps[clients[i].ID])
+		clients[i].Installed = err == nil
+	}
+	return clients
+}
+
+// connectionType returns ConnectionTypeDatabase
+func (s DatabaseSettings) connectionType() string {
+	return ConnectionTypeDatabase
+}
+
+// apply stores the settings on a database favorite
+func (s DatabaseSettings) apply(f *Favorite) {
+	f.Database = &s
+}
+
+// AddDatabaseFavorite saves a database connection to a VM's SQL port
+func (a *App) AddDatabaseFavorite(displayName, projectID, projectName, instanceName, zone string, remotePort int, settings DatabaseSettings) (*Favorite, error) {
+	return a.addConnectionFavorite(displayName, projectID, projectName, instanceName, zone, remotePort, settings)
+}
+
+// UpdateDatabaseSettings changes a database favorite's hand-off settings
+func (a *App) UpdateDatabaseSettings(favoriteID string, settings DatabaseSettings) error {
+	return a.updateConnectionSettings(favoriteID, settings)
+}
+
+// OpenDatabaseClient hands a database favorite's running tunnel off to a client
+func (a *App) OpenDatabaseClient(favoriteID, client string) ConnectResult {
+	a.countFeature("database_client")
+	fav := a.GetConnectionInfo(favoriteID)
+	if fav == nil {
+		return ConnectResult{Error: "Connection not found"}
+	}
+	if fav.Database == nil {
+		return ConnectResult{Error: "Connection is not a database connection"}
+	}
+	tunnel := a.findActiveTunnel(fav)
+	if tunnel == nil {
+		return ConnectResult{Error: "No running tunnel for this connection"}
+	}
+
+	settings := *fav.Database
+	settings.Client = client
+	if err := settings.validate(); err != nil {
+		return ConnectResult{Tunnel: tunnel, Error: err.Error()}
+	}
+	if err := launchDatabaseClient(settings, databaseUsername(fav), tunnel.LocalPort); err != nil {
+		return ConnectResult{Tunnel: tunnel, Error: err.Error()}
+	}
+	return ConnectResult{Success: true, Tunnel: tunnel}
+}
+
+// openDatabaseForTunnel waits for the SQL port and opens the configured client
+func (a *App) openDatabaseForTunnel(fav *Favorite, tunnel *TunnelInfo) ConnectResult {
+	if !a.waitForTunnelRunning(tunnel.ID, 2*time.Second) {
+		return a.connectError(tunnel, MsgTunnelStartTimeout)
+	}
+	if probe := a.ProbeTunnel(tunnel.ID); !probe.Reachable {
+		return ConnectResult{Tunnel: tunnel, Error: probe.Error, Code: probe.Code, RetryAfter: probe.RetryAfter}
+	}
+
+	if fav.Database != nil && fav.Database.Client != "" && fav.Database.Client != DatabaseClientNone {
+		if err := launchDatabaseClient(*fav.Database, databaseUsername(fav), tunnel.LocalPort); err != nil {
+			return ConnectResult{Tunnel: tunnel, Error: fmt.Sprintf("Tunnel started but %v", err)}
+		}
+	}
+
+	if info, err := a.GetTunnel(tunnel.ID); err == nil {
+		tunnel = info
+	}
+	return ConnectResult{Success: true, Tunnel: tunnel}
+}
+
+// databaseUsername returns the username to prefill in the database client
+func databaseUsername(fav *Favorite) string {
+	if fav.Database != nil && fav.Database.Username != "" {
+		return fav.Database.Username
+	}
+	return fav.Username
+}
+
+// launchDatabaseClient opens a client pre-filled with localhost:<port>, the username and database
+func launchDatabaseClient(settings DatabaseSettings, username string, localPort int) error {
+	appPath, ok := databaseClientApps[settings.Client]
+	if !ok {
+		return fmt.Errorf("unsupported database client: %s", settings.Client)
+	}
+	if _, err := os.Stat(appPath); err != nil {
+		return fmt.Errorf("%s is not installed", strings.TrimSuffix(filepath.Base(appPath), ".app"))
+	}
+
+	var args []string
+	switch settings.Client {
+	case DatabaseClientTablePlus:
+		args = []string{"-a", appPath, databaseURL(settings, username, localPort)}
+	case DatabaseClientDBeaver:
+		// DBeaver keeps running, it isn't a helper command
+		cmd := exec.Command(appPath+"/Contents/MacOS/dbeaver", "-con", dbeaverConnectionSpec(settings, username, localPort))
+		return cmd.Start()
+	case DatabaseClientAzureDataStudio:
+		q := url.Values{}
+		q.Set("server", fmt.Sprintf("localhost,%d", localPort))
+		q.Set("authenticationType", "SqlLogin")
+		if username != "" {
+			q.Set("user", username)
+		}
+		if settings.Database != "" {
+			q.Set("database", settings.Database)
+		}
+		args = []string{"azuredatastudio://connect?" + q.Encode()}
+	}
+	if _, err := runCommand("open", args...); err != nil {
+		return fmt.Errorf("failed to open database client: %w", err)
+	}
+	return nil
+}
+
+// databaseURL returns a connection URL such as postgresql://user@localhost:5432/db
+func databaseURL(settings DatabaseSettings, username string, localPort int) string {
+	scheme := map[string]string{
+		DatabaseSQLServer: "sqlserver",
+		DatabasePostgres:  "postgresql",
+		DatabaseMySQL:     "mysql",
+	}[settings.Engine]
+	u := url.URL{Scheme: scheme, Host: fmt.Sprintf("localhost:%d", localPort)}
+	if username != "" {
+		u.User = url.User(username)
+	}
+	if settings.Database != "" {
+		u.Path = "/" + settings.Database
+	}
+	return u.String()
+}
+
+// dbeaverConnectionSpec returns a DBeaver -con argument for the tunnel
+func dbeaverConnectionSpec(settings DatabaseSettings, username string, localPort int) string {
+	driver := map[string]string{
+		DatabaseSQLServer: "sqlserver",
+		DatabasePostgres:  "postgresql",
+		DatabaseMySQL:     "mysql",
+	}[settings.Engine]
+	parts := []string{
+		"driver=" + driver,
+		"host=localhost",
+		fmt.Sprintf("port=%d", localPort),
+		"name=" + fmt.Sprintf("IAP localhost:%d", localPort),
+		"openConsole=false",
+		"connect=true",
+	}
+	if username != "" {
+		parts = append(parts, "user="+username)
+	}
+	if settings.Database != "" {
+		parts = append(parts, "database="+settings.Database)
+	}
+	return strings.Join(parts, "|")
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxConcurrentDials is how many IAP dials run at once when the setting is 0
+	defaultMaxConcurrentDials = 8
+	// MaxConcurrentDialsLimit is the largest accepted MaxConcurrentDials setting
+	MaxConcurrentDialsLimit = 64
+	// maxQueuedDials is how many dials may wait for a slot before new ones fail right away
+	maxQueuedDials = 64
+)
+
+// errDialQueueFull is returned when too many IAP dials are already waiting
+var errDialQueueFull = errors.New("too many IAP connections are waiting, try again shortly")
+
+// DialQueueStats reports how IAP dials waited for a slot since launch
+type DialQueueStats struct {
+	Limit   int `json:"limit"`
+	Active  int `json:"active"`  // Dials in progress
+	Waiting int `json:"waiting"` // Dials queued for a slot
+	// Dials counts every dial, Queued the ones that got a slot after waiting and Rejected the
+	// ones that found the queue full
+	Dials    int64 `json:"dials"`
+	Queued   int64 `json:"queued"`
+	Rejected int64 `json:"rejected"`
+	// AvgWaitMs and MaxWaitMs are the wait of queued dials
+	AvgWaitMs int64 `json:"avgWaitMs"`
+	MaxWaitMs int64 `json:"maxWaitMs"`
+}
+
+// dialLimiter bounds concurrent IAP dials, so a burst of reconnects doesn't trip API rate
+// limits. Waiting dials are queued per target and slots go round-robin across targets, so a
+// tunnel with many reconnecting clients can't starve the others.
+type dialLimiter struct {
+	mu     sync.Mutex
+	active int
+	queues map[string][]*dialWaiter // By target
+	order  []string                 // Targets with waiting dials, next to serve first
+	queued int
+
+	dials     int64
+	waits     int64
+	rejected  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// dialWaiter is a dial queued for a slot
+type dialWaiter struct {
+	ready   chan struct{}
+	granted bool // Guarded by dialLimiter.mu
+}
+
+// maxConcurrentDials returns the configured dial limit
+func (a *App) maxConcurrentDials() int {
+	if n := a.GetSettings().Advanced.MaxConcurrentDials; n > 0 {
+		return n
+	}
+	return defaultMaxConcurrentDials
+}
+
+// acquire waits for a dial slot for target. release must be called once the dial finished.
+func (l *dialLimiter) acquire(ctx context.Context, target string, limit int) error {
+	l.mu.Lock()
+	l.dials++
+	if l.active < limit && l.queued == 0 {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.queued >= maxQueuedDials {
+		l.rejected++
+		l.mu.Unlock()
+		return errDialQueueFull
+	}
+	w := &dialWaiter{ready: make(chan struct{})}
+	if l.queues == nil {
+		l.queues = make(map[string][]*dialWaiter)
+	}
+	if len(l.queues[target]) == 0 {
+		l.order = append(l.order, target)
+	}
+	l.queues[target] = append(l.queues[target], w)
+	l.queued++
+	l.mu.Unlock()
+
+	started := time.Now()
+	select {
+	case <-w.ready:
+		l.recordWait(time.Since(started))
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if w.granted {
+			// The slot arrived just as the dial was canceled, pass it on
+			l.mu.Unlock()
+			l.release(limit)
+			return ctx.Err()
+		}
+		l.removeLocked(target, w)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// release frees a dial slot and hands it to the next target in line
+func (l *dialLimiter) release(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	for l.active < limit && len(l.order) > 0 {
+		target := l.order[0]
+		l.order = l.order[1:]
+		queue := l.queues[target]
+		w := queue[0]
+		if len(queue) > 1 {
+			l.queues[target] = queue[1:]
+			l.order = append(l.order, target)
+		} else {
+			delete(l.queues, target)
+		}
+		l.queued--
+		l.active++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// removeLocked drops a canceled waiter from its target's queue
+func (l *dialLimiter) removeLocked(target string, w *dialWaiter) {
+	queue := l.queues[target]
+	for i, queuedWaiter := range queue {
+		if queuedWaiter == w {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			l.queued--
+			break
+		}
+	}
+	if len(queue) > 0 {
+		l.queues[target] = queue
+		return
+	}
+	delete(l.queues, target)
+	for i, t := range l.order {
+		if t == target {
+			l.order = append(l.order[:i:i], l.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// recordWait adds the wait of a queued dial to the stats
+func (l *dialLimiter) recordWait(wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.waits++
+	l.totalWait += wait
+	if wait > l.maxWait {
+		l.maxWait = wait
+	}
+}
+
+// GetDialQueueStats returns how IAP dials were limited and queued since launch
+func (a *App) GetDialQueueStats() DialQueueStats {
+	limit := a.maxConcurrentDials()
+	l := &a.dialLimit
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := DialQueueStats{
+		Limit:     limit,
+		Active:    l.active,
+		Waiting:   l.queued,
+		Dials:     l.dials,
+		Queued:    l.waits,
+		Rejected:  l.rejected,
+		MaxWaitMs: l.maxWait.Milliseconds(),
+	}
+	if l.waits > 0 {
+		stats.AvgWaitMs = (l.totalWait / time.Duration(l.waits)).Milliseconds()
+	}
+	return stats
+}
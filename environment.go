@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Favorite environments
+const (
+	EnvironmentProd    = "prod"
+	EnvironmentStaging = "staging"
+	EnvironmentDev     = "dev"
+
+	// productionConfirmWindow is how long a production confirmation allows connecting
+	productionConfirmWindow = 2 * time.Minute
+)
+
+// EnvironmentInfo describes an environment for the frontend
+type EnvironmentInfo struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Color string `json:"color"` // CSS color for badges and tunnel rows
+}
+
+// environments lists the environments in display order
+var environments = []EnvironmentInfo{
+	{ID: EnvironmentProd, Label: "Production", Color: "#d32f2f"},
+	{ID: EnvironmentStaging, Label: "Staging", Color: "#f9a825"},
+	{ID: EnvironmentDev, Label: "Development", Color: "#388e3c"},
+}
+
+// ProductionSafeguards apply to favorites in the prod environment
+type ProductionSafeguards struct {
+	// RequireConfirmation makes connecting wait for ConfirmProductionConnect
+	RequireConfirmation bool `json:"requireConfirmation,omitempty"`
+	// MaxDurationMinutes caps how long production tunnels stay open, 0 disables
+	MaxDurationMinutes int `json:"maxDurationMinutes,omitempty"`
+}
+
+// productionConfirmations holds when production favorites were confirmed, by favorite ID
+type productionConfirmations struct {
+	mu sync.Mutex
+	at map[string]time.Time
+}
+
+// GetEnvironments returns the environments a favorite can be classified as, with their colors
+func (a *App) GetEnvironments() []EnvironmentInfo {
+	return environments
+}
+
+// validateEnvironment checks an environment ID, empty means unclassified
+func validateEnvironment(env string) error {
+	if env == "" {
+		return nil
+	}
+	for _, e := range environments {
+		if e.ID == env {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid environment: %s", env)
+}
+
+// environmentColor returns the color of an environment, empty when unclassified
+func environmentColor(env string) string {
+	for _, e := range environments {
+		if e.ID == env {
+			return e.Color
+		}
+	}
+	return ""
+}
+
+// ConfirmProductionConnect confirms connecting to a production favorite. The confirmation is
+// valid for two minutes, after that ConnectFavorite asks again.
+func (a *App) ConfirmProductionConnect(favoriteID string) error {
+	fav := a.GetConnectionInfo(favoriteID)
+	if fav == nil {
+		return fmt.Errorf("connection not found")
+	}
+	if !a.isAgent {
+		target := auditTarget(fav.ProjectID, fav.Zone, fav.InstanceName)
+		a.audit("prod.confirmed", fav.ID, target, "Production connection confirmed")
+	}
+
+	a.prodConfirmations.mu.Lock()
+	if a.prodConfirmations.at == nil {
+		a.prodConfirmations.at = make(map[string]time.Time)
+	}
+	a.prodConfirmations.at[favoriteID] = time.Now()
+	a.prodConfirmations.mu.Unlock()
+
+	// The agent checks the confirmation again when it starts the tunnel
+	if a.agentAttached() {
+		return a.agentCall("ConfirmProductionConnect", nil, favoriteID)
+	}
+	return nil
+}
+
+// needsProductionConfirmation reports whether connecting to the favorite must be confirmed first
+func (a *App) needsProductionConfirmation(fav *Favorite) bool {
+	if fav.Environment != EnvironmentProd || !a.GetSettings().Production.RequireConfirmation {
+		return false
+	}
+
+	a.prodConfirmations.mu.Lock()
+	defer a.prodConfirmations.mu.Unlock()
+	confirmedAt, ok := a.prodConfirmations.at[fav.ID]
+	return !ok || time.Since(confirmedAt) > productionConfirmWindow
+}
+
+// checkProductionConfirmation refuses to connect to a production favorite that wasn't confirmed.
+// fav may be nil for VMs without a favorite.
+func (a *App) checkProductionConfirmation(fav *Favorite) error {
+	if fav != nil && a.needsProductionConfirmation(fav) {
+		return fmt.Errorf("connecting to production VM %s must be confirmed first", favoriteLabel(*fav))
+	}
+	return nil
+}
+
+// endpointFavorite returns the favorite whose safeguards apply to a tunnel: the one the endpoint
+// was resolved for, or for tunnels started without one (the VM list, restored sessions, ssh) a
+// favorite of the VM, preferably with the same ports
+func (a *App) endpointFavorite(ep *targetEndpoint, localPort, remotePort int) *Favorite {
+	if ep.favorite != nil {
+		return ep.favorite
+	}
+	if !ep.isInstance() {
+		return nil
+	}
+	if fav := a.favoriteFor(ep.ProjectID, ep.Name, ep.Zone, remotePort, localPort); fav != nil {
+		return fav
+	}
+	return a.favoriteFor(ep.ProjectID, ep.Name, ep.Zone, 0, 0)
+}
+
+// maxDurationFor returns the favorite's maximum tunnel duration, capped for production
+func (a *App) maxDurationFor(fav *Favorite) int {
+	minutes := fav.MaxDurationMinutes
+	if fav.Environment != EnvironmentProd {
+		return minutes
+	}
+	limit := a.GetSettings().Production.MaxDurationMinutes
+	if limit > 0 && (minutes == 0 || minutes > limit) {
+		return limit
+	}
+	return minutes
+}
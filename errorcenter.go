@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrorReportedEvent is emitted with a RecentError when an error is recorded, at most once per
+// errorEmitInterval for a repeating one
+const ErrorReportedEvent = "errors:reported"
+
+// Subsystems errors are recorded for
+const (
+	ErrorSourceAuth     = "auth"
+	ErrorSourceAPI      = "api"
+	ErrorSourceTunnel   = "tunnel"
+	ErrorSourceBookmark = "bookmark"
+	ErrorSourceKeychain = "keychain"
+)
+
+const (
+	// maxRecentErrors is how many distinct errors are kept, the oldest is dropped first
+	maxRecentErrors = 100
+	// errorEmitInterval throttles events for an error that keeps repeating, e.g. every
+	// connection of a tunnel whose VM is down
+	errorEmitInterval = 5 * time.Second
+)
+
+// RecentError is an error recorded by the errors center. Repeats of the same message from the
+// same source and target are counted instead of listed again.
+type RecentError struct {
+	ID      string `json:"id"`
+	Source  string `json:"source"`           // One of the ErrorSource constants
+	Target  string `json:"target,omitempty"` // What failed, e.g. a project, tunnel or favorite
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+	FirstAt string `json:"firstAt"` // RFC3339
+	LastAt  string `json:"lastAt"`  // RFC3339
+}
+
+// errorCenter keeps the last errors of the subsystems, in memory only
+type errorCenter struct {
+	mu      sync.Mutex
+	entries []*recentErrorEntry
+	nextID  int
+}
+
+type recentErrorEntry struct {
+	RecentError
+	lastEmit time.Time
+}
+
+// reportError records an error of a subsystem and tells the frontend. A nil err is ignored.
+func (a *App) reportError(source, target string, err error) {
+	if err == nil {
+		return
+	}
+	message := redact(err.Error())
+	now := time.Now()
+
+	c := &a.recentErrors
+	c.mu.Lock()
+	var entry *recentErrorEntry
+	for _, e := range c.entries {
+		if e.Source == source && e.Target == target && e.Message == message {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		c.nextID++
+		entry = &recentErrorEntry{RecentError: RecentError{
+			ID:      fmt.Sprintf("err-%d", c.nextID),
+			Source:  source,
+			Target:  target,
+			Message: message,
+			FirstAt: now.Format(time.RFC3339),
+		}}
+		c.entries = append(c.entries, entry)
+		if len(c.entries) > maxRecentErrors {
+			c.entries = c.entries[len(c.entries)-maxRecentErrors:]
+		}
+	}
+	entry.Count++
+	entry.LastAt = now.Format(time.RFC3339)
+	emit := now.Sub(entry.lastEmit) >= errorEmitInterval
+	if emit {
+		entry.lastEmit = now
+	}
+	snapshot := entry.RecentError
+	c.mu.Unlock()
+
+	if emit {
+		a.emitEvent(ErrorReportedEvent, snapshot)
+	}
+}
+
+// GetRecentErrors returns the recorded errors, the most recent first
+func (a *App) GetRecentErrors() []RecentError {
+	c := &a.recentErrors
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	errs := make([]RecentError, 0, len(c.entries))
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		errs = append(errs, c.entries[i].RecentError)
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].LastAt > errs[j].LastAt
+	})
+	return errs
+}
+
+// ClearRecentErrors forgets the recorded errors and returns how many there were
+func (a *App) ClearRecentErrors() int {
+	c := &a.recentErrors
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.entries)
+	c.entries = nil
+	return n
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// TunnelExpiryWarningEvent is emitted shortly before a tunnel is stopped automatically
+	TunnelExpiryWarningEvent = "tunnel:expiry-warning"
+	// TunnelExpiredEvent is emitted when a tunnel was stopped because it reached its maximum duration
+	TunnelExpiredEvent = "tunnel:expired"
+
+	// tunnelExpiryWarning is how long before expiry the warning is sent
+	tunnelExpiryWarning = 5 * time.Minute
+)
+
+// TunnelExpiryNotice is the payload of the tunnel expiry events
+type TunnelExpiryNotice struct {
+	TunnelID  string `json:"tunnelId"`
+	VMName    string `json:"vmName"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// SetTunnelMaxDuration stops the tunnel automatically after the given number of minutes
+// from now, with a warning 5 minutes before. Zero removes the limit.
+func (a *App) SetTunnelMaxDuration(tunnelID string, minutes int) error {
+	if minutes < 0 {
+		return fmt.Errorf("duration must not be negative")
+	}
+
+	a.tunnelsMu.RLock()
+	_, local := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+	if !local && a.agentAttached() {
+		return a.agentCall("SetTunnelMaxDuration", nil, tunnelID, minutes)
+	}
+
+	a.tunnelsMu.Lock()
+	defer a.tunnelsMu.Unlock()
+
+	tunnel, ok := a.tunnels[tunnelID]
+	if !ok {
+		return fmt.Errorf("tunnel not found")
+	}
+	if tunnel.Status != "running" && tunnel.Status != "starting" {
+		return fmt.Errorf("tunnel is not active")
+	}
+
+	tunnel.stopExpiryTimers()
+	if minutes == 0 {
+		tunnel.ExpiresAt = time.Time{}
+		tunnel.addLog("Automatic stop disabled")
+		return nil
+	}
+
+	duration := time.Duration(minutes) * time.Minute
+	tunnel.ExpiresAt = time.Now().Add(duration)
+	notice := TunnelExpiryNotice{
+		TunnelID:  tunnel.ID,
+		VMName:    tunnel.VMName,
+		ExpiresAt: tunnel.ExpiresAt.Format(time.RFC3339),
+	}
+
+	if duration > tunnelExpiryWarning {
+		tunnel.expiryTimers = append(tunnel.expiryTimers, time.AfterFunc(duration-tunnelExpiryWarning, func() {
+			tunnel.addLog("Tunnel will stop automatically in 5 minutes")
+			a.emitEvent(TunnelExpiryWarningEvent, notice)
+			a.notify(a.tr(MsgNotifyExpiryWarning, tunnel.VMName))
+		}))
+	}
+	tunnel.expiryTimers = append(tunnel.expiryTimers, time.AfterFunc(duration, func() {
+		tunnel.addLog("Maximum duration reached, stopping tunnel")
+		if err := a.StopTunnel(tunnel.ID); err != nil {
+			return
+		}
+		a.emitEvent(TunnelExpiredEvent, notice)
+		a.notify(a.tr(MsgNotifyExpired, tunnel.VMName))
+	}))
+
+	tunnel.addLog(fmt.Sprintf("Tunnel will stop automatically at %s", tunnel.ExpiresAt.Format("15:04")))
+	return nil
+}
+
+// stopExpiryTimers cancels pending expiry timers (caller must hold tunnelsMu)
+func (t *Tunnel) stopExpiryTimers() {
+	for _, timer := range t.expiryTimers {
+		timer.Stop()
+	}
+	t.expiryTimers = nil
+}
+
+// emitEvent sends an event to the frontend once the app has started
+func (a *App) emitEvent(name string, data ...interface{}) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, name, data...)
+	}
+}
+
+// postNotification shows a macOS notification so warnings are seen while the app is in the background
+func postNotification(title, message string) {
+	// Pass values as arguments so they never need AppleScript escaping
+	runCommand("osascript",
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		title, message,
+	)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ExportResult represents the outcome of exporting favorites to another client
+type ExportResult struct {
+	Success bool   `json:"success"`
+	Path    string `json:"path,omitempty"`
+	Count   int    `json:"count"`
+	Error   string `json:"error,omitempty"`
+}
+
+// royalDocument is a Royal JSON (rJSON) document, importable by Royal TSX and Royal TS
+type royalDocument struct {
+	Name    string        `json:"Name"`
+	Objects []royalObject `json:"Objects"`
+}
+
+// royalObject is a Royal JSON remote desktop connection
+type royalObject struct {
+	Type                  string `json:"Type"`
+	Name                  string `json:"Name"`
+	ComputerName          string `json:"ComputerName"`
+	Port                  int    `json:"Port"`
+	Username              string `json:"Username,omitempty"`
+	Password              string `json:"Password,omitempty"`
+	Description           string `json:"Description,omitempty"`
+	Path                  string `json:"Path,omitempty"` // Folder inside the document
+	CredentialsFromParent bool   `json:"CredentialsFromParent"`
+}
+
+// ExportRoyalTSX writes the favorites (all when none are given) as a Royal JSON file pointed at
+// their localhost ports. Passwords from Keychain are included only when includePasswords is set.
+// Royal's .rtsz document format is not published, Royal TSX imports rJSON via File > Import.
+func (a *App) ExportRoyalTSX(connectionIDs []string, includePasswords bool) ExportResult {
+	a.countFeature("export_royal_tsx")
+	favorites := a.exportFavorites(connectionIDs)
+	if len(favorites) == 0 {
+		return ExportResult{Error: "no connections to export"}
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export to Royal TSX",
+		DefaultFilename: "IAP Tunnels.json",
+		Filters:         []runtime.FileFilter{{DisplayName: "Royal JSON (*.json)", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return ExportResult{Error: "export cancelled"}
+	}
+
+	doc := royalDocument{Name: AppName, Objects: make([]royalObject, 0, len(favorites))}
+	for _, f := range favorites {
+		obj := royalObject{
+			Type:         "RemoteDesktopConnection",
+			Name:         favoriteLabel(f),
+			ComputerName: "localhost",
+			Port:         f.LocalPort,
+			Username:     f.Username,
+			Description:  fmt.Sprintf("IAP tunnel to %s/%s (%s)", f.ProjectID, f.InstanceName, f.Zone),
+			Path:         f.ProjectID,
+		}
+		if includePasswords && f.Username != "" {
+			if password, err := a.GetPasswordFromKeychain(f.ProjectID, f.Zone, f.InstanceName, f.Username); err == nil {
+				obj.Password = strings.TrimRight(password, "\r\n")
+			}
+		}
+		doc.Objects = append(doc.Objects, obj)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return ExportResult{Error: fmt.Sprintf("failed to encode export: %v", err)}
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return ExportResult{Error: fmt.Sprintf("failed to write export: %v", err)}
+	}
+	return ExportResult{Success: true, Path: path, Count: len(doc.Objects)}
+}
+
+// ExportJumpDesktop writes one .rdp file per favorite into a chosen folder for Jump Desktop's
+// importer. Jump Desktop keeps passwords in its own keychain, so only usernames are exported.
+func (a *App) ExportJumpDesktop(connectionIDs []string) ExportResult {
+	a.countFeature("export_jump_desktop")
+	favorites := a.exportFavorites(connectionIDs)
+	if len(favorites) == 0 {
+		return ExportResult{Error: "no connections to export"}
+	}
+
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                "Export to Jump Desktop",
+		CanCreateDirectories: true,
+	})
+	if err != nil || dir == "" {
+		return ExportResult{Error: "export cancelled"}
+	}
+
+	count := 0
+	used := make(map[string]bool)
+	for _, f := range favorites {
+		name := exportFileName(favoriteLabel(f))
+		if used[name] {
+			name = name + "-" + f.ID
+		}
+		used[name] = true
+
+		path := filepath.Join(dir, name+".rdp")
+		if err := os.WriteFile(path, rdpFileContent(f, f.LocalPort), 0600); err != nil {
+			return ExportResult{Path: dir, Count: count, Error: fmt.Sprintf("failed to write %s: %v", path, err)}
+		}
+		count++
+	}
+	return ExportResult{Success: true, Path: dir, Count: count}
+}
+
+// ExportRDPFile saves a favorite as an .rdp file for RDP clients other than Windows App
+func (a *App) ExportRDPFile(connectionID string) ExportResult {
+	a.countFeature("export_rdp_file")
+	favorites := a.exportFavorites([]string{connectionID})
+	if len(favorites) == 0 {
+		return ExportResult{Error: "connection not found"}
+	}
+	f := favorites[0]
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export .rdp File",
+		DefaultFilename: exportFileName(favoriteLabel(f)) + ".rdp",
+		Filters:         []runtime.FileFilter{{DisplayName: "Remote Desktop File (*.rdp)", Pattern: "*.rdp"}},
+	})
+	if err != nil || path == "" {
+		return ExportResult{Error: "export cancelled"}
+	}
+	if err := os.WriteFile(path, rdpFileContent(f, f.LocalPort), 0600); err != nil {
+		return ExportResult{Error: fmt.Sprintf("failed to write %s: %v", path, err)}
+	}
+	return ExportResult{Success: true, Path: path, Count: 1}
+}
+
+// rdpFileContent returns an .rdp file pointing at a favorite's tunnel port. Passwords are never written.
+func rdpFileContent(f Favorite, localPort int) []byte {
+	lines := []string{
+		fmt.Sprintf("full address:s:localhost:%d", localPort),
+		"autoreconnection enabled:i:1",
+	}
+	if f.Username != "" {
+		lines = append(lines, "username:s:"+f.Username)
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// exportFavorites returns the RDP favorites to export, all when no IDs are given
+func (a *App) exportFavorites(connectionIDs []string) []Favorite {
+	wanted := make(map[string]bool, len(connectionIDs))
+	for _, id := range connectionIDs {
+		wanted[id] = true
+	}
+
+	var favorites []Favorite
+	for _, f := range a.GetFavorites() {
+		if len(wanted) > 0 && !wanted[f.ID] {
+			continue
+		}
+		if f.ConnectionType != "" && f.ConnectionType != ConnectionTypeRDP {
+			continue
+		}
+		favorites = append(favorites, f)
+	}
+	return favorites
+}
+
+// exportFileName makes a display name safe to use as a file name
+func exportFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "connection"
+	}
+	return name
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// externalStopTimeout bounds waiting for a gcloud tunnel to release its port after SIGTERM
+const externalStopTimeout = 5 * time.Second
+
+// ExternalTunnel is a gcloud compute start-iap-tunnel process running outside the app
+type ExternalTunnel struct {
+	PID          int    `json:"pid"`
+	ProjectID    string `json:"projectId,omitempty"`
+	InstanceName string `json:"instanceName"`
+	Zone         string `json:"zone,omitempty"`
+	RemotePort   int    `json:"remotePort"`
+	LocalHost    string `json:"localHost,omitempty"`
+	LocalPort    int    `json:"localPort,omitempty"` // 0 while gcloud hasn't bound its port yet
+	// FavoriteID is the saved connection for the same VM and remote port, if any
+	FavoriteID string `json:"favoriteId,omitempty"`
+	// Adoptable is set when project, zone and local port are known, so the app can run the tunnel
+	Adoptable bool `json:"adoptable"`
+}
+
+// ListExternalTunnels finds IAP tunnels started with gcloud outside the app. Project and zone fall
+// back to the gcloud configuration when the command line doesn't set them.
+func (a *App) ListExternalTunnels() ([]ExternalTunnel, error) {
+	output, err := runCommand("ps", "-axo", "pid=,command=")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	var tunnels []ExternalTunnel
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if t, ok := parseStartIAPTunnel(fields[1:]); ok {
+			t.PID = pid
+			tunnels = append(tunnels, t)
+		}
+	}
+	if len(tunnels) == 0 {
+		return []ExternalTunnel{}, nil
+	}
+
+	listening := listeningPorts(tunnels)
+	var defaultProject, defaultZone string
+	defaultsRead := false
+	for i := range tunnels {
+		t := &tunnels[i]
+		if t.LocalPort == 0 {
+			t.LocalPort = listening[t.PID]
+		}
+		if t.ProjectID == "" || t.Zone == "" {
+			if !defaultsRead {
+				defaultProject, defaultZone = a.gcloudDefaults()
+				defaultsRead = true
+			}
+			if t.ProjectID == "" {
+				t.ProjectID = defaultProject
+			}
+			if t.Zone == "" {
+				t.Zone = defaultZone
+			}
+		}
+		if fav := a.favoriteFor(t.ProjectID, t.InstanceName, t.Zone, t.RemotePort, t.LocalPort); fav != nil {
+			t.FavoriteID = fav.ID
+		}
+		t.Adoptable = t.ProjectID != "" && t.Zone != "" && t.LocalPort != 0
+	}
+
+	sort.Slice(tunnels, func(i, j int) bool { return tunnels[i].PID < tunnels[j].PID })
+	return tunnels, nil
+}
+
+// TakeOverExternalTunnel stops a gcloud tunnel and starts the same tunnel in the app, on the same
+// local port so clients can reconnect without changes
+func (a *App) TakeOverExternalTunnel(pid int) (*TunnelInfo, error) {
+	t, err := a.externalTunnel(pid)
+	if err != nil {
+		return nil, err
+	}
+	if !t.Adoptable {
+		return nil, fmt.Errorf("the gcloud tunnel doesn't name its project, zone or local port, save it as a connection instead")
+	}
+	// Ask before stopping gcloud, the new tunnel would be refused
+	fav := a.GetConnectionInfo(t.FavoriteID)
+	if fav == nil {
+		fav = a.endpointFavorite(a.instanceEndpoint(t.ProjectID, t.InstanceName, t.Zone), t.LocalPort, t.RemotePort)
+	}
+	if err := a.checkProductionConfirmation(fav); err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return nil, fmt.Errorf("failed to stop gcloud tunnel: %w", err)
+	}
+	deadline := time.Now().Add(externalStopTimeout)
+	for processAlive(pid) || a.isPortInUse(t.LocalPort) {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("gcloud tunnel %d did not release port %d", pid, t.LocalPort)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	info, err := a.StartTunnelWithRemotePort(t.ProjectID, t.InstanceName, t.Zone, t.LocalPort, t.RemotePort)
+	if err != nil {
+		return nil, err
+	}
+	a.audit("tunnel.adopted", t.FavoriteID, auditTarget(t.ProjectID, t.Zone, t.InstanceName),
+		fmt.Sprintf("Took over gcloud tunnel %d on port %d", pid, t.LocalPort))
+	return info, nil
+}
+
+// SaveExternalTunnel saves a gcloud tunnel as a connection, leaving the process running
+func (a *App) SaveExternalTunnel(pid int) (*Favorite, error) {
+	t, err := a.externalTunnel(pid)
+	if err != nil {
+		return nil, err
+	}
+	if t.ProjectID == "" || t.Zone == "" {
+		return nil, fmt.Errorf("the gcloud tunnel doesn't name its project or zone")
+	}
+	if t.FavoriteID != "" {
+		return a.GetConnectionInfo(t.FavoriteID), nil
+	}
+	return a.AddFavorite(t.InstanceName, t.ProjectID, t.ProjectID, t.InstanceName, t.Zone, t.RemotePort, t.LocalPort)
+}
+
+// externalTunnel looks up a gcloud tunnel by PID, so only gcloud processes can be stopped
+func (a *App) externalTunnel(pid int) (*ExternalTunnel, error) {
+	tunnels, err := a.ListExternalTunnels()
+	if err != nil {
+		return nil, err
+	}
+	for i := range tunnels {
+		if tunnels[i].PID == pid {
+			return &tunnels[i], nil
+		}
+	}
+	return nil, fmt.Errorf("gcloud tunnel not found")
+}
+
+// parseStartIAPTunnel reads the target of a gcloud compute start-iap-tunnel command line
+func parseStartIAPTunnel(args []string) (ExternalTunnel, bool) {
+	start := -1
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "compute" && args[i+1] == "start-iap-tunnel" {
+			start = i + 2
+			break
+		}
+	}
+	if start == -1 {
+		return ExternalTunnel{}, false
+	}
+
+	var t ExternalTunnel
+	var positional []string
+	for i := start; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		switch name {
+		case "zone", "project", "local-host-port":
+		default:
+			// Other flags either take their value inline or are booleans
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "zone":
+			t.Zone = value
+		case "project":
+			t.ProjectID = value
+		case "local-host-port":
+			host, port, err := net.SplitHostPort(value)
+			if err == nil {
+				t.LocalHost = host
+				t.LocalPort, _ = strconv.Atoi(port)
+			}
+		}
+	}
+	if len(positional) < 2 {
+		return ExternalTunnel{}, false
+	}
+	remotePort, err := strconv.Atoi(positional[1])
+	if err != nil {
+		return ExternalTunnel{}, false
+	}
+	t.InstanceName = positional[0]
+	t.RemotePort = remotePort
+	return t, true
+}
+
+// listeningPorts returns the first TCP port each tunnel process listens on
+func listeningPorts(tunnels []ExternalTunnel) map[int]int {
+	pids := make([]string, 0, len(tunnels))
+	for _, t := range tunnels {
+		pids = append(pids, strconv.Itoa(t.PID))
+	}
+	ports := make(map[int]int)
+	// lsof exits with status 1 when nothing matches, the output is still usable
+	output, _ := runCommand("lsof", "-nP", "-a", "-p", strings.Join(pids, ","), "-iTCP", "-sTCP:LISTEN", "-Fpn")
+	pid := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if len(line) < 2 {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			pid, _ = strconv.Atoi(line[1:])
+		case 'n':
+			if _, ok := ports[pid]; ok {
+				continue
+			}
+			if i := strings.LastIndex(line, ":"); i != -1 {
+				if port, err := strconv.Atoi(line[i+1:]); err == nil {
+					ports[pid] = port
+				}
+			}
+		}
+	}
+	return ports
+}
+
+// gcloudDefaults returns the project and zone of the active gcloud configuration
+func (a *App) gcloudDefaults() (project, zone string) {
+	info := a.FindGcloud()
+	if !info.Found {
+		return "", ""
+	}
+	get := func(key string) string {
+		output, err := runCommandSpec(commandSpec{name: info.Path, args: []string{"config", "get-value", key}})
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(output))
+	}
+	return get("core/project"), get("compute/zone")
+}
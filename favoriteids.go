@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+)
+
+// favoriteIDPattern matches the UUIDs favorites are identified by
+var favoriteIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// legacyFavoriteIDNamespace is hashed with the ID of a favorite saved by an older version to
+// derive its UUID
+const legacyFavoriteIDNamespace = "go-iap:favorite:"
+
+// newFavoriteID returns a random (version 4) UUID for a new favorite. Unlike the bookmark ID it
+// doesn't depend on the VM, so a renamed VM keeps its favorite and one VM can have several.
+func newFavoriteID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate favorite ID: %w", err)
+	}
+	return formatUUID(b, 4), nil
+}
+
+// migratedFavoriteID derives a name-based (version 5 style) UUID from the ID an older version
+// gave a favorite, so every Mac migrating the same synced favorite arrives at the same UUID
+func migratedFavoriteID(legacyID string) string {
+	sum := sha1.Sum([]byte(legacyFavoriteIDNamespace + legacyID))
+	var b [16]byte
+	copy(b[:], sum[:16])
+	return formatUUID(b, 5)
+}
+
+// canonicalFavoriteID returns the UUID a favorite ID refers to. IDs from before the migration,
+// e.g. in launch links and scripts, refer to the UUID migrateFavoriteIDs derived from them.
+func canonicalFavoriteID(id string) string {
+	if id == "" || favoriteIDPattern.MatchString(id) {
+		return id
+	}
+	return migratedFavoriteID(id)
+}
+
+// formatUUID sets the version and RFC 4122 variant bits of b and formats it
+func formatUUID(b [16]byte, version byte) string {
+	b[6] = b[6]&0x0f | version<<4
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// favoriteBookmarkID derives the numeric Windows App bookmark ID of a favorite from its UUID
+func favoriteBookmarkID(favoriteID string) string {
+	return numericBookmarkID(favoriteID)
+}
+
+// migrateFavoriteIDs moves favorites saved before IDs were UUIDs to one: the old ID, which
+// Windows App knows the bookmark by, becomes the BookmarkID. Tombstones are rekeyed the same
+// way so deletions still apply. It reports whether anything changed.
+func migrateFavoriteIDs(favorites []Favorite, deleted map[string]string) bool {
+	changed := false
+	for i := range favorites {
+		f := &favorites[i]
+		if !favoriteIDPattern.MatchString(f.ID) {
+			if f.BookmarkID == "" {
+				f.BookmarkID = f.ID
+			}
+			f.ID = migratedFavoriteID(f.ID)
+			changed = true
+		}
+		if f.BookmarkID == "" {
+			f.BookmarkID = favoriteBookmarkID(f.ID)
+			changed = true
+		}
+	}
+	for id, deletedAt := range deleted {
+		if favoriteIDPattern.MatchString(id) {
+			continue
+		}
+		delete(deleted, id)
+		deleted[migratedFavoriteID(id)] = deletedAt
+		changed = true
+	}
+	return changed
+}
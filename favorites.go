@@ -0,0 +1,532 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Favorite sort orders accepted by GetFavoritesSorted
+const (
+	FavoriteSortCustom = "custom"
+	FavoriteSortName   = "name"
+	FavoriteSortRecent = "recent"
+)
+
+// FavoritePatch describes a partial update to a favorite. Nil fields are left unchanged.
+type FavoritePatch struct {
+	DisplayName   *string `json:"displayName,omitempty"`
+	ProjectID     *string `json:"projectId,omitempty"`
+	ProjectName   *string `json:"projectName,omitempty"`
+	InstanceName  *string `json:"instanceName,omitempty"`
+	Zone          *string `json:"zone,omitempty"`
+	RemotePort    *int    `json:"remotePort,omitempty"`
+	Profile       *string `json:"profile,omitempty"`
+	Username      *string `json:"username,omitempty"`
+	Notes         *string `json:"notes,omitempty"`
+	BookmarkGroup *string `json:"bookmarkGroup,omitempty"`
+	// MaxDurationMinutes limits how long tunnels for the favorite stay open, 0 disables
+	MaxDurationMinutes *int `json:"maxDurationMinutes,omitempty"`
+	// IdleTimeoutMinutes stops tunnels after the given time without connections, 0 disables
+	IdleTimeoutMinutes *int `json:"idleTimeoutMinutes,omitempty"`
+	// MemberSelection picks instance group members, "round-robin" or "healthiest"
+	MemberSelection *string `json:"memberSelection,omitempty"`
+	// Environment is "prod", "staging", "dev" or empty
+	Environment *string `json:"environment,omitempty"`
+	// Icon and Color set the favorite's Appearance, empty resets it
+	Icon  *string `json:"icon,omitempty"`
+	Color *string `json:"color,omitempty"`
+}
+
+// changesIdentity reports whether the patch moves a favorite to another VM
+func (p FavoritePatch) changesIdentity() bool {
+	return p.ProjectID != nil || p.InstanceName != nil || p.Zone != nil
+}
+
+// validate checks the patch values
+func (p FavoritePatch) validate() error {
+	if p.RemotePort != nil && (*p.RemotePort <= 0 || *p.RemotePort > 65535) {
+		return fmt.Errorf("invalid remote port: %d", *p.RemotePort)
+	}
+	if p.MaxDurationMinutes != nil && *p.MaxDurationMinutes < 0 {
+		return fmt.Errorf("maximum duration must not be negative")
+	}
+	if p.IdleTimeoutMinutes != nil && *p.IdleTimeoutMinutes < 0 {
+		return fmt.Errorf("idle timeout must not be negative")
+	}
+	if p.MemberSelection != nil {
+		switch *p.MemberSelection {
+		case "", MemberSelectionRoundRobin, MemberSelectionHealthiest:
+		default:
+			return fmt.Errorf("invalid member selection: %s", *p.MemberSelection)
+		}
+	}
+	if p.Environment != nil {
+		if err := validateEnvironment(*p.Environment); err != nil {
+			return err
+		}
+	}
+	if p.Icon != nil || p.Color != nil {
+		var ap Appearance
+		if p.Icon != nil {
+			ap.Icon = *p.Icon
+		}
+		if p.Color != nil {
+			ap.Color = *p.Color
+		}
+		if err := ap.normalize().validate(); err != nil {
+			return err
+		}
+	}
+	if p.Notes != nil && len(*p.Notes) > MaxNotesLength {
+		return fmt.Errorf("notes are too long (max %d bytes)", MaxNotesLength)
+	}
+	for name, v := range map[string]*string{"project": p.ProjectID, "instance": p.InstanceName, "zone": p.Zone} {
+		if v != nil && strings.TrimSpace(*v) == "" {
+			return fmt.Errorf("%s cannot be empty", name)
+		}
+	}
+	return nil
+}
+
+// apply applies the patch to a favorite
+func (p FavoritePatch) apply(f *Favorite) {
+	if p.DisplayName != nil {
+		f.DisplayName = *p.DisplayName
+	}
+	if p.ProjectID != nil {
+		f.ProjectID = strings.TrimSpace(*p.ProjectID)
+	}
+	if p.ProjectName != nil {
+		f.ProjectName = *p.ProjectName
+	}
+	if p.InstanceName != nil {
+		f.InstanceName = strings.TrimSpace(*p.InstanceName)
+	}
+	if p.Zone != nil {
+		f.Zone = strings.TrimSpace(*p.Zone)
+	}
+	if p.RemotePort != nil {
+		f.RemotePort = *p.RemotePort
+	}
+	if p.Profile != nil {
+		f.Profile = strings.TrimSpace(*p.Profile)
+	}
+	if p.Username != nil {
+		f.Username = *p.Username
+	}
+	if p.Notes != nil {
+		f.Notes = *p.Notes
+	}
+	if p.BookmarkGroup != nil {
+		f.BookmarkGroup = strings.TrimSpace(*p.BookmarkGroup)
+	}
+	if p.MaxDurationMinutes != nil {
+		f.MaxDurationMinutes = *p.MaxDurationMinutes
+	}
+	if p.IdleTimeoutMinutes != nil {
+		f.IdleTimeoutMinutes = *p.IdleTimeoutMinutes
+	}
+	if p.MemberSelection != nil {
+		f.MemberSelection = *p.MemberSelection
+	}
+	if p.Environment != nil {
+		f.Environment = *p.Environment
+	}
+	if p.Icon != nil {
+		f.Icon = strings.TrimSpace(*p.Icon)
+	}
+	if p.Color != nil {
+		f.Color = strings.ToLower(strings.TrimSpace(*p.Color))
+	}
+}
+
+// UpdateFavoriteFields applies a partial update to a single favorite
+func (a *App) UpdateFavoriteFields(favoriteID string, patch FavoritePatch) error {
+	if patch.changesIdentity() {
+		return fmt.Errorf("project, instance and zone cannot be changed, duplicate the connection instead")
+	}
+	_, err := a.BulkUpdateFavorites([]string{favoriteID}, patch)
+	return err
+}
+
+// DuplicateFavorite clones a favorite onto another VM, remote port or profile, keeping its settings.
+// The overrides must point the copy at a VM, port and profile that is not saved yet.
+func (a *App) DuplicateFavorite(favoriteID string, overrides FavoritePatch) (*Favorite, error) {
+	if err := overrides.validate(); err != nil {
+		return nil, err
+	}
+	if !overrides.changesIdentity() && overrides.RemotePort == nil && overrides.Profile == nil {
+		return nil, fmt.Errorf("duplicate must target a different project, instance, zone, remote port or profile")
+	}
+
+	// Get a free port no favorite uses first (before locking config)
+	ports, err := a.freePortsForFavorites(1)
+	if err != nil {
+		return nil, err
+	}
+	localPort := ports[0]
+
+	var duplicate Favorite
+	err = a.mutateConfig(func(cfg *AppConfig) error {
+		source := favoriteIn(cfg, favoriteID)
+		if source == nil {
+			return fmt.Errorf("favorite not found")
+		}
+
+		duplicate = source.clone()
+		overrides.apply(&duplicate)
+
+		key := favoriteVMKey(duplicate)
+		for _, f := range cfg.Favorites {
+			if favoriteVMKey(f) == key {
+				return duplicateFavoriteError(duplicate)
+			}
+			if f.LocalPort == localPort {
+				return fmt.Errorf("allocated port %d is already assigned, please retry", localPort)
+			}
+		}
+
+		id, err := newFavoriteID()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		duplicate.ID = id
+		duplicate.BookmarkID = favoriteBookmarkID(id)
+		duplicate.LocalPort = localPort
+		duplicate.CreatedAt = now
+		duplicate.UpdatedAt = now
+		duplicate.SortIndex = a.nextSortIndexLocked()
+		// Bookmarks are per favorite, the copy starts without one
+		duplicate.HasBookmark = false
+		duplicate.BookmarkHasCreds = false
+		duplicate.BookmarkUnavailable = false
+		// Usage statistics are per favorite too, HourCounts must not share the source's array
+		duplicate.LastConnectedAt = ""
+		duplicate.ConnectCount = 0
+		duplicate.HourCounts = nil
+
+		cfg.Favorites = append(cfg.Favorites, duplicate)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &duplicate, nil
+}
+
+// clone returns a copy of the favorite that shares no slices or settings blocks with it, so
+// changing one never changes the other
+func (f Favorite) clone() Favorite {
+	c := f
+	if f.HourCounts != nil {
+		c.HourCounts = append([]int(nil), f.HourCounts...)
+	}
+	if f.RemoteApps != nil {
+		c.RemoteApps = append([]RemoteApp(nil), f.RemoteApps...)
+	}
+	if f.AWS != nil {
+		aws := *f.AWS
+		c.AWS = &aws
+	}
+	if f.Azure != nil {
+		azure := *f.Azure
+		c.Azure = &azure
+	}
+	if f.Kubernetes != nil {
+		kubernetes := *f.Kubernetes
+		c.Kubernetes = &kubernetes
+	}
+	if f.VNC != nil {
+		vnc := *f.VNC
+		c.VNC = &vnc
+	}
+	if f.Web != nil {
+		web := *f.Web
+		c.Web = &web
+	}
+	if f.Database != nil {
+		database := *f.Database
+		c.Database = &database
+	}
+	if f.OnConnect != nil {
+		launcher := *f.OnConnect
+		if launcher.Arguments != nil {
+			launcher.Arguments = append([]string(nil), launcher.Arguments...)
+		}
+		c.OnConnect = &launcher
+	}
+	if f.Preamble != nil {
+		preamble := *f.Preamble
+		c.Preamble = &preamble
+	}
+	if f.CreatedAtDisplay != nil {
+		created := *f.CreatedAtDisplay
+		c.CreatedAtDisplay = &created
+	}
+	if f.LastConnectedAtDisplay != nil {
+		lastConnected := *f.LastConnectedAtDisplay
+		c.LastConnectedAtDisplay = &lastConnected
+	}
+	return c
+}
+
+// BulkUpdateFavorites applies the same patch to several favorites and returns the number updated.
+// Identity fields (project, instance, zone) cannot be bulk-edited.
+func (a *App) BulkUpdateFavorites(favoriteIDs []string, patch FavoritePatch) (int, error) {
+	if err := patch.validate(); err != nil {
+		return 0, err
+	}
+	if patch.changesIdentity() {
+		return 0, fmt.Errorf("project, instance and zone cannot be bulk-edited")
+	}
+
+	wanted := make(map[string]bool, len(favoriteIDs))
+	for _, id := range favoriteIDs {
+		wanted[id] = true
+	}
+
+	count := 0
+	err := a.mutateConfig(func(cfg *AppConfig) error {
+		// Make sure every favorite exists before changing anything
+		found := 0
+		for _, f := range cfg.Favorites {
+			if wanted[f.ID] {
+				found++
+			}
+		}
+		if found != len(wanted) {
+			return fmt.Errorf("%d of %d favorites not found", len(wanted)-found, len(wanted))
+		}
+
+		// Changing the port or profile must not turn a favorite into a duplicate of another
+		if patch.RemotePort != nil || patch.Profile != nil {
+			byKey := make(map[string]int, len(cfg.Favorites))
+			var patched []Favorite
+			for _, f := range cfg.Favorites {
+				if wanted[f.ID] {
+					patch.apply(&f)
+					patched = append(patched, f)
+				}
+				byKey[favoriteVMKey(f)]++
+			}
+			for _, f := range patched {
+				if byKey[favoriteVMKey(f)] > 1 {
+					return duplicateFavoriteError(f)
+				}
+			}
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		for i := range cfg.Favorites {
+			if !wanted[cfg.Favorites[i].ID] {
+				continue
+			}
+			patch.apply(&cfg.Favorites[i])
+			cfg.Favorites[i].UpdatedAt = now
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ReorderFavorites stores a user-defined order. Favorites missing from orderedIDs
+// keep their relative order after the listed ones.
+func (a *App) ReorderFavorites(orderedIDs []string) error {
+	position := make(map[string]int, len(orderedIDs))
+	for i, id := range orderedIDs {
+		if _, dup := position[id]; dup {
+			return fmt.Errorf("favorite %s listed more than once", id)
+		}
+		position[id] = i
+	}
+
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		current := make(map[string]bool, len(cfg.Favorites))
+		for _, f := range cfg.Favorites {
+			current[f.ID] = true
+		}
+		for _, id := range orderedIDs {
+			if !current[id] {
+				return fmt.Errorf("favorite not found: %s", id)
+			}
+		}
+
+		// Listed favorites first, the rest in their existing order
+		favorites := make([]Favorite, len(cfg.Favorites))
+		copy(favorites, cfg.Favorites)
+		sort.SliceStable(favorites, func(i, j int) bool {
+			return favorites[i].SortIndex < favorites[j].SortIndex
+		})
+		sort.SliceStable(favorites, func(i, j int) bool {
+			pi, iListed := position[favorites[i].ID]
+			pj, jListed := position[favorites[j].ID]
+			if iListed && jListed {
+				return pi < pj
+			}
+			return iListed && !jListed
+		})
+
+		// UpdatedAt stays, reordering must not make a favorite win over edits in iCloud sync
+		changed := false
+		for i := range favorites {
+			if favorites[i].SortIndex != i {
+				favorites[i].SortIndex = i
+				changed = true
+			}
+		}
+		if !changed {
+			return errConfigUnchanged
+		}
+
+		cfg.Favorites = favorites
+		return nil
+	})
+}
+
+// GetFavoritesSorted returns favorites ordered by "custom" (default), "name" or "recent"
+func (a *App) GetFavoritesSorted(sortBy string) ([]Favorite, error) {
+	favorites := a.GetFavorites()
+
+	switch sortBy {
+	case "", FavoriteSortCustom:
+		// GetFavorites already returns the custom order
+	case FavoriteSortName:
+		sort.SliceStable(favorites, func(i, j int) bool {
+			return strings.ToLower(favoriteLabel(favorites[i])) < strings.ToLower(favoriteLabel(favorites[j]))
+		})
+	case FavoriteSortRecent:
+		sort.SliceStable(favorites, func(i, j int) bool {
+			return favoriteLastUsed(favorites[i]).After(favoriteLastUsed(favorites[j]))
+		})
+	default:
+		return nil, fmt.Errorf("unknown sort order: %s", sortBy)
+	}
+	return favorites, nil
+}
+
+// favoriteLabel returns the name shown for a favorite
+func favoriteLabel(f Favorite) string {
+	if f.DisplayName != "" {
+		return f.DisplayName
+	}
+	return f.InstanceName
+}
+
+// nextSortIndexLocked returns the index for a newly added favorite (caller must hold configMu)
+func (a *App) nextSortIndexLocked() int {
+	next := 0
+	for _, f := range a.config.Favorites {
+		if f.SortIndex >= next {
+			next = f.SortIndex + 1
+		}
+	}
+	return next
+}
+
+// SuggestedConnection is a favorite ranked by how likely it is to be used next
+type SuggestedConnection struct {
+	Favorite Favorite `json:"favorite"`
+	Score    float64  `json:"score"`
+	Reason   string   `json:"reason"`
+}
+
+// maxSuggestions limits the number of results from GetSuggestedConnections
+const maxSuggestions = 5
+
+// GetSuggestedConnections ranks favorites by recency, frequency and the current time of day
+func (a *App) GetSuggestedConnections() []SuggestedConnection {
+	now := time.Now()
+	hour := now.Hour()
+
+	var suggestions []SuggestedConnection
+	for _, f := range a.GetFavorites() {
+		if f.ConnectCount == 0 {
+			continue
+		}
+
+		// Recency halves every 3 days
+		recency := 0.0
+		if last := parseTimestamp(f.LastConnectedAt); !last.IsZero() {
+			days := now.Sub(last).Hours() / 24
+			recency = math.Pow(0.5, days/3)
+		}
+
+		// Frequency grows slowly so a few heavy favorites don't dominate
+		frequency := math.Log1p(float64(f.ConnectCount)) / math.Log1p(100)
+
+		// Share of connections made around this hour (+/- 1h)
+		timeOfDay := 0.0
+		if len(f.HourCounts) == 24 {
+			near := f.HourCounts[hour] + f.HourCounts[(hour+23)%24] + f.HourCounts[(hour+1)%24]
+			timeOfDay = float64(near) / float64(f.ConnectCount)
+		}
+
+		score := 0.5*recency + 0.3*math.Min(frequency, 1) + 0.2*math.Min(timeOfDay, 1)
+
+		reason := "Used recently"
+		switch {
+		case timeOfDay >= 0.5 && 0.2*timeOfDay >= 0.5*recency:
+			reason = "Usually used at this time"
+		case frequency >= recency:
+			reason = "Used frequently"
+		}
+
+		suggestions = append(suggestions, SuggestedConnection{
+			Favorite: f,
+			Score:    math.Round(score*1000) / 1000,
+			Reason:   reason,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
+
+// recordConnectionUsage updates the usage statistics of a favorite
+func (a *App) recordConnectionUsage(favoriteID string) {
+	now := time.Now()
+	a.mutateConfig(func(cfg *AppConfig) error {
+		f := favoriteIn(cfg, favoriteID)
+		if f == nil {
+			return fmt.Errorf("favorite not found")
+		}
+		f.LastConnectedAt = now.Format(time.RFC3339)
+		f.ConnectCount++
+		if len(f.HourCounts) != 24 {
+			f.HourCounts = make([]int, 24)
+		}
+		f.HourCounts[now.Hour()]++
+		// Remember it as the last connection for the reconnect shortcut
+		cfg.LastConnection = &LastConnection{
+			ProjectID:          f.ProjectID,
+			ProjectName:        f.ProjectName,
+			InstanceName:       f.InstanceName,
+			Zone:               f.Zone,
+			RemotePort:         f.RemotePort,
+			PreferredLocalPort: f.LocalPort,
+		}
+		return nil
+	})
+}
+
+// favoriteLastUsed returns when a favorite was last connected, falling back to its last edit
+func favoriteLastUsed(f Favorite) time.Time {
+	if t := parseTimestamp(f.LastConnectedAt); !t.IsZero() {
+		return t
+	}
+	return favoriteUpdatedAt(f)
+}
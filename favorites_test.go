@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+// hourTotal sums a favorite's connections per hour of day
+func hourTotal(f *Favorite) int {
+	total := 0
+	for _, n := range f.HourCounts {
+		total += n
+	}
+	return total
+}
+
+// TestDuplicateFavoriteUsage connects a duplicate and checks the source's statistics stay put
+func TestDuplicateFavoriteUsage(t *testing.T) {
+	app := newTestApp(t)
+	source, err := addTestFavorite(app, "vm")
+	if err != nil {
+		t.Fatalf("AddFavorite: %v", err)
+	}
+	app.recordConnectionUsage(source.ID)
+
+	profile := "admin"
+	duplicate, err := app.DuplicateFavorite(source.ID, FavoritePatch{Profile: &profile})
+	if err != nil {
+		t.Fatalf("DuplicateFavorite: %v", err)
+	}
+	if duplicate.ConnectCount != 0 || duplicate.LastConnectedAt != "" || hourTotal(duplicate) != 0 {
+		t.Errorf("duplicate starts with usage: %d connects, last %q, %d by hour",
+			duplicate.ConnectCount, duplicate.LastConnectedAt, hourTotal(duplicate))
+	}
+
+	app.recordConnectionUsage(duplicate.ID)
+
+	src := app.GetConnectionInfo(source.ID)
+	if src.ConnectCount != 1 || hourTotal(src) != 1 {
+		t.Errorf("source has %d connects and %d by hour, want 1 and 1", src.ConnectCount, hourTotal(src))
+	}
+	dup := app.GetConnectionInfo(duplicate.ID)
+	if dup.ConnectCount != 1 || hourTotal(dup) != 1 {
+		t.Errorf("duplicate has %d connects and %d by hour, want 1 and 1", dup.ConnectCount, hourTotal(dup))
+	}
+}
+
+// TestDuplicateFavoriteRemoteApps edits a RemoteApp of a duplicate and checks the source keeps its own
+func TestDuplicateFavoriteRemoteApps(t *testing.T) {
+	app := newTestApp(t)
+	source, err := addTestFavorite(app, "vm")
+	if err != nil {
+		t.Fatalf("AddFavorite: %v", err)
+	}
+	if err := app.SetRemoteApp(source.ID, RemoteApp{Alias: "excel", DisplayName: "Excel"}); err != nil {
+		t.Fatalf("SetRemoteApp: %v", err)
+	}
+
+	profile := "admin"
+	duplicate, err := app.DuplicateFavorite(source.ID, FavoritePatch{Profile: &profile})
+	if err != nil {
+		t.Fatalf("DuplicateFavorite: %v", err)
+	}
+	if err := app.SetRemoteApp(duplicate.ID, RemoteApp{Alias: "excel", DisplayName: "Excel (admin)"}); err != nil {
+		t.Fatalf("SetRemoteApp: %v", err)
+	}
+
+	if got := app.GetConnectionInfo(source.ID).RemoteApps[0].DisplayName; got != "Excel" {
+		t.Errorf("source RemoteApp is %q after editing the duplicate, want %q", got, "Excel")
+	}
+}
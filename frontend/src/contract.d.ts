@@ -0,0 +1,1290 @@
+// Code generated by --contract ts. DO NOT EDIT.
+// Contract version ba2a07de00df
+
+export interface AWSTarget {
+	instanceId: string;
+	region: string;
+	profile?: string;
+}
+
+export interface AgentStatus {
+	installed: boolean;
+	running: boolean;
+	attached: boolean;
+	tunnels: number;
+	error?: string;
+}
+
+export interface AppSettings {
+	restoreTunnels?: string;
+	icloudSync?: boolean;
+	bookmarkGroup?: string;
+	reconnectHotkey?: string;
+	healthCheckInterval?: number;
+	allowWellKnownPorts?: boolean;
+	sessionRecording?: boolean;
+	notifyDuringFocus?: boolean;
+	lowPowerMode?: string;
+	telemetryOptIn?: boolean;
+	language?: string;
+	userSwitch?: string;
+	timeZone?: string;
+	useAgent?: boolean;
+	production: ProductionSafeguards;
+	experimentalProviders?: boolean;
+	operator: OperatorPolicy;
+	confirmCloudMutations?: boolean;
+	advanced: TunnelAdvanced;
+	cloudMonitoring: CloudMonitoringSettings;
+}
+
+export interface Appearance {
+	icon?: string;
+	color?: string;
+}
+
+export interface AuditEntry {
+	time: string;
+	action: string;
+	connectionId?: string;
+	target?: string;
+	message?: string;
+	timeDisplay?: DisplayTime;
+}
+
+export interface AuthProgress {
+	status: string;
+	message: string;
+}
+
+export interface AuthStatus {
+	authenticated: boolean;
+	error?: string;
+	email?: string;
+}
+
+export interface AzureTarget {
+	subscription: string;
+	resourceGroup: string;
+	bastion: string;
+	resourceId: string;
+}
+
+export interface BookmarkReconcileResult {
+	created: string[];
+	updated: string[];
+	orphaned: string[];
+	deleted: string[];
+	renamed: string[];
+	failures: string[];
+	warnings: string[];
+	error?: string;
+}
+
+export interface BookmarkResult {
+	success: boolean;
+	bookmarkId?: string;
+	error?: string;
+	warning?: string;
+}
+
+export interface BookmarkVerification {
+	connectionId: string;
+	bookmarkId: string;
+	exists: boolean;
+	hostname?: string;
+	port?: number;
+	expectedPort: number;
+	username?: string;
+	friendlyName?: string;
+	group?: string;
+	hasCreds: boolean;
+	drift: string[];
+	updated: boolean;
+	error?: string;
+}
+
+export interface BootProgress {
+	projectId: string;
+	zone: string;
+	instanceName: string;
+	stage: string;
+	message: string;
+	percent: number;
+	elapsed: number;
+	readyIn?: number;
+	done: boolean;
+	error?: string;
+}
+
+export interface CatalogImportResult {
+	imported: number;
+	skipped: string[];
+}
+
+export interface CloudAuthStatus {
+	kind: string;
+	cliFound: boolean;
+	cliPath?: string;
+	signedIn: boolean;
+	account?: string;
+	error?: string;
+}
+
+export interface CloudMonitoringSettings {
+	enabled?: boolean;
+	projectId?: string;
+}
+
+export interface CloudMonitoringStatus {
+	enabled: boolean;
+	lastExportAt?: string;
+	lastError?: string;
+	exportedSeries: number;
+}
+
+export interface ConflictResolution {
+	conflictId: string;
+	action: string;
+	keepId: string;
+	description?: string;
+}
+
+export interface ConnectResult {
+	success: boolean;
+	tunnel?: TunnelInfo;
+	error?: string;
+	code?: string;
+	retryAfter?: number;
+	url?: string;
+	disabledService?: string;
+	resolution?: InstanceResolution;
+	rdpFile?: string;
+	confirmProduction?: boolean;
+	favoriteId?: string;
+}
+
+export interface Contract {
+	version: string;
+	methods: ContractMethod[];
+	events: ContractEvent[];
+	types: ContractType[];
+}
+
+export interface ContractEvent {
+	name: string;
+	payload: string;
+}
+
+export interface ContractField {
+	name: string;
+	type: string;
+	optional: boolean;
+}
+
+export interface ContractMethod {
+	name: string;
+	params: string[];
+	returns: string;
+	canFail: boolean;
+}
+
+export interface ContractType {
+	name: string;
+	fields: ContractField[];
+}
+
+export interface CostItem {
+	description: string;
+	quantity: number;
+	unit: string;
+	unitPrice: number;
+	hourly: number;
+}
+
+export interface CredentialLinkResult {
+	linked: number;
+	missing: string[];
+	unmatched: string[];
+}
+
+export interface DatabaseClient {
+	id: string;
+	name: string;
+	installed: boolean;
+}
+
+export interface DatabaseSettings {
+	engine: string;
+	database?: string;
+	username?: string;
+	client?: string;
+}
+
+export interface DetachedView {
+	id: string;
+	kind: string;
+	tunnelId?: string;
+	title: string;
+	x: number;
+	y: number;
+	width: number;
+	height: number;
+}
+
+export interface DetectedPort {
+	port: number;
+	service: string;
+	connectionType?: string;
+	open: boolean;
+	latencyMs?: number;
+	error?: string;
+}
+
+export interface DialQueueStats {
+	limit: number;
+	active: number;
+	waiting: number;
+	dials: number;
+	queued: number;
+	rejected: number;
+	avgWaitMs: number;
+	maxWaitMs: number;
+}
+
+export interface DisplayTime {
+	local: string;
+	relative: string;
+	timeZone: string;
+}
+
+export interface EnvironmentInfo {
+	id: string;
+	label: string;
+	color: string;
+}
+
+export interface ExportResult {
+	success: boolean;
+	path?: string;
+	count: number;
+	error?: string;
+}
+
+export interface ExternalTunnel {
+	pid: number;
+	projectId?: string;
+	instanceName: string;
+	zone?: string;
+	remotePort: number;
+	localHost?: string;
+	localPort?: number;
+	favoriteId?: string;
+	adoptable: boolean;
+}
+
+export interface Favorite {
+	id: string;
+	bookmarkId: string;
+	displayName: string;
+	projectId: string;
+	projectName?: string;
+	instanceName: string;
+	zone: string;
+	remotePort: number;
+	profile?: string;
+	localPort: number;
+	createdAt: string;
+	updatedAt?: string;
+	notes?: string;
+	bookmarkGroup?: string;
+	sortIndex: number;
+	lastConnectedAt?: string;
+	connectCount: number;
+	hourCounts?: number[];
+	maxDurationMinutes?: number;
+	idleTimeoutMinutes?: number;
+	memberSelection?: string;
+	targetKind?: string;
+	aws?: AWSTarget;
+	azure?: AzureTarget;
+	kubernetes?: KubernetesTarget;
+	hostname?: string;
+	hostsEntry?: boolean;
+	region?: string;
+	network?: string;
+	destGroup?: string;
+	connectionType?: string;
+	vnc?: VNCSettings;
+	web?: WebSettings;
+	database?: DatabaseSettings;
+	remoteApps?: RemoteApp[];
+	onConnect?: Launcher;
+	preamble?: PreambleSettings;
+	environment?: string;
+	icon?: string;
+	color?: string;
+	username?: string;
+	hasBookmark: boolean;
+	bookmarkHasCreds: boolean;
+	bookmarkUnavailable?: boolean;
+	createdAtDisplay?: DisplayTime;
+	lastConnectedAtDisplay?: DisplayTime;
+}
+
+export interface FavoriteConflict {
+	id: string;
+	kind: string;
+	description: string;
+	favoriteIds: string[];
+	resolutions: ConflictResolution[];
+}
+
+export interface FavoritePatch {
+	displayName?: string;
+	projectId?: string;
+	projectName?: string;
+	instanceName?: string;
+	zone?: string;
+	remotePort?: number;
+	profile?: string;
+	username?: string;
+	notes?: string;
+	bookmarkGroup?: string;
+	maxDurationMinutes?: number;
+	idleTimeoutMinutes?: number;
+	memberSelection?: string;
+	environment?: string;
+	icon?: string;
+	color?: string;
+}
+
+export interface FavoriteSearchResult {
+	favorite: Favorite;
+	score: number;
+	matched: string[];
+}
+
+export interface FavoriteZoneIssue {
+	favoriteId: string;
+	zone: string;
+	code: string;
+	message: string;
+	suggestion?: string;
+}
+
+export interface FreeRDPStatus {
+	installed: boolean;
+	path?: string;
+}
+
+export interface GcloudInfo {
+	found: boolean;
+	path?: string;
+	version?: string;
+	outdated?: boolean;
+	error?: string;
+}
+
+export interface GcloudUpdateOutput {
+	line: string;
+	done: boolean;
+	error?: string;
+}
+
+export interface HostnameResolution {
+	hostname: string;
+	found: boolean;
+	instanceName?: string;
+	zone?: string;
+	matchedBy?: string;
+	error?: string;
+}
+
+export interface HostsEntry {
+	tunnelId: string;
+	hostname: string;
+}
+
+export interface HostsHelperStatus {
+	installed: boolean;
+	entries: HostsEntry[];
+	allowed: string[];
+}
+
+export interface ICloudSyncStatus {
+	enabled: boolean;
+	available: boolean;
+	path?: string;
+	lastSyncedAt?: string;
+	error?: string;
+}
+
+export interface InstanceMetadata {
+	fingerprint: string;
+	items: MetadataItem[];
+}
+
+export interface InstanceResolution {
+	connectionId: string;
+	instanceName: string;
+	storedZone: string;
+	found: boolean;
+	moved: boolean;
+	zones?: string[];
+	instanceGroup?: string;
+	error?: string;
+	disabledService?: string;
+}
+
+export interface JITRequest {
+	connectionId: string;
+	minutes: number;
+	username: string;
+	deleteKeychainOnExpiry: boolean;
+	confirmed?: boolean;
+}
+
+export interface JITSession {
+	id: string;
+	connectionId: string;
+	tunnelId: string;
+	username: string;
+	startedAt: string;
+	expiresAt: string;
+	password?: string;
+	deleteKeychainOnExpiry?: boolean;
+}
+
+export interface KubernetesTarget {
+	context: string;
+	namespace: string;
+	resource: string;
+}
+
+export interface LANShare {
+	tunnelId: string;
+	address: string;
+	startedAt: string;
+	allowedPeers: string[];
+	serviceType?: string;
+	name?: string;
+	advertiseError?: string;
+}
+
+export interface LanguageInfo {
+	current: string;
+	system: string;
+	available: string[];
+}
+
+export interface LastConnection {
+	projectId: string;
+	projectName?: string;
+	instanceName: string;
+	zone: string;
+	remotePort: number;
+	preferredLocalPort?: number;
+}
+
+export interface LaunchAction {
+	favoriteId: string;
+	title: string;
+	command: string;
+	displayName: string;
+}
+
+export interface Launcher {
+	app?: string;
+	arguments?: string[];
+	command?: string;
+}
+
+export interface ManagedDefaults {
+	bookmarkGroup?: string;
+	allowedProjects?: string[];
+	idleTimeoutMinutes?: number;
+	telemetryDisabled?: boolean;
+	lanSharingDisabled?: boolean;
+	source?: string;
+}
+
+export interface MetadataItem {
+	key: string;
+	value: string;
+}
+
+export interface MetadataUpdateRequest {
+	projectId: string;
+	zone: string;
+	instanceName: string;
+	key: string;
+	value: string;
+	delete: boolean;
+	fingerprint: string;
+	confirmed: boolean;
+}
+
+export interface MutationPlan {
+	operation: string;
+	target: string;
+	changes: string[];
+}
+
+export interface NetworkEndpoint {
+	host: string;
+	port: number;
+	protocol: string;
+	purpose: string;
+	process: string;
+	connections: number;
+	lastUsedAt?: string;
+}
+
+export interface Operation {
+	id: string;
+	kind: string;
+	title: string;
+	status: string;
+	percent: number;
+	message?: string;
+	error?: string;
+	startedAt: string;
+	finishedAt?: string;
+	result?: any;
+}
+
+export interface OperatorPolicy {
+	enabled?: boolean;
+	restricted?: string[];
+	pinHash?: string;
+	pinSalt?: string;
+	touchId?: boolean;
+	unlockMinutes?: number;
+}
+
+export interface OperatorStatus {
+	enabled: boolean;
+	unlocked: boolean;
+	unlockedUntil?: string;
+	restricted: string[];
+	touchId: boolean;
+	touchIdAvailable: boolean;
+}
+
+export interface PasswordProgress {
+	connectionId: string;
+	stage: string;
+	message: string;
+	attempt?: number;
+}
+
+export interface PasswordRotationOptions {
+	username: string;
+	saveToKeychain: boolean;
+	updateBookmark: boolean;
+	concurrency: number;
+	dryRun?: boolean;
+	confirmed?: boolean;
+}
+
+export interface PasswordRotationResult {
+	favoriteId: string;
+	displayName: string;
+	projectId: string;
+	instanceName: string;
+	username?: string;
+	success: boolean;
+	skipped: boolean;
+	error?: string;
+	keychainSaved: boolean;
+	bookmarkUpdated: boolean;
+	plan?: MutationPlan;
+}
+
+export interface PatchJobInfo {
+	name: string;
+	displayName?: string;
+	state: string;
+	instanceState?: string;
+	percent: number;
+	createdAt?: string;
+	error?: string;
+}
+
+export interface PatchStatus {
+	favoriteId: string;
+	osName?: string;
+	osVersion?: string;
+	inventoryUpdatedAt?: string;
+	pendingUpdates: number;
+	pendingTitles: string[];
+	compliant: boolean;
+	pendingReboot: boolean;
+	lastPatchJob?: PatchJobInfo;
+	nextPatchAt?: string;
+	nextPatchDeployment?: string;
+	nextPatchReboots: boolean;
+}
+
+export interface PortCheck {
+	port: number;
+	conflict: boolean;
+	service?: string;
+	warning?: string;
+	alternatives?: number[];
+}
+
+export interface PowerStatus {
+	onBattery: boolean;
+	lowPower: boolean;
+	mode: string;
+}
+
+export interface PreambleSettings {
+	mode: string;
+	custom?: string;
+}
+
+export interface ProbeResult {
+	reachable: boolean;
+	latencyMs?: number;
+	error?: string;
+	code?: string;
+	retryAfter?: number;
+}
+
+export interface ProductionSafeguards {
+	requireConfirmation?: boolean;
+	maxDurationMinutes?: number;
+}
+
+export interface Project {
+	id: string;
+	name: string;
+}
+
+export interface ProjectDefaults {
+	remotePort?: number;
+	bookmarkGroup?: string;
+	username?: string;
+	maxDurationMinutes?: number;
+	idleTimeoutMinutes?: number;
+}
+
+export interface ProjectDefaultsEntry {
+	projectId: string;
+	defaults: ProjectDefaults;
+}
+
+export interface QueuedNotification {
+	title: string;
+	message: string;
+	time: string;
+}
+
+export interface QuietModeStatus {
+	active: boolean;
+	focus: boolean;
+	mirroring: boolean;
+	queued: number;
+}
+
+export interface RecentError {
+	id: string;
+	source: string;
+	target?: string;
+	message: string;
+	count: number;
+	firstAt: string;
+	lastAt: string;
+}
+
+export interface RegionInfo {
+	name: string;
+	zones: string[];
+}
+
+export interface RegionVMs {
+	region: string;
+	vms: VM[];
+}
+
+export interface RemoteApp {
+	alias: string;
+	displayName: string;
+	arguments?: string;
+}
+
+export interface RemotePortPreset {
+	port: number;
+	service: string;
+	connectionType?: string;
+}
+
+export interface RouteCheck {
+	host: string;
+	addresses: string[];
+	interface?: string;
+	gateway?: string;
+	viaVpn: boolean;
+	reachable: boolean;
+	latencyMs?: number;
+	certIssuer?: string;
+	error?: string;
+}
+
+export interface SMBMount {
+	connectionId: string;
+	tunnelId: string;
+	share: string;
+	mountPath: string;
+	mountedAt: string;
+}
+
+export interface ServiceStatus {
+	service: string;
+	title: string;
+	enabled: boolean;
+	error?: string;
+}
+
+export interface SessionRecord {
+	tunnelId: string;
+	target: string;
+	localPort: number;
+	remotePort: number;
+	client?: string;
+	startedAt: string;
+	endedAt: string;
+	seconds: number;
+	bytesSent: number;
+	bytesReceived: number;
+	error?: string;
+	startedAtDisplay?: DisplayTime;
+	endedAtDisplay?: DisplayTime;
+}
+
+export interface SessionRecords {
+	records: SessionRecord[];
+	skipped?: number;
+}
+
+export interface SharedTunnel {
+	projectId: string;
+	instanceName: string;
+	zone: string;
+	remotePort: number;
+}
+
+export interface StartupIssue {
+	code: string;
+	message: string;
+	detail?: string;
+	path?: string;
+	movedTo?: string;
+	backup?: string;
+	actions: string[];
+}
+
+export interface SuggestedConnection {
+	favorite: Favorite;
+	score: number;
+	reason: string;
+}
+
+export interface Target {
+	kind: string;
+	name: string;
+	id?: string;
+	location?: string;
+	status?: string;
+}
+
+export interface TelemetryPreview {
+	optedIn: boolean;
+	allowed: boolean;
+	endpoint?: string;
+	lastSent?: string;
+	report: TelemetryReport;
+}
+
+export interface TelemetryReport {
+	appVersion: string;
+	os: string;
+	periodStart: string;
+	features: Record<string, number>;
+	errors: Record<string, number>;
+}
+
+export interface ThroughputSample {
+	at: string;
+	inBps: number;
+	outBps: number;
+}
+
+export interface TunnelAdvanced {
+	compression?: boolean;
+	connectTimeout?: number;
+	preDial?: boolean;
+	stallTimeout?: number;
+	resetStalled?: boolean;
+	maxConcurrentDials?: number;
+}
+
+export interface TunnelExpiryNotice {
+	tunnelId: string;
+	vmName: string;
+	expiresAt: string;
+}
+
+export interface TunnelGroup {
+	projectId: string;
+	activeCount: number;
+	tunnels: TunnelInfo[];
+}
+
+export interface TunnelInfo {
+	id: string;
+	projectId: string;
+	vmName: string;
+	zone: string;
+	localPort: number;
+	remotePort: number;
+	status: string;
+	startedAt: string;
+	bookmarkId?: string;
+	expiresAt?: string;
+	startedAtDisplay?: DisplayTime;
+	expiresAtDisplay?: DisplayTime;
+	instanceGroup?: string;
+	host?: string;
+	favoriteId?: string;
+	environment?: string;
+	environmentColor?: string;
+	health: string;
+	lastDialOk?: string;
+	dialFailures: number;
+	lastError?: string;
+	reconnectAttempts: number;
+	agent?: boolean;
+	simulated?: boolean;
+}
+
+export interface TunnelLogBatch {
+	tunnelId: string;
+	entries: TunnelLogEntry[];
+	skipped?: number;
+}
+
+export interface TunnelLogEntry {
+	seq: number;
+	time: string;
+	message: string;
+}
+
+export interface TunnelLogTail {
+	tunnelId: string;
+	entries: TunnelLogEntry[];
+	nextSeq: number;
+	truncated?: boolean;
+}
+
+export interface TunnelMetrics {
+	tunnelId: string;
+	bucketSeconds: number;
+	samples: ThroughputSample[];
+	totalIn: number;
+	totalOut: number;
+}
+
+export interface TunnelPauseStatus {
+	paused: boolean;
+	pausedSince?: string;
+	reason?: string;
+}
+
+export interface TunnelRestoreResult {
+	spec: TunnelSpec;
+	tunnel?: TunnelInfo;
+	error?: string;
+}
+
+export interface TunnelSnippet {
+	tunnel: SharedTunnel;
+	json: string;
+	url: string;
+}
+
+export interface TunnelSpec {
+	projectId: string;
+	vmName: string;
+	zone: string;
+	localPort: number;
+	remotePort: number;
+}
+
+export interface TunnelStall {
+	tunnelId: string;
+	client: string;
+	stalled: boolean;
+	seconds: number;
+	reset: boolean;
+}
+
+export interface VM {
+	name: string;
+	zone: string;
+	region: string;
+	status: string;
+	privateIp: string;
+	machineType: string;
+	isWindows: boolean;
+	createdAt?: string;
+	lastStartedAt?: string;
+	lastStoppedAt?: string;
+	uptimeSeconds?: number;
+	longRunning?: boolean;
+}
+
+export interface VMChanges {
+	projectId: string;
+	revision: number;
+	added: VM[];
+	removed: VM[];
+	changed: VM[];
+}
+
+export interface VMCostEstimate {
+	projectId: string;
+	zone: string;
+	region: string;
+	machineType: string;
+	currency: string;
+	hourly: number;
+	monthly: number;
+	items: CostItem[];
+	note: string;
+}
+
+export interface VMPowerState {
+	favoriteId: string;
+	status: string;
+	checkedAt: string;
+	error?: string;
+	createdAt?: string;
+	lastStartedAt?: string;
+	lastStoppedAt?: string;
+	uptimeSeconds?: number;
+	longRunning?: boolean;
+}
+
+export interface VMSnapshot {
+	projectId: string;
+	revision: number;
+	vms: VM[];
+}
+
+export interface VNCSettings {
+	display: number;
+	openViewer: boolean;
+	username?: string;
+}
+
+export interface VPNDiagnostics {
+	vpnInterfaces: string[];
+	vpnClients: string[];
+	routes: RouteCheck[];
+	issues: VPNIssue[];
+}
+
+export interface VPNIssue {
+	severity: string;
+	code: string;
+	host?: string;
+	message: string;
+	guidance: string;
+}
+
+export interface WebSettings {
+	scheme?: string;
+	path?: string;
+	hostHeader?: string;
+	insecureTls?: boolean;
+}
+
+export interface WindowsAppStatus {
+	installed: boolean;
+	path?: string;
+	error?: string;
+}
+
+export interface WindowsPasswordRequest {
+	connectionId: string;
+	username: string;
+	saveToKeychain: boolean;
+	updateBookmark: boolean;
+	dryRun?: boolean;
+	confirmed?: boolean;
+}
+
+export interface WindowsPasswordResult {
+	success: boolean;
+	username?: string;
+	password?: string;
+	error?: string;
+	bookmarkUpdated: boolean;
+	keychainSaved: boolean;
+	plan?: MutationPlan;
+}
+
+export interface ZoneCatalog {
+	projectId: string;
+	regions: RegionInfo[];
+	zones: ZoneInfo[];
+	fetchedAt: string;
+}
+
+export interface ZoneInfo {
+	name: string;
+	region: string;
+	status: string;
+}
+
+// App is the API bound as window.go.main.App
+export interface App {
+	AddAWSFavorite(arg1: string, arg2: AWSTarget, arg3: number): Promise<Favorite>;
+	AddAzureFavorite(arg1: string, arg2: AzureTarget, arg3: number): Promise<Favorite>;
+	AddDatabaseFavorite(arg1: string, arg2: string, arg3: string, arg4: string, arg5: string, arg6: number, arg7: DatabaseSettings): Promise<Favorite>;
+	AddFavorite(arg1: string, arg2: string, arg3: string, arg4: string, arg5: string, arg6: number, arg7: number): Promise<Favorite>;
+	AddFavoriteWithProfile(arg1: string, arg2: string, arg3: string, arg4: string, arg5: string, arg6: string, arg7: number): Promise<Favorite>;
+	AddHostnameFavorite(arg1: string, arg2: string, arg3: string, arg4: string, arg5: string, arg6: string, arg7: string, arg8: number): Promise<Favorite>;
+	AddKubernetesFavorite(arg1: string, arg2: KubernetesTarget, arg3: number): Promise<Favorite>;
+	AddVNCFavorite(arg1: string, arg2: string, arg3: string, arg4: string, arg5: string, arg6: VNCSettings): Promise<Favorite>;
+	AddWebFavorite(arg1: string, arg2: string, arg3: string, arg4: string, arg5: string, arg6: number, arg7: WebSettings): Promise<Favorite>;
+	AdoptRunningTunnels(): Promise<TunnelInfo[]>;
+	AttachView(arg1: string): Promise<void>;
+	BulkUpdateFavorites(arg1: string[], arg2: FavoritePatch): Promise<number>;
+	CancelOperation(arg1: string): Promise<void>;
+	CancelWindowsPasswordReset(arg1: string): Promise<void>;
+	CheckAuth(): Promise<AuthStatus>;
+	CheckFreeRDP(): Promise<FreeRDPStatus>;
+	CheckLocalPort(arg1: number): Promise<PortCheck>;
+	CheckProjectServices(arg1: string): Promise<ServiceStatus[]>;
+	CheckVPNConflicts(): Promise<VPNDiagnostics>;
+	CheckWindowsApp(): Promise<WindowsAppStatus>;
+	ClearRecentErrors(): Promise<number>;
+	ClearStoppedTunnels(): Promise<number>;
+	CloudLogin(arg1: string, arg2: string): Promise<CloudAuthStatus>;
+	ConfirmInstanceZone(arg1: string, arg2: string): Promise<TunnelInfo>;
+	ConfirmProductionConnect(arg1: string): Promise<void>;
+	ConnectFavorite(arg1: string): Promise<ConnectResult>;
+	ConnectRemoteApp(arg1: string, arg2: string): Promise<ConnectResult>;
+	CreateWindowsAppBookmark(arg1: string, arg2: string, arg3: string, arg4: number): Promise<BookmarkResult>;
+	DeleteOrphanedBookmarks(arg1: string[]): Promise<BookmarkReconcileResult>;
+	DeletePasswordFromKeychain(arg1: string, arg2: string, arg3: string, arg4: string): Promise<void>;
+	DeleteWindowsAppBookmark(arg1: string): Promise<BookmarkResult>;
+	DetachTunnelLog(arg1: string): Promise<DetachedView>;
+	DetectOpenPorts(arg1: string, arg2: number[]): Promise<DetectedPort[]>;
+	DisableOperatorMode(): Promise<void>;
+	DismissPreviousTunnels(): Promise<void>;
+	DismissStartupIssue(arg1: string): Promise<void>;
+	DuplicateFavorite(arg1: string, arg2: FavoritePatch): Promise<Favorite>;
+	EnableOperatorMode(arg1: string, arg2: string[], arg3: boolean, arg4: number): Promise<OperatorStatus>;
+	EnableService(arg1: string, arg2: string): Promise<void>;
+	EndJITSession(arg1: string): Promise<void>;
+	ExportConnectionCatalog(arg1: string[], arg2: string): Promise<ExportResult>;
+	ExportCredentialMappings(arg1: string[]): Promise<ExportResult>;
+	ExportJumpDesktop(arg1: string[]): Promise<ExportResult>;
+	ExportRDPFile(arg1: string): Promise<ExportResult>;
+	ExportRoyalTSX(arg1: string[], arg2: boolean): Promise<ExportResult>;
+	ExportTunnelSpec(arg1: string): Promise<TunnelSnippet>;
+	FindGcloud(): Promise<GcloudInfo>;
+	FlushCredentialCache(): Promise<number>;
+	GenerateBookmarkID(arg1: string, arg2: string, arg3: string): Promise<string>;
+	GenerateWindowsPassword(arg1: WindowsPasswordRequest): Promise<WindowsPasswordResult>;
+	GetActiveTunnels(): Promise<TunnelInfo[]>;
+	GetAgentStatus(): Promise<AgentStatus>;
+	GetAllProjectDefaults(): Promise<ProjectDefaultsEntry[]>;
+	GetAuditLog(arg1: number): Promise<AuditEntry[]>;
+	GetCloudAuthStatus(arg1: string, arg2: string): Promise<CloudAuthStatus>;
+	GetCloudMonitoringStatus(): Promise<CloudMonitoringStatus>;
+	GetConnectionInfo(arg1: string): Promise<Favorite>;
+	GetConnectionString(arg1: string, arg2: string): Promise<string>;
+	GetConnectionStringFlavors(): Promise<string[]>;
+	GetContract(): Promise<Contract>;
+	GetDatabaseClients(): Promise<DatabaseClient[]>;
+	GetDetachedViews(): Promise<DetachedView[]>;
+	GetDialQueueStats(): Promise<DialQueueStats>;
+	GetEnvironments(): Promise<EnvironmentInfo[]>;
+	GetFavoriteByVM(arg1: string, arg2: string, arg3: string): Promise<Favorite>;
+	GetFavoriteConflicts(): Promise<FavoriteConflict[]>;
+	GetFavoriteStatuses(): Promise<Record<string, VMPowerState>>;
+	GetFavorites(): Promise<Favorite[]>;
+	GetFavoritesSorted(arg1: string): Promise<Favorite[]>;
+	GetFreePort(): Promise<number>;
+	GetGroupAppearances(): Promise<Record<string, Appearance>>;
+	GetHostsHelperStatus(): Promise<HostsHelperStatus>;
+	GetICloudSyncStatus(): Promise<ICloudSyncStatus>;
+	GetInstanceMetadata(arg1: string, arg2: string, arg3: string): Promise<InstanceMetadata>;
+	GetJITSessions(): Promise<JITSession[]>;
+	GetKubeContexts(): Promise<string[]>;
+	GetLANShares(): Promise<LANShare[]>;
+	GetLanguages(): Promise<LanguageInfo>;
+	GetLastConnection(): Promise<LastConnection>;
+	GetLaunchActions(): Promise<LaunchAction[]>;
+	GetManagedDefaults(): Promise<ManagedDefaults>;
+	GetNetworkActivity(): Promise<NetworkEndpoint[]>;
+	GetOperation(arg1: string): Promise<Operation>;
+	GetOperatorStatus(): Promise<OperatorStatus>;
+	GetPasswordFromKeychain(arg1: string, arg2: string, arg3: string, arg4: string): Promise<string>;
+	GetPatchStatus(arg1: string): Promise<PatchStatus>;
+	GetPendingRestore(): Promise<TunnelSpec[]>;
+	GetPowerStatus(): Promise<PowerStatus>;
+	GetProjectDefaults(arg1: string): Promise<ProjectDefaults>;
+	GetQuietMode(): Promise<QuietModeStatus>;
+	GetRecentErrors(): Promise<RecentError[]>;
+	GetRemotePortPresets(): Promise<RemotePortPreset[]>;
+	GetSMBMounts(): Promise<SMBMount[]>;
+	GetSSHConfig(arg1: string[]): Promise<string>;
+	GetSessionRecords(arg1: number): Promise<SessionRecords>;
+	GetSettings(): Promise<AppSettings>;
+	GetStartupIssues(): Promise<StartupIssue[]>;
+	GetSuggestedConnections(): Promise<SuggestedConnection[]>;
+	GetTargetKinds(): Promise<string[]>;
+	GetTelemetryPreview(): Promise<TelemetryPreview>;
+	GetTunnel(arg1: string): Promise<TunnelInfo>;
+	GetTunnelMetrics(arg1: string): Promise<TunnelMetrics>;
+	GetTunnelPauseStatus(): Promise<TunnelPauseStatus>;
+	GetTunnels(): Promise<TunnelInfo[]>;
+	GetTunnelsByProject(): Promise<TunnelGroup[]>;
+	GetUsedPorts(): Promise<number[]>;
+	GetVMCostEstimate(arg1: string, arg2: string, arg3: string): Promise<VMCostEstimate>;
+	GetZoneCatalog(arg1: string): Promise<ZoneCatalog>;
+	ImportConnectionCatalog(arg1: string): Promise<CatalogImportResult>;
+	ImportCredentialMappings(): Promise<CredentialLinkResult>;
+	ImportTunnelSpec(arg1: string): Promise<SharedTunnel>;
+	InstallAgent(): Promise<void>;
+	InstallHostsHelper(): Promise<void>;
+	IsFavorite(arg1: string, arg2: string, arg3: string): Promise<boolean>;
+	LaunchFreeRDP(arg1: string): Promise<void>;
+	ListExternalTunnels(): Promise<ExternalTunnel[]>;
+	ListOperations(): Promise<Operation[]>;
+	ListProjects(arg1: string): Promise<Project[]>;
+	ListTargets(arg1: string, arg2: string): Promise<Target[]>;
+	ListVMs(arg1: string, arg2: string): Promise<VM[]>;
+	ListVMsByRegion(arg1: string, arg2: string, arg3: string): Promise<RegionVMs[]>;
+	LockOperator(): Promise<OperatorStatus>;
+	MountSMBShare(arg1: string, arg2: string): Promise<SMBMount>;
+	MoveDetachedView(arg1: string, arg2: number, arg3: number, arg4: number, arg5: number): Promise<void>;
+	OpenDatabaseClient(arg1: string, arg2: string): Promise<ConnectResult>;
+	OpenGcloudInstallPage(): Promise<void>;
+	OpenWebPreview(arg1: string, arg2: WebSettings): Promise<ConnectResult>;
+	OpenWindowsApp(): Promise<void>;
+	PreviewInstanceMetadataItem(arg1: MetadataUpdateRequest): Promise<MutationPlan>;
+	PreviewPatchJob(arg1: string): Promise<MutationPlan>;
+	PreviewStartVM(arg1: string, arg2: string, arg3: string): Promise<MutationPlan>;
+	ProbeTunnel(arg1: string): Promise<ProbeResult>;
+	ReconcileBookmarks(): Promise<BookmarkReconcileResult>;
+	ReconnectLastSession(): Promise<ConnectResult>;
+	RefreshAuth(): Promise<AuthStatus>;
+	RefreshFavoriteStatuses(): Promise<Record<string, VMPowerState>>;
+	RemoveFavorite(arg1: string): Promise<void>;
+	RemoveRemoteApp(arg1: string, arg2: string): Promise<void>;
+	RemoveTunnel(arg1: string): Promise<void>;
+	ReorderFavorites(arg1: string[]): Promise<void>;
+	ResolveFavoriteConflicts(arg1: ConflictResolution[]): Promise<void>;
+	ResolveHostname(arg1: string, arg2: string): Promise<HostnameResolution>;
+	ResolveInstance(arg1: string): Promise<InstanceResolution>;
+	RestoreConfigBackup(): Promise<void>;
+	RestorePreviousTunnels(): Promise<TunnelRestoreResult[]>;
+	RotatePasswordsForGroup(arg1: string, arg2: PasswordRotationOptions): Promise<PasswordRotationResult[]>;
+	RotatePasswordsForGroupAsync(arg1: string, arg2: PasswordRotationOptions): Promise<string>;
+	RunADCLogin(): Promise<AuthProgress>;
+	SaveExternalTunnel(arg1: number): Promise<Favorite>;
+	SaveLastConnection(arg1: string, arg2: string, arg3: string, arg4: string, arg5: number, arg6: number): Promise<void>;
+	SearchFavorites(arg1: string): Promise<FavoriteSearchResult[]>;
+	SetFavoriteAppearance(arg1: string, arg2: string, arg3: string): Promise<void>;
+	SetGroupAppearance(arg1: string, arg2: string, arg3: string): Promise<void>;
+	SetInstanceMetadataItem(arg1: MetadataUpdateRequest): Promise<void>;
+	SetOnConnectLauncher(arg1: string, arg2: Launcher): Promise<void>;
+	SetProjectDefaults(arg1: string, arg2: ProjectDefaults): Promise<void>;
+	SetRemoteApp(arg1: string, arg2: RemoteApp): Promise<void>;
+	SetTelemetryOptIn(arg1: boolean): Promise<void>;
+	SetTunnelIdleTimeout(arg1: string, arg2: number): Promise<void>;
+	SetTunnelMaxDuration(arg1: string, arg2: number): Promise<void>;
+	SetTunnelsPaused(arg1: boolean): Promise<TunnelPauseStatus>;
+	ShareTunnelOnLAN(arg1: string, arg2: string, arg3: string[], arg4: boolean, arg5: string): Promise<LANShare>;
+	SimulateTunnel(): Promise<TunnelInfo>;
+	StartGroupAsync(arg1: string): Promise<string>;
+	StartJITSession(arg1: JITRequest): Promise<JITSession>;
+	StartTunnel(arg1: string, arg2: string, arg3: string, arg4: number): Promise<TunnelInfo>;
+	StartTunnelForConnection(arg1: string): Promise<TunnelInfo>;
+	StartTunnelWithBookmark(arg1: string, arg2: string, arg3: string, arg4: number): Promise<TunnelInfo>;
+	StartTunnelWithRemotePort(arg1: string, arg2: string, arg3: string, arg4: number, arg5: number): Promise<TunnelInfo>;
+	StartVM(arg1: string, arg2: string, arg3: string, arg4: boolean): Promise<void>;
+	StartVMAsync(arg1: string, arg2: string, arg3: string, arg4: boolean): Promise<string>;
+	StopAllTunnels(): Promise<number>;
+	StopLANShare(arg1: string): Promise<void>;
+	StopTunnel(arg1: string): Promise<void>;
+	StopTunnelAndDeleteBookmark(arg1: string): Promise<void>;
+	StopTunnelsForProject(arg1: string): Promise<number>;
+	SyncICloudNow(): Promise<void>;
+	SyncICloudNowAsync(): Promise<string>;
+	TailTunnelLog(arg1: string, arg2: number): Promise<TunnelLogTail>;
+	TakeOverExternalTunnel(arg1: number): Promise<TunnelInfo>;
+	TriggerPatchJob(arg1: string, arg2: boolean): Promise<PatchJobInfo>;
+	UninstallAgent(): Promise<void>;
+	UninstallHostsHelper(): Promise<void>;
+	UnlockOperator(arg1: string): Promise<OperatorStatus>;
+	UnlockOperatorWithTouchID(): Promise<OperatorStatus>;
+	UnmountSMBShare(arg1: string): Promise<void>;
+	UnwatchVMs(arg1: string): Promise<void>;
+	UpdateBookmarkPort(arg1: string, arg2: string, arg3: string, arg4: string, arg5: number): Promise<BookmarkResult>;
+	UpdateConnectionBookmarkStatus(arg1: string, arg2: boolean, arg3: boolean): Promise<void>;
+	UpdateDatabaseSettings(arg1: string, arg2: DatabaseSettings): Promise<void>;
+	UpdateFavorite(arg1: string, arg2: string, arg3: number): Promise<void>;
+	UpdateFavoriteBookmarkGroup(arg1: string, arg2: string): Promise<void>;
+	UpdateFavoriteFields(arg1: string, arg2: FavoritePatch): Promise<void>;
+	UpdateFavoriteHostsEntry(arg1: string, arg2: boolean): Promise<void>;
+	UpdateFavoriteNotes(arg1: string, arg2: string): Promise<void>;
+	UpdateGcloud(): Promise<GcloudInfo>;
+	UpdatePreambleSettings(arg1: string, arg2: PreambleSettings): Promise<void>;
+	UpdateSettings(arg1: AppSettings): Promise<void>;
+	UpdateVNCSettings(arg1: string, arg2: VNCSettings): Promise<void>;
+	UpdateWebSettings(arg1: string, arg2: WebSettings): Promise<void>;
+	ValidateFavoriteZones(): Promise<FavoriteZoneIssue[]>;
+	VerifyBookmark(arg1: string): Promise<BookmarkVerification>;
+	WatchVMs(arg1: string): Promise<VMSnapshot>;
+}
+
+// Events maps event names to the payload passed to EventsOn handlers
+export interface Events {
+	"bookmarks:reconciled": BookmarkReconcileResult;
+	"config:synced": void;
+	"errors:reported": RecentError;
+	"favorites:conflicts": FavoriteConflict[];
+	"favorites:status": VMPowerState[];
+	"gcloud:update": GcloudUpdateOutput;
+	"jit:ended": string;
+	"launch:connect": ConnectResult;
+	"notifications:queued": QueuedNotification[];
+	"operation:update": Operation;
+	"password:progress": PasswordProgress;
+	"power:mode": PowerStatus;
+	"session:reconnect": ConnectResult;
+	"startup:issues": StartupIssue[];
+	"tunnel:expired": TunnelExpiryNotice;
+	"tunnel:expiry-warning": TunnelExpiryNotice;
+	"tunnel:idle-stopped": string;
+	"tunnel:import": SharedTunnel;
+	"tunnel:log": TunnelLogBatch;
+	"tunnel:stalled": TunnelStall;
+	"tunnels:adopted": TunnelInfo[];
+	"tunnels:paused": TunnelPauseStatus;
+	"vm:boot-progress": BootProgress;
+	"vms:changes": VMChanges;
+	"window:attached": string;
+	"window:detached": DetachedView;
+	"windowsapp:status": WindowsAppStatus;
+}
@@ -18,9 +18,13 @@ const state = {
     },
     isStartingTunnel: false,
     currentView: 'new', // 'new', 'details', 'empty'
-    pendingRestartNotification: false // Flag to show restart notification after password modal closes
+    pendingRestartNotification: false, // Flag to show restart notification after password modal closes
+    logView: { tunnelId: null, nextSeq: 1 } // Tunnel whose log is shown and the next expected line
 };
 
+// Maximum log lines kept in the logs panel
+const MAX_LOG_ENTRIES = 500;
+
 // DOM Elements
 const elements = {
     // Auth
@@ -131,6 +135,7 @@ async function init() {
     await loadTunnels();
     setupEventListeners();
     startStatusPolling();
+    window.runtime.EventsOn('tunnel:log', handleTunnelLogBatch);
     
     // Show appropriate view
     if (state.connections.length === 0) {
@@ -317,6 +322,7 @@ async function loadConnections() {
         const favorites = await window.go.main.App.GetFavorites();
         state.connections = (favorites || []).map(f => ({
             id: f.id,
+            bookmarkId: f.bookmarkId,
             name: f.displayName,
             projectId: f.projectId,
             projectName: f.projectName,
@@ -579,7 +585,7 @@ async function deleteConnection() {
         // Delete Windows App bookmark first if it exists
         if (hasBookmark && state.windowsAppInstalled) {
             try {
-                await window.go.main.App.DeleteWindowsAppBookmark(state.selectedConnection.id);
+                await window.go.main.App.DeleteWindowsAppBookmark(state.selectedConnection.bookmarkId);
             } catch (e) {
                 console.error('Failed to delete bookmark:', e);
                 // Continue with connection deletion even if bookmark deletion fails
@@ -972,7 +978,8 @@ async function executeBookmarkCreation() {
                 connectionId: state.selectedConnection.id,
                 username: username,
                 saveToKeychain: saveToKeychain,
-                updateBookmark: true // Always update bookmark since that's the purpose
+                updateBookmark: true, // Always update bookmark since that's the purpose
+                confirmed: true // Submitting the modal confirms the reset
             });
             
             hideLoadingModal();
@@ -1177,7 +1184,8 @@ async function executePasswordGeneration() {
             connectionId: state.selectedConnection.id,
             username: username,
             saveToKeychain: saveToKeychain,
-            updateBookmark: updateBookmark
+            updateBookmark: updateBookmark,
+            confirmed: true // Submitting the modal confirms the reset
         });
         
         hideLoadingModal();
@@ -1296,15 +1304,55 @@ function updateButtons() {
 }
 
 function updateLogsUI(tunnel) {
-    if (!tunnel?.logs?.length) {
-        elements.logsContainer.innerHTML = '<div class="log-placeholder">No logs yet...</div>';
+    // Lines arrive through tunnel:log events, only reload when switching tunnels
+    if (!tunnel || tunnel.id === state.logView.tunnelId) return;
+
+    state.logView = { tunnelId: tunnel.id, nextSeq: 1 };
+    elements.logsContainer.innerHTML = '<div class="log-placeholder">No logs yet...</div>';
+    loadTunnelLog(tunnel.id, 0);
+}
+
+async function loadTunnelLog(tunnelId, fromSeq) {
+    try {
+        const tail = await window.go.main.App.TailTunnelLog(tunnelId, fromSeq);
+        if (tunnelId !== state.logView.tunnelId) return;
+        appendLogEntries(tail.entries);
+        state.logView.nextSeq = tail.nextSeq;
+    } catch (error) {
+        console.error('Failed to load tunnel log:', error);
+    }
+}
+
+function handleTunnelLogBatch(batch) {
+    if (batch.tunnelId !== state.logView.tunnelId || !batch.entries?.length) return;
+
+    // Catch up from the backend if lines were coalesced away or missed
+    if (batch.skipped || batch.entries[0].seq > state.logView.nextSeq) {
+        loadTunnelLog(batch.tunnelId, state.logView.nextSeq);
         return;
     }
-    
-    elements.logsContainer.innerHTML = tunnel.logs.map(log => 
-        `<div class="log-entry">${escapeHtml(log)}</div>`
-    ).join('');
-    elements.logsContainer.scrollTop = elements.logsContainer.scrollHeight;
+
+    const entries = batch.entries.filter(entry => entry.seq >= state.logView.nextSeq);
+    appendLogEntries(entries);
+    if (entries.length) {
+        state.logView.nextSeq = entries[entries.length - 1].seq + 1;
+    }
+}
+
+function appendLogEntries(entries) {
+    if (!entries?.length) return;
+
+    const container = elements.logsContainer;
+    container.querySelector('.log-placeholder')?.remove();
+    container.insertAdjacentHTML('beforeend', entries.map(entry =>
+        `<div class="log-entry">${escapeHtml(`[${entry.time}] ${entry.message}`)}</div>`
+    ).join(''));
+
+    const rendered = container.querySelectorAll('.log-entry');
+    for (let i = 0; i < rendered.length - MAX_LOG_ENTRIES; i++) {
+        rendered[i].remove();
+    }
+    container.scrollTop = container.scrollHeight;
 }
 
 function toggleOverflowMenu() {
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// GcloudUpdateEvent is emitted with a GcloudUpdateOutput for each line gcloud prints while updating
+	GcloudUpdateEvent = "gcloud:update"
+	// minGcloudVersion is the oldest Google Cloud SDK release not reported as outdated
+	minGcloudVersion = 450
+	// gcloudUpdateTimeout bounds downloading and installing components
+	gcloudUpdateTimeout = 15 * time.Minute
+)
+
+// GcloudUpdateOutput is a line of output of a running gcloud update
+type GcloudUpdateOutput struct {
+	Line string `json:"line"`
+	Done bool   `json:"done"` // Set on the final event, which has no line
+	// Error is set on the final event when the update failed
+	Error string `json:"error,omitempty"`
+}
+
+// gcloudUpdateState prevents two updates from running at once
+type gcloudUpdateState struct {
+	mu      sync.Mutex
+	running bool
+}
+
+// gcloudOutdated reports whether a version printed by gcloud is older than minGcloudVersion
+func gcloudOutdated(version string) bool {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	return err == nil && major < minGcloudVersion
+}
+
+// UpdateGcloud runs gcloud components update without prompting and emits GcloudUpdateEvent for
+// each line of output. Installations managed by Homebrew or a system package manager can't be
+// updated this way and return an error saying so.
+func (a *App) UpdateGcloud() (GcloudInfo, error) {
+	info := a.FindGcloud()
+	if !info.Found {
+		return info, errors.New(info.Error)
+	}
+
+	a.gcloudUpdate.mu.Lock()
+	if a.gcloudUpdate.running {
+		a.gcloudUpdate.mu.Unlock()
+		return info, fmt.Errorf("a gcloud update is already running")
+	}
+	a.gcloudUpdate.running = true
+	a.gcloudUpdate.mu.Unlock()
+	defer func() {
+		a.gcloudUpdate.mu.Lock()
+		a.gcloudUpdate.running = false
+		a.gcloudUpdate.mu.Unlock()
+	}()
+
+	stream := &lineEmitter{emit: func(line string) {
+		a.emitEvent(GcloudUpdateEvent, GcloudUpdateOutput{Line: line})
+	}}
+	_, err := runCommandSpec(commandSpec{
+		name: info.Path,
+		args: []string{"components", "update", "--quiet"},
+		// --quiet answers the confirmation, the environment also covers prompts of older releases
+		env:      []string{"CLOUDSDK_CORE_DISABLE_PROMPTS=1"},
+		timeout:  gcloudUpdateTimeout,
+		combined: true,
+		stream:   stream,
+	})
+	stream.flush()
+
+	if err != nil {
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) && strings.Contains(cmdErr.Output, "component manager is disabled") {
+			err = fmt.Errorf("this gcloud installation is managed by a package manager, update it with Homebrew or the package manager that installed it")
+		} else {
+			err = fmt.Errorf("failed to update gcloud: %w", err)
+		}
+		a.emitEvent(GcloudUpdateEvent, GcloudUpdateOutput{Done: true, Error: err.Error()})
+		return info, err
+	}
+
+	a.emitEvent(GcloudUpdateEvent, GcloudUpdateOutput{Done: true})
+	return a.verifyGcloud(info.Path), nil
+}
+
+// lineEmitter calls emit for each complete line written to it
+type lineEmitter struct {
+	emit func(line string)
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *lineEmitter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i == -1 {
+			break
+		}
+		// Progress bars redraw with carriage returns, each redraw becomes a line
+		if line := strings.TrimSpace(string(w.buf[:i])); line != "" {
+			w.emit(line)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush emits a trailing line without a newline
+func (w *lineEmitter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if line := strings.TrimSpace(string(w.buf)); line != "" {
+		w.emit(line)
+	}
+	w.buf = nil
+}
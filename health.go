@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+const (
+	// FavoriteStatusEvent is emitted with the favorites whose VM power state changed
+	FavoriteStatusEvent = "favorites:status"
+	// MinHealthCheckInterval is the shortest allowed polling interval in seconds
+	MinHealthCheckInterval = 30
+
+	// Maximum number of concurrent instance lookups per poll
+	healthCheckConcurrency = 4
+)
+
+// VMPowerState represents the last known power state of a favorite's VM
+type VMPowerState struct {
+	FavoriteID string `json:"favoriteId"`
+	Status     string `json:"status"` // Compute Engine status (RUNNING, TERMINATED, ...) or "UNKNOWN"
+	CheckedAt  string `json:"checkedAt"`
+	Error      string `json:"error,omitempty"`
+	InstanceTimes
+}
+
+// healthCheckerState tracks the background VM status poller
+type healthCheckerState struct {
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	interval time.Duration
+	states   map[string]VMPowerState
+}
+
+// GetFavoriteStatuses returns the last known VM power state per favorite ID
+func (a *App) GetFavoriteStatuses() map[string]VMPowerState {
+	a.health.mu.Lock()
+	defer a.health.mu.Unlock()
+
+	states := make(map[string]VMPowerState, len(a.health.states))
+	for id, state := range a.health.states {
+		states[id] = state
+	}
+	return states
+}
+
+// RefreshFavoriteStatuses polls every favorite's VM immediately
+func (a *App) RefreshFavoriteStatuses() map[string]VMPowerState {
+	a.checkFavoriteHealth(context.Background())
+	return a.GetFavoriteStatuses()
+}
+
+// applyHealthChecker starts, restarts or stops the poller according to settings and low power mode
+func (a *App) applyHealthChecker() {
+	interval := time.Duration(a.GetSettings().HealthCheckInterval) * time.Second
+	if a.lowPowerActive() {
+		interval = 0
+	}
+
+	a.health.mu.Lock()
+	defer a.health.mu.Unlock()
+
+	if a.health.cancel != nil {
+		if a.health.interval == interval {
+			return
+		}
+		a.health.cancel()
+		a.health.cancel = nil
+	}
+	if interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.health.cancel = cancel
+	a.health.interval = interval
+
+	go func() {
+		a.checkFavoriteHealth(ctx)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.checkFavoriteHealth(ctx)
+			}
+		}
+	}()
+}
+
+// checkFavoriteHealth fetches the status of every favorite's VM and emits changes
+func (a *App) checkFavoriteHealth(ctx context.Context) {
+	if a.tokenSource == nil {
+		return
+	}
+	defer beginActivity("Checking VM status")()
+
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return
+	}
+
+	// Targets in other clouds have no Compute Engine power state
+	var favorites []Favorite
+	for _, f := range a.GetFavorites() {
+		if inGoogleCloud(&f) {
+			favorites = append(favorites, f)
+		}
+	}
+	results := make([]VMPowerState, len(favorites))
+
+	sem := make(chan struct{}, healthCheckConcurrency)
+	var wg sync.WaitGroup
+	for i, f := range favorites {
+		wg.Add(1)
+		go func(i int, f Favorite) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			state := VMPowerState{
+				FavoriteID: f.ID,
+				Status:     "UNKNOWN",
+				CheckedAt:  time.Now().Format(time.RFC3339),
+			}
+			instance, err := computeService.Instances.Get(f.ProjectID, f.Zone, f.InstanceName).Context(ctx).Do()
+			if err != nil {
+				state.Error = err.Error()
+				if disabled := a.serviceDisabled(f.ProjectID, err); disabled != nil {
+					state.Error = disabled.Error()
+				}
+			} else {
+				state.Status = instance.Status
+				state.InstanceTimes = instanceTimes(instance)
+			}
+			results[i] = state
+		}(i, f)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Record new states and collect the ones that changed
+	var changed []VMPowerState
+	a.health.mu.Lock()
+	previous := a.health.states
+	a.health.states = make(map[string]VMPowerState, len(results))
+	for _, state := range results {
+		a.health.states[state.FavoriteID] = state
+		if old, ok := previous[state.FavoriteID]; !ok || old.Status != state.Status {
+			changed = append(changed, state)
+		}
+	}
+	a.health.mu.Unlock()
+
+	if len(changed) > 0 && a.ctx != nil {
+		runtime.EventsEmit(a.ctx, FavoriteStatusEvent, changed)
+	}
+}
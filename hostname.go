@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// destGroupTarget is an IAP destination group host used instead of an instance
+type destGroupTarget struct {
+	Host      string
+	Region    string
+	Network   string
+	DestGroup string
+}
+
+// HostnameResolution describes which instance an internal DNS name points at
+type HostnameResolution struct {
+	Hostname     string `json:"hostname"`
+	Found        bool   `json:"found"`
+	InstanceName string `json:"instanceName,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	MatchedBy    string `json:"matchedBy,omitempty"` // "internal-dns", "hostname" or "ip"
+	Error        string `json:"error,omitempty"`
+}
+
+// hostnameLookupTimeout bounds the local DNS lookup used to match NIC IPs
+const hostnameLookupTimeout = 3 * time.Second
+
+// ResolveHostname finds the instance behind an internal DNS name in a project
+func (a *App) ResolveHostname(projectID, hostname string) HostnameResolution {
+	hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+	result := HostnameResolution{Hostname: hostname}
+	if hostname == "" {
+		result.Error = "hostname is required"
+		return result
+	}
+	if a.tokenSource == nil {
+		result.Error = "not authenticated"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to create compute client: %v", err)
+		return result
+	}
+
+	// Compute Engine internal DNS: VM.ZONE.c.PROJECT.internal or VM.c.PROJECT.internal
+	labels := strings.Split(hostname, ".")
+	if len(labels) >= 4 && labels[len(labels)-1] == "internal" && labels[len(labels)-3] == "c" {
+		name := labels[0]
+		var zones []string
+		if len(labels) == 5 {
+			zones = []string{labels[1]}
+		} else if zones, err = findInstanceZones(ctx, computeService, projectID, name); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		for _, zone := range zones {
+			if _, err := computeService.Instances.Get(projectID, zone, name).Context(ctx).Do(); err == nil {
+				result.Found, result.InstanceName, result.Zone, result.MatchedBy = true, name, zone, "internal-dns"
+				return result
+			}
+		}
+	}
+
+	// Otherwise match custom hostnames, then NIC IPs the name resolves to locally (e.g. over VPN)
+	lookupCtx, lookupCancel := context.WithTimeout(ctx, hostnameLookupTimeout)
+	addrs, _ := net.DefaultResolver.LookupHost(lookupCtx, hostname)
+	lookupCancel()
+	ips := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		ips[addr] = true
+	}
+
+	var ipMatch *HostnameResolution
+	err = computeService.Instances.AggregatedList(projectID).Pages(ctx, func(page *compute.InstanceAggregatedList) error {
+		for zonePath, instanceList := range page.Items {
+			zone := strings.TrimPrefix(zonePath, "zones/")
+			for _, instance := range instanceList.Instances {
+				if strings.EqualFold(instance.Hostname, hostname) {
+					result.Found, result.InstanceName, result.Zone, result.MatchedBy = true, instance.Name, zone, "hostname"
+					return nil
+				}
+				if ipMatch != nil {
+					continue
+				}
+				for _, nic := range instance.NetworkInterfaces {
+					if ips[nic.NetworkIP] {
+						ipMatch = &HostnameResolution{Hostname: hostname, Found: true, InstanceName: instance.Name, Zone: zone, MatchedBy: "ip"}
+						break
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to list instances: %v", err)
+		return result
+	}
+	if !result.Found && ipMatch != nil {
+		return *ipMatch
+	}
+	return result
+}
+
+// AddHostnameFavorite saves a connection that targets an internal DNS name instead of an instance.
+// Region, network and destGroup configure the destination group fallback and may be empty.
+func (a *App) AddHostnameFavorite(displayName, projectID, projectName, hostname, region, network, destGroup string, remotePort int) (*Favorite, error) {
+	resolution := a.ResolveHostname(projectID, hostname)
+	if resolution.Error != "" {
+		return nil, fmt.Errorf("%s", resolution.Error)
+	}
+	if !resolution.Found && destGroup == "" {
+		return nil, fmt.Errorf("%s does not match an instance, configure a destination group to reach it", resolution.Hostname)
+	}
+	if destGroup != "" && region == "" {
+		return nil, fmt.Errorf("a region is required for destination group tunnels")
+	}
+
+	instanceName, zone := resolution.Hostname, ""
+	if resolution.Found {
+		instanceName, zone = resolution.InstanceName, resolution.Zone
+	}
+	if displayName == "" {
+		displayName = resolution.Hostname
+	}
+
+	return a.addFavorite(displayName, projectID, projectName, instanceName, zone, remotePort, func(f *Favorite) {
+		f.Hostname = resolution.Hostname
+		f.Region = strings.TrimSpace(region)
+		f.Network = strings.TrimSpace(network)
+		f.DestGroup = strings.TrimSpace(destGroup)
+	})
+}
+
+// updateResolvedInstance stores the instance a hostname favorite currently resolves to
+func (a *App) updateResolvedInstance(connectionID, instanceName, zone string) {
+	a.updateFavorite(connectionID, func(f *Favorite) error {
+		f.InstanceName = instanceName
+		f.Zone = zone
+		return nil
+	})
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// hostsHelperPath is the root-owned helper that rewrites the app's block of /etc/hosts
+	hostsHelperPath = "/Library/PrivilegedHelperTools/com.wails.iap-tunnel-manager.hosts"
+	// hostsAllowlistPath lists the hostnames the helper may map, root-owned so only an
+	// administrator can extend it
+	hostsAllowlistPath = hostsHelperPath + ".allow"
+	// hostsSudoersPath lets the user run the helper without a password, and nothing else
+	hostsSudoersPath = "/etc/sudoers.d/iap-tunnel-manager"
+	// hostsFilePath is the file the helper edits
+	hostsFilePath = "/etc/hosts"
+	// hostsAgentPollInterval is how often the GUI checks whether an agent tunnel with an entry stopped
+	hostsAgentPollInterval = 5 * time.Second
+	// Markers of the block the helper owns in /etc/hosts, the rest of the file is left alone
+	hostsBlockBegin = "# BEGIN IAP Tunnel Manager"
+	hostsBlockEnd   = "# END IAP Tunnel Manager"
+)
+
+// hostsHelperScript is installed as hostsHelperPath. It replaces the app's block with entries
+// mapping the hostnames it is given to 127.0.0.1, no hostnames remove the block. Hostnames
+// missing from the allowlist are refused.
+const hostsHelperScript = `#!/bin/sh
+# Installed by IAP Tunnel Manager: maps tunneled hostnames to 127.0.0.1 in a marked block of /etc/hosts
+set -eu
+HOSTS=/etc/hosts
+ALLOW="` + hostsAllowlistPath + `"
+BEGIN="` + hostsBlockBegin + `"
+END="` + hostsBlockEnd + `"
+for h in "$@"; do
+	case "$h" in
+	"" | -* | *[!a-z0-9.-]*) echo "invalid hostname: $h" >&2; exit 64 ;;
+	esac
+	grep -Fxq -- "$h" "$ALLOW" || { echo "hostname not allowed: $h" >&2; exit 77; }
+done
+tmp=$(mktemp /etc/hosts.iaptm.XXXXXX)
+trap 'rm -f "$tmp"' EXIT
+awk -v b="$BEGIN" -v e="$END" '$0 == b { skip = 1; next } $0 == e { skip = 0; next } !skip' "$HOSTS" > "$tmp"
+if [ $# -gt 0 ]; then
+	{ echo "$BEGIN"; for h in "$@"; do printf '127.0.0.1\t%s\n' "$h"; done; echo "$END"; } >> "$tmp"
+fi
+cat "$tmp" > "$HOSTS"
+dscacheutil -flushcache
+killall -HUP mDNSResponder 2>/dev/null || true
+`
+
+// Patterns of what goes into the helper's arguments and the sudoers rule
+var (
+	hostsHostnamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+	macUserPattern       = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+)
+
+// HostsEntry is an internal hostname mapped to 127.0.0.1 while its tunnel runs
+type HostsEntry struct {
+	TunnelID string `json:"tunnelId"`
+	Hostname string `json:"hostname"`
+}
+
+// HostsHelperStatus reports the /etc/hosts helper and the entries it maintains
+type HostsHelperStatus struct {
+	Installed bool         `json:"installed"`
+	Entries   []HostsEntry `json:"entries"`
+	// Allowed are the hostnames the helper may map, those of the hostname favorites at install time
+	Allowed []string `json:"allowed"`
+}
+
+// hostsState holds the hostnames mapped for running tunnels, by tunnel ID
+type hostsState struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+// hostsHelperInstalled reports whether the helper, its allowlist and sudoers rule are in place
+func hostsHelperInstalled() bool {
+	for _, path := range []string{hostsHelperPath, hostsAllowlistPath, hostsSudoersPath} {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// hostsAllowlist returns the hostnames the installed helper may map
+func hostsAllowlist() []string {
+	data, err := os.ReadFile(hostsAllowlistPath)
+	if err != nil {
+		return []string{}
+	}
+	return uniqueStrings(strings.Fields(string(data)))
+}
+
+// normalizeHostsHostname lower-cases a hostname and drops a trailing dot, as the helper expects
+func normalizeHostsHostname(hostname string) string {
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
+
+// GetHostsHelperStatus returns whether the /etc/hosts helper is installed and its entries
+func (a *App) GetHostsHelperStatus() HostsHelperStatus {
+	status := HostsHelperStatus{Installed: hostsHelperInstalled(), Entries: []HostsEntry{}, Allowed: hostsAllowlist()}
+	a.hosts.mu.Lock()
+	for tunnelID, hostname := range a.hosts.entries {
+		status.Entries = append(status.Entries, HostsEntry{TunnelID: tunnelID, Hostname: hostname})
+	}
+	a.hosts.mu.Unlock()
+	sort.Slice(status.Entries, func(i, j int) bool {
+		return status.Entries[i].Hostname < status.Entries[j].Hostname
+	})
+	return status
+}
+
+// InstallHostsHelper installs the /etc/hosts helper and a sudoers rule that lets this user run
+// it without a password, after asking for an administrator password once. The helper may only map
+// the hostnames of the current hostname favorites, install it again after adding one.
+func (a *App) InstallHostsHelper() error {
+	username := macUser()
+	if !macUserPattern.MatchString(username) {
+		return fmt.Errorf("unsupported user name: %s", username)
+	}
+	var allowed []string
+	for _, f := range a.GetFavorites() {
+		if hostname := normalizeHostsHostname(f.Hostname); hostsHostnamePattern.MatchString(hostname) {
+			allowed = append(allowed, hostname)
+		}
+	}
+	if len(allowed) == 0 {
+		return fmt.Errorf("there are no hostname favorites to map in /etc/hosts")
+	}
+	allowed = uniqueStrings(allowed)
+	sort.Strings(allowed)
+
+	dir, err := os.MkdirTemp("", "iaptm-hosts")
+	if err != nil {
+		return fmt.Errorf("failed to prepare the helper: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	script, sudoers, allowlist := filepath.Join(dir, "helper"), filepath.Join(dir, "sudoers"), filepath.Join(dir, "allow")
+	if err := os.WriteFile(script, []byte(hostsHelperScript), 0600); err != nil {
+		return fmt.Errorf("failed to prepare the helper: %w", err)
+	}
+	if err := os.WriteFile(allowlist, []byte(strings.Join(allowed, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to prepare the helper: %w", err)
+	}
+	rule := fmt.Sprintf("%s ALL=(root) NOPASSWD: %s\n", username, hostsHelperPath)
+	if err := os.WriteFile(sudoers, []byte(rule), 0600); err != nil {
+		return fmt.Errorf("failed to prepare the helper: %w", err)
+	}
+	if output, err := runCommand("visudo", "-c", "-f", sudoers); err != nil {
+		return commandError("invalid sudoers rule", err, output)
+	}
+
+	// Paths are passed as arguments so they never need AppleScript or shell escaping
+	output, err := runCommand("osascript",
+		"-e", "on run argv",
+		"-e", `do shell script "mkdir -p " & quoted form of (item 3 of argv) & " && install -o root -g wheel -m 0755 " & quoted form of (item 1 of argv) & " " & quoted form of (item 4 of argv) & " && install -o root -g wheel -m 0644 " & quoted form of (item 6 of argv) & " " & quoted form of (item 7 of argv) & " && install -o root -g wheel -m 0440 " & quoted form of (item 2 of argv) & " " & quoted form of (item 5 of argv) with administrator privileges`,
+		"-e", "end run",
+		script, sudoers, filepath.Dir(hostsHelperPath), hostsHelperPath, hostsSudoersPath, allowlist, hostsAllowlistPath,
+	)
+	if err != nil {
+		return commandError("failed to install the hosts helper", err, output)
+	}
+	a.audit("hosts.helper_installed", "", "", strings.Join(allowed, ", "))
+	return nil
+}
+
+// UninstallHostsHelper removes the app's /etc/hosts entries, the helper and its sudoers rule
+func (a *App) UninstallHostsHelper() error {
+	a.hosts.mu.Lock()
+	a.hosts.entries = nil
+	a.hosts.mu.Unlock()
+	if hostsHelperInstalled() {
+		if err := runHostsHelper(nil); err != nil {
+			return err
+		}
+	}
+	output, err := runCommand("osascript",
+		"-e", "on run argv",
+		"-e", `do shell script "rm -f " & quoted form of (item 1 of argv) & " " & quoted form of (item 2 of argv) & " " & quoted form of (item 3 of argv) with administrator privileges`,
+		"-e", "end run",
+		hostsHelperPath, hostsSudoersPath, hostsAllowlistPath,
+	)
+	if err != nil {
+		return commandError("failed to remove the hosts helper", err, output)
+	}
+	a.audit("hosts.helper_removed", "", "", hostsHelperPath)
+	return nil
+}
+
+// UpdateFavoriteHostsEntry sets whether the favorite's hostname is mapped to 127.0.0.1 in
+// /etc/hosts while its tunnel runs, for clients that need the real name (TLS SNI, Kerberos).
+// The client must then use the favorite's local port, so it works best when the local port is
+// the service's port. Applies to tunnels started afterwards.
+func (a *App) UpdateFavoriteHostsEntry(favoriteID string, enabled bool) error {
+	if enabled && !hostsHelperInstalled() {
+		return fmt.Errorf("install the hosts helper first")
+	}
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		if enabled && f.Hostname == "" {
+			return fmt.Errorf("only hostname favorites can be mapped in /etc/hosts")
+		}
+		if hostname := normalizeHostsHostname(f.Hostname); enabled && !containsString(hostsAllowlist(), hostname) {
+			return fmt.Errorf("install the hosts helper again to allow mapping %s", hostname)
+		}
+		f.HostsEntry = enabled
+		return nil
+	})
+}
+
+// addHostsEntry maps hostname to 127.0.0.1 until the tunnel stops. Tunnels the agent runs are
+// polled through its API, the agent can't run the GUI's stop hooks.
+func (a *App) addHostsEntry(tunnelID, hostname string) error {
+	hostname = normalizeHostsHostname(hostname)
+	if !hostsHostnamePattern.MatchString(hostname) {
+		return fmt.Errorf("can't map %s in /etc/hosts", hostname)
+	}
+	if !hostsHelperInstalled() {
+		return fmt.Errorf("the hosts helper is not installed")
+	}
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+	if !ok && !a.agentAttached() {
+		return fmt.Errorf("tunnel not found")
+	}
+
+	a.hosts.mu.Lock()
+	if a.hosts.entries == nil {
+		a.hosts.entries = make(map[string]string)
+	}
+	a.hosts.entries[tunnelID] = hostname
+	err := a.applyHostsEntriesLocked()
+	a.hosts.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		go a.removeHostsEntryWhenStopped(tunnelID)
+		return nil
+	}
+	tunnel.addStopHook(func() { a.removeHostsEntry(tunnelID) })
+	tunnel.addLog(fmt.Sprintf("Mapped %s to 127.0.0.1 in /etc/hosts", hostname))
+	return nil
+}
+
+// removeHostsEntryWhenStopped drops the entry of an agent tunnel once the tunnel is no longer
+// active, or the entry was removed otherwise
+func (a *App) removeHostsEntryWhenStopped(tunnelID string) {
+	ticker := time.NewTicker(hostsAgentPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.hosts.mu.Lock()
+		_, mapped := a.hosts.entries[tunnelID]
+		a.hosts.mu.Unlock()
+		if !mapped {
+			return
+		}
+		info, err := a.GetTunnel(tunnelID)
+		if err != nil || (info.Status != "running" && info.Status != "starting") {
+			a.removeHostsEntry(tunnelID)
+			return
+		}
+	}
+}
+
+// removeHostsEntry drops the tunnel's /etc/hosts entry
+func (a *App) removeHostsEntry(tunnelID string) {
+	a.hosts.mu.Lock()
+	defer a.hosts.mu.Unlock()
+	if _, ok := a.hosts.entries[tunnelID]; !ok {
+		return
+	}
+	delete(a.hosts.entries, tunnelID)
+	if err := a.applyHostsEntriesLocked(); err != nil {
+		a.reportError(ErrorSourceTunnel, "/etc/hosts", err)
+	}
+}
+
+// applyHostsEntriesLocked writes the mapped hostnames through the helper. Callers hold hosts.mu.
+func (a *App) applyHostsEntriesLocked() error {
+	var hostnames []string
+	for _, hostname := range a.hosts.entries {
+		hostnames = append(hostnames, hostname)
+	}
+	hostnames = uniqueStrings(hostnames)
+	sort.Strings(hostnames)
+	return runHostsHelper(hostnames)
+}
+
+// runHostsHelper replaces the app's /etc/hosts block with hostnames, never prompting for a password
+func runHostsHelper(hostnames []string) error {
+	output, err := runCommand("sudo", append([]string{"-n", hostsHelperPath}, hostnames...)...)
+	if err != nil {
+		return commandError("failed to update /etc/hosts", err, output)
+	}
+	return nil
+}
+
+// resetHostsEntries replaces the app's /etc/hosts block at startup: entries a crash left behind
+// are removed, those of hostname tunnels the agent kept running are mapped again
+func (a *App) resetHostsEntries(adopted []TunnelInfo) {
+	entries := make(map[string]string)
+	for _, t := range adopted {
+		if t.Status != "running" && t.Status != "starting" {
+			continue
+		}
+		fav := a.GetConnectionInfo(t.FavoriteID)
+		if fav == nil || !fav.HostsEntry || fav.Hostname == "" {
+			continue
+		}
+		if hostname := normalizeHostsHostname(fav.Hostname); hostsHostnamePattern.MatchString(hostname) {
+			entries[t.ID] = hostname
+		}
+	}
+	if len(entries) == 0 || !hostsHelperInstalled() {
+		a.clearHostsEntries()
+		return
+	}
+
+	a.hosts.mu.Lock()
+	a.hosts.entries = entries
+	err := a.applyHostsEntriesLocked()
+	a.hosts.mu.Unlock()
+	if err != nil {
+		a.logWarningf("Failed to update /etc/hosts: %v", err)
+	}
+	for tunnelID := range entries {
+		go a.removeHostsEntryWhenStopped(tunnelID)
+	}
+}
+
+// releaseHostsEntries removes the /etc/hosts entries of the tunnels this process runs, at
+// shutdown. While the agent is attached its tunnels outlive the GUI, so their entries stay.
+func (a *App) releaseHostsEntries() {
+	if !a.agentAttached() {
+		a.clearHostsEntries()
+		return
+	}
+
+	a.tunnelsMu.RLock()
+	owned := make(map[string]bool, len(a.tunnels))
+	for id := range a.tunnels {
+		owned[id] = true
+	}
+	a.tunnelsMu.RUnlock()
+
+	a.hosts.mu.Lock()
+	defer a.hosts.mu.Unlock()
+	changed := false
+	for tunnelID := range a.hosts.entries {
+		if owned[tunnelID] {
+			delete(a.hosts.entries, tunnelID)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := a.applyHostsEntriesLocked(); err != nil {
+		a.logWarningf("Failed to clean up /etc/hosts: %v", err)
+	}
+}
+
+// clearHostsEntries removes the app's /etc/hosts block, e.g. one left behind by a crash. It
+// does nothing when the block isn't there.
+func (a *App) clearHostsEntries() {
+	a.hosts.mu.Lock()
+	defer a.hosts.mu.Unlock()
+	a.hosts.entries = nil
+	data, err := os.ReadFile(hostsFilePath)
+	if err != nil || !strings.Contains(string(data), hostsBlockBegin) || !hostsHelperInstalled() {
+		return
+	}
+	if err := runHostsHelper(nil); err != nil {
+		a.logWarningf("Failed to clean up /etc/hosts: %v", err)
+	}
+}
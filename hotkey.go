@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReconnectEvent is emitted after the reconnect shortcut was handled
+const ReconnectEvent = "session:reconnect"
+
+// Carbon modifier masks used by RegisterEventHotKey
+const (
+	hotkeyModCmd     = 1 << 8
+	hotkeyModShift   = 1 << 9
+	hotkeyModOption  = 1 << 11
+	hotkeyModControl = 1 << 12
+)
+
+// hotkeyKeyCodes maps key names to macOS virtual key codes (ANSI layout)
+var hotkeyKeyCodes = map[string]uint32{
+	"a": 0x00, "s": 0x01, "d": 0x02, "f": 0x03, "h": 0x04, "g": 0x05, "z": 0x06, "x": 0x07,
+	"c": 0x08, "v": 0x09, "b": 0x0B, "q": 0x0C, "w": 0x0D, "e": 0x0E, "r": 0x0F, "y": 0x10,
+	"t": 0x11, "1": 0x12, "2": 0x13, "3": 0x14, "4": 0x15, "6": 0x16, "5": 0x17, "9": 0x19,
+	"7": 0x1A, "8": 0x1C, "0": 0x1D, "o": 0x1F, "u": 0x20, "i": 0x22, "p": 0x23, "l": 0x25,
+	"j": 0x26, "k": 0x28, "n": 0x2D, "m": 0x2E,
+	"f1": 0x7A, "f2": 0x78, "f3": 0x63, "f4": 0x76, "f5": 0x60, "f6": 0x61,
+	"f7": 0x62, "f8": 0x64, "f9": 0x65, "f10": 0x6D, "f11": 0x67, "f12": 0x6F,
+}
+
+// hotkey is a parsed global shortcut
+type hotkey struct {
+	KeyCode   uint32
+	Modifiers uint32
+}
+
+// parseHotkey parses shortcuts like "alt+cmd+i" or "ctrl+shift+f5"
+func parseHotkey(spec string) (hotkey, error) {
+	var hk hotkey
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(spec, " ", "")), "+")
+	if len(parts) < 2 {
+		return hk, fmt.Errorf("hotkey %q needs at least one modifier and a key", spec)
+	}
+
+	for _, mod := range parts[:len(parts)-1] {
+		switch mod {
+		case "cmd", "command", "⌘":
+			hk.Modifiers |= hotkeyModCmd
+		case "alt", "option", "opt", "⌥":
+			hk.Modifiers |= hotkeyModOption
+		case "ctrl", "control", "⌃":
+			hk.Modifiers |= hotkeyModControl
+		case "shift", "⇧":
+			hk.Modifiers |= hotkeyModShift
+		default:
+			return hk, fmt.Errorf("unknown modifier %q in hotkey %q", mod, spec)
+		}
+	}
+
+	key := parts[len(parts)-1]
+	code, ok := hotkeyKeyCodes[key]
+	if !ok {
+		return hk, fmt.Errorf("unsupported key %q in hotkey %q", key, spec)
+	}
+	hk.KeyCode = code
+	return hk, nil
+}
+
+// applyReconnectHotkey registers (or clears) the global reconnect shortcut from settings
+func (a *App) applyReconnectHotkey() error {
+	spec := a.GetSettings().ReconnectHotkey
+	if spec == "" {
+		unregisterGlobalHotkey()
+		return nil
+	}
+
+	hk, err := parseHotkey(spec)
+	if err != nil {
+		return err
+	}
+	return registerGlobalHotkey(hk, func() {
+		go a.reconnectFromHotkey()
+	})
+}
+
+// reconnectFromHotkey runs ReconnectLastSession and reports the result to the frontend
+func (a *App) reconnectFromHotkey() {
+	result := a.ReconnectLastSession()
+	if !result.Success {
+		a.logWarningf("Reconnect shortcut failed: %s", result.Error)
+	}
+	a.emitEvent(ReconnectEvent, result)
+}
+
+// ReconnectLastSession starts a tunnel for the last connection (unless one is running) and opens RDP.
+// Production VMs must be confirmed again like any other connect.
+func (a *App) ReconnectLastSession() ConnectResult {
+	last := a.GetLastConnection()
+	if last == nil {
+		return ConnectResult{Error: "No previous connection to reconnect"}
+	}
+
+	if fav := a.favoriteFor(last.ProjectID, last.InstanceName, last.Zone, last.RemotePort, last.PreferredLocalPort); fav != nil {
+		return a.ConnectFavorite(fav.ID)
+	}
+	// An ad hoc tunnel to a VM saved as a production favorite on other ports
+	if fav := a.favoriteFor(last.ProjectID, last.InstanceName, last.Zone, 0, 0); fav != nil && a.needsProductionConfirmation(fav) {
+		return a.confirmProductionResult(fav)
+	}
+
+	// Ad hoc connection: reuse a running tunnel if there is one
+	tunnel := a.findActiveTunnel(&Favorite{
+		ProjectID:    last.ProjectID,
+		InstanceName: last.InstanceName,
+		Zone:         last.Zone,
+		RemotePort:   a.projectRemotePort(last.ProjectID, last.RemotePort),
+		LocalPort:    last.PreferredLocalPort,
+	})
+	if tunnel == nil {
+		var err error
+		tunnel, err = a.StartTunnelWithRemotePort(last.ProjectID, last.InstanceName, last.Zone, last.PreferredLocalPort, last.RemotePort)
+		if err != nil {
+			return ConnectResult{Error: fmt.Sprintf("Failed to start tunnel: %v", err)}
+		}
+	}
+	return a.openRDPForTunnel(nil, tunnel)
+}
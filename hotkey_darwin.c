@@ -0,0 +1,65 @@
+// Global hotkey registration through the Carbon Event Manager.
+// Carbon hotkey APIs must run on the main thread, so calls are marshalled there.
+
+#include <Carbon/Carbon.h>
+#include <dispatch/dispatch.h>
+#include <pthread.h>
+
+extern void iaptmHotkeyPressed(void);
+
+static EventHandlerRef iaptmHandlerRef = NULL;
+static EventHotKeyRef iaptmHotKeyRef = NULL;
+
+typedef struct {
+	UInt32 keyCode;
+	UInt32 modifiers;
+	OSStatus status;
+} iaptmRegisterArgs;
+
+static OSStatus iaptmHotkeyHandler(EventHandlerCallRef next, EventRef event, void *userData) {
+	iaptmHotkeyPressed();
+	return noErr;
+}
+
+static void iaptmUnregisterOnMain(void *ctx) {
+	if (iaptmHotKeyRef != NULL) {
+		UnregisterEventHotKey(iaptmHotKeyRef);
+		iaptmHotKeyRef = NULL;
+	}
+}
+
+static void iaptmRegisterOnMain(void *ctx) {
+	iaptmRegisterArgs *args = (iaptmRegisterArgs *)ctx;
+
+	if (iaptmHandlerRef == NULL) {
+		EventTypeSpec spec = { kEventClassKeyboard, kEventHotKeyPressed };
+		args->status = InstallApplicationEventHandler(&iaptmHotkeyHandler, 1, &spec, NULL, &iaptmHandlerRef);
+		if (args->status != noErr) {
+			return;
+		}
+	}
+
+	iaptmUnregisterOnMain(NULL);
+
+	EventHotKeyID hotKeyID = { 'IAPT', 1 };
+	args->status = RegisterEventHotKey(args->keyCode, args->modifiers, hotKeyID,
+		GetApplicationEventTarget(), 0, &iaptmHotKeyRef);
+}
+
+static void iaptmRunOnMain(void *ctx, dispatch_function_t fn) {
+	if (pthread_main_np()) {
+		fn(ctx);
+	} else {
+		dispatch_sync_f(dispatch_get_main_queue(), ctx, fn);
+	}
+}
+
+int iaptmRegisterHotkey(uint32_t keyCode, uint32_t modifiers) {
+	iaptmRegisterArgs args = { keyCode, modifiers, noErr };
+	iaptmRunOnMain(&args, iaptmRegisterOnMain);
+	return (int)args.status;
+}
+
+void iaptmUnregisterHotkey(void) {
+	iaptmRunOnMain(NULL, iaptmUnregisterOnMain);
+}
@@ -0,0 +1,54 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework Carbon
+#include <stdint.h>
+
+int iaptmRegisterHotkey(uint32_t keyCode, uint32_t modifiers);
+void iaptmUnregisterHotkey(void);
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	hotkeyHandler   func()
+	hotkeyHandlerMu sync.Mutex
+)
+
+// registerGlobalHotkey registers a system-wide shortcut, replacing any previous one
+func registerGlobalHotkey(hk hotkey, handler func()) error {
+	hotkeyHandlerMu.Lock()
+	hotkeyHandler = handler
+	hotkeyHandlerMu.Unlock()
+
+	if status := C.iaptmRegisterHotkey(C.uint32_t(hk.KeyCode), C.uint32_t(hk.Modifiers)); status != 0 {
+		return fmt.Errorf("failed to register hotkey (OSStatus %d), it may be used by another application", int(status))
+	}
+	return nil
+}
+
+// unregisterGlobalHotkey removes the registered shortcut
+func unregisterGlobalHotkey() {
+	C.iaptmUnregisterHotkey()
+
+	hotkeyHandlerMu.Lock()
+	hotkeyHandler = nil
+	hotkeyHandlerMu.Unlock()
+}
+
+//export iaptmHotkeyPressed
+func iaptmHotkeyPressed() {
+	hotkeyHandlerMu.Lock()
+	handler := hotkeyHandler
+	hotkeyHandlerMu.Unlock()
+
+	if handler != nil {
+		handler()
+	}
+}
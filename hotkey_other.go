@@ -0,0 +1,13 @@
+//go:build !darwin
+
+package main
+
+import "fmt"
+
+// registerGlobalHotkey is only implemented on macOS
+func registerGlobalHotkey(hk hotkey, handler func()) error {
+	return fmt.Errorf("global hotkeys are only supported on macOS")
+}
+
+// unregisterGlobalHotkey is only implemented on macOS
+func unregisterGlobalHotkey() {}
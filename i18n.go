@@ -0,0 +1,160 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// localeFS holds the message catalogs, one JSON object of code -> format string per language
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// defaultLanguage is used for missing catalogs and messages
+const defaultLanguage = "en"
+
+// Message codes for backend strings shown to users
+const (
+	MsgConnectionNotFound  = "connection_not_found"
+	MsgInstanceMoved       = "instance_moved"
+	MsgInstanceNotFound    = "instance_not_found"
+	MsgTunnelStartFailed   = "tunnel_start_failed"
+	MsgTunnelStartTimeout  = "tunnel_start_timeout"
+	MsgVMPortNotReady      = "vm_port_not_ready"
+	MsgRDPClientFailed     = "rdp_client_failed"
+	MsgNoRDPClient         = "no_rdp_client"
+	MsgConfirmProduction   = "confirm_production"
+	MsgScreenSharingFailed = "screen_sharing_failed"
+	MsgLauncherFailed      = "launcher_failed"
+	MsgPreviewProxyFailed  = "preview_proxy_failed"
+	MsgBrowserFailed       = "browser_failed"
+	MsgNotifyExpiryWarning = "notify_expiry_warning"
+	MsgNotifyExpired       = "notify_expired"
+	MsgNotifyIdleStopped   = "notify_idle_stopped"
+	MsgNotifyQueuedSummary = "notify_queued_summary"
+	MsgServiceDisabled     = "service_disabled"
+)
+
+// catalogState holds the message catalogs by language, parsed on first use
+type catalogState struct {
+	once     sync.Once
+	messages map[string]map[string]string
+}
+
+var (
+	systemLanguageOnce sync.Once
+	systemLanguage     string
+)
+
+// LanguageInfo describes the active and available languages
+type LanguageInfo struct {
+	Current   string   `json:"current"`
+	System    string   `json:"system"`
+	Available []string `json:"available"`
+}
+
+// GetLanguages returns the language used for backend messages and the available catalogs
+func (a *App) GetLanguages() LanguageInfo {
+	info := LanguageInfo{Current: a.language(), System: detectSystemLanguage()}
+	for lang := range a.messageCatalogs() {
+		info.Available = append(info.Available, lang)
+	}
+	sort.Strings(info.Available)
+	return info
+}
+
+// tr formats the message for code in the user's language, falling back to English
+func (a *App) tr(code string, args ...interface{}) string {
+	catalogs := a.messageCatalogs()
+	format, ok := catalogs[a.language()][code]
+	if !ok {
+		format, ok = catalogs[defaultLanguage][code]
+	}
+	if !ok {
+		format = code
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// connectError returns a failed ConnectResult with a translated message
+func (a *App) connectError(tunnel *TunnelInfo, code string, args ...interface{}) ConnectResult {
+	return ConnectResult{Tunnel: tunnel, Error: a.tr(code, args...), Code: code}
+}
+
+// language returns the configured language, or the system one if it has a catalog
+func (a *App) language() string {
+	catalogs := a.messageCatalogs()
+	if lang := normalizeLanguage(a.GetSettings().Language); lang != "" {
+		if _, ok := catalogs[lang]; ok {
+			return lang
+		}
+	}
+	if lang := detectSystemLanguage(); lang != "" {
+		if _, ok := catalogs[lang]; ok {
+			return lang
+		}
+	}
+	return defaultLanguage
+}
+
+// messageCatalogs returns the embedded message catalogs, parsing them on first use
+func (a *App) messageCatalogs() map[string]map[string]string {
+	a.catalogs.once.Do(func() {
+		catalogs := make(map[string]map[string]string)
+		a.catalogs.messages = catalogs
+		files, _ := localeFS.ReadDir("locales")
+		for _, f := range files {
+			data, err := localeFS.ReadFile(path.Join("locales", f.Name()))
+			if err != nil {
+				continue
+			}
+			var catalog map[string]string
+			if err := json.Unmarshal(data, &catalog); err != nil {
+				continue
+			}
+			catalogs[strings.TrimSuffix(f.Name(), ".json")] = catalog
+		}
+	})
+	return a.catalogs.messages
+}
+
+// appleLanguagePattern matches the first entry of `defaults read -g AppleLanguages`
+var appleLanguagePattern = regexp.MustCompile(`"?([A-Za-z]{2,3})[-_A-Za-z0-9]*"?,?`)
+
+// detectSystemLanguage returns the macOS preferred language, or the one from LANG
+func detectSystemLanguage() string {
+	systemLanguageOnce.Do(func() {
+		if output, err := runCommand("defaults", "read", "-g", "AppleLanguages"); err == nil {
+			for _, line := range strings.Split(string(output), "\n") {
+				if m := appleLanguagePattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+					systemLanguage = normalizeLanguage(m[1])
+					return
+				}
+			}
+		}
+		systemLanguage = normalizeLanguage(os.Getenv("LANG"))
+	})
+	return systemLanguage
+}
+
+// normalizeLanguage reduces tags like "uk-UA" or "de_DE.UTF-8" to the language code
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_."); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "c" || tag == "posix" {
+		return ""
+	}
+	return tag
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// ICloudSyncFileName is the name of the synced file inside the iCloud Drive folder
+	ICloudSyncFileName = "config.json"
+	// ConfigSyncedEvent is emitted after remote changes were merged into the local config
+	ConfigSyncedEvent = "config:synced"
+
+	icloudPollInterval = 15 * time.Second
+	// Tombstones older than this are dropped during sync
+	icloudTombstoneTTL = 90 * 24 * time.Hour
+)
+
+// ICloudSyncStatus represents the state of iCloud Drive sync
+type ICloudSyncStatus struct {
+	Enabled      bool   `json:"enabled"`
+	Available    bool   `json:"available"`
+	Path         string `json:"path,omitempty"`
+	LastSyncedAt string `json:"lastSyncedAt,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// iCloudSyncState tracks the background iCloud Drive watcher
+type iCloudSyncState struct {
+	mu           sync.Mutex
+	syncMu       sync.Mutex // serializes sync runs
+	cancel       context.CancelFunc
+	lastModTime  time.Time
+	lastSyncedAt time.Time
+	lastError    string
+}
+
+// syncedConfig is the subset of the config stored in iCloud Drive.
+// Secrets never live in the config; machine-local bookmark state is stripped before upload.
+type syncedConfig struct {
+	Favorites        []Favorite        `json:"favorites"`
+	DeletedFavorites map[string]string `json:"deletedFavorites,omitempty"`
+}
+
+// icloudDriveDir returns the iCloud Drive root for the current user
+func icloudDriveDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, "Library", "Mobile Documents", "com~apple~CloudDocs")
+}
+
+// icloudSyncPath returns the path of the synced config file
+func icloudSyncPath() string {
+	dir := icloudDriveDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, AppName, ICloudSyncFileName)
+}
+
+// GetICloudSyncStatus returns the current iCloud Drive sync status
+func (a *App) GetICloudSyncStatus() ICloudSyncStatus {
+	status := ICloudSyncStatus{
+		Enabled: a.GetSettings().ICloudSync,
+		Path:    icloudSyncPath(),
+	}
+	if dir := icloudDriveDir(); dir != "" {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			status.Available = true
+		}
+	}
+
+	a.icloud.mu.Lock()
+	if !a.icloud.lastSyncedAt.IsZero() {
+		status.LastSyncedAt = a.icloud.lastSyncedAt.Format(time.RFC3339)
+	}
+	status.Error = a.icloud.lastError
+	a.icloud.mu.Unlock()
+
+	if status.Enabled && !status.Available && status.Error == "" {
+		status.Error = "iCloud Drive is not available. Enable it in System Settings > Apple ID > iCloud."
+	}
+	return status
+}
+
+// SyncICloudNow merges the iCloud Drive copy with the local config immediately
+func (a *App) SyncICloudNow() error {
+	if !a.GetSettings().ICloudSync {
+		return fmt.Errorf("iCloud sync is disabled")
+	}
+	return a.syncICloud()
+}
+
+// startICloudSync starts the background watcher (no-op if already running)
+func (a *App) startICloudSync() {
+	a.icloud.mu.Lock()
+	if a.icloud.cancel != nil {
+		a.icloud.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.icloud.cancel = cancel
+	a.icloud.mu.Unlock()
+
+	go func() {
+		a.syncICloud()
+
+		ticker := time.NewTicker(icloudPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Local changes are still pushed by scheduleICloudSync
+				if a.lowPowerActive() {
+					continue
+				}
+				if a.icloudChanged() {
+					a.syncICloud()
+				}
+			}
+		}
+	}()
+}
+
+// stopICloudSync stops the background watcher
+func (a *App) stopICloudSync() {
+	a.icloud.mu.Lock()
+	defer a.icloud.mu.Unlock()
+
+	if a.icloud.cancel != nil {
+		a.icloud.cancel()
+		a.icloud.cancel = nil
+	}
+}
+
+// scheduleICloudSync pushes local changes to iCloud Drive in the background
+func (a *App) scheduleICloudSync() {
+	a.icloud.mu.Lock()
+	running := a.icloud.cancel != nil
+	a.icloud.mu.Unlock()
+
+	if running {
+		go a.syncICloud()
+	}
+}
+
+// icloudChanged reports whether the synced file changed since the last sync
+func (a *App) icloudChanged() bool {
+	info, err := os.Stat(icloudSyncPath())
+	if err != nil {
+		return false
+	}
+
+	a.icloud.mu.Lock()
+	defer a.icloud.mu.Unlock()
+	return !info.ModTime().Equal(a.icloud.lastModTime)
+}
+
+// syncICloud merges the remote copy into the local config and writes the result back to iCloud Drive
+func (a *App) syncICloud() error {
+	a.icloud.syncMu.Lock()
+	defer a.icloud.syncMu.Unlock()
+
+	err := a.syncICloudLocked()
+
+	a.icloud.mu.Lock()
+	if err != nil {
+		a.icloud.lastError = err.Error()
+	} else {
+		a.icloud.lastError = ""
+		a.icloud.lastSyncedAt = time.Now()
+	}
+	a.icloud.mu.Unlock()
+
+	return err
+}
+
+func (a *App) syncICloudLocked() error {
+	syncPath := icloudSyncPath()
+	if syncPath == "" {
+		return fmt.Errorf("iCloud Drive path not available")
+	}
+	if info, err := os.Stat(icloudDriveDir()); err != nil || !info.IsDir() {
+		return fmt.Errorf("iCloud Drive is not available")
+	}
+
+	// Read the remote copy (missing file means first sync)
+	var remote syncedConfig
+	remoteData, err := os.ReadFile(syncPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read iCloud config: %w", err)
+	}
+	if len(remoteData) > 0 {
+		if err := json.Unmarshal(remoteData, &remote); err != nil {
+			return fmt.Errorf("failed to parse iCloud config: %w", err)
+		}
+		// Macs running an older version still upload favorites without UUIDs
+		migrateFavoriteIDs(remote.Favorites, remote.DeletedFavorites)
+	}
+
+	// Merge into local config, the regular save writes the local files
+	var upload syncedConfig
+	localChanged := false
+	a.mutateConfig(func(cfg *AppConfig) error {
+		deleted := mergeTombstones(cfg.DeletedFavorites, remote.DeletedFavorites)
+		merged := mergeFavorites(cfg.Favorites, remote.Favorites, deleted)
+		upload = syncedConfig{
+			Favorites:        make([]Favorite, len(merged)),
+			DeletedFavorites: deleted,
+		}
+		copy(upload.Favorites, merged)
+		if favoritesEqual(cfg.Favorites, merged) && len(deleted) == len(cfg.DeletedFavorites) {
+			return errConfigUnchanged
+		}
+		cfg.Favorites = merged
+		cfg.DeletedFavorites = deleted
+		localChanged = true
+		return nil
+	})
+
+	if localChanged {
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, ConfigSyncedEvent)
+		}
+		// Favorites added on two Macs can collide, let the user pick how to reconcile them
+		a.notifyFavoriteConflicts()
+	}
+
+	// Bookmarks live in the local Windows App, so their state doesn't travel
+	for i := range upload.Favorites {
+		upload.Favorites[i].HasBookmark = false
+		upload.Favorites[i].BookmarkHasCreds = false
+		upload.Favorites[i].BookmarkUnavailable = false
+	}
+
+	data, err := json.MarshalIndent(upload, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal iCloud config: %w", err)
+	}
+	if !bytes.Equal(data, remoteData) {
+		if err := writePrivateFile(syncPath, data); err != nil {
+			return fmt.Errorf("failed to write iCloud config: %w", err)
+		}
+	}
+
+	if info, err := os.Stat(syncPath); err == nil {
+		a.icloud.mu.Lock()
+		a.icloud.lastModTime = info.ModTime()
+		a.icloud.mu.Unlock()
+	}
+	return nil
+}
+
+// mergeTombstones combines deletion records, keeping the latest time and dropping expired ones
+func mergeTombstones(local, remote map[string]string) map[string]string {
+	merged := make(map[string]string)
+	cutoff := time.Now().Add(-icloudTombstoneTTL)
+	for _, source := range []map[string]string{local, remote} {
+		for id, deletedAt := range source {
+			t := parseTimestamp(deletedAt)
+			if t.Before(cutoff) {
+				continue
+			}
+			if existing, ok := merged[id]; ok && !parseTimestamp(existing).Before(t) {
+				continue
+			}
+			merged[id] = deletedAt
+		}
+	}
+	return merged
+}
+
+// mergeFavorites combines local and remote favorites, newest edit wins.
+// Local bookmark state and usage statistics are preserved since they are per machine.
+func mergeFavorites(local, remote []Favorite, deleted map[string]string) []Favorite {
+	remoteByID := make(map[string]Favorite, len(remote))
+	for _, f := range remote {
+		remoteByID[f.ID] = f
+	}
+
+	isDeleted := func(f Favorite) bool {
+		deletedAt, ok := deleted[f.ID]
+		return ok && parseTimestamp(deletedAt).After(favoriteUpdatedAt(f))
+	}
+
+	merged := make([]Favorite, 0, len(local)+len(remote))
+	seen := make(map[string]bool, len(local))
+	for _, f := range local {
+		seen[f.ID] = true
+		if r, ok := remoteByID[f.ID]; ok && favoriteUpdatedAt(r).After(favoriteUpdatedAt(f)) {
+			r.HasBookmark = f.HasBookmark
+			r.BookmarkHasCreds = f.BookmarkHasCreds
+			r.BookmarkUnavailable = f.BookmarkUnavailable
+			// Usage statistics are tracked per machine
+			r.LastConnectedAt = f.LastConnectedAt
+			r.ConnectCount = f.ConnectCount
+			r.HourCounts = f.HourCounts
+			f = r
+		}
+		if isDeleted(f) {
+			continue
+		}
+		merged = append(merged, f)
+	}
+	for _, r := range remote {
+		if seen[r.ID] || isDeleted(r) {
+			continue
+		}
+		r.HasBookmark = false
+		r.BookmarkHasCreds = false
+		r.BookmarkUnavailable = false
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// favoritesEqual compares two favorite lists field by field
+func favoritesEqual(a, b []Favorite) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// favoriteUpdatedAt returns the last modification time of a favorite
+func favoriteUpdatedAt(f Favorite) time.Time {
+	if f.UpdatedAt != "" {
+		return parseTimestamp(f.UpdatedAt)
+	}
+	return parseTimestamp(f.CreatedAt)
+}
+
+// parseTimestamp parses an RFC3339 timestamp, returning the zero time on failure
+func parseTimestamp(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
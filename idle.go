@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// TunnelIdleStoppedEvent is emitted when a tunnel was stopped after being idle
+	TunnelIdleStoppedEvent = "tunnel:idle-stopped"
+
+	// idleCheckInterval is how often idle tunnels are checked
+	idleCheckInterval = 30 * time.Second
+)
+
+// SetTunnelIdleTimeout stops the tunnel once it had no open connections for the given
+// number of minutes. Zero disables the idle timeout.
+func (a *App) SetTunnelIdleTimeout(tunnelID string, minutes int) error {
+	if minutes < 0 {
+		return fmt.Errorf("idle timeout must not be negative")
+	}
+	minutes = a.managedIdleTimeout(minutes)
+
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+	if !ok {
+		if a.agentAttached() {
+			return a.agentCall("SetTunnelIdleTimeout", nil, tunnelID, minutes)
+		}
+		return fmt.Errorf("tunnel not found")
+	}
+
+	tunnel.logsMu.Lock()
+	if tunnel.idleStop != nil {
+		close(tunnel.idleStop)
+		tunnel.idleStop = nil
+	}
+	tunnel.idleTimeout = time.Duration(minutes) * time.Minute
+	tunnel.lastActivity = time.Now()
+	var stop chan struct{}
+	if minutes > 0 {
+		stop = make(chan struct{})
+		tunnel.idleStop = stop
+	}
+	tunnel.logsMu.Unlock()
+
+	if minutes == 0 {
+		tunnel.addLog("Idle timeout disabled")
+		return nil
+	}
+	tunnel.addLog(fmt.Sprintf("Tunnel will stop after %d minutes without connections", minutes))
+	go a.watchIdleTunnel(tunnel, stop)
+	return nil
+}
+
+// watchIdleTunnel stops the tunnel when it stays idle past its timeout
+func (a *App) watchIdleTunnel(tunnel *Tunnel, stop chan struct{}) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		a.tunnelsMu.RLock()
+		active := tunnel.Status == "running" || tunnel.Status == "starting"
+		a.tunnelsMu.RUnlock()
+		if !active {
+			return
+		}
+
+		tunnel.logsMu.Lock()
+		idle := tunnel.activeConns == 0 && time.Since(tunnel.lastActivity) >= tunnel.idleTimeout
+		tunnel.logsMu.Unlock()
+		if !idle {
+			continue
+		}
+
+		tunnel.addLog("No connections within the idle timeout, stopping tunnel")
+		if err := a.StopTunnel(tunnel.ID); err != nil {
+			return
+		}
+		a.emitEvent(TunnelIdleStoppedEvent, tunnel.ID)
+		a.notify(a.tr(MsgNotifyIdleStopped, tunnel.VMName))
+		return
+	}
+}
+
+// connOpened records a new connection through the tunnel
+func (t *Tunnel) connOpened() {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	t.activeConns++
+	t.lastActivity = time.Now()
+	t.metrics.sessions.Add(1)
+}
+
+// connClosed records the end of a connection through the tunnel
+func (t *Tunnel) connClosed() {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	t.activeConns--
+	t.lastActivity = time.Now()
+}
+
+// stopIdleWatcher ends the idle watcher, if any
+func (t *Tunnel) stopIdleWatcher() {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	if t.idleStop != nil {
+		close(t.idleStop)
+		t.idleStop = nil
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JITSessionEndedEvent is emitted when a just-in-time session was torn down
+const JITSessionEndedEvent = "jit:ended"
+
+// JITRequest describes a time-boxed access request
+type JITRequest struct {
+	ConnectionID string `json:"connectionId"`
+	Minutes      int    `json:"minutes"`
+	Username     string `json:"username"`
+	// DeleteKeychainOnExpiry removes the temporary password from Keychain at the end
+	DeleteKeychainOnExpiry bool `json:"deleteKeychainOnExpiry"`
+	// Confirmed must be set when the ConfirmCloudMutations setting is on, the session resets the password
+	Confirmed bool `json:"confirmed,omitempty"`
+}
+
+// JITSession represents an active just-in-time access session
+type JITSession struct {
+	ID           string `json:"id"`
+	ConnectionID string `json:"connectionId"`
+	TunnelID     string `json:"tunnelId"`
+	Username     string `json:"username"`
+	StartedAt    string `json:"startedAt"`
+	ExpiresAt    string `json:"expiresAt"`
+	Password     string `json:"password,omitempty"` // Only returned by StartJITSession
+	// DeleteKeychainOnExpiry removes the temporary password from Keychain at the end
+	DeleteKeychainOnExpiry bool `json:"deleteKeychainOnExpiry,omitempty"`
+
+	timer *time.Timer
+}
+
+// jitState holds the active just-in-time sessions by ID. They are saved in StateFileName, so
+// sessions that expire while the app is closed are still torn down.
+type jitState struct {
+	mu       sync.Mutex
+	sessions map[string]*JITSession
+}
+
+// StartJITSession creates a temporary credential, starts the tunnel and schedules full teardown
+func (a *App) StartJITSession(req JITRequest) (*JITSession, error) {
+	a.countFeature("jit_session")
+	if req.Minutes <= 0 {
+		return nil, fmt.Errorf("time box must be at least one minute")
+	}
+	conn := a.GetConnectionInfo(req.ConnectionID)
+	if conn == nil {
+		return nil, fmt.Errorf("connection not found")
+	}
+	target := auditTarget(conn.ProjectID, conn.Zone, conn.InstanceName)
+
+	a.jit.mu.Lock()
+	for _, s := range a.jit.sessions {
+		if s.ConnectionID == req.ConnectionID {
+			a.jit.mu.Unlock()
+			return nil, fmt.Errorf("a just-in-time session is already active for this connection")
+		}
+	}
+	a.jit.mu.Unlock()
+
+	a.audit("jit.requested", conn.ID, target, fmt.Sprintf("Requested %d minute session", req.Minutes))
+
+	// Temporary credential, stored in Keychain and the bookmark for the session
+	creds := a.generateWindowsPassword(WindowsPasswordRequest{
+		ConnectionID:   req.ConnectionID,
+		Username:       req.Username,
+		SaveToKeychain: true,
+		UpdateBookmark: true,
+		Confirmed:      req.Confirmed,
+	})
+	if !creds.Success {
+		a.audit("jit.failed", conn.ID, target, "Credential creation failed: "+creds.Error)
+		return nil, fmt.Errorf("failed to create temporary credential: %s", creds.Error)
+	}
+	a.audit("jit.credential", conn.ID, target, fmt.Sprintf("Temporary password set for %s", creds.Username))
+
+	tunnel := a.findActiveTunnel(conn)
+	if tunnel == nil {
+		var err error
+		tunnel, err = a.StartTunnelForConnection(req.ConnectionID)
+		if err != nil {
+			a.audit("jit.failed", conn.ID, target, "Tunnel start failed: "+err.Error())
+			a.teardownJITCredentials(conn, creds.Username, req.DeleteKeychainOnExpiry)
+			return nil, fmt.Errorf("failed to start tunnel: %w", err)
+		}
+	}
+	a.audit("jit.tunnel", conn.ID, target, fmt.Sprintf("Tunnel open on localhost:%d", tunnel.LocalPort))
+
+	// The tunnel stops itself, the session timer below cleans up credentials
+	a.SetTunnelMaxDuration(tunnel.ID, req.Minutes)
+
+	now := time.Now()
+	session := &JITSession{
+		ID:                     fmt.Sprintf("jit-%s-%d", conn.ID, now.UnixNano()),
+		ConnectionID:           conn.ID,
+		TunnelID:               tunnel.ID,
+		Username:               creds.Username,
+		StartedAt:              now.Format(time.RFC3339),
+		ExpiresAt:              now.Add(time.Duration(req.Minutes) * time.Minute).Format(time.RFC3339),
+		DeleteKeychainOnExpiry: req.DeleteKeychainOnExpiry,
+	}
+	a.trackJITSession(session, time.Duration(req.Minutes)*time.Minute)
+	a.saveJITSessions()
+
+	a.audit("jit.started", conn.ID, target, fmt.Sprintf("Session %s expires at %s", session.ID, session.ExpiresAt))
+
+	result := *session
+	result.Password = creds.Password
+	return &result, nil
+}
+
+// EndJITSession tears down a just-in-time session before its time box expires
+func (a *App) EndJITSession(sessionID string) error {
+	if !a.endJITSession(sessionID, "ended by user") {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+// GetJITSessions returns the active just-in-time sessions, soonest expiry first
+func (a *App) GetJITSessions() []JITSession {
+	a.jit.mu.Lock()
+	defer a.jit.mu.Unlock()
+
+	sessions := make([]JITSession, 0, len(a.jit.sessions))
+	for _, s := range a.jit.sessions {
+		session := *s
+		session.timer = nil
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ExpiresAt < sessions[j].ExpiresAt
+	})
+	return sessions
+}
+
+// endJITSession stops the tunnel and strips credentials, returning false if the session is unknown
+func (a *App) endJITSession(sessionID, reason string) bool {
+	a.jit.mu.Lock()
+	session, ok := a.jit.sessions[sessionID]
+	if ok {
+		delete(a.jit.sessions, sessionID)
+	}
+	a.jit.mu.Unlock()
+	if !ok {
+		return false
+	}
+	session.timer.Stop()
+	a.saveJITSessions()
+
+	conn := a.GetConnectionInfo(session.ConnectionID)
+	target := ""
+	if conn != nil {
+		target = auditTarget(conn.ProjectID, conn.Zone, conn.InstanceName)
+	}
+
+	if info, err := a.GetTunnel(session.TunnelID); err == nil && (info.Status == "running" || info.Status == "starting") {
+		a.StopTunnel(session.TunnelID)
+		a.audit("jit.tunnel-stopped", session.ConnectionID, target, "Tunnel stopped")
+	}
+
+	if conn != nil {
+		a.teardownJITCredentials(conn, session.Username, session.DeleteKeychainOnExpiry)
+	}
+
+	a.audit("jit.ended", session.ConnectionID, target, fmt.Sprintf("Session %s %s", session.ID, reason))
+	a.emitEvent(JITSessionEndedEvent, session.ID)
+	return true
+}
+
+// trackJITSession adds a session and ends it once remaining has passed
+func (a *App) trackJITSession(session *JITSession, remaining time.Duration) {
+	a.jit.mu.Lock()
+	defer a.jit.mu.Unlock()
+	session.timer = time.AfterFunc(remaining, func() {
+		a.endJITSession(session.ID, "expired")
+	})
+	if a.jit.sessions == nil {
+		a.jit.sessions = make(map[string]*JITSession)
+	}
+	a.jit.sessions[session.ID] = session
+}
+
+// saveJITSessions saves the active sessions in the config
+func (a *App) saveJITSessions() {
+	sessions := a.GetJITSessions()
+	a.mutateConfig(func(cfg *AppConfig) error {
+		if len(sessions) == 0 && len(cfg.JITSessions) == 0 {
+			return errConfigUnchanged
+		}
+		cfg.JITSessions = sessions
+		return nil
+	})
+}
+
+// resumeJITSessions re-arms the sessions saved by the previous launch. Sessions that expired while
+// the app was closed are torn down right away, their tunnel is usually gone but the temporary
+// credentials are not.
+func (a *App) resumeJITSessions() {
+	a.configMu.RLock()
+	var saved []JITSession
+	if a.config != nil {
+		saved = append(saved, a.config.JITSessions...)
+	}
+	a.configMu.RUnlock()
+
+	for i := range saved {
+		session := saved[i]
+		var remaining time.Duration
+		if expiresAt, err := time.Parse(time.RFC3339, session.ExpiresAt); err == nil && time.Now().Before(expiresAt) {
+			remaining = time.Until(expiresAt)
+		}
+		a.trackJITSession(&session, remaining)
+	}
+}
+
+// teardownJITCredentials removes the temporary password from the bookmark and optionally Keychain
+func (a *App) teardownJITCredentials(conn *Favorite, username string, deleteKeychain bool) {
+	target := auditTarget(conn.ProjectID, conn.Zone, conn.InstanceName)
+
+	if a.CheckWindowsApp().Installed {
+		// Recreate the bookmark without credentials
+		a.DeleteWindowsAppBookmark(conn.BookmarkID)
+		if result := a.CreateWindowsAppBookmark(conn.ProjectID, conn.InstanceName, conn.Zone, conn.LocalPort); result.Success {
+			a.UpdateConnectionBookmarkStatus(conn.ID, true, false)
+			a.audit("jit.bookmark-stripped", conn.ID, target, "Bookmark credentials removed")
+		} else {
+			a.UpdateConnectionBookmarkStatus(conn.ID, false, false)
+			a.audit("jit.bookmark-stripped", conn.ID, target, "Bookmark removed: "+result.Error)
+		}
+	}
+
+	if deleteKeychain {
+		if err := a.DeletePasswordFromKeychain(conn.ProjectID, conn.Zone, conn.InstanceName, username); err == nil {
+			a.audit("jit.keychain-deleted", conn.ID, target, fmt.Sprintf("Keychain password for %s deleted", username))
+		}
+	}
+}
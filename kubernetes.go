@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TargetKindKubernetes is a service or pod reached through "kubectl port-forward", e.g. an
+// internal tool running on GKE
+const TargetKindKubernetes = "kubernetes"
+
+// kubeProjectPrefix starts the project ID of Kubernetes favorites: "kube:CONTEXT"
+const kubeProjectPrefix = "kube:"
+
+// KubernetesTarget locates a service or pod in a cluster of the kubeconfig
+type KubernetesTarget struct {
+	// Context is the kubeconfig context of the cluster, e.g. "gke_my-project_us-central1_tools"
+	Context   string `json:"context"`
+	Namespace string `json:"namespace"`
+	// Resource is "svc/NAME" or "pod/NAME"
+	Resource string `json:"resource"`
+}
+
+func init() {
+	targetProviders[TargetKindKubernetes] = func(a *App) TargetProvider { return kubernetesTargets{a} }
+}
+
+// GetKubeContexts returns the contexts of the kubeconfig; GKE clusters are added to it with
+// "gcloud container clusters get-credentials"
+func (a *App) GetKubeContexts() ([]string, error) {
+	kubectl, err := findCLI("kubectl")
+	if err != nil {
+		return nil, err
+	}
+	output, err := runCommand(kubectl, "config", "get-contexts", "--output", "name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kubeconfig contexts: %w", err)
+	}
+	contexts := []string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			contexts = append(contexts, line)
+		}
+	}
+	return contexts, nil
+}
+
+// AddKubernetesFavorite adds a favorite for a service or pod reached through kubectl port-forward
+func (a *App) AddKubernetesFavorite(displayName string, target KubernetesTarget, remotePort int) (*Favorite, error) {
+	target.Context = strings.TrimSpace(target.Context)
+	target.Namespace = strings.TrimSpace(target.Namespace)
+	target.Resource = strings.TrimSpace(target.Resource)
+	if target.Namespace == "" {
+		target.Namespace = "default"
+	}
+	if target.Context == "" {
+		return nil, fmt.Errorf("a kubeconfig context is required")
+	}
+	if !strings.HasPrefix(target.Resource, "svc/") && !strings.HasPrefix(target.Resource, "pod/") {
+		return nil, fmt.Errorf("resource must be svc/NAME or pod/NAME")
+	}
+	if remotePort == 0 {
+		return nil, fmt.Errorf("a remote port is required")
+	}
+	if displayName == "" {
+		displayName = target.Resource[strings.Index(target.Resource, "/")+1:]
+	}
+	return a.addFavorite(displayName, kubeProjectPrefix+target.Context, target.Context, target.Namespace+"/"+target.Resource, "", remotePort, func(f *Favorite) {
+		f.TargetKind = TargetKindKubernetes
+		f.Kubernetes = &target
+	})
+}
+
+// kubernetesTargets provides services and pods of the clusters in the kubeconfig
+type kubernetesTargets struct{ a *App }
+
+func (p kubernetesTargets) Kind() string { return TargetKindKubernetes }
+
+// List returns the services and running pods of the context in projectID, "kube:CONTEXT"
+func (p kubernetesTargets) List(ctx context.Context, projectID string) ([]Target, error) {
+	kubeContext, ok := strings.CutPrefix(projectID, kubeProjectPrefix)
+	if !ok || kubeContext == "" {
+		return nil, fmt.Errorf("not a kubeconfig context: %s", projectID)
+	}
+	kubectl, err := findCLI("kubectl")
+	if err != nil {
+		return nil, err
+	}
+	output, err := runCommandSpec(commandSpec{
+		name: kubectl,
+		args: []string{"--context", kubeContext, "get", "services,pods", "--all-namespaces", "--output", "json"},
+		ctx:  ctx,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services and pods: %w", err)
+	}
+
+	var list struct {
+		Items []struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Status struct {
+				Phase string `json:"phase"` // Pods only
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse services and pods: %w", err)
+	}
+	targets := []Target{}
+	for _, item := range list.Items {
+		target := Target{Kind: TargetKindKubernetes, Location: item.Metadata.Namespace}
+		switch item.Kind {
+		case "Service":
+			target.Name = "svc/" + item.Metadata.Name
+		case "Pod":
+			if item.Status.Phase != "Running" {
+				continue
+			}
+			target.Name = "pod/" + item.Metadata.Name
+			target.Status = item.Status.Phase
+		default:
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func (p kubernetesTargets) Resolve(ctx context.Context, fav *Favorite) (*targetEndpoint, error) {
+	if fav.Kubernetes == nil {
+		return nil, fmt.Errorf("favorite has no Kubernetes target")
+	}
+	target := *fav.Kubernetes
+	return &targetEndpoint{
+		provider:  p,
+		ProjectID: fav.ProjectID,
+		Name:      fav.InstanceName,
+		cloud:     target,
+	}, nil
+}
+
+// Dial connects through "kubectl port-forward". kubectl exits when the pod behind a service
+// goes away; the next connection starts it again and picks another pod.
+func (p kubernetesTargets) Dial(ctx context.Context, ep *targetEndpoint, port int) (io.ReadWriteCloser, error) {
+	target, ok := ep.cloud.(KubernetesTarget)
+	if !ok {
+		return nil, fmt.Errorf("not a Kubernetes endpoint: %s", ep)
+	}
+	kubectl, err := findCLI("kubectl")
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s/%d", TargetKindKubernetes, target.Context, target.Namespace, target.Resource, port)
+	return p.a.cloudForward(ctx, key, func(localPort int) (string, []string) {
+		return kubectl, []string{
+			"--context", target.Context,
+			"--namespace", target.Namespace,
+			"port-forward", target.Resource,
+			fmt.Sprintf("%d:%d", localPort, port),
+			"--address", "127.0.0.1",
+		}
+	})
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bonjour service types advertised for the connection types, see ShareTunnelOnLAN
+var lanServiceTypes = map[string]string{
+	ConnectionTypeRDP: "_rdp._tcp",
+	ConnectionTypeVNC: "_rfb._tcp",
+	ConnectionTypeWeb: "_http._tcp",
+}
+
+// serviceTypePattern matches DNS-SD service types, e.g. "_rdp._tcp"
+var serviceTypePattern = regexp.MustCompile(`^_[a-z0-9][a-z0-9-]{0,14}\._(tcp|udp)$`)
+
+// LANShare is a tunnel made reachable from the local network, e.g. for a colleague during pair
+// debugging. Its listener forwards to the tunnel's loopback port, so the tunnel's logs, metrics
+// and limits apply to LAN clients too.
+type LANShare struct {
+	TunnelID  string `json:"tunnelId"`
+	Address   string `json:"address"` // LAN address and port, e.g. "192.168.1.20:33890"
+	StartedAt string `json:"startedAt"`
+	// AllowedPeers are the addresses and networks (CIDR) that may connect, others are refused
+	AllowedPeers []string `json:"allowedPeers"`
+	// ServiceType and Name are set while the share is advertised through Bonjour
+	ServiceType string `json:"serviceType,omitempty"`
+	Name        string `json:"name,omitempty"`
+	// AdvertiseError is set when dns-sd couldn't advertise the share, it is reachable anyway
+	AdvertiseError string `json:"advertiseError,omitempty"`
+}
+
+// lanShareState holds the running LAN shares by tunnel ID
+type lanShareState struct {
+	mu     sync.Mutex
+	shares map[string]*lanShare
+}
+
+type lanShare struct {
+	LANShare
+	listener net.Listener
+	cancel   context.CancelFunc // Stops the Bonjour advertisement
+	peers    []*net.IPNet
+}
+
+// parseLANPeers parses the peers allowed to connect to a share, IP addresses or CIDR networks
+func parseLANPeers(peers []string) ([]*net.IPNet, error) {
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("name the addresses allowed to connect, e.g. a colleague's IP address")
+	}
+	nets := make([]*net.IPNet, 0, len(peers))
+	for _, peer := range peers {
+		if _, ipNet, err := net.ParseCIDR(peer); err == nil {
+			nets = append(nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(peer)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address: %s, expected an IP address or network", peer)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// allows reports whether a LAN client may connect to the share
+func (s *lanShare) allows(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipNet := range s.peers {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShareTunnelOnLAN makes a running tunnel reachable on a LAN address of this Mac, the first
+// private IPv4 address when address is empty, for the peers given as IP addresses or networks.
+// With advertise, the share is announced through Bonjour under the favorite's name, as
+// serviceType or the type of the favorite's connection. Production tunnels can't be shared.
+func (a *App) ShareTunnelOnLAN(tunnelID, address string, peers []string, advertise bool, serviceType string) (*LANShare, error) {
+	if a.managed != nil && a.managed.LANSharingDisabled {
+		return nil, fmt.Errorf("sharing tunnels on the local network is disabled by your administrator")
+	}
+	if err := a.requireOperatorUnlock(OperatorActionLANShare); err != nil {
+		return nil, err
+	}
+	a.countFeature("lan_share")
+	allowed, err := parseLANPeers(peers)
+	if err != nil {
+		return nil, err
+	}
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("tunnel not found")
+	}
+	info := tunnel.toInfo()
+	fav := a.GetConnectionInfo(info.FavoriteID)
+	if fav == nil {
+		fav = a.favoriteFor(info.ProjectID, info.VMName, info.Zone, info.RemotePort, info.LocalPort)
+	}
+	if info.Environment == EnvironmentProd || (fav != nil && fav.Environment == EnvironmentProd) {
+		return nil, fmt.Errorf("production tunnels can't be shared on the local network")
+	}
+
+	if advertise && serviceType == "" {
+		connectionType := ConnectionTypeRDP
+		if fav != nil && fav.ConnectionType != "" {
+			connectionType = fav.ConnectionType
+		}
+		if serviceType = lanServiceTypes[connectionType]; serviceType == "" {
+			return nil, fmt.Errorf("choose a Bonjour service type for %s connections", connectionType)
+		}
+	}
+	if advertise && !serviceTypePattern.MatchString(serviceType) {
+		return nil, fmt.Errorf("invalid service type: %s, expected e.g. _rdp._tcp", serviceType)
+	}
+
+	ip, err := lanAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	a.lanShares.mu.Lock()
+	defer a.lanShares.mu.Unlock()
+	if _, ok := a.lanShares.shares[tunnelID]; ok {
+		return nil, fmt.Errorf("tunnel is already shared on the local network")
+	}
+	// The tunnel's port if it's free on the LAN address, so the colleague sees the familiar one
+	listener, err := net.Listen("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(info.LocalPort)))
+	if err != nil {
+		if listener, err = net.Listen("tcp", net.JoinHostPort(ip.String(), "0")); err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", ip, err)
+		}
+	}
+	share := &lanShare{
+		LANShare: LANShare{
+			TunnelID:     tunnelID,
+			Address:      listener.Addr().String(),
+			StartedAt:    time.Now().Format(time.RFC3339),
+			AllowedPeers: peers,
+		},
+		listener: listener,
+		cancel:   func() {},
+		peers:    allowed,
+	}
+	go a.serveLANShare(tunnel, share)
+
+	if advertise {
+		name := info.VMName
+		if fav != nil {
+			name = favoriteLabel(*fav)
+		}
+		if err := share.advertise(name, serviceType); err != nil {
+			share.AdvertiseError = err.Error()
+		}
+	}
+
+	if a.lanShares.shares == nil {
+		a.lanShares.shares = make(map[string]*lanShare)
+	}
+	a.lanShares.shares[tunnelID] = share
+	tunnel.addStopHook(func() { a.StopLANShare(tunnelID) })
+	tunnel.addLog(fmt.Sprintf("Shared on the local network at %s", share.Address))
+	a.audit("tunnel.lan_shared", info.FavoriteID, auditTarget(info.ProjectID, info.Zone, info.VMName),
+		fmt.Sprintf("%s for %s", share.Address, strings.Join(peers, ", ")))
+	result := share.LANShare
+	return &result, nil
+}
+
+// advertise announces the share through Bonjour with dns-sd, which keeps the registration
+// until it is stopped
+func (s *lanShare) advertise(name, serviceType string) error {
+	port := s.listener.Addr().(*net.TCPAddr).Port
+	// Shutdown stops dns-sd with the other helper commands
+	ctx, cancel := context.WithCancel(commandsCtx)
+	cmd := exec.CommandContext(ctx, "dns-sd", "-R", name, serviceType, "local", strconv.Itoa(port))
+	cmd.WaitDelay = commandWaitDelay
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("failed to start dns-sd: %w", err)
+	}
+	go cmd.Wait()
+	s.cancel = cancel
+	s.ServiceType, s.Name = serviceType, name
+	return nil
+}
+
+// serveLANShare forwards connections of the allowed peers to the tunnel's loopback listener until
+// the share stops
+func (a *App) serveLANShare(tunnel *Tunnel, share *lanShare) {
+	target := fmt.Sprintf("127.0.0.1:%d", tunnel.LocalPort)
+	for {
+		conn, err := share.listener.Accept()
+		if err != nil {
+			return
+		}
+		if !share.allows(conn.RemoteAddr()) {
+			tunnel.addLog(fmt.Sprintf("Refused LAN connection from %s, not an allowed peer", conn.RemoteAddr()))
+			conn.Close()
+			continue
+		}
+		tunnel.addLog(fmt.Sprintf("LAN connection from %s", conn.RemoteAddr()))
+		go func() {
+			defer conn.Close()
+			upstream, err := net.DialTimeout("tcp", target, 5*time.Second)
+			if err != nil {
+				tunnel.addLog(fmt.Sprintf("Failed to forward LAN connection: %v", err))
+				return
+			}
+			defer upstream.Close()
+			done := make(chan struct{})
+			go func() {
+				relay(upstream, conn)
+				upstream.(*net.TCPConn).CloseWrite()
+				close(done)
+			}()
+			relay(conn, upstream)
+			<-done
+		}()
+	}
+}
+
+// StopLANShare stops sharing a tunnel on the local network and its Bonjour advertisement
+func (a *App) StopLANShare(tunnelID string) error {
+	a.lanShares.mu.Lock()
+	share, ok := a.lanShares.shares[tunnelID]
+	delete(a.lanShares.shares, tunnelID)
+	a.lanShares.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("tunnel is not shared on the local network")
+	}
+	share.cancel()
+	share.listener.Close()
+	a.audit("tunnel.lan_unshared", "", "", share.Address)
+	return nil
+}
+
+// GetLANShares returns the tunnels shared on the local network, oldest first
+func (a *App) GetLANShares() []LANShare {
+	a.lanShares.mu.Lock()
+	defer a.lanShares.mu.Unlock()
+	shares := make([]LANShare, 0, len(a.lanShares.shares))
+	for _, s := range a.lanShares.shares {
+		shares = append(shares, s.LANShare)
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].StartedAt < shares[j].StartedAt
+	})
+	return shares
+}
+
+// lanAddress checks that address is a LAN address of this Mac, or picks the first private IPv4
+// address when it's empty
+func lanAddress(address string) (net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network addresses: %w", err)
+	}
+	var want net.IP
+	if address != "" {
+		if want = net.ParseIP(address); want == nil {
+			return nil, fmt.Errorf("invalid address: %s", address)
+		}
+		if want.IsLoopback() || want.IsUnspecified() {
+			return nil, fmt.Errorf("%s is not a LAN address", address)
+		}
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if want != nil && ipNet.IP.Equal(want) {
+			return want, nil
+		}
+		if want == nil && ipNet.IP.To4() != nil && ipNet.IP.IsPrivate() {
+			return ipNet.IP, nil
+		}
+	}
+	if want != nil {
+		return nil, fmt.Errorf("%s is not an address of this Mac", address)
+	}
+	return nil, fmt.Errorf("this Mac has no private network address")
+}
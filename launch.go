@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/options"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// ConnectArg is the launch argument that connects to a favorite on startup
+	ConnectArg = "--connect"
+	// LaunchConnectEvent is emitted with the ConnectResult of a --connect launch
+	LaunchConnectEvent = "launch:connect"
+	// SingleInstanceID identifies the running instance for second-launch forwarding
+	SingleInstanceID = "com.wails.iap-tunnel-manager"
+)
+
+// LaunchAction describes how a launcher can trigger a favorite
+type LaunchAction struct {
+	FavoriteID  string `json:"favoriteId"`
+	Title       string `json:"title"`
+	Command     string `json:"command"`
+	DisplayName string `json:"displayName"`
+}
+
+// parseConnectArg returns the favorite ID from "--connect <id>" or "--connect=<id>"
+func parseConnectArg(args []string) string {
+	for i, arg := range args {
+		if arg == ConnectArg && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(arg, ConnectArg+"=") {
+			return strings.TrimPrefix(arg, ConnectArg+"=")
+		}
+	}
+	return ""
+}
+
+// setLaunchArgs records the command line arguments handled once the app has started
+func (a *App) setLaunchArgs(args []string) {
+	a.launchConnectID = parseConnectArg(args)
+}
+
+// handleLaunchConnect connects to the favorite requested on the command line
+func (a *App) handleLaunchConnect() {
+	if a.launchConnectID == "" {
+		return
+	}
+	favoriteID := a.launchConnectID
+	a.launchConnectID = ""
+	go a.connectFromLauncher(favoriteID)
+}
+
+// onSecondInstanceLaunch handles "open --args --connect <id>" while the app is already running
+func (a *App) onSecondInstanceLaunch(data options.SecondInstanceData) {
+	if a.ctx != nil {
+		runtime.WindowUnminimise(a.ctx)
+		runtime.WindowShow(a.ctx)
+	}
+	if favoriteID := parseConnectArg(data.Args); favoriteID != "" {
+		go a.connectFromLauncher(favoriteID)
+	}
+}
+
+// connectFromLauncher connects to a favorite and reports the result to the frontend
+func (a *App) connectFromLauncher(favoriteID string) {
+	result := a.ConnectFavorite(favoriteID)
+	if a.ctx == nil {
+		return
+	}
+	if !result.Success {
+		a.logWarningf("Launch connect to %s failed: %s", favoriteID, result.Error)
+	}
+	runtime.EventsEmit(a.ctx, LaunchConnectEvent, result)
+}
+
+// GetLaunchActions returns a shell command per favorite for Spotlight, Raycast or Alfred scripts.
+// open -n starts another instance even while the app runs, plain open -a would only activate it and
+// drop the arguments. The single instance lock hands them to the running app, see
+// onSecondInstanceLaunch.
+func (a *App) GetLaunchActions() []LaunchAction {
+	favorites := a.GetFavorites()
+	actions := make([]LaunchAction, 0, len(favorites))
+	for _, f := range favorites {
+		name := favoriteLabel(f)
+		actions = append(actions, LaunchAction{
+			FavoriteID:  f.ID,
+			Title:       fmt.Sprintf("Connect to %s", name),
+			Command:     fmt.Sprintf("open -n -a %q --args %s %s", AppName, ConnectArg, f.ID),
+			DisplayName: name,
+		})
+	}
+	return actions
+}
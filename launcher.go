@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Launcher opens a local app bundle or runs a shell command once a favorite's tunnel is up,
+// instead of the client for its connection type. Arguments and the command may use the
+// {port}, {host}, {instance}, {project} and {zone} variables.
+type Launcher struct {
+	App       string   `json:"app,omitempty"`       // App bundle name or path, opened with `open -a`
+	Arguments []string `json:"arguments,omitempty"` // Passed to the app with --args
+	Command   string   `json:"command,omitempty"`   // Run with /bin/sh -c, variables are shell-quoted
+}
+
+// validate checks that exactly one of App and Command is set
+func (l Launcher) validate() error {
+	app := strings.TrimSpace(l.App)
+	command := strings.TrimSpace(l.Command)
+	if app == "" && command == "" {
+		return fmt.Errorf("launcher needs an app or a command")
+	}
+	if app != "" && command != "" {
+		return fmt.Errorf("launcher can't have both an app and a command")
+	}
+	if command != "" && len(l.Arguments) > 0 {
+		return fmt.Errorf("launcher arguments only apply to apps")
+	}
+	return nil
+}
+
+// SetOnConnectLauncher sets (or clears, when launcher is nil) what a favorite opens on connect
+func (a *App) SetOnConnectLauncher(favoriteID string, launcher *Launcher) error {
+	if launcher != nil {
+		if err := launcher.validate(); err != nil {
+			return err
+		}
+		l := *launcher
+		l.App = strings.TrimSpace(l.App)
+		l.Command = strings.TrimSpace(l.Command)
+		launcher = &l
+	}
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		f.OnConnect = launcher
+		return nil
+	})
+}
+
+// openLauncherForTunnel waits for the tunnel and the remote port, then runs the favorite's launcher
+func (a *App) openLauncherForTunnel(fav *Favorite, tunnel *TunnelInfo) ConnectResult {
+	if !a.waitForTunnelRunning(tunnel.ID, 2*time.Second) {
+		return a.connectError(tunnel, MsgTunnelStartTimeout)
+	}
+	if probe := a.ProbeTunnel(tunnel.ID); !probe.Reachable {
+		return ConnectResult{Tunnel: tunnel, Error: probe.Error, Code: probe.Code, RetryAfter: probe.RetryAfter}
+	}
+
+	if err := runLauncher(*fav.OnConnect, launcherVars(tunnel)); err != nil {
+		a.logToTunnel(tunnel.ID, fmt.Sprintf("On connect launcher failed: %v", err))
+		return a.connectError(tunnel, MsgLauncherFailed, err)
+	}
+
+	if info, err := a.GetTunnel(tunnel.ID); err == nil {
+		tunnel = info
+	}
+	return ConnectResult{Success: true, Tunnel: tunnel}
+}
+
+// launcherVars returns the template variables for a tunnel
+func launcherVars(tunnel *TunnelInfo) map[string]string {
+	return map[string]string{
+		"port":     strconv.Itoa(tunnel.LocalPort),
+		"host":     "localhost",
+		"instance": tunnel.VMName,
+		"project":  tunnel.ProjectID,
+		"zone":     tunnel.Zone,
+	}
+}
+
+// expandLauncherVars replaces {name} variables, quoting each value with quote when set
+func expandLauncherVars(s string, vars map[string]string, quote func(string) string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		if quote != nil {
+			value = quote(value)
+		}
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// runLauncher starts the launcher without waiting for it to exit
+func runLauncher(l Launcher, vars map[string]string) error {
+	var cmd *exec.Cmd
+	if l.Command != "" {
+		cmd = exec.Command("/bin/sh", "-c", expandLauncherVars(l.Command, vars, shellQuote))
+	} else {
+		args := []string{"-a", l.App}
+		if len(l.Arguments) > 0 {
+			args = append(args, "--args")
+			for _, arg := range l.Arguments {
+				args = append(args, expandLauncherVars(arg, vars, nil))
+			}
+		}
+		cmd = exec.Command("open", args...)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start launcher: %w", err)
+	}
+	// Reap the process in the background, shell commands may run for the whole session
+	go cmd.Wait()
+	return nil
+}
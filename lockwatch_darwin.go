@@ -0,0 +1,46 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework AppKit -framework Foundation
+
+void iaptmWatchSystemLock(void);
+*/
+import "C"
+
+import "sync"
+
+var (
+	systemLockHandler   func(reason string)
+	systemLockHandlerMu sync.Mutex
+	systemLockOnce      sync.Once
+)
+
+// Reasons passed by iaptmSystemLocking, in the order of lockwatch_darwin.m
+var systemLockReasons = []string{"system sleep", "screen locked", "display sleep", "user switched"}
+
+// watchSystemLock calls handler when the Mac goes to sleep, locks the screen, turns the
+// displays off or switches to another user
+func watchSystemLock(handler func(reason string)) {
+	systemLockHandlerMu.Lock()
+	systemLockHandler = handler
+	systemLockHandlerMu.Unlock()
+
+	systemLockOnce.Do(func() { C.iaptmWatchSystemLock() })
+}
+
+//export iaptmSystemLocking
+func iaptmSystemLocking(kind C.int) {
+	systemLockHandlerMu.Lock()
+	handler := systemLockHandler
+	systemLockHandlerMu.Unlock()
+
+	reason := "system locking"
+	if int(kind) < len(systemLockReasons) {
+		reason = systemLockReasons[kind]
+	}
+	if handler != nil {
+		handler(reason)
+	}
+}
@@ -0,0 +1,25 @@
+// Sleep, screen lock and user switch notifications, so cached credentials are dropped before
+// the Mac is left unattended.
+
+#import <AppKit/AppKit.h>
+
+extern void iaptmSystemLocking(int kind);
+
+// iaptmWatchSystemLock subscribes to the notifications once for the life of the app. Kinds
+// index systemLockReasons in lockwatch_darwin.go.
+void iaptmWatchSystemLock(void) {
+	@autoreleasepool {
+		NSNotificationCenter *workspace = [[NSWorkspace sharedWorkspace] notificationCenter];
+		NSOperationQueue *queue = [NSOperationQueue mainQueue];
+
+		[workspace addObserverForName:NSWorkspaceWillSleepNotification object:nil queue:queue
+		                   usingBlock:^(NSNotification *note) { iaptmSystemLocking(0); }];
+		// There is no public screen lock notification in NSWorkspace, loginwindow posts this one
+		[[NSDistributedNotificationCenter defaultCenter] addObserverForName:@"com.apple.screenIsLocked" object:nil queue:queue
+		                                                         usingBlock:^(NSNotification *note) { iaptmSystemLocking(1); }];
+		[workspace addObserverForName:NSWorkspaceScreensDidSleepNotification object:nil queue:queue
+		                   usingBlock:^(NSNotification *note) { iaptmSystemLocking(2); }];
+		[workspace addObserverForName:NSWorkspaceSessionDidResignActiveNotification object:nil queue:queue
+		                   usingBlock:^(NSNotification *note) { iaptmSystemLocking(3); }];
+	}
+}
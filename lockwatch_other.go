@@ -0,0 +1,6 @@
+//go:build !darwin
+
+package main
+
+// watchSystemLock is only supported on macOS, elsewhere cached passwords just expire
+func watchSystemLock(handler func(reason string)) {}
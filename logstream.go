@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// TunnelLogEvent is emitted with a TunnelLogBatch when new log lines are available
+	TunnelLogEvent = "tunnel:log"
+	// maxTunnelLogEntries is the number of log lines kept per tunnel
+	maxTunnelLogEntries = 500
+	// logCoalesceDelay batches log lines into one event per tunnel
+	logCoalesceDelay = 250 * time.Millisecond
+	// maxLogBatch bounds a single event, busy tunnels are told to catch up with TailTunnelLog
+	maxLogBatch = 100
+)
+
+// TunnelLogEntry is a single tunnel log line
+type TunnelLogEntry struct {
+	Seq     uint64 `json:"seq"` // Increases by one per line, starting at 1
+	Time    string `json:"time"`
+	Message string `json:"message"`
+}
+
+// TunnelLogBatch carries the log lines added since the previous batch
+type TunnelLogBatch struct {
+	TunnelID string           `json:"tunnelId"`
+	Entries  []TunnelLogEntry `json:"entries"`
+	// Skipped lines were coalesced away, fetch them with TailTunnelLog if needed
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// TunnelLogTail is the result of TailTunnelLog
+type TunnelLogTail struct {
+	TunnelID  string           `json:"tunnelId"`
+	Entries   []TunnelLogEntry `json:"entries"`
+	NextSeq   uint64           `json:"nextSeq"`             // Pass as fromSeq to continue
+	Truncated bool             `json:"truncated,omitempty"` // Lines before the first entry were dropped
+}
+
+// TailTunnelLog returns the tunnel's log lines with a sequence number of at least fromSeq
+func (a *App) TailTunnelLog(tunnelID string, fromSeq uint64) (*TunnelLogTail, error) {
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+	if !ok {
+		if a.agentAttached() {
+			var tail TunnelLogTail
+			if err := a.agentCall("TailTunnelLog", &tail, tunnelID, fromSeq); err != nil {
+				return nil, err
+			}
+			return &tail, nil
+		}
+		return nil, fmt.Errorf("tunnel not found")
+	}
+
+	tunnel.logsMu.Lock()
+	defer tunnel.logsMu.Unlock()
+
+	tail := &TunnelLogTail{TunnelID: tunnelID, NextSeq: tunnel.logSeq + 1, Entries: []TunnelLogEntry{}}
+	if len(tunnel.logs) == 0 {
+		return tail, nil
+	}
+	first := tunnel.logs[0].Seq
+	if fromSeq < first {
+		tail.Truncated = fromSeq > 0 && first > 1
+		fromSeq = first
+	}
+	if fromSeq <= tunnel.logSeq {
+		start := int(fromSeq - first)
+		tail.Entries = append(tail.Entries, tunnel.logs[start:]...)
+	}
+	return tail, nil
+}
+
+// queueLogEvent schedules a coalesced log event for the entry (caller must hold logsMu)
+func (t *Tunnel) queueLogEvent(entry TunnelLogEntry) {
+	if t.emit == nil {
+		return
+	}
+	t.pendingLogs = append(t.pendingLogs, entry)
+	if !t.logFlushScheduled {
+		t.logFlushScheduled = true
+		time.AfterFunc(logCoalesceDelay, t.flushLogEvent)
+	}
+}
+
+// flushLogEvent emits the pending log lines as one batch
+func (t *Tunnel) flushLogEvent() {
+	t.logsMu.Lock()
+	batch := TunnelLogBatch{TunnelID: t.ID, Entries: t.pendingLogs}
+	t.pendingLogs = nil
+	t.logFlushScheduled = false
+	t.logsMu.Unlock()
+
+	if len(batch.Entries) > maxLogBatch {
+		batch.Skipped = len(batch.Entries) - maxLogBatch
+		batch.Entries = batch.Entries[batch.Skipped:]
+	}
+	t.emit(TunnelLogEvent, batch)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -13,24 +14,48 @@ import (
 var assets embed.FS
 
 func main() {
+	// ssh ProxyCommand mode runs headless
+	if code, ok := runProxyMode(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+	// The background agent keeps tunnels alive without the GUI
+	if code, ok := runAgentMode(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+	// Health for scripts, with an exit code to gate on
+	if code, ok := runStatusMode(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+	// Frontend definitions are generated from the running binary
+	if code, ok := runContractMode(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
 	// Create application with options
 	app := NewApp()
+	app.setLaunchArgs(os.Args[1:])
 
 	err := wails.Run(&options.App{
 		Title:     "IAP Tunnel Manager",
 		Width:     1200,
 		Height:    800,
-		MinWidth:  900,
-		MinHeight: 600,
+		MinWidth:  minWindowWidth,
+		MinHeight: minWindowHeight,
 		AssetServer: &assetserver.Options{
 			Assets: assets,
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnDomReady:       app.domReady,
+		OnBeforeClose:    app.beforeClose,
 		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
+		SingleInstanceLock: &options.SingleInstanceLock{
+			UniqueId:               SingleInstanceID,
+			OnSecondInstanceLaunch: app.onSecondInstanceLaunch,
+		},
 		Mac: &mac.Options{
 			TitleBar: &mac.TitleBar{
 				TitlebarAppearsTransparent: false,
@@ -44,6 +69,7 @@ func main() {
 				Title:   "IAP Tunnel Manager",
 				Message: "A macOS app for managing GCP IAP RDP tunnels",
 			},
+			OnUrlOpen: app.onUrlOpen,
 		},
 	})
 
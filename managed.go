@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ManagedPreferencesDomain is the preference domain MDM profiles configure
+	ManagedPreferencesDomain = "com.wails.IAP Tunnel Manager"
+	// managedDefaultsFile is an alternative to a profile for administrators, in /Library/Application Support
+	managedDefaultsFile = "defaults.json"
+)
+
+// ManagedDefaults are administrator-provided defaults. They sit beneath the user's own settings,
+// except AllowedProjects and IdleTimeoutMinutes which are enforced.
+type ManagedDefaults struct {
+	// BookmarkGroup is used when the user hasn't chosen a Windows App group
+	BookmarkGroup string `json:"bookmarkGroup,omitempty"`
+	// AllowedProjects limits projects to these IDs or patterns (e.g. "corp-*"), empty allows all
+	AllowedProjects []string `json:"allowedProjects,omitempty"`
+	// IdleTimeoutMinutes is the longest a tunnel may stay idle, 0 leaves it to the user
+	IdleTimeoutMinutes int `json:"idleTimeoutMinutes,omitempty"`
+	// TelemetryDisabled turns usage metrics off and hides the opt-in
+	TelemetryDisabled bool `json:"telemetryDisabled,omitempty"`
+	// LANSharingDisabled keeps tunnels on loopback, see ShareTunnelOnLAN
+	LANSharingDisabled bool `json:"lanSharingDisabled,omitempty"`
+	// Source is the file the defaults were read from
+	Source string `json:"source,omitempty"`
+}
+
+// GetManagedDefaults returns the administrator-provided defaults, nil when the app isn't managed
+func (a *App) GetManagedDefaults() *ManagedDefaults {
+	if a.managed == nil {
+		return nil
+	}
+	managed := *a.managed
+	return &managed
+}
+
+// loadManagedDefaults reads the first managed defaults found: the per-user and computer-wide
+// managed preferences installed by MDM, then defaults.json in /Library/Application Support
+func loadManagedDefaults() *ManagedDefaults {
+	var candidates []string
+	if u, err := user.Current(); err == nil {
+		candidates = append(candidates, filepath.Join("/Library/Managed Preferences", u.Username, ManagedPreferencesDomain+".plist"))
+	}
+	candidates = append(candidates,
+		filepath.Join("/Library/Managed Preferences", ManagedPreferencesDomain+".plist"),
+		filepath.Join("/Library/Application Support", AppName, managedDefaultsFile),
+	)
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err != nil {
+			continue
+		}
+		managed, err := readManagedDefaults(candidate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring managed defaults in %s: %v\n", candidate, err)
+			continue
+		}
+		managed.Source = candidate
+		return managed
+	}
+	return nil
+}
+
+// readManagedDefaults parses a managed preferences plist (any plist format) or a JSON file
+func readManagedDefaults(file string) (*ManagedDefaults, error) {
+	var data []byte
+	var err error
+	if strings.HasSuffix(file, ".plist") {
+		data, err = runCommand("plutil", "-convert", "json", "-o", "-", file)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Field names match case-insensitively, so profiles can use BookmarkGroup or bookmarkGroup
+	var managed ManagedDefaults
+	if err := json.Unmarshal(data, &managed); err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	if managed.IdleTimeoutMinutes < 0 {
+		managed.IdleTimeoutMinutes = 0
+	}
+	return &managed, nil
+}
+
+// projectAllowed reports whether the administrator allows tunnels into a project
+func (a *App) projectAllowed(projectID string) bool {
+	if a.managed == nil || len(a.managed.AllowedProjects) == 0 {
+		return true
+	}
+	for _, pattern := range a.managed.AllowedProjects {
+		if ok, err := path.Match(pattern, projectID); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// managedIdleTimeout caps an idle timeout in minutes at the administrator's limit (0 means none)
+func (a *App) managedIdleTimeout(minutes int) int {
+	if a.managed == nil || a.managed.IdleTimeoutMinutes == 0 {
+		return minutes
+	}
+	if minutes == 0 || minutes > a.managed.IdleTimeoutMinutes {
+		return a.managed.IdleTimeoutMinutes
+	}
+	return minutes
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// metadataConflictRetries is how often a metadata write is retried after a fingerprint conflict
+const metadataConflictRetries = 3
+
+// errMetadataConflict is returned when metadata changed since the caller read it
+var errMetadataConflict = errors.New("instance metadata was changed by someone else, reload and try again")
+
+// protectedMetadataKeys are managed by the app and can't be edited directly
+var protectedMetadataKeys = map[string]bool{
+	"windows-keys": true,
+}
+
+// MetadataItem represents a single instance metadata key/value pair
+type MetadataItem struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// InstanceMetadata represents the metadata of a VM instance
+type InstanceMetadata struct {
+	Fingerprint string         `json:"fingerprint"`
+	Items       []MetadataItem `json:"items"`
+}
+
+// MetadataUpdateRequest represents a request to set or delete a metadata item
+type MetadataUpdateRequest struct {
+	ProjectID    string `json:"projectId"`
+	Zone         string `json:"zone"`
+	InstanceName string `json:"instanceName"`
+	Key          string `json:"key"`
+	Value        string `json:"value"`
+	Delete       bool   `json:"delete"`
+	// Fingerprint from GetInstanceMetadata; the write fails if metadata changed since
+	Fingerprint string `json:"fingerprint"`
+	// Confirmed must be set once the user has confirmed the change
+	Confirmed bool `json:"confirmed"`
+}
+
+// GetInstanceMetadata returns the instance metadata items sorted by key
+func (a *App) GetInstanceMetadata(projectID, zone, instanceName string) (*InstanceMetadata, error) {
+	if a.tokenSource == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+
+	ctx := context.Background()
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %w", err)
+	}
+
+	instance, err := computeService.Instances.Get(projectID, zone, instanceName).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+
+	result := &InstanceMetadata{Items: []MetadataItem{}}
+	if instance.Metadata != nil {
+		result.Fingerprint = instance.Metadata.Fingerprint
+		for _, item := range instance.Metadata.Items {
+			value := ""
+			if item.Value != nil {
+				value = *item.Value
+			}
+			result.Items = append(result.Items, MetadataItem{Key: item.Key, Value: value})
+		}
+	}
+
+	sort.Slice(result.Items, func(i, j int) bool {
+		return result.Items[i].Key < result.Items[j].Key
+	})
+	return result, nil
+}
+
+// SetInstanceMetadataItem sets or deletes a single metadata item
+func (a *App) SetInstanceMetadataItem(req MetadataUpdateRequest) error {
+	if a.tokenSource == nil {
+		return fmt.Errorf("not authenticated")
+	}
+	if !req.Confirmed {
+		return fmt.Errorf("metadata change must be confirmed")
+	}
+	if err := a.requireOperatorUnlock(OperatorActionMetadata); err != nil {
+		return err
+	}
+
+	key := strings.TrimSpace(req.Key)
+	if key == "" {
+		return fmt.Errorf("metadata key is required")
+	}
+	if protectedMetadataKeys[key] {
+		return fmt.Errorf("%s is managed by the app and can't be edited directly", key)
+	}
+	if req.Fingerprint == "" {
+		return fmt.Errorf("metadata fingerprint is required")
+	}
+
+	ctx := context.Background()
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return fmt.Errorf("failed to create compute client: %w", err)
+	}
+
+	return updateInstanceMetadata(ctx, computeService, req.ProjectID, req.Zone, req.InstanceName, req.Fingerprint, func(metadata *compute.Metadata) error {
+		if req.Delete {
+			if !deleteMetadataItem(metadata, key) {
+				return fmt.Errorf("metadata key %s not found", key)
+			}
+			return nil
+		}
+		setMetadataItem(metadata, key, req.Value)
+		return nil
+	})
+}
+
+// updateInstanceMetadata reads the instance metadata, applies mutate and writes it back.
+// With an expected fingerprint the write fails on any concurrent change; without one,
+// the read-modify-write is retried when the fingerprint changes between read and write.
+func updateInstanceMetadata(ctx context.Context, svc *compute.Service, projectID, zone, instanceName, expectedFingerprint string, mutate func(*compute.Metadata) error) error {
+	for attempt := 0; ; attempt++ {
+		instance, err := svc.Instances.Get(projectID, zone, instanceName).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to get instance: %w", err)
+		}
+
+		metadata := instance.Metadata
+		if metadata == nil {
+			metadata = &compute.Metadata{}
+		}
+		if expectedFingerprint != "" && metadata.Fingerprint != expectedFingerprint {
+			return errMetadataConflict
+		}
+
+		if err := mutate(metadata); err != nil {
+			return err
+		}
+
+		_, err = svc.Instances.SetMetadata(projectID, zone, instanceName, metadata).Context(ctx).Do()
+		if err == nil {
+			return nil
+		}
+		if !isPreconditionFailed(err) {
+			return err
+		}
+		if expectedFingerprint != "" || attempt+1 >= metadataConflictRetries {
+			return errMetadataConflict
+		}
+	}
+}
+
+// setMetadataItem sets a metadata item, adding it if missing
+func setMetadataItem(metadata *compute.Metadata, key, value string) {
+	for _, item := range metadata.Items {
+		if item.Key == key {
+			item.Value = stringPtr(value)
+			return
+		}
+	}
+	metadata.Items = append(metadata.Items, &compute.MetadataItems{
+		Key:   key,
+		Value: stringPtr(value),
+	})
+}
+
+// deleteMetadataItem removes a metadata item and reports whether it existed
+func deleteMetadataItem(metadata *compute.Metadata, key string) bool {
+	for i, item := range metadata.Items {
+		if item.Key == key {
+			metadata.Items = append(metadata.Items[:i], metadata.Items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// isPreconditionFailed reports whether err is a fingerprint mismatch (HTTP 412)
+func isPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// metricsBucket is the width of one throughput sample
+	metricsBucket = 5 * time.Second
+	// metricsSamples is how many samples a tunnel keeps, 15 minutes of history
+	metricsSamples = 180
+)
+
+// ThroughputSample is the average throughput of a tunnel over one bucket
+type ThroughputSample struct {
+	At     time.Time `json:"at"`     // End of the bucket
+	InBps  int64     `json:"inBps"`  // Bytes per second received from the VM
+	OutBps int64     `json:"outBps"` // Bytes per second sent to the VM
+}
+
+// TunnelMetrics is the throughput history of a tunnel, oldest sample first
+type TunnelMetrics struct {
+	TunnelID      string             `json:"tunnelId"`
+	BucketSeconds int                `json:"bucketSeconds"`
+	Samples       []ThroughputSample `json:"samples"`
+	TotalIn       int64              `json:"totalIn"`
+	TotalOut      int64              `json:"totalOut"`
+}
+
+// tunnelMetrics counts the bytes of a tunnel and keeps a ring of throughput samples
+type tunnelMetrics struct {
+	bytesIn    atomic.Int64
+	bytesOut   atomic.Int64
+	sessions   atomic.Int64
+	dialErrors atomic.Int64
+
+	mu      sync.Mutex
+	samples [metricsSamples]ThroughputSample
+	next    int
+	count   int
+	lastIn  int64
+	lastOut int64
+}
+
+// sample records the throughput since the previous sample
+func (m *tunnelMetrics) sample(now time.Time, elapsed time.Duration) {
+	in, out := m.bytesIn.Load(), m.bytesOut.Load()
+	seconds := int64(elapsed / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples[m.next] = ThroughputSample{
+		At:     now,
+		InBps:  (in - m.lastIn) / seconds,
+		OutBps: (out - m.lastOut) / seconds,
+	}
+	m.next = (m.next + 1) % metricsSamples
+	if m.count < metricsSamples {
+		m.count++
+	}
+	m.lastIn, m.lastOut = in, out
+}
+
+// snapshot returns the samples in chronological order
+func (m *tunnelMetrics) snapshot() []ThroughputSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	samples := make([]ThroughputSample, 0, m.count)
+	start := (m.next - m.count + metricsSamples) % metricsSamples
+	for i := 0; i < m.count; i++ {
+		samples = append(samples, m.samples[(start+i)%metricsSamples])
+	}
+	return samples
+}
+
+// countingWriter adds the bytes written to a counter
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// sampleTunnelThroughput samples the tunnel's byte counters every bucket until ctx is done
+func (a *App) sampleTunnelThroughput(ctx context.Context, tunnel *Tunnel) {
+	ticker := time.NewTicker(metricsBucket)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			tunnel.metrics.sample(now, now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// GetTunnelMetrics returns throughput samples of a tunnel in 5 second buckets for the last 15 minutes
+func (a *App) GetTunnelMetrics(tunnelID string) (*TunnelMetrics, error) {
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+	if !ok {
+		if a.agentAttached() {
+			var metrics TunnelMetrics
+			if err := a.agentCall("GetTunnelMetrics", &metrics, tunnelID); err != nil {
+				return nil, err
+			}
+			return &metrics, nil
+		}
+		return nil, fmt.Errorf("tunnel not found")
+	}
+
+	return &TunnelMetrics{
+		TunnelID:      tunnelID,
+		BucketSeconds: int(metricsBucket / time.Second),
+		Samples:       tunnel.metrics.snapshot(),
+		TotalIn:       tunnel.metrics.bytesIn.Load(),
+		TotalOut:      tunnel.metrics.bytesOut.Load(),
+	}, nil
+}
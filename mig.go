@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// Instance group member selection modes
+const (
+	MemberSelectionRoundRobin = "round-robin"
+	MemberSelectionHealthiest = "healthiest"
+)
+
+// groupMember is a running instance of a managed instance group
+type groupMember struct {
+	Name    string
+	Zone    string
+	Healthy bool
+}
+
+// instanceGroupRef identifies a managed instance group by name and location
+type instanceGroupRef struct {
+	Name   string
+	Zone   string // Set for zonal groups
+	Region string // Set for regional groups
+}
+
+func (g instanceGroupRef) String() string {
+	if g.Region != "" {
+		return g.Region + "/" + g.Name
+	}
+	return g.Zone + "/" + g.Name
+}
+
+// groupCursorState tracks the next round-robin position in an instance group per favorite
+type groupCursorState struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+// selectGroupMember picks a member when the favorite's instance name is really an instance group.
+// It returns ok=false when the name is an existing instance or no matching group exists.
+func (a *App) selectGroupMember(conn *Favorite) (member groupMember, group instanceGroupRef, ok bool, err error) {
+	if a.tokenSource == nil {
+		return member, group, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return member, group, false, fmt.Errorf("failed to create compute client: %w", err)
+	}
+
+	// Exact instance names always win over group names
+	if _, err := computeService.Instances.Get(conn.ProjectID, conn.Zone, conn.InstanceName).Context(ctx).Do(); err == nil || !isNotFound(err) {
+		return member, group, false, nil
+	}
+
+	group, found, err := findInstanceGroup(ctx, computeService, conn.ProjectID, conn.InstanceName)
+	if err != nil || !found {
+		return member, group, false, err
+	}
+
+	members, err := listGroupMembers(ctx, computeService, conn.ProjectID, group)
+	if err != nil {
+		return member, group, false, err
+	}
+	if len(members) == 0 {
+		return member, group, false, fmt.Errorf("instance group %s has no running members", group)
+	}
+
+	if conn.MemberSelection == MemberSelectionHealthiest {
+		var healthy []groupMember
+		for _, m := range members {
+			if m.Healthy {
+				healthy = append(healthy, m)
+			}
+		}
+		if len(healthy) > 0 {
+			members = healthy
+		}
+	}
+
+	a.groupCursor.mu.Lock()
+	if a.groupCursor.next == nil {
+		a.groupCursor.next = make(map[string]int)
+	}
+	idx := a.groupCursor.next[conn.ID] % len(members)
+	a.groupCursor.next[conn.ID] = idx + 1
+	a.groupCursor.mu.Unlock()
+
+	return members[idx], group, true, nil
+}
+
+// findInstanceGroup looks for a managed instance group named name, or whose instances are named after it
+func findInstanceGroup(ctx context.Context, computeService *compute.Service, projectID, name string) (instanceGroupRef, bool, error) {
+	var group instanceGroupRef
+	found := false
+	err := computeService.InstanceGroupManagers.AggregatedList(projectID).
+		Pages(ctx, func(page *compute.InstanceGroupManagerAggregatedList) error {
+			for scope, list := range page.Items {
+				for _, mig := range list.InstanceGroupManagers {
+					if found || (mig.Name != name && mig.BaseInstanceName != name) {
+						continue
+					}
+					group = instanceGroupRef{Name: mig.Name}
+					if strings.HasPrefix(scope, "regions/") {
+						group.Region = strings.TrimPrefix(scope, "regions/")
+					} else {
+						group.Zone = strings.TrimPrefix(scope, "zones/")
+					}
+					found = true
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return group, false, fmt.Errorf("failed to list instance groups: %w", err)
+	}
+	return group, found, nil
+}
+
+// listGroupMembers returns the running members of a managed instance group, sorted by name
+func listGroupMembers(ctx context.Context, computeService *compute.Service, projectID string, group instanceGroupRef) ([]groupMember, error) {
+	var managed []*compute.ManagedInstance
+	var err error
+	if group.Region != "" {
+		err = computeService.RegionInstanceGroupManagers.ListManagedInstances(projectID, group.Region, group.Name).
+			Pages(ctx, func(page *compute.RegionInstanceGroupManagersListInstancesResponse) error {
+				managed = append(managed, page.ManagedInstances...)
+				return nil
+			})
+	} else {
+		err = computeService.InstanceGroupManagers.ListManagedInstances(projectID, group.Zone, group.Name).
+			Pages(ctx, func(page *compute.InstanceGroupManagersListManagedInstancesResponse) error {
+				managed = append(managed, page.ManagedInstances...)
+				return nil
+			})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members of instance group %s: %w", group, err)
+	}
+
+	var members []groupMember
+	for _, mi := range managed {
+		if mi.InstanceStatus != "RUNNING" {
+			continue
+		}
+		// Instance is a URL like .../zones/us-central1-a/instances/web-abcd
+		parts := strings.Split(mi.Instance, "/")
+		if len(parts) < 4 || parts[len(parts)-4] != "zones" {
+			continue
+		}
+		healthy := mi.CurrentAction == "NONE"
+		for _, h := range mi.InstanceHealth {
+			if h.DetailedHealthState != "HEALTHY" {
+				healthy = false
+			}
+		}
+		members = append(members, groupMember{
+			Name:    parts[len(parts)-1],
+			Zone:    parts[len(parts)-3],
+			Healthy: healthy,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+const (
+	// monitoringExportInterval is how often tunnel metrics are written to Cloud Monitoring
+	monitoringExportInterval = time.Minute
+	// monitoringMetricPrefix namespaces the custom metrics
+	monitoringMetricPrefix = "custom.googleapis.com/iap_tunnel_manager/"
+	// monitoringMaxSeries is the Cloud Monitoring limit of time series per request
+	monitoringMaxSeries = 200
+)
+
+// CloudMonitoringSettings configures the export of tunnel metrics to Cloud Monitoring
+type CloudMonitoringSettings struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// ProjectID receives the metrics of every tunnel, empty writes each tunnel's metrics to its own project
+	ProjectID string `json:"projectId,omitempty"`
+}
+
+// CloudMonitoringStatus reports the state of the metrics export
+type CloudMonitoringStatus struct {
+	Enabled        bool   `json:"enabled"`
+	LastExportAt   string `json:"lastExportAt,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+	ExportedSeries int    `json:"exportedSeries"` // Time series written by the last export
+}
+
+// monitoringState tracks the background exporter
+type monitoringState struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	status CloudMonitoringStatus
+}
+
+// GetCloudMonitoringStatus returns the result of the last Cloud Monitoring export
+func (a *App) GetCloudMonitoringStatus() CloudMonitoringStatus {
+	a.monitoring.mu.Lock()
+	defer a.monitoring.mu.Unlock()
+	status := a.monitoring.status
+	status.Enabled = a.monitoring.cancel != nil
+	return status
+}
+
+// applyMonitoringExport starts or stops the exporter according to settings
+func (a *App) applyMonitoringExport() {
+	enabled := a.GetSettings().CloudMonitoring.Enabled
+
+	a.monitoring.mu.Lock()
+	defer a.monitoring.mu.Unlock()
+
+	if (a.monitoring.cancel != nil) == enabled {
+		return
+	}
+	if !enabled {
+		a.monitoring.cancel()
+		a.monitoring.cancel = nil
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.monitoring.cancel = cancel
+	go func() {
+		ticker := time.NewTicker(monitoringExportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if a.lowPowerActive() {
+					continue
+				}
+				a.exportTunnelMetrics(ctx)
+			}
+		}
+	}()
+}
+
+// exportTunnelMetrics writes the metrics of the running tunnels and records the outcome
+func (a *App) exportTunnelMetrics(ctx context.Context) {
+	series, err := a.writeTunnelMetrics(ctx)
+
+	a.monitoring.mu.Lock()
+	defer a.monitoring.mu.Unlock()
+	a.monitoring.status.ExportedSeries = series
+	if err != nil {
+		a.monitoring.status.LastError = err.Error()
+		return
+	}
+	a.monitoring.status.LastError = ""
+	a.monitoring.status.LastExportAt = time.Now().Format(time.RFC3339)
+}
+
+// writeTunnelMetrics creates one time series per tunnel and metric, grouped by project
+func (a *App) writeTunnelMetrics(ctx context.Context) (int, error) {
+	if a.tokenSource == nil {
+		return 0, fmt.Errorf("not authenticated")
+	}
+	override := a.GetSettings().CloudMonitoring.ProjectID
+
+	var running []*Tunnel
+	a.tunnelsMu.RLock()
+	for _, t := range a.tunnels {
+		if t.Status == "running" {
+			running = append(running, t)
+		}
+	}
+	a.tunnelsMu.RUnlock()
+
+	byProject := make(map[string][]*monitoring.TimeSeries)
+	now := time.Now()
+	for _, t := range running {
+		project := t.ProjectID
+		if override != "" {
+			project = override
+		}
+		byProject[project] = append(byProject[project], tunnelTimeSeries(t, project, now)...)
+	}
+	if len(byProject) == 0 {
+		return 0, nil
+	}
+
+	svc, err := monitoring.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create monitoring client: %w", err)
+	}
+	a.recordNetActivity(hostMonitoring)
+
+	projects := make([]string, 0, len(byProject))
+	for project := range byProject {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	written := 0
+	for _, project := range projects {
+		series := byProject[project]
+		for start := 0; start < len(series); start += monitoringMaxSeries {
+			end := min(start+monitoringMaxSeries, len(series))
+			req := &monitoring.CreateTimeSeriesRequest{TimeSeries: series[start:end]}
+			if _, err := svc.Projects.TimeSeries.Create("projects/"+project, req).Context(ctx).Do(); err != nil {
+				return written, fmt.Errorf("failed to write metrics to %s: %w", project, err)
+			}
+			written += end - start
+		}
+	}
+	return written, nil
+}
+
+// tunnelTimeSeries returns the current points of a tunnel's metrics
+func tunnelTimeSeries(t *Tunnel, project string, now time.Time) []*monitoring.TimeSeries {
+	target := t.VMName
+	if t.Host != "" {
+		target = t.Host
+	}
+	labels := map[string]string{
+		"tunnel_id":   t.ID,
+		"target":      target,
+		"zone":        t.Zone,
+		"remote_port": strconv.Itoa(t.RemotePort),
+		"environment": t.Environment,
+	}
+	resource := &monitoring.MonitoredResource{Type: "global", Labels: map[string]string{"project_id": project}}
+
+	t.logsMu.Lock()
+	active := int64(t.activeConns)
+	t.logsMu.Unlock()
+
+	point := func(kind string, value int64) *monitoring.Point {
+		interval := &monitoring.TimeInterval{EndTime: now.Format(time.RFC3339Nano)}
+		// Cumulative metrics count from the start of the tunnel
+		if kind == "CUMULATIVE" {
+			interval.StartTime = t.StartedAt.Format(time.RFC3339Nano)
+		}
+		return &monitoring.Point{Interval: interval, Value: &monitoring.TypedValue{Int64Value: &value}}
+	}
+	metric := func(name, kind string, value int64) *monitoring.TimeSeries {
+		return &monitoring.TimeSeries{
+			Metric:     &monitoring.Metric{Type: monitoringMetricPrefix + name, Labels: labels},
+			Resource:   resource,
+			MetricKind: kind,
+			ValueType:  "INT64",
+			Points:     []*monitoring.Point{point(kind, value)},
+		}
+	}
+
+	return []*monitoring.TimeSeries{
+		metric("active_sessions", "GAUGE", active),
+		metric("sessions", "CUMULATIVE", t.metrics.sessions.Load()),
+		metric("bytes_received", "CUMULATIVE", t.metrics.bytesIn.Load()),
+		metric("bytes_sent", "CUMULATIVE", t.metrics.bytesOut.Load()),
+		metric("dial_errors", "CUMULATIVE", t.metrics.dialErrors.Load()),
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// Cloud mutation operations reported in plans
+const (
+	MutationWindowsPassword = "windows-password"
+	MutationMetadata        = "metadata"
+	MutationVMStart         = "vm-start"
+	MutationPatchJob        = "patch-job"
+)
+
+// errMutationNotConfirmed is returned when confirmation is required and the request wasn't confirmed
+var errMutationNotConfirmed = errors.New("changes to cloud resources must be confirmed, review the dry run and confirm")
+
+// MutationPlan describes what a cloud mutation would change, returned by dry runs
+type MutationPlan struct {
+	Operation string   `json:"operation"`
+	Target    string   `json:"target"` // project/zone/instance
+	Changes   []string `json:"changes"`
+}
+
+// requireMutationConfirmation enforces the ConfirmCloudMutations setting
+func (a *App) requireMutationConfirmation(confirmed bool) error {
+	if a.GetSettings().ConfirmCloudMutations && !confirmed {
+		return errMutationNotConfirmed
+	}
+	return nil
+}
+
+// passwordResetPlan describes a Windows password reset without performing it
+func passwordResetPlan(conn *Favorite, req WindowsPasswordRequest, username string) *MutationPlan {
+	plan := &MutationPlan{
+		Operation: MutationWindowsPassword,
+		Target:    auditTarget(conn.ProjectID, conn.Zone, conn.InstanceName),
+		Changes: []string{
+			fmt.Sprintf("Add a windows-keys metadata entry for %s (expires after %s)", username, windowsKeyExpiry),
+			fmt.Sprintf("Reset the password of Windows user %s, creating the user as an administrator if missing", username),
+		},
+	}
+	if req.SaveToKeychain {
+		plan.Changes = append(plan.Changes, "Save the new password to Keychain")
+	}
+	if req.UpdateBookmark {
+		plan.Changes = append(plan.Changes, "Update the Windows App bookmark with the new credentials")
+	}
+	return plan
+}
+
+// PreviewInstanceMetadataItem reports what SetInstanceMetadataItem would change, without writing
+func (a *App) PreviewInstanceMetadataItem(req MetadataUpdateRequest) (*MutationPlan, error) {
+	key := strings.TrimSpace(req.Key)
+	if key == "" {
+		return nil, fmt.Errorf("metadata key is required")
+	}
+	metadata, err := a.GetInstanceMetadata(req.ProjectID, req.Zone, req.InstanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var current *string
+	for _, item := range metadata.Items {
+		if item.Key == key {
+			value := item.Value
+			current = &value
+			break
+		}
+	}
+
+	plan := &MutationPlan{Operation: MutationMetadata, Target: auditTarget(req.ProjectID, req.Zone, req.InstanceName)}
+	switch {
+	case protectedMetadataKeys[key]:
+		return nil, fmt.Errorf("%s is managed by the app and can't be edited directly", key)
+	case req.Fingerprint != "" && req.Fingerprint != metadata.Fingerprint:
+		return nil, errMetadataConflict
+	case req.Delete && current == nil:
+		return nil, fmt.Errorf("metadata key %s not found", key)
+	case req.Delete:
+		plan.Changes = []string{fmt.Sprintf("Delete %s (currently %q)", key, *current)}
+	case current == nil:
+		plan.Changes = []string{fmt.Sprintf("Add %s = %q", key, req.Value)}
+	case *current == req.Value:
+		plan.Changes = []string{}
+	default:
+		plan.Changes = []string{fmt.Sprintf("Change %s from %q to %q", key, *current, req.Value)}
+	}
+	return plan, nil
+}
+
+// PreviewStartVM reports what StartVM would change, without starting the VM
+func (a *App) PreviewStartVM(projectID, zone, instanceName string) (*MutationPlan, error) {
+	if a.tokenSource == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+
+	ctx := context.Background()
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %w", err)
+	}
+	instance, err := computeService.Instances.Get(projectID, zone, instanceName).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+
+	plan := &MutationPlan{Operation: MutationVMStart, Target: auditTarget(projectID, zone, instanceName), Changes: []string{}}
+	switch instance.Status {
+	case "RUNNING", "PROVISIONING", "STAGING":
+		// Already up, starting is a no-op
+	case "SUSPENDED":
+		return nil, fmt.Errorf("VM is suspended, resume it in the Cloud Console")
+	default:
+		plan.Changes = append(plan.Changes, fmt.Sprintf("Start %s (currently %s), compute charges resume while it runs", instanceName, instance.Status))
+	}
+	return plan, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Hosts the app connects to, listed for firewall rule authors
+const (
+	hostIAP             = "tunnel.cloudproxy.app"
+	hostOAuth           = "oauth2.googleapis.com"
+	hostCompute         = "compute.googleapis.com"
+	hostResourceManager = "cloudresourcemanager.googleapis.com"
+	hostMonitoring      = "monitoring.googleapis.com"
+	hostBilling         = "cloudbilling.googleapis.com"
+	hostOSConfig        = "osconfig.googleapis.com"
+)
+
+// Process names shown in the network activity list
+const (
+	processApp   = "IAP Tunnel Manager"
+	processAgent = "IAP Tunnel Agent"
+)
+
+// NetworkEndpoint is an outbound endpoint the app uses, as a firewall like Little Snitch or LuLu sees it
+type NetworkEndpoint struct {
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	Protocol    string `json:"protocol"`
+	Purpose     string `json:"purpose"`
+	Process     string `json:"process"`
+	Connections int    `json:"connections"`          // Currently open, only known for IAP tunnels
+	LastUsedAt  string `json:"lastUsedAt,omitempty"` // Empty when not used since launch or not tracked
+}
+
+// netActivityState records when the app last talked to each host
+type netActivityState struct {
+	mu       sync.Mutex
+	lastUsed map[string]time.Time
+}
+
+// recordNetActivity notes that host was just contacted
+func (a *App) recordNetActivity(host string) {
+	a.netActivity.mu.Lock()
+	defer a.netActivity.mu.Unlock()
+	if a.netActivity.lastUsed == nil {
+		a.netActivity.lastUsed = make(map[string]time.Time)
+	}
+	a.netActivity.lastUsed[host] = time.Now()
+}
+
+// GetNetworkActivity lists the outbound endpoints the app and its agent use, with open IAP
+// connections, so firewall rules can be written without guessing
+func (a *App) GetNetworkActivity() []NetworkEndpoint {
+	a.tunnelsMu.RLock()
+	tunnels := make([]*Tunnel, 0, len(a.tunnels))
+	for _, t := range a.tunnels {
+		tunnels = append(tunnels, t)
+	}
+	a.tunnelsMu.RUnlock()
+
+	iapConns := 0
+	for _, t := range tunnels {
+		t.logsMu.Lock()
+		iapConns += t.activeConns
+		t.logsMu.Unlock()
+	}
+
+	endpoints := []NetworkEndpoint{
+		{Host: hostIAP, Port: 443, Protocol: "wss", Purpose: "IAP TCP forwarding, one WebSocket per tunneled connection", Process: processApp, Connections: iapConns},
+		{Host: hostOAuth, Port: 443, Protocol: "https", Purpose: "Refreshing Google credentials", Process: processApp},
+		{Host: hostCompute, Port: 443, Protocol: "https", Purpose: "Listing VMs, power state, starting VMs and Windows password resets", Process: processApp},
+		{Host: hostResourceManager, Port: 443, Protocol: "https", Purpose: "Listing projects", Process: processApp},
+		{Host: hostBilling, Port: 443, Protocol: "https", Purpose: "Price list for VM cost estimates", Process: processApp},
+		{Host: hostOSConfig, Port: 443, Protocol: "https", Purpose: "Windows patch compliance and patch jobs", Process: processApp},
+	}
+	if a.GetSettings().CloudMonitoring.Enabled {
+		endpoints = append(endpoints, NetworkEndpoint{Host: hostMonitoring, Port: 443, Protocol: "https", Purpose: "Exporting tunnel metrics", Process: processApp})
+	}
+	if telemetryEndpoint != "" && a.GetSettings().TelemetryOptIn {
+		if u, err := url.Parse(telemetryEndpoint); err == nil {
+			endpoints = append(endpoints, NetworkEndpoint{Host: u.Hostname(), Port: 443, Protocol: "https", Purpose: "Anonymous daily usage counts", Process: processApp})
+		}
+	}
+	if a.agentAttached() {
+		endpoints = append(endpoints,
+			NetworkEndpoint{Host: hostIAP, Port: 443, Protocol: "wss", Purpose: "IAP TCP forwarding for tunnels run by the agent", Process: processAgent},
+			NetworkEndpoint{Host: hostOAuth, Port: 443, Protocol: "https", Purpose: "Refreshing Google credentials", Process: processAgent},
+		)
+	}
+
+	a.netActivity.mu.Lock()
+	for i := range endpoints {
+		if endpoints[i].Process != processApp {
+			continue
+		}
+		if at, ok := a.netActivity.lastUsed[endpoints[i].Host]; ok {
+			endpoints[i].LastUsedAt = at.Format(time.RFC3339)
+		}
+	}
+	a.netActivity.mu.Unlock()
+
+	// Busiest first, the rest in a stable order
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Connections > endpoints[j].Connections
+	})
+	return endpoints
+}
+
+// activityTokenSource records a credentials refresh whenever the underlying source returns a new token
+type activityTokenSource struct {
+	src    oauth2.TokenSource
+	record func()
+
+	mu   sync.Mutex
+	last string
+}
+
+func (s *activityTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	refreshed := token.AccessToken != s.last
+	s.last = token.AccessToken
+	s.mu.Unlock()
+	if refreshed {
+		s.record()
+	}
+	return token, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// OperationEvent is emitted with an Operation whenever its progress or status changes
+	OperationEvent = "operation:update"
+
+	// maxFinishedOperations is how many finished operations ListOperations keeps
+	maxFinishedOperations = 50
+)
+
+// Operation statuses
+const (
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationCanceled  = "canceled"
+)
+
+// Operation kinds
+const (
+	OperationVMStart          = "vm-start"
+	OperationPasswordRotation = "password-rotation"
+	OperationGroupStart       = "group-start"
+	OperationICloudSync       = "icloud-sync"
+)
+
+// Operation is a long-running action started in the background
+type Operation struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Title      string      `json:"title"`
+	Status     string      `json:"status"`
+	Percent    int         `json:"percent"`
+	Message    string      `json:"message,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+// operationReporter updates the progress of a running operation
+type operationReporter func(percent int, message string)
+
+// runningOperation is an operation with its cancel function
+type runningOperation struct {
+	op     Operation
+	cancel context.CancelFunc
+}
+
+// operationsState tracks running and recently finished operations
+type operationsState struct {
+	mu    sync.Mutex
+	seq   int
+	byID  map[string]*runningOperation
+	order []string // IDs, oldest first
+}
+
+// GroupStartResult is one row of a group start
+type GroupStartResult struct {
+	FavoriteID  string      `json:"favoriteId"`
+	DisplayName string      `json:"displayName"`
+	Tunnel      *TunnelInfo `json:"tunnel,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// startOperation runs fn in the background and returns the operation ID right away.
+// Progress and the final result are emitted as OperationEvent.
+func (a *App) startOperation(kind, title string, fn func(ctx context.Context, report operationReporter) (interface{}, error)) string {
+	ctx, cancel := context.WithCancel(commandsCtx)
+
+	a.ops.mu.Lock()
+	if a.ops.byID == nil {
+		a.ops.byID = make(map[string]*runningOperation)
+	}
+	a.ops.seq++
+	run := &runningOperation{
+		op: Operation{
+			ID:        fmt.Sprintf("op-%d-%d", time.Now().Unix(), a.ops.seq),
+			Kind:      kind,
+			Title:     title,
+			Status:    OperationRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+	a.ops.byID[run.op.ID] = run
+	a.ops.order = append(a.ops.order, run.op.ID)
+	a.pruneOperationsLocked()
+	snapshot := run.op
+	a.ops.mu.Unlock()
+
+	a.emitEvent(OperationEvent, snapshot)
+
+	go func() {
+		defer cancel()
+		report := func(percent int, message string) {
+			a.updateOperation(run, func(op *Operation) {
+				op.Percent = percent
+				op.Message = message
+			})
+		}
+
+		result, err := fn(ctx, report)
+
+		a.updateOperation(run, func(op *Operation) {
+			now := time.Now()
+			op.FinishedAt = &now
+			op.Result = result
+			switch {
+			case ctx.Err() != nil:
+				op.Status = OperationCanceled
+				if err != nil && !errors.Is(err, context.Canceled) {
+					op.Error = err.Error()
+				}
+			case err != nil:
+				op.Status = OperationFailed
+				op.Error = err.Error()
+			default:
+				op.Status = OperationSucceeded
+				op.Percent = 100
+			}
+		})
+	}()
+
+	return snapshot.ID
+}
+
+// updateOperation applies a change to an operation and emits the new state
+func (a *App) updateOperation(run *runningOperation, change func(op *Operation)) {
+	a.ops.mu.Lock()
+	change(&run.op)
+	snapshot := run.op
+	a.ops.mu.Unlock()
+
+	a.emitEvent(OperationEvent, snapshot)
+}
+
+// pruneOperationsLocked drops the oldest finished operations beyond maxFinishedOperations (caller must hold ops.mu)
+func (a *App) pruneOperationsLocked() {
+	finished := 0
+	for _, id := range a.ops.order {
+		if a.ops.byID[id].op.Status != OperationRunning {
+			finished++
+		}
+	}
+
+	kept := a.ops.order[:0]
+	for _, id := range a.ops.order {
+		if finished > maxFinishedOperations && a.ops.byID[id].op.Status != OperationRunning {
+			delete(a.ops.byID, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	a.ops.order = kept
+}
+
+// ListOperations returns running and recently finished operations, newest first
+func (a *App) ListOperations() []Operation {
+	a.ops.mu.Lock()
+	defer a.ops.mu.Unlock()
+
+	ops := make([]Operation, 0, len(a.ops.order))
+	for i := len(a.ops.order) - 1; i >= 0; i-- {
+		ops = append(ops, a.ops.byID[a.ops.order[i]].op)
+	}
+	return ops
+}
+
+// GetOperation returns an operation by ID
+func (a *App) GetOperation(id string) (*Operation, error) {
+	a.ops.mu.Lock()
+	defer a.ops.mu.Unlock()
+
+	run, ok := a.ops.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("operation not found")
+	}
+	op := run.op
+	return &op, nil
+}
+
+// CancelOperation cancels a running operation. Work already done, such as a started VM, is not undone.
+func (a *App) CancelOperation(id string) error {
+	a.ops.mu.Lock()
+	run, ok := a.ops.byID[id]
+	var running bool
+	if ok {
+		running = run.op.Status == OperationRunning
+	}
+	a.ops.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("operation not found")
+	}
+	if !running {
+		return fmt.Errorf("operation already finished")
+	}
+	run.cancel()
+	return nil
+}
+
+// StartVMAsync starts a stopped VM as an operation that finishes once Windows is ready.
+// BootProgressEvent is still emitted along with the operation updates.
+func (a *App) StartVMAsync(projectID, zone, instanceName string, confirmed bool) (string, error) {
+	if a.tokenSource == nil {
+		return "", fmt.Errorf("not authenticated")
+	}
+	if err := a.requireMutationConfirmation(confirmed); err != nil {
+		return "", err
+	}
+	if err := a.requireOperatorUnlock(OperatorActionVMStart); err != nil {
+		return "", err
+	}
+
+	title := fmt.Sprintf("Start %s", instanceName)
+	return a.startOperation(OperationVMStart, title, func(ctx context.Context, report operationReporter) (interface{}, error) {
+		return nil, a.startVM(ctx, projectID, zone, instanceName, func(p BootProgress) {
+			report(p.Percent, p.Message)
+		})
+	}), nil
+}
+
+// RotatePasswordsForGroupAsync runs RotatePasswordsForGroup as an operation.
+// The result is the []PasswordRotationResult; canceling skips VMs that haven't started.
+func (a *App) RotatePasswordsForGroupAsync(group string, opts PasswordRotationOptions) (string, error) {
+	if a.tokenSource == nil {
+		return "", fmt.Errorf("not authenticated")
+	}
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+	if !opts.DryRun {
+		if err := a.requireMutationConfirmation(opts.Confirmed); err != nil {
+			return "", err
+		}
+		if err := a.requireOperatorUnlock(OperatorActionPasswordRotation); err != nil {
+			return "", err
+		}
+	}
+
+	title := fmt.Sprintf("Rotate passwords in %s", group)
+	return a.startOperation(OperationPasswordRotation, title, func(ctx context.Context, report operationReporter) (interface{}, error) {
+		return a.rotatePasswordsForGroup(ctx, group, opts, report)
+	}), nil
+}
+
+// StartGroupAsync starts tunnels for every favorite in a bookmark group as an operation.
+// The result is a []GroupStartResult in favorites order.
+func (a *App) StartGroupAsync(group string) (string, error) {
+	members := a.groupMembers(group)
+	if len(members) == 0 {
+		return "", fmt.Errorf("no connections in group %q", group)
+	}
+
+	title := fmt.Sprintf("Connect %s", group)
+	return a.startOperation(OperationGroupStart, title, func(ctx context.Context, report operationReporter) (interface{}, error) {
+		results := make([]GroupStartResult, 0, len(members))
+		failed := 0
+		for i, f := range members {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			report(i*100/len(members), fmt.Sprintf("Connecting %s", favoriteLabel(f)))
+
+			result := GroupStartResult{FavoriteID: f.ID, DisplayName: favoriteLabel(f)}
+			if existing := a.findActiveTunnel(&f); existing != nil {
+				result.Tunnel = existing
+			} else if info, err := a.StartTunnelForConnection(f.ID); err != nil {
+				result.Error = err.Error()
+				failed++
+			} else {
+				result.Tunnel = info
+			}
+			results = append(results, result)
+		}
+		if failed > 0 {
+			return results, fmt.Errorf("%d of %d connections failed", failed, len(members))
+		}
+		return results, nil
+	}), nil
+}
+
+// SyncICloudNowAsync runs SyncICloudNow as an operation
+func (a *App) SyncICloudNowAsync() (string, error) {
+	if !a.GetSettings().ICloudSync {
+		return "", fmt.Errorf("iCloud sync is disabled")
+	}
+
+	return a.startOperation(OperationICloudSync, "Sync with iCloud", func(ctx context.Context, report operationReporter) (interface{}, error) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, a.syncICloud()
+	}), nil
+}
+
+// groupMembers returns the favorites whose bookmark group is group
+func (a *App) groupMembers(group string) []Favorite {
+	var members []Favorite
+	for _, f := range a.GetFavorites() {
+		fav := f
+		if a.bookmarkGroupFor(&fav) == group {
+			members = append(members, f)
+		}
+	}
+	return members
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Actions operator mode can restrict. Actions the app gains later that change cloud resources,
+// such as stopping VMs or creating firewall rules, add their own.
+const (
+	OperatorActionPasswordReset    = "password-reset"
+	OperatorActionPasswordRotation = "password-rotation"
+	OperatorActionVMStart          = "vm-start"
+	OperatorActionPatchJob         = "patch-job"
+	OperatorActionMetadata         = "metadata"
+	OperatorActionSettings         = "settings"
+	OperatorActionLANShare         = "lan-share"
+)
+
+// operatorActions are all restrictable actions, restricted by default
+var operatorActions = []string{
+	OperatorActionPasswordReset,
+	OperatorActionPasswordRotation,
+	OperatorActionVMStart,
+	OperatorActionPatchJob,
+	OperatorActionMetadata,
+	OperatorActionSettings,
+	OperatorActionLANShare,
+}
+
+const (
+	// defaultOperatorUnlockMinutes is how long an unlock lasts when the policy doesn't say
+	defaultOperatorUnlockMinutes = 5
+	// minOperatorPINLength is the shortest accepted administrator PIN
+	minOperatorPINLength = 4
+	// operatorPINIterations is the PBKDF2 work factor of the PIN hash
+	operatorPINIterations = 200000
+	// maxOperatorPINFailures wrong PINs in a row lock unlocking for operatorLockout
+	maxOperatorPINFailures = 5
+	operatorLockout        = time.Minute
+)
+
+// errOperatorLocked is returned for restricted actions while operator mode is locked
+var errOperatorLocked = errors.New("operator mode: an administrator must unlock this action")
+
+// OperatorPolicy restricts actions on shared Macs, such as an operations desk, to holders of
+// the administrator PIN or Touch ID. It lives in the settings but is only changed through
+// EnableOperatorMode and DisableOperatorMode.
+type OperatorPolicy struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Restricted lists the actions that need an unlock, empty restricts all of them
+	Restricted []string `json:"restricted,omitempty"`
+	// PINHash is the PBKDF2-SHA256 hash of the administrator PIN with PINSalt, both hex
+	PINHash string `json:"pinHash,omitempty"`
+	PINSalt string `json:"pinSalt,omitempty"`
+	// TouchID also accepts a Touch ID fingerprint in place of the PIN
+	TouchID bool `json:"touchId,omitempty"`
+	// UnlockMinutes is how long an unlock lasts, 0 uses 5
+	UnlockMinutes int `json:"unlockMinutes,omitempty"`
+}
+
+// OperatorStatus reports operator mode to the UI, without the PIN hash
+type OperatorStatus struct {
+	Enabled          bool     `json:"enabled"`
+	Unlocked         bool     `json:"unlocked"`
+	UnlockedUntil    string   `json:"unlockedUntil,omitempty"`
+	Restricted       []string `json:"restricted"`
+	TouchID          bool     `json:"touchId"`
+	TouchIDAvailable bool     `json:"touchIdAvailable"`
+}
+
+// operatorState is the unlock of operator mode, held in memory only
+type operatorState struct {
+	mu            sync.Mutex
+	unlockedUntil time.Time
+	failures      int
+	lockedUntil   time.Time // Set after too many wrong PINs
+}
+
+// restricts reports whether the policy requires an unlock for action
+func (p OperatorPolicy) restricts(action string) bool {
+	if !p.Enabled {
+		return false
+	}
+	return len(p.Restricted) == 0 || containsString(p.Restricted, action)
+}
+
+// unlockDuration returns how long an unlock lasts
+func (p OperatorPolicy) unlockDuration() time.Duration {
+	minutes := p.UnlockMinutes
+	if minutes <= 0 {
+		minutes = defaultOperatorUnlockMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// operatorUnlocked reports whether an administrator unlock is in effect
+func (a *App) operatorUnlocked() bool {
+	a.operator.mu.Lock()
+	defer a.operator.mu.Unlock()
+	return time.Now().Before(a.operator.unlockedUntil)
+}
+
+// requireOperatorUnlock enforces operator mode for a restricted action
+func (a *App) requireOperatorUnlock(action string) error {
+	if !a.GetSettings().Operator.restricts(action) || a.operatorUnlocked() {
+		return nil
+	}
+	a.audit("operator.denied", "", "", action)
+	return fmt.Errorf("%w (%s)", errOperatorLocked, action)
+}
+
+// GetOperatorStatus returns whether operator mode is on and unlocked
+func (a *App) GetOperatorStatus() OperatorStatus {
+	policy := a.GetSettings().Operator
+	status := OperatorStatus{
+		Enabled:          policy.Enabled,
+		Restricted:       policy.Restricted,
+		TouchID:          policy.TouchID,
+		TouchIDAvailable: touchIDAvailable(),
+	}
+	if len(status.Restricted) == 0 {
+		status.Restricted = operatorActions
+	}
+	a.operator.mu.Lock()
+	if policy.Enabled && time.Now().Before(a.operator.unlockedUntil) {
+		status.Unlocked = true
+		status.UnlockedUntil = a.operator.unlockedUntil.Format(time.RFC3339)
+	}
+	a.operator.mu.Unlock()
+	return status
+}
+
+// EnableOperatorMode turns operator mode on, or changes its policy while unlocked. restricted
+// empty restricts every action. The PIN is only kept as a hash.
+func (a *App) EnableOperatorMode(pin string, restricted []string, touchID bool, unlockMinutes int) (OperatorStatus, error) {
+	current := a.GetSettings().Operator
+	if current.Enabled && !a.operatorUnlocked() {
+		return a.GetOperatorStatus(), errOperatorLocked
+	}
+	if len(pin) < minOperatorPINLength {
+		return a.GetOperatorStatus(), fmt.Errorf("PIN must have at least %d characters", minOperatorPINLength)
+	}
+	for _, action := range restricted {
+		if !containsString(operatorActions, action) {
+			return a.GetOperatorStatus(), fmt.Errorf("unknown action: %s", action)
+		}
+	}
+	if unlockMinutes < 0 {
+		return a.GetOperatorStatus(), fmt.Errorf("unlock duration must not be negative")
+	}
+	if touchID && !touchIDAvailable() {
+		return a.GetOperatorStatus(), fmt.Errorf("Touch ID is not available on this Mac")
+	}
+
+	var salt [16]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return a.GetOperatorStatus(), fmt.Errorf("failed to generate PIN salt: %w", err)
+	}
+	policy := OperatorPolicy{
+		Enabled:       true,
+		Restricted:    uniqueStrings(restricted),
+		PINHash:       hex.EncodeToString(operatorPINHash(pin, salt[:])),
+		PINSalt:       hex.EncodeToString(salt[:]),
+		TouchID:       touchID,
+		UnlockMinutes: unlockMinutes,
+	}
+	if err := a.mutateConfig(func(cfg *AppConfig) error {
+		cfg.Settings.Operator = policy
+		return nil
+	}); err != nil {
+		return a.GetOperatorStatus(), err
+	}
+	a.LockOperator()
+	a.audit("operator.enabled", "", "", fmt.Sprintf("Restricted: %v", policy.Restricted))
+	return a.GetOperatorStatus(), nil
+}
+
+// DisableOperatorMode turns operator mode off, which needs an unlock
+func (a *App) DisableOperatorMode() error {
+	if a.GetSettings().Operator.Enabled && !a.operatorUnlocked() {
+		return errOperatorLocked
+	}
+	if err := a.mutateConfig(func(cfg *AppConfig) error {
+		if !cfg.Settings.Operator.Enabled {
+			return errConfigUnchanged
+		}
+		cfg.Settings.Operator = OperatorPolicy{}
+		return nil
+	}); err != nil {
+		return err
+	}
+	a.LockOperator()
+	a.audit("operator.disabled", "", "", "")
+	return nil
+}
+
+// UnlockOperator unlocks restricted actions with the administrator PIN for the policy's duration
+func (a *App) UnlockOperator(pin string) (OperatorStatus, error) {
+	policy := a.GetSettings().Operator
+	if !policy.Enabled {
+		return a.GetOperatorStatus(), fmt.Errorf("operator mode is off")
+	}
+
+	a.operator.mu.Lock()
+	if wait := time.Until(a.operator.lockedUntil); wait > 0 {
+		a.operator.mu.Unlock()
+		return a.GetOperatorStatus(), fmt.Errorf("too many wrong PINs, try again in %d seconds", int(wait.Seconds())+1)
+	}
+	a.operator.mu.Unlock()
+
+	salt, errSalt := hex.DecodeString(policy.PINSalt)
+	want, errHash := hex.DecodeString(policy.PINHash)
+	if errSalt != nil || errHash != nil {
+		return a.GetOperatorStatus(), fmt.Errorf("operator policy is damaged, reset it by editing the settings file")
+	}
+	if subtle.ConstantTimeCompare(operatorPINHash(pin, salt), want) != 1 {
+		a.operator.mu.Lock()
+		a.operator.failures++
+		if a.operator.failures >= maxOperatorPINFailures {
+			a.operator.failures = 0
+			a.operator.lockedUntil = time.Now().Add(operatorLockout)
+		}
+		a.operator.mu.Unlock()
+		a.audit("operator.unlock_failed", "", "", "Wrong PIN")
+		return a.GetOperatorStatus(), fmt.Errorf("wrong PIN")
+	}
+
+	a.unlockOperator(policy, "PIN")
+	return a.GetOperatorStatus(), nil
+}
+
+// UnlockOperatorWithTouchID unlocks restricted actions with Touch ID, when the policy allows it
+func (a *App) UnlockOperatorWithTouchID() (OperatorStatus, error) {
+	policy := a.GetSettings().Operator
+	if !policy.Enabled {
+		return a.GetOperatorStatus(), fmt.Errorf("operator mode is off")
+	}
+	if !policy.TouchID {
+		return a.GetOperatorStatus(), fmt.Errorf("Touch ID unlock is not allowed, use the PIN")
+	}
+	if err := authenticateTouchID("unlock administrator actions"); err != nil {
+		a.audit("operator.unlock_failed", "", "", "Touch ID: "+err.Error())
+		return a.GetOperatorStatus(), err
+	}
+	a.unlockOperator(policy, "Touch ID")
+	return a.GetOperatorStatus(), nil
+}
+
+// unlockOperator starts an unlock after successful authentication
+func (a *App) unlockOperator(policy OperatorPolicy, method string) {
+	a.operator.mu.Lock()
+	a.operator.failures = 0
+	a.operator.unlockedUntil = time.Now().Add(policy.unlockDuration())
+	a.operator.mu.Unlock()
+	a.audit("operator.unlocked", "", "", "Unlocked with "+method)
+}
+
+// LockOperator ends an unlock early
+func (a *App) LockOperator() OperatorStatus {
+	a.operator.mu.Lock()
+	a.operator.unlockedUntil = time.Time{}
+	a.operator.mu.Unlock()
+	return a.GetOperatorStatus()
+}
+
+// operatorPINHash derives the PIN hash with PBKDF2-HMAC-SHA256, a single 32 byte block
+func operatorPINHash(pin string, salt []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(pin))
+	mac.Write(salt)
+	mac.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := mac.Sum(nil)
+	sum := append([]byte(nil), u...)
+	for i := 1; i < operatorPINIterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range sum {
+			sum[j] ^= u[j]
+		}
+	}
+	return sum
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/osconfig/v1"
+)
+
+const (
+	// patchJobDuration bounds a patch job started from the app
+	patchJobDuration = "3600s"
+	// recentPatchJobs is how many of the project's latest patch jobs are checked for a pending reboot
+	recentPatchJobs = 10
+	// maxPendingUpdateTitles limits the update titles returned with a patch status
+	maxPendingUpdateTitles = 20
+)
+
+// PatchStatus is the OS Config patch state of a favorite's Windows VM
+type PatchStatus struct {
+	FavoriteID string `json:"favoriteId"`
+	OSName     string `json:"osName,omitempty"`
+	OSVersion  string `json:"osVersion,omitempty"`
+	// InventoryUpdatedAt is when the OS Config agent last reported, empty if it never did
+	InventoryUpdatedAt string   `json:"inventoryUpdatedAt,omitempty"`
+	PendingUpdates     int      `json:"pendingUpdates"`
+	PendingTitles      []string `json:"pendingTitles"`
+	Compliant          bool     `json:"compliant"`
+	// PendingReboot is set when the VM's latest patch job finished but needs a reboot
+	PendingReboot bool          `json:"pendingReboot"`
+	LastPatchJob  *PatchJobInfo `json:"lastPatchJob,omitempty"`
+	// NextPatchAt is the next run of a patch deployment that targets the VM
+	NextPatchAt         string `json:"nextPatchAt,omitempty"`
+	NextPatchDeployment string `json:"nextPatchDeployment,omitempty"`
+	NextPatchReboots    bool   `json:"nextPatchReboots"` // The deployment may reboot the VM
+}
+
+// PatchJobInfo summarizes an OS Config patch job
+type PatchJobInfo struct {
+	Name          string  `json:"name"`
+	DisplayName   string  `json:"displayName,omitempty"`
+	State         string  `json:"state"`
+	InstanceState string  `json:"instanceState,omitempty"` // State of this VM within the job
+	Percent       float64 `json:"percent"`
+	CreatedAt     string  `json:"createdAt,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// GetPatchStatus reports patch compliance, a pending reboot and upcoming patch deployments
+// of a Windows favorite, from OS Config
+func (a *App) GetPatchStatus(connectionID string) (*PatchStatus, error) {
+	ctx := context.Background()
+	fav, instance, err := a.patchTarget(ctx, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := osconfig.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OS Config client: %w", err)
+	}
+	a.recordNetActivity(hostOSConfig)
+
+	status := &PatchStatus{FavoriteID: fav.ID, PendingTitles: []string{}}
+
+	inventoryName := fmt.Sprintf("projects/%s/locations/%s/instances/%s/inventory", fav.ProjectID, fav.Zone, fav.InstanceName)
+	inventory, err := svc.Projects.Locations.Instances.Inventories.Get(inventoryName).View("FULL").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OS inventory, is the OS Config agent enabled on the VM?: %w", err)
+	}
+	status.InventoryUpdatedAt = inventory.UpdateTime
+	if inventory.OsInfo != nil {
+		status.OSName = inventory.OsInfo.LongName
+		status.OSVersion = inventory.OsInfo.Version
+	}
+	for _, item := range inventory.Items {
+		if item.Type != "AVAILABLE_PACKAGE" || item.AvailablePackage == nil || item.AvailablePackage.WuaPackage == nil {
+			continue
+		}
+		status.PendingUpdates++
+		status.PendingTitles = append(status.PendingTitles, item.AvailablePackage.WuaPackage.Title)
+	}
+	sort.Strings(status.PendingTitles)
+	if len(status.PendingTitles) > maxPendingUpdateTitles {
+		status.PendingTitles = status.PendingTitles[:maxPendingUpdateTitles]
+	}
+	status.Compliant = status.PendingUpdates == 0 && inventory.UpdateTime != ""
+
+	// Patch history and schedules are best effort, they need more permissions than the inventory
+	if job, err := a.lastPatchJobFor(ctx, svc, fav); err == nil && job != nil {
+		status.LastPatchJob = job
+		status.PendingReboot = job.InstanceState == "SUCCEEDED_REBOOT_REQUIRED"
+	}
+	a.nextPatchDeploymentFor(ctx, svc, fav, instance, status)
+
+	return status, nil
+}
+
+// PreviewPatchJob reports what TriggerPatchJob would change, without starting it
+func (a *App) PreviewPatchJob(connectionID string) (*MutationPlan, error) {
+	fav, _, err := a.patchTarget(context.Background(), connectionID)
+	if err != nil {
+		return nil, err
+	}
+	return &MutationPlan{
+		Operation: MutationPatchJob,
+		Target:    auditTarget(fav.ProjectID, fav.Zone, fav.InstanceName),
+		Changes: []string{
+			"Install the pending critical and security Windows updates",
+			"Reboot the VM if an update requires it, disconnecting open RDP sessions",
+		},
+	}, nil
+}
+
+// TriggerPatchJob starts an OS Config patch job that installs pending Windows updates on the
+// favorite's VM. confirmed must be set when the ConfirmCloudMutations setting is on.
+func (a *App) TriggerPatchJob(connectionID string, confirmed bool) (*PatchJobInfo, error) {
+	if err := a.requireMutationConfirmation(confirmed); err != nil {
+		return nil, err
+	}
+	if err := a.requireOperatorUnlock(OperatorActionPatchJob); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	fav, _, err := a.patchTarget(ctx, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := osconfig.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OS Config client: %w", err)
+	}
+	a.recordNetActivity(hostOSConfig)
+
+	req := &osconfig.ExecutePatchJobRequest{
+		DisplayName: fmt.Sprintf("iap-tunnel-manager-%s", fav.InstanceName),
+		Description: "Started from IAP Tunnel Manager",
+		Duration:    patchJobDuration,
+		InstanceFilter: &osconfig.PatchInstanceFilter{
+			Instances: []string{fmt.Sprintf("zones/%s/instances/%s", fav.Zone, fav.InstanceName)},
+		},
+		PatchConfig: &osconfig.PatchConfig{
+			RebootConfig: "DEFAULT",
+			WindowsUpdate: &osconfig.WindowsUpdateSettings{
+				Classifications: []string{"CRITICAL", "SECURITY"},
+			},
+		},
+	}
+	target := auditTarget(fav.ProjectID, fav.Zone, fav.InstanceName)
+	job, err := svc.Projects.PatchJobs.Execute("projects/"+fav.ProjectID, req).Context(ctx).Do()
+	if err != nil {
+		a.audit("patch.failed", fav.ID, target, err.Error())
+		return nil, fmt.Errorf("failed to start patch job: %w", err)
+	}
+	a.audit("patch.started", fav.ID, target, fmt.Sprintf("Patch job %s started", job.Name))
+	return patchJobInfo(job), nil
+}
+
+// patchTarget resolves a favorite and checks that its VM runs Windows
+func (a *App) patchTarget(ctx context.Context, connectionID string) (*Favorite, *compute.Instance, error) {
+	if a.tokenSource == nil {
+		return nil, nil, fmt.Errorf("not authenticated")
+	}
+	fav := a.GetConnectionInfo(connectionID)
+	if fav == nil {
+		return nil, nil, fmt.Errorf("connection not found")
+	}
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create compute client: %w", err)
+	}
+	instance, err := computeService.Instances.Get(fav.ProjectID, fav.Zone, fav.InstanceName).Context(ctx).Do()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+	if !instanceIsWindows(instance) {
+		return nil, nil, fmt.Errorf("patch management is only available for Windows VMs")
+	}
+	return fav, instance, nil
+}
+
+// lastPatchJobFor finds the newest of the project's recent patch jobs that included the VM
+func (a *App) lastPatchJobFor(ctx context.Context, svc *osconfig.Service, fav *Favorite) (*PatchJobInfo, error) {
+	resp, err := svc.Projects.PatchJobs.List("projects/" + fav.ProjectID).PageSize(recentPatchJobs).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	jobs := resp.PatchJobs
+	sort.Slice(jobs, func(i, j int) bool {
+		return parseTimestamp(jobs[i].CreateTime).After(parseTimestamp(jobs[j].CreateTime))
+	})
+
+	suffix := fmt.Sprintf("/zones/%s/instances/%s", fav.Zone, fav.InstanceName)
+	for _, job := range jobs {
+		var instanceState string
+		err := svc.Projects.PatchJobs.InstanceDetails.List(job.Name).Context(ctx).Pages(ctx, func(page *osconfig.ListPatchJobInstanceDetailsResponse) error {
+			for _, d := range page.PatchJobInstanceDetails {
+				if strings.HasSuffix(d.Name, suffix) {
+					instanceState = d.State
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if instanceState != "" {
+			info := patchJobInfo(job)
+			info.InstanceState = instanceState
+			return info, nil
+		}
+	}
+	return nil, nil
+}
+
+// nextPatchDeploymentFor fills in the earliest scheduled patch deployment that targets the VM
+func (a *App) nextPatchDeploymentFor(ctx context.Context, svc *osconfig.Service, fav *Favorite, instance *compute.Instance, status *PatchStatus) {
+	var next time.Time
+	svc.Projects.PatchDeployments.List("projects/"+fav.ProjectID).Context(ctx).Pages(ctx, func(page *osconfig.ListPatchDeploymentsResponse) error {
+		for _, d := range page.PatchDeployments {
+			if d.State == "PAUSED" || !patchFilterMatches(d.InstanceFilter, fav.Zone, fav.InstanceName, instance.Labels) {
+				continue
+			}
+			var at time.Time
+			switch {
+			case d.RecurringSchedule != nil:
+				at = parseTimestamp(d.RecurringSchedule.NextExecuteTime)
+			case d.OneTimeSchedule != nil:
+				at = parseTimestamp(d.OneTimeSchedule.ExecuteTime)
+			}
+			if at.IsZero() || at.Before(time.Now()) || (!next.IsZero() && !at.Before(next)) {
+				continue
+			}
+			next = at
+			status.NextPatchAt = at.Format(time.RFC3339)
+			status.NextPatchDeployment = d.Name
+			status.NextPatchReboots = d.PatchConfig == nil || d.PatchConfig.RebootConfig != "NEVER"
+		}
+		return nil
+	})
+}
+
+// patchFilterMatches reports whether an OS Config instance filter targets the VM. A VM must meet
+// every criterion that is set, and any value within a criterion.
+func patchFilterMatches(filter *osconfig.PatchInstanceFilter, zone, name string, labels map[string]string) bool {
+	if filter == nil {
+		return false
+	}
+	if filter.All {
+		return true
+	}
+	if len(filter.Zones) > 0 && !containsString(filter.Zones, zone) {
+		return false
+	}
+	if len(filter.Instances) > 0 {
+		suffix := fmt.Sprintf("zones/%s/instances/%s", zone, name)
+		matched := false
+		for _, instance := range filter.Instances {
+			if strings.HasSuffix(instance, suffix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(filter.InstanceNamePrefixes) > 0 {
+		matched := false
+		for _, prefix := range filter.InstanceNamePrefixes {
+			if strings.HasPrefix(name, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(filter.GroupLabels) > 0 {
+		matched := false
+		for _, group := range filter.GroupLabels {
+			if labelsMatch(group.Labels, labels) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// labelsMatch reports whether labels contains every wanted label
+func labelsMatch(want, labels map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// patchJobInfo converts an OS Config patch job
+func patchJobInfo(job *osconfig.PatchJob) *PatchJobInfo {
+	return &PatchJobInfo{
+		Name:        job.Name,
+		DisplayName: job.DisplayName,
+		State:       job.State,
+		Percent:     job.PercentComplete,
+		CreatedAt:   job.CreateTime,
+		Error:       job.ErrorMessage,
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// maxPortDetectConcurrency bounds the IAP connections DetectOpenPorts opens at once
+const maxPortDetectConcurrency = 4
+
+// RemotePortPreset is a well-known service port offered when setting up a favorite
+type RemotePortPreset struct {
+	Port           int    `json:"port"`
+	Service        string `json:"service"`
+	ConnectionType string `json:"connectionType,omitempty"` // Suggested connection type, empty for plain tunnels
+}
+
+// remotePortPresets are the ports DetectOpenPorts probes when no candidates are given, in display order
+var remotePortPresets = []RemotePortPreset{
+	{3389, "Remote Desktop (RDP)", ConnectionTypeRDP},
+	{22, "SSH", ""},
+	{5985, "WinRM (HTTP)", ""},
+	{5986, "WinRM (HTTPS)", ""},
+	{443, "HTTPS", ConnectionTypeWeb},
+	{80, "HTTP", ConnectionTypeWeb},
+	{8080, "HTTP (alternate)", ConnectionTypeWeb},
+	{5900, "VNC", ConnectionTypeVNC},
+	{1433, "SQL Server", ConnectionTypeDatabase},
+	{5432, "PostgreSQL", ConnectionTypeDatabase},
+	{3306, "MySQL", ConnectionTypeDatabase},
+}
+
+// DetectedPort is the probe result of one remote port
+type DetectedPort struct {
+	RemotePortPreset
+	Open      bool   `json:"open"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// GetRemotePortPresets returns the well-known remote ports with their services
+func (a *App) GetRemotePortPresets() []RemotePortPreset {
+	return remotePortPresets
+}
+
+// DetectOpenPorts probes remote ports of a favorite's VM through short-lived IAP connections and
+// reports which accept connections. Without candidates the presets are probed.
+func (a *App) DetectOpenPorts(connectionID string, candidates []int) ([]DetectedPort, error) {
+	a.countFeature("detect_ports")
+	fav := a.GetConnectionInfo(connectionID)
+	if fav == nil {
+		return nil, fmt.Errorf("connection not found")
+	}
+	if a.tokenSource == nil && inGoogleCloud(fav) {
+		return nil, fmt.Errorf("not authenticated")
+	}
+
+	if len(candidates) == 0 {
+		for _, p := range remotePortPresets {
+			candidates = append(candidates, p.Port)
+		}
+	}
+	for _, port := range candidates {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port: %d", port)
+		}
+	}
+
+	// Resolve once so every port is probed on the same instance of a group
+	ep, err := a.resolveTarget(fav)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]DetectedPort, len(candidates))
+	sem := make(chan struct{}, maxPortDetectConcurrency)
+	var wg sync.WaitGroup
+	for i, port := range candidates {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = a.detectPort(ep, port)
+		}(i, port)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// detectPort probes one remote port, it is open once the VM accepted the connection
+func (a *App) detectPort(ep *targetEndpoint, port int) DetectedPort {
+	result := DetectedPort{RemotePortPreset: RemotePortPreset{Port: port, Service: "Unknown"}}
+	for _, p := range remotePortPresets {
+		if p.Port == port {
+			result.RemotePortPreset = p
+			break
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	latency, err := a.probeEndpoint(ctx, ep, port)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Open = true
+	result.LatencyMs = latency.Milliseconds()
+	return result
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// wellKnownLocalPorts are ports used by macOS services that a tunnel must not shadow
+var wellKnownLocalPorts = map[int]string{
+	22:   "Remote Login (SSH)",
+	88:   "Kerberos",
+	445:  "File Sharing (SMB)",
+	548:  "File Sharing (AFP)",
+	631:  "CUPS printing",
+	3283: "Apple Remote Desktop",
+	3689: "Music sharing (DAAP)",
+	5000: "AirPlay Receiver",
+	5900: "Screen Sharing (VNC)",
+	7000: "AirPlay Receiver",
+}
+
+// PortCheck is the result of checking a local port before binding it
+type PortCheck struct {
+	Port         int    `json:"port"`
+	Conflict     bool   `json:"conflict"`
+	Service      string `json:"service,omitempty"`
+	Warning      string `json:"warning,omitempty"`
+	Alternatives []int  `json:"alternatives,omitempty"`
+}
+
+// CheckLocalPort warns when a local port overlaps a well-known macOS service and suggests alternatives
+func (a *App) CheckLocalPort(port int) PortCheck {
+	result := PortCheck{Port: port}
+	service, ok := wellKnownLocalPorts[port]
+	if !ok {
+		return result
+	}
+
+	result.Conflict = true
+	result.Service = service
+	result.Warning = fmt.Sprintf("Port %d is used by macOS %s, binding it can hijack that service", port, service)
+	result.Alternatives = a.suggestLocalPorts(port)
+	return result
+}
+
+// checkWellKnownPort rejects well-known ports unless the user allowed them in settings
+func (a *App) checkWellKnownPort(port int) error {
+	if a.GetSettings().AllowWellKnownPorts {
+		return nil
+	}
+	check := a.CheckLocalPort(port)
+	if !check.Conflict {
+		return nil
+	}
+	if len(check.Alternatives) > 0 {
+		return fmt.Errorf("%s. Suggested alternative: %d", check.Warning, check.Alternatives[0])
+	}
+	return fmt.Errorf("%s", check.Warning)
+}
+
+// suggestLocalPorts returns free ports related to port (e.g. 5900 -> 15900), plus a random free port
+func (a *App) suggestLocalPorts(port int) []int {
+	var ports []int
+	for _, candidate := range []int{port + 10000, port + 20000, port + 50000} {
+		if candidate > 65535 || a.isPortInUse(candidate) || wellKnownLocalPorts[candidate] != "" {
+			continue
+		}
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", candidate))
+		if err != nil {
+			continue
+		}
+		listener.Close()
+		ports = append(ports, candidate)
+	}
+	if free, err := a.GetFreePort(); err == nil {
+		ports = append(ports, free)
+	}
+	return ports
+}
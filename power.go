@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Low power modes
+const (
+	LowPowerAuto = "auto" // Default, low power while on battery
+	LowPowerOn   = "on"
+	LowPowerOff  = "off"
+)
+
+const (
+	// PowerModeEvent is emitted with the PowerStatus when low power mode turns on or off
+	PowerModeEvent = "power:mode"
+	// powerCheckInterval is how often the power source is checked
+	powerCheckInterval = time.Minute
+)
+
+// powerState tracks the power source and whether background work is paused
+type powerState struct {
+	mu        sync.Mutex
+	onBattery bool
+	lowPower  bool
+	watching  bool
+}
+
+// PowerStatus reports the power source and the effective low power mode
+type PowerStatus struct {
+	OnBattery bool   `json:"onBattery"`
+	LowPower  bool   `json:"lowPower"` // Background pollers are paused
+	Mode      string `json:"mode"`     // auto, on or off
+}
+
+// GetPowerStatus returns the current power source and low power state
+func (a *App) GetPowerStatus() PowerStatus {
+	a.power.mu.Lock()
+	defer a.power.mu.Unlock()
+
+	return PowerStatus{
+		OnBattery: a.power.onBattery,
+		LowPower:  a.power.lowPower,
+		Mode:      a.lowPowerMode(),
+	}
+}
+
+// lowPowerActive reports whether background pollers should stay paused
+func (a *App) lowPowerActive() bool {
+	a.power.mu.Lock()
+	defer a.power.mu.Unlock()
+	return a.power.lowPower
+}
+
+// lowPowerMode returns the configured low power mode
+func (a *App) lowPowerMode() string {
+	if mode := a.GetSettings().LowPowerMode; mode != "" {
+		return mode
+	}
+	return LowPowerAuto
+}
+
+// startPowerWatcher checks the power source now and then every minute
+func (a *App) startPowerWatcher() {
+	a.power.mu.Lock()
+	if a.power.watching {
+		a.power.mu.Unlock()
+		return
+	}
+	a.power.watching = true
+	a.power.mu.Unlock()
+
+	a.applyPowerMode()
+	go func() {
+		ticker := time.NewTicker(powerCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			a.applyPowerMode()
+		}
+	}()
+}
+
+// applyPowerMode re-evaluates low power mode and pauses or resumes the pollers on change
+func (a *App) applyPowerMode() {
+	onBattery := onBatteryPower()
+
+	var lowPower bool
+	switch a.lowPowerMode() {
+	case LowPowerOn:
+		lowPower = true
+	case LowPowerOff:
+		lowPower = false
+	default:
+		lowPower = onBattery
+	}
+
+	a.power.mu.Lock()
+	changed := a.power.lowPower != lowPower
+	a.power.onBattery = onBattery
+	a.power.lowPower = lowPower
+	a.power.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	// The health checker reads lowPowerActive when deciding its interval
+	a.applyHealthChecker()
+	a.emitEvent(PowerModeEvent, a.GetPowerStatus())
+}
+
+// onBatteryPower reports whether the Mac is running on battery
+func onBatteryPower() bool {
+	output, err := runCommand("pmset", "-g", "batt")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(output), "'Battery Power'")
+}
+
+// validateLowPowerMode checks a low power mode setting
+func validateLowPowerMode(mode string) error {
+	switch mode {
+	case "", LowPowerAuto, LowPowerOn, LowPowerOff:
+		return nil
+	default:
+		return fmt.Errorf("invalid low power mode: %s", mode)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// Preamble modes, what is sent on the IAP leg before the client's first byte
+const (
+	// PreambleProxyV2 sends a binary PROXY protocol v2 header, as accepted by nginx
+	// ("listen ... proxy_protocol") and haproxy ("accept-proxy")
+	PreambleProxyV2 = "proxy-v2"
+	// PreambleCustom sends PreambleSettings.Custom with its placeholders filled in
+	PreambleCustom = "custom"
+)
+
+// maxCustomPreamble bounds a custom preamble, services read it before the real protocol
+const maxCustomPreamble = 1024
+
+// proxyV2Signature starts every PROXY protocol v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// PROXY protocol v2 TLV types
+const (
+	// pp2TypeAuthority carries the Mac's host name
+	pp2TypeAuthority = 0x02
+	// pp2TypeMacUser is the first custom type, it carries the Mac user, e.g. "jane"
+	pp2TypeMacUser = 0xE0
+)
+
+// PreambleSettings makes tunnels of a favorite announce the originating client to the service
+// on the VM, e.g. so nginx or haproxy can log the Mac user behind each connection
+type PreambleSettings struct {
+	Mode string `json:"mode"` // PreambleProxyV2 or PreambleCustom
+	// Custom is sent as is, with {user}, {host}, {client} and {target} replaced by the Mac user,
+	// the Mac's host name, the local client address and the tunnel target
+	Custom string `json:"custom,omitempty"`
+}
+
+// validate checks the preamble settings
+func (s PreambleSettings) validate() error {
+	switch s.Mode {
+	case PreambleProxyV2:
+	case PreambleCustom:
+		if s.Custom == "" {
+			return fmt.Errorf("custom preamble is empty")
+		}
+		if len(s.Custom) > maxCustomPreamble {
+			return fmt.Errorf("custom preamble is too long (max %d bytes)", maxCustomPreamble)
+		}
+	default:
+		return fmt.Errorf("invalid preamble mode: %s", s.Mode)
+	}
+	return nil
+}
+
+// UpdatePreambleSettings sets what a favorite's tunnels send before each connection's data, nil
+// turns it off. It applies to tunnels started afterwards.
+func (a *App) UpdatePreambleSettings(favoriteID string, settings *PreambleSettings) error {
+	if settings != nil {
+		if err := settings.validate(); err != nil {
+			return err
+		}
+	}
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		f.Preamble = settings
+		return nil
+	})
+}
+
+// writePreamble sends the tunnel's preamble for a local connection on the IAP leg, if it has one
+func writePreamble(w io.Writer, settings *PreambleSettings, localConn net.Conn, target string) error {
+	if settings == nil {
+		return nil
+	}
+	var data []byte
+	switch settings.Mode {
+	case PreambleProxyV2:
+		data = proxyV2Header(localConn.RemoteAddr(), localConn.LocalAddr(), macUser(), macHost())
+	case PreambleCustom:
+		data = []byte(strings.NewReplacer(
+			"{user}", macUser(),
+			"{host}", macHost(),
+			"{client}", localConn.RemoteAddr().String(),
+			"{target}", target,
+		).Replace(settings.Custom))
+	default:
+		return nil
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to send preamble: %w", err)
+	}
+	return nil
+}
+
+// proxyV2Header builds a PROXY protocol v2 header for a TCP connection from src to dst, with the
+// Mac user and host name as TLVs
+func proxyV2Header(src, dst net.Addr, username, hostname string) []byte {
+	var addrs bytes.Buffer
+	family := byte(0x00) // AF_UNSPEC, the addresses aren't TCP
+	srcTCP, srcOK := src.(*net.TCPAddr)
+	dstTCP, dstOK := dst.(*net.TCPAddr)
+	if srcOK && dstOK {
+		if src4, dst4 := srcTCP.IP.To4(), dstTCP.IP.To4(); src4 != nil && dst4 != nil {
+			family = 0x11 // TCP over IPv4
+			addrs.Write(src4)
+			addrs.Write(dst4)
+		} else {
+			family = 0x21 // TCP over IPv6
+			addrs.Write(srcTCP.IP.To16())
+			addrs.Write(dstTCP.IP.To16())
+		}
+		binary.Write(&addrs, binary.BigEndian, uint16(srcTCP.Port))
+		binary.Write(&addrs, binary.BigEndian, uint16(dstTCP.Port))
+	}
+	writeTLV := func(kind byte, value string) {
+		if value == "" {
+			return
+		}
+		addrs.WriteByte(kind)
+		binary.Write(&addrs, binary.BigEndian, uint16(len(value)))
+		addrs.WriteString(value)
+	}
+	writeTLV(pp2TypeAuthority, hostname)
+	writeTLV(pp2TypeMacUser, username)
+
+	var header bytes.Buffer
+	header.Write(proxyV2Signature)
+	header.WriteByte(0x21) // Version 2, PROXY command
+	header.WriteByte(family)
+	binary.Write(&header, binary.BigEndian, uint16(addrs.Len()))
+	header.Write(addrs.Bytes())
+	return header.Bytes()
+}
+
+// macUser returns the short name of the logged in Mac user
+func macUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// macHost returns the Mac's host name
+func macHost() string {
+	host, _ := os.Hostname()
+	return host
+}
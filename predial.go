@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// spareConnTTL is how long a pre-dialed IAP connection waits for a local connection. Servers
+// close connections that don't start their handshake soon (RDP after about 30 seconds), so
+// spares are discarded well before.
+const spareConnTTL = 15 * time.Second
+
+// tunnelSpare is the IAP connection a tunnel dialed ahead for its next local connection.
+// The IAP relay carries one TCP stream per websocket, so connections can't be multiplexed;
+// dialing ahead takes the websocket handshake out of the connection setup instead.
+type tunnelSpare struct {
+	mu      sync.Mutex
+	conn    io.ReadWriteCloser
+	timer   *time.Timer // Discards conn after spareConnTTL
+	dialing bool
+}
+
+// take returns the spare connection, nil when there is none
+func (s *tunnelSpare) take() io.ReadWriteCloser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn := s.conn
+	if conn != nil {
+		s.timer.Stop()
+		s.conn, s.timer = nil, nil
+	}
+	return conn
+}
+
+// discard closes the spare connection, e.g. when the tunnel stops
+func (s *tunnelSpare) discard() {
+	if conn := s.take(); conn != nil {
+		conn.Close()
+	}
+}
+
+// tunnelConn returns an IAP connection for a new local connection, the spare if one is waiting.
+// It reports whether the spare was used. With PreDial on, every connection leaves a spare for
+// the next one: RDP opens several connections within seconds on connect and reconnect.
+func (a *App) tunnelConn(ctx context.Context, tunnel *Tunnel) (io.ReadWriteCloser, bool, error) {
+	if conn := tunnel.spare.take(); conn != nil {
+		a.preDial(ctx, tunnel)
+		return conn, true, nil
+	}
+
+	tunnel.recordDialAttempt()
+	conn, err := a.dialEndpoint(ctx, tunnel.target, tunnel.RemotePort)
+	if err != nil {
+		tunnel.recordDialFailure(err)
+		return nil, false, err
+	}
+	tunnel.recordDialSuccess()
+	a.preDial(ctx, tunnel)
+	return conn, false, nil
+}
+
+// preDial dials a spare connection for the tunnel in the background unless one is ready or
+// being dialed. ctx is the tunnel's, the connection is closed when it ends.
+func (a *App) preDial(ctx context.Context, tunnel *Tunnel) {
+	if !a.GetSettings().Advanced.PreDial {
+		return
+	}
+	s := &tunnel.spare
+	s.mu.Lock()
+	if s.conn != nil || s.dialing {
+		s.mu.Unlock()
+		return
+	}
+	s.dialing = true
+	s.mu.Unlock()
+
+	go func() {
+		tunnel.recordDialAttempt()
+		conn, err := a.dialEndpoint(ctx, tunnel.target, tunnel.RemotePort)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.dialing = false
+		if err != nil {
+			// The next local connection dials itself and reports the error
+			tunnel.addLog(fmt.Sprintf("Failed to pre-dial IAP: %v", err))
+			return
+		}
+		tunnel.recordDialSuccess()
+		if ctx.Err() != nil {
+			conn.Close()
+			return
+		}
+		s.conn = conn
+		s.timer = time.AfterFunc(spareConnTTL, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.conn == conn {
+				s.conn, s.timer = nil, nil
+				conn.Close()
+			}
+		})
+	}()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cedws/iapc/iap"
+)
+
+const (
+	// probeTimeout bounds a single reachability probe through IAP
+	probeTimeout = 8 * time.Second
+	// probeRetryAfter is the suggested wait (seconds) before retrying an unreachable VM
+	probeRetryAfter = 15
+	// probePollInterval is how often a probe checks whether IAP confirmed the backend connection
+	probePollInterval = 50 * time.Millisecond
+	// iapCloseConnectFailed is the close code IAP sends when the VM port refused the connection
+	iapCloseConnectFailed = 4003
+)
+
+// ProbeResult represents the outcome of a reachability probe through a tunnel
+type ProbeResult struct {
+	Reachable  bool   `json:"reachable"`
+	LatencyMs  int64  `json:"latencyMs,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Code       string `json:"code,omitempty"`       // Message code of Error
+	RetryAfter int    `json:"retryAfter,omitempty"` // Seconds until a retry makes sense
+}
+
+// ProbeTunnel checks that the VM behind a tunnel accepts connections on the remote port
+func (a *App) ProbeTunnel(tunnelID string) ProbeResult {
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+	if !ok {
+		if a.agentAttached() {
+			var result ProbeResult
+			if err := a.agentCall("ProbeTunnel", &result, tunnelID); err != nil {
+				return ProbeResult{Error: err.Error()}
+			}
+			return result
+		}
+		return ProbeResult{Error: "tunnel not found"}
+	}
+	info := tunnel.toInfo()
+	if a.tokenSource == nil && !tunnel.target.external() {
+		return ProbeResult{Error: "not authenticated"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	latency, err := a.probeEndpoint(ctx, tunnel.target, tunnel.RemotePort)
+	if err != nil {
+		a.logToTunnel(tunnelID, fmt.Sprintf("Reachability probe failed: %v", err))
+		return ProbeResult{
+			Error:      a.tr(MsgVMPortNotReady, info.RemotePort),
+			Code:       MsgVMPortNotReady,
+			RetryAfter: probeRetryAfter,
+		}
+	}
+
+	return ProbeResult{
+		Reachable: true,
+		LatencyMs: latency.Milliseconds(),
+	}
+}
+
+// probeEndpoint connects to a port of an endpoint and waits until the backend accepted the
+// connection, returning how long that took. The IAP dial returns once the websocket is up, the
+// VM port answers later with CONNECT_SUCCESS, or a close with iapCloseConnectFailed.
+func (a *App) probeEndpoint(ctx context.Context, ep *targetEndpoint, port int) (time.Duration, error) {
+	start := time.Now()
+	conn, err := a.dialEndpoint(ctx, ep, port)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := waitBackendConnected(ctx, conn); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// waitBackendConnected waits until IAP confirmed the connection to the VM port. Connections of
+// the other providers are up once dialed.
+func waitBackendConnected(ctx context.Context, conn io.ReadWriteCloser) error {
+	iapConn, ok := conn.(*iap.Conn)
+	if !ok {
+		return nil
+	}
+	// A failed backend connection surfaces as a read error, closing conn ends the read
+	readErr := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := iapConn.Read(b[:])
+		readErr <- err
+	}()
+
+	ticker := time.NewTicker(probePollInterval)
+	defer ticker.Stop()
+	for !iapConn.Connected() {
+		select {
+		case err := <-readErr:
+			// Data can only arrive after CONNECT_SUCCESS, e.g. a server banner
+			if err == nil || iapConn.Connected() {
+				return nil
+			}
+			var closeErr *iap.CloseError
+			if errors.As(err, &closeErr) && closeErr.Code == iapCloseConnectFailed {
+				return fmt.Errorf("the VM port refused the connection: %w", err)
+			}
+			return fmt.Errorf("connection closed before the VM accepted it: %w", err)
+		case <-ctx.Done():
+			return fmt.Errorf("the VM didn't accept the connection in time")
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// logToTunnel appends a log line to a tunnel if it still exists
+func (a *App) logToTunnel(tunnelID, msg string) {
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+
+	if ok {
+		tunnel.addLog(msg)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultRemotePort is the RDP port used when neither the caller nor the project sets one
+const defaultRemotePort = 3389
+
+// ProjectDefaults are settings applied to new favorites and ad hoc tunnels in a project
+type ProjectDefaults struct {
+	RemotePort    int    `json:"remotePort,omitempty"`
+	BookmarkGroup string `json:"bookmarkGroup,omitempty"`
+	Username      string `json:"username,omitempty"` // Windows account
+	// MaxDurationMinutes and IdleTimeoutMinutes stop tunnels automatically, 0 disables
+	MaxDurationMinutes int `json:"maxDurationMinutes,omitempty"`
+	IdleTimeoutMinutes int `json:"idleTimeoutMinutes,omitempty"`
+}
+
+// ProjectDefaultsEntry pairs a project with its defaults
+type ProjectDefaultsEntry struct {
+	ProjectID string          `json:"projectId"`
+	Defaults  ProjectDefaults `json:"defaults"`
+}
+
+// validate checks the defaults
+func (d ProjectDefaults) validate() error {
+	if d.RemotePort < 0 || d.RemotePort > 65535 {
+		return fmt.Errorf("invalid remote port: %d", d.RemotePort)
+	}
+	if d.MaxDurationMinutes < 0 || d.IdleTimeoutMinutes < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	return nil
+}
+
+// GetProjectDefaults returns the defaults for a project (zero values when none are set)
+func (a *App) GetProjectDefaults(projectID string) ProjectDefaults {
+	a.configMu.RLock()
+	defer a.configMu.RUnlock()
+
+	if a.config == nil {
+		return ProjectDefaults{}
+	}
+	return a.config.ProjectDefaults[projectID]
+}
+
+// GetAllProjectDefaults returns every project with defaults, sorted by project ID
+func (a *App) GetAllProjectDefaults() []ProjectDefaultsEntry {
+	a.configMu.RLock()
+	defer a.configMu.RUnlock()
+
+	entries := []ProjectDefaultsEntry{}
+	if a.config == nil {
+		return entries
+	}
+	for projectID, defaults := range a.config.ProjectDefaults {
+		entries = append(entries, ProjectDefaultsEntry{ProjectID: projectID, Defaults: defaults})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ProjectID < entries[j].ProjectID
+	})
+	return entries
+}
+
+// SetProjectDefaults stores the defaults for a project, empty defaults remove the entry
+func (a *App) SetProjectDefaults(projectID string, defaults ProjectDefaults) error {
+	projectID = strings.TrimSpace(projectID)
+	if projectID == "" {
+		return fmt.Errorf("project is required")
+	}
+	if err := defaults.validate(); err != nil {
+		return err
+	}
+	defaults.BookmarkGroup = strings.TrimSpace(defaults.BookmarkGroup)
+	defaults.Username = strings.TrimSpace(defaults.Username)
+
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		if defaults == (ProjectDefaults{}) {
+			delete(cfg.ProjectDefaults, projectID)
+			return nil
+		}
+		if cfg.ProjectDefaults == nil {
+			cfg.ProjectDefaults = make(map[string]ProjectDefaults)
+		}
+		cfg.ProjectDefaults[projectID] = defaults
+		return nil
+	})
+}
+
+// projectRemotePort returns remotePort, or the project's default when it is 0
+func (a *App) projectRemotePort(projectID string, remotePort int) int {
+	if remotePort > 0 {
+		return remotePort
+	}
+	if port := a.GetProjectDefaults(projectID).RemotePort; port > 0 {
+		return port
+	}
+	return defaultRemotePort
+}
+
+// applyProjectDefaultsLocked fills unset favorite fields from its project's defaults (caller must hold configMu)
+func (a *App) applyProjectDefaultsLocked(f *Favorite) {
+	defaults, ok := a.config.ProjectDefaults[f.ProjectID]
+	if !ok {
+		return
+	}
+	if f.BookmarkGroup == "" {
+		f.BookmarkGroup = defaults.BookmarkGroup
+	}
+	if f.Username == "" {
+		f.Username = defaults.Username
+	}
+	if f.MaxDurationMinutes == 0 {
+		f.MaxDurationMinutes = defaults.MaxDurationMinutes
+	}
+	if f.IdleTimeoutMinutes == 0 {
+		f.IdleTimeoutMinutes = defaults.IdleTimeoutMinutes
+	}
+}
+
+// applyProjectTunnelLimits applies the project's automatic stop settings to an ad hoc tunnel
+func (a *App) applyProjectTunnelLimits(info *TunnelInfo) *TunnelInfo {
+	defaults := a.GetProjectDefaults(info.ProjectID)
+	if minutes := defaults.MaxDurationMinutes; minutes > 0 {
+		// Keep an earlier stop, e.g. the production cap of the VM's favorite
+		expiresAt, err := time.Parse(time.RFC3339, info.ExpiresAt)
+		if err != nil || time.Until(expiresAt) > time.Duration(minutes)*time.Minute {
+			a.SetTunnelMaxDuration(info.ID, minutes)
+		}
+	}
+	if defaults.IdleTimeoutMinutes > 0 {
+		a.SetTunnelIdleTimeout(info.ID, defaults.IdleTimeoutMinutes)
+	}
+	if updated, err := a.GetTunnel(info.ID); err == nil {
+		return updated
+	}
+	return info
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ProxyArg runs the app as an ssh ProxyCommand: "--proxy <host> [port]"
+	ProxyArg = "--proxy"
+	// defaultSSHPort is used when ssh doesn't pass a port
+	defaultSSHPort = 22
+)
+
+// runProxyMode handles "--proxy <host> [port]" without starting the UI.
+// It reports whether the arguments requested proxy mode and the exit code.
+func runProxyMode(args []string) (int, bool) {
+	if len(args) == 0 || args[0] != ProxyArg {
+		return 0, false
+	}
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s <host> [port]\n", ProxyArg)
+		return 2, true
+	}
+
+	port := defaultSSHPort
+	if len(args) > 2 {
+		p, err := strconv.Atoi(args[2])
+		if err != nil || p <= 0 || p > 65535 {
+			fmt.Fprintf(os.Stderr, "invalid port: %s\n", args[2])
+			return 2, true
+		}
+		port = p
+	}
+
+	app := NewApp()
+	app.loadConfig()
+	if err := app.initCredentials(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1, true
+	}
+	if err := app.proxyStdio(args[1], port, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1, true
+	}
+	return 0, true
+}
+
+// proxyStdio connects stdin/stdout to a VM port through IAP until either side closes
+func (a *App) proxyStdio(host string, port int, in io.Reader, out io.Writer) error {
+	target, err := a.proxyTarget(host)
+	if err != nil {
+		return err
+	}
+	if !a.projectAllowed(target.ProjectID) {
+		return fmt.Errorf("project %s is not allowed by your administrator", target.ProjectID)
+	}
+	// There is no window to confirm in, production connections that need it go through the app
+	fav := a.endpointFavorite(target, 0, port)
+	if err := a.checkProductionConfirmation(fav); err != nil {
+		return fmt.Errorf("%w, connect from the app instead", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if fav != nil {
+		if minutes := a.maxDurationFor(fav); minutes > 0 {
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(minutes)*time.Minute)
+			defer cancel()
+		}
+	}
+
+	conn, err := a.dialEndpoint(ctx, target, port)
+	if err != nil {
+		return fmt.Errorf("failed to dial IAP: %w", err)
+	}
+	defer conn.Close()
+
+	var once sync.Once
+	done := make(chan struct{})
+	finish := func() { once.Do(func() { close(done) }) }
+
+	go func() {
+		relay(conn, in)
+		finish()
+	}()
+	go func() {
+		relay(out, conn)
+		finish()
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("maximum duration of %s reached", favoriteLabel(*fav))
+	}
+	return nil
+}
+
+// proxyTarget finds the endpoint for an ssh host: a favorite's ssh alias or ID, or "project/zone/instance"
+func (a *App) proxyTarget(host string) (*targetEndpoint, error) {
+	if parts := strings.Split(host, "/"); len(parts) == 3 {
+		return a.instanceEndpoint(parts[0], parts[2], parts[1]), nil
+	}
+
+	id := canonicalFavoriteID(host)
+	for _, f := range a.GetFavorites() {
+		if f.ID == id || sshAlias(f) == host {
+			return a.resolveTarget(&f)
+		}
+	}
+	return nil, fmt.Errorf("no connection matches %q", host)
+}
+
+// sshAlias returns the ssh host alias for a favorite, e.g. "iap-web-server"
+func sshAlias(f Favorite) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(favoriteLabel(f)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	return "iap-" + strings.Trim(b.String(), "-")
+}
+
+// GetSSHConfig returns an ssh_config snippet routing the favorites (all when none are given)
+// through this app, so "ssh iap-<name>" works from any terminal
+func (a *App) GetSSHConfig(connectionIDs []string) (string, error) {
+	a.countFeature("ssh_config")
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate app executable: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(connectionIDs))
+	for _, id := range connectionIDs {
+		wanted[id] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("# IAP Tunnel Manager\n")
+	seen := make(map[string]bool)
+	for _, f := range a.GetFavorites() {
+		if len(wanted) > 0 && !wanted[f.ID] {
+			continue
+		}
+		alias := sshAlias(f)
+		if seen[alias] {
+			// Duplicate names fall back to the favorite ID
+			alias = f.ID
+		}
+		seen[alias] = true
+
+		fmt.Fprintf(&b, "Host %s\n", alias)
+		fmt.Fprintf(&b, "  HostName %s\n", alias)
+		if f.Username != "" {
+			fmt.Fprintf(&b, "  User %s\n", f.Username)
+		}
+		fmt.Fprintf(&b, "  ProxyCommand %q %s %%h %%p\n", executable, ProxyArg)
+		b.WriteString("  ServerAliveInterval 30\n")
+		// Instance names are reused across projects, don't pin host keys to the alias
+		fmt.Fprintf(&b, "  HostKeyAlias %s\n\n", f.ID)
+	}
+	return b.String(), nil
+}
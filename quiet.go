@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// QueuedNotificationsEvent is emitted with the notifications held back while quiet mode was active
+	QueuedNotificationsEvent = "notifications:queued"
+	// quietPollInterval is how often quiet mode is rechecked while notifications are queued
+	quietPollInterval = 30 * time.Second
+	// maxQueuedNotifications bounds the queue, older notifications are dropped first
+	maxQueuedNotifications = 50
+)
+
+// notificationQueue holds the notifications queued during quiet mode. flushing is set while a
+// goroutine waits for quiet mode to end.
+type notificationQueue struct {
+	mu       sync.Mutex
+	queued   []QueuedNotification
+	flushing bool
+}
+
+// QueuedNotification is a notification held back while quiet mode was active
+type QueuedNotification struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Time    string `json:"time"`
+}
+
+// QuietModeStatus reports whether notifications and popups should be held back
+type QuietModeStatus struct {
+	Active    bool `json:"active"`
+	Focus     bool `json:"focus"`     // A Focus (Do Not Disturb) mode is on
+	Mirroring bool `json:"mirroring"` // A display is mirrored, e.g. while presenting
+	Queued    int  `json:"queued"`    // Notifications waiting for quiet mode to end
+}
+
+// GetQuietMode reports Focus and screen mirroring state so the frontend can hold back reconnect popups
+func (a *App) GetQuietMode() QuietModeStatus {
+	status := QuietModeStatus{}
+	if !a.GetSettings().NotifyDuringFocus {
+		status.Focus = focusModeActive()
+		status.Mirroring = displayMirrored()
+		status.Active = status.Focus || status.Mirroring
+	}
+
+	a.notifications.mu.Lock()
+	status.Queued = len(a.notifications.queued)
+	a.notifications.mu.Unlock()
+	return status
+}
+
+// notify posts a macOS notification, or queues it until Focus and screen mirroring are off
+func (a *App) notify(message string) {
+	if !a.GetQuietMode().Active {
+		postNotification(AppName, message)
+		return
+	}
+
+	a.notifications.mu.Lock()
+	defer a.notifications.mu.Unlock()
+
+	a.notifications.queued = append(a.notifications.queued, QueuedNotification{
+		Title:   AppName,
+		Message: message,
+		Time:    time.Now().Format(time.RFC3339),
+	})
+	if len(a.notifications.queued) > maxQueuedNotifications {
+		a.notifications.queued = a.notifications.queued[len(a.notifications.queued)-maxQueuedNotifications:]
+	}
+	if !a.notifications.flushing {
+		a.notifications.flushing = true
+		go a.flushNotificationsWhenQuietEnds()
+	}
+}
+
+// flushNotificationsWhenQuietEnds waits for quiet mode to end and delivers the queued notifications
+func (a *App) flushNotificationsWhenQuietEnds() {
+	ticker := time.NewTicker(quietPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if a.GetQuietMode().Active {
+			continue
+		}
+
+		a.notifications.mu.Lock()
+		queued := a.notifications.queued
+		a.notifications.queued = nil
+		a.notifications.flushing = false
+		a.notifications.mu.Unlock()
+
+		// One summary instead of a burst of banners
+		if len(queued) == 1 {
+			postNotification(queued[0].Title, queued[0].Message)
+		} else if len(queued) > 1 {
+			postNotification(AppName, a.tr(MsgNotifyQueuedSummary, len(queued), queued[len(queued)-1].Message))
+		}
+		a.emitEvent(QueuedNotificationsEvent, queued)
+		return
+	}
+}
+
+// focusModeActive reports whether a Focus mode is on. Manually enabled modes are recorded as
+// assertions in the DoNotDisturb database (macOS 12+); the file is unreadable without Full Disk Access,
+// in which case Focus is treated as off.
+func focusModeActive() bool {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(home, "Library", "DoNotDisturb", "DB", "Assertions.json"))
+	if err != nil {
+		return false
+	}
+
+	var assertions struct {
+		Data []struct {
+			StoreAssertionRecords []json.RawMessage `json:"storeAssertionRecords"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &assertions); err != nil {
+		return false
+	}
+	for _, d := range assertions.Data {
+		if len(d.StoreAssertionRecords) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+// Display mirroring detection through Quartz Display Services, used to hold back
+// notifications while presenting.
+
+#include <ApplicationServices/ApplicationServices.h>
+
+#define IAPTM_MAX_DISPLAYS 16
+
+// iaptmDisplayMirrored returns 1 if any active display is part of a mirror set.
+int iaptmDisplayMirrored(void) {
+	CGDirectDisplayID displays[IAPTM_MAX_DISPLAYS];
+	uint32_t count = 0;
+	if (CGGetActiveDisplayList(IAPTM_MAX_DISPLAYS, displays, &count) != kCGErrorSuccess) {
+		return 0;
+	}
+	for (uint32_t i = 0; i < count; i++) {
+		if (CGDisplayIsInMirrorSet(displays[i])) {
+			return 1;
+		}
+	}
+	return 0;
+}
@@ -0,0 +1,15 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices
+
+int iaptmDisplayMirrored(void);
+*/
+import "C"
+
+// displayMirrored reports whether any active display is mirrored, e.g. to a projector
+func displayMirrored() bool {
+	return C.iaptmDisplayMirrored() != 0
+}
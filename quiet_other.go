@@ -0,0 +1,8 @@
+//go:build !darwin
+
+package main
+
+// displayMirrored is only implemented on macOS
+func displayMirrored() bool {
+	return false
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// SessionRecordingFileName is the encrypted session log in the config directory
+	SessionRecordingFileName = "sessions.log"
+	// recordingKeyAccount is the Keychain account holding the session log key
+	recordingKeyAccount = "session-recording-key"
+	// maxSessionRecords bounds the number of records returned by GetSessionRecords
+	maxSessionRecords = 1000
+	// securityItemNotFound is the exit status of security when the Keychain item doesn't exist
+	// (errSecItemNotFound)
+	securityItemNotFound = 44
+)
+
+// recordingState serializes access to the session log and caches its key
+type recordingState struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+// SessionRecord is the metadata of one connection through a tunnel
+type SessionRecord struct {
+	TunnelID   string `json:"tunnelId"`
+	Target     string `json:"target"` // project/zone/instance
+	LocalPort  int    `json:"localPort"`
+	RemotePort int    `json:"remotePort"`
+	Client     string `json:"client,omitempty"` // Local address of the client
+	StartedAt  string `json:"startedAt"`
+	EndedAt    string `json:"endedAt"`
+	Seconds    int64  `json:"seconds"`
+	BytesSent  int64  `json:"bytesSent"`     // Client -> VM
+	BytesRecv  int64  `json:"bytesReceived"` // VM -> client
+	Error      string `json:"error,omitempty"`
+	// StartedAtDisplay and EndedAtDisplay are set by GetSessionRecords, never recorded
+	StartedAtDisplay *DisplayTime `json:"startedAtDisplay,omitempty"`
+	EndedAtDisplay   *DisplayTime `json:"endedAtDisplay,omitempty"`
+}
+
+// recordSession appends a session record to the encrypted session log when recording is enabled.
+// Failures are only logged to the tunnel so recording never breaks a connection.
+func (a *App) recordSession(tunnel *Tunnel, record SessionRecord) {
+	if !a.GetSettings().SessionRecording {
+		return
+	}
+
+	dir := a.getConfigDir()
+	if dir == "" {
+		return
+	}
+	record.TunnelID = tunnel.ID
+	record.Target = auditTarget(tunnel.ProjectID, tunnel.Zone, tunnel.VMName)
+	record.LocalPort = tunnel.LocalPort
+	record.RemotePort = tunnel.RemotePort
+	record.Error = redact(record.Error)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	a.recording.mu.Lock()
+	defer a.recording.mu.Unlock()
+
+	gcm, err := a.sessionCipher()
+	if err != nil {
+		tunnel.addLog(fmt.Sprintf("Failed to record session: %v", err))
+		return
+	}
+	line, err := encryptRecord(gcm, data)
+	if err != nil {
+		tunnel.addLog(fmt.Sprintf("Failed to record session: %v", err))
+		return
+	}
+	if err := os.MkdirAll(dir, configDirMode); err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, SessionRecordingFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		tunnel.addLog(fmt.Sprintf("Failed to record session: %v", err))
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
+
+// SessionRecords are the most recent session records
+type SessionRecords struct {
+	Records []SessionRecord `json:"records"`
+	// Skipped counts the lines that couldn't be read, e.g. truncated by a crash
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// GetSessionRecords decrypts the most recent session records, newest first. Unreadable lines are
+// skipped, so one bad line doesn't hide the rest of the log.
+func (a *App) GetSessionRecords(limit int) (*SessionRecords, error) {
+	if limit <= 0 || limit > maxSessionRecords {
+		limit = maxSessionRecords
+	}
+
+	a.recording.mu.Lock()
+	defer a.recording.mu.Unlock()
+
+	result := &SessionRecords{Records: []SessionRecord{}}
+	f, err := os.Open(filepath.Join(a.getConfigDir(), SessionRecordingFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	gcm, err := a.sessionCipher()
+	if err != nil {
+		return nil, err
+	}
+	var records []SessionRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record SessionRecord
+		data, err := decryptRecord(gcm, scanner.Text())
+		if err == nil {
+			err = json.Unmarshal(data, &record)
+		}
+		if err != nil {
+			result.Skipped++
+			continue
+		}
+		records = append(records, record)
+		if len(records) > limit {
+			records = records[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Newest first
+	for i := len(records) - 1; i >= 0; i-- {
+		records[i].StartedAtDisplay = a.displayTime(records[i].StartedAt)
+		records[i].EndedAtDisplay = a.displayTime(records[i].EndedAt)
+		result.Records = append(result.Records, records[i])
+	}
+	if result.Skipped > 0 {
+		a.logWarningf("Skipped %d unreadable session records", result.Skipped)
+	}
+	return result, nil
+}
+
+// sessionCipher returns the AES-GCM cipher for the session log, creating the key in Keychain on first use.
+// Must be called with recording.mu held.
+func (a *App) sessionCipher() (cipher.AEAD, error) {
+	if a.recording.key == nil {
+		key, err := loadRecordingKey()
+		if err != nil {
+			return nil, err
+		}
+		a.recording.key = key
+	}
+
+	block, err := aes.NewCipher(a.recording.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadRecordingKey reads the session log key from Keychain, generating and storing one if there is
+// none yet. Other failures (a locked Keychain, denied access) are returned: a new key would make the
+// existing log unreadable.
+func loadRecordingKey() ([]byte, error) {
+	output, err := runCommand("security", "find-generic-password",
+		"-s", KeychainService,
+		"-a", recordingKeyAccount,
+		"-w",
+	)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(output)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("the session recording key in Keychain is invalid")
+		}
+		return key, nil
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != securityItemNotFound {
+		return nil, commandError("failed to read the session recording key", err, nil)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate recording key: %w", err)
+	}
+	if err := saveToKeychain(KeychainService, recordingKeyAccount, hex.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to save recording key: %w", err)
+	}
+	return key, nil
+}
+
+// encryptRecord seals a record as base64(nonce || ciphertext)
+func encryptRecord(gcm cipher.AEAD, data []byte) (string, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, data, nil)), nil
+}
+
+// decryptRecord opens a line written by encryptRecord
+func decryptRecord(gcm cipher.AEAD, line string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("corrupt session record")
+	}
+	data, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt session record: %w", err)
+	}
+	return data, nil
+}
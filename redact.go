@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// redactedText replaces secrets in logs and error messages
+const redactedText = "[REDACTED]"
+
+// minRedactedSecretLength avoids redacting short values that would mangle ordinary text
+const minRedactedSecretLength = 4
+
+// secretPatterns match secrets that can show up in CLI output, serial port output and errors.
+// The first submatch, if any, is kept so the redacted text still says what was removed.
+var secretPatterns = []*regexp.Regexp{
+	// Command line credentials (Windows App CLI, FreeRDP, security)
+	regexp.MustCompile(`(?i)(--password[= ]|/p:|-w )\S+`),
+	// key=value, "key": "value" and "key: value" line style passwords and tokens
+	regexp.MustCompile(`(?i)\b((?:password|passwd|secret|token|access_token|refresh_token)=)[^\s&"]+`),
+	regexp.MustCompile(`(?i)("(?:password|secret|token|access_token|refresh_token|encryptedPassword)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`(?im)^(\s*password\s*:\s*)\S+`),
+	regexp.MustCompile(`(?i)(bearer )[A-Za-z0-9._~+/=-]+`),
+	// Google OAuth access and refresh tokens
+	regexp.MustCompile(`ya29\.[A-Za-z0-9._-]+`),
+	regexp.MustCompile(`1//[A-Za-z0-9._-]{20,}`),
+	// JWTs such as ID tokens
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	// Long base64 blobs, e.g. encrypted Windows passwords
+	regexp.MustCompile(`[A-Za-z0-9+/]{80,}={0,2}`),
+}
+
+// redact scrubs known secrets and secret-looking values from s
+func redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if len(secret) >= minRedactedSecretLength {
+			s = strings.ReplaceAll(s, secret, redactedText)
+		}
+	}
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if sub := pattern.FindStringSubmatch(match); len(sub) > 1 {
+				return sub[1] + redactedText
+			}
+			return redactedText
+		})
+	}
+	return s
+}
+
+// commandError formats a failed CLI call with its redacted output
+func commandError(action string, err error, output []byte, secrets ...string) error {
+	if len(strings.TrimSpace(string(output))) == 0 {
+		// A *CommandError already carries the output
+		return fmt.Errorf("%s: %s", action, redact(err.Error(), secrets...))
+	}
+	return fmt.Errorf("%s: %v - %s", action, err, redact(strings.TrimSpace(string(output)), secrets...))
+}
+
+// logWarningf writes a redacted warning to the Wails log, or to the agent's log file
+func (a *App) logWarningf(format string, args ...interface{}) {
+	if a.isAgent {
+		log.Print(redact(fmt.Sprintf(format, args...)))
+		return
+	}
+	if a.ctx == nil {
+		return
+	}
+	runtime.LogWarning(a.ctx, redact(fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// relayBufferSize is the largest data frame of the IAP relay, so each read from a local
+// connection is sent as one frame
+const relayBufferSize = 16 * 1024
+
+// relayBuffers recycles the copy buffers of tunneled connections, which otherwise allocate two
+// buffers per connection for as long as it lasts
+var relayBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, relayBufferSize)
+		return &buf
+	},
+}
+
+// relay copies src to dst through a pooled buffer until src ends. io.Copy would hand TCP
+// connections to ReadFrom or WriteTo, which on macOS fall back to a fresh 32 KiB buffer per call.
+func relay(dst io.Writer, src io.Reader) (int64, error) {
+	buf := relayBuffers.Get().(*[]byte)
+	defer relayBuffers.Put(buf)
+	// Hiding the optional interfaces makes io.CopyBuffer use buf
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+}
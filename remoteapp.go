@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// remoteAppAliasPattern limits aliases to names that are safe in .rdp files and file names
+var remoteAppAliasPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// RemoteApp is a published application on a favorite's VM, e.g. SSMS on a jump box
+type RemoteApp struct {
+	Alias       string `json:"alias"`       // RemoteApp alias published on the session host
+	DisplayName string `json:"displayName"` // Shown in Windows App and the favorite menu
+	Arguments   string `json:"arguments,omitempty"`
+}
+
+// validate checks the RemoteApp definition
+func (r RemoteApp) validate() error {
+	if !remoteAppAliasPattern.MatchString(r.Alias) {
+		return fmt.Errorf("invalid RemoteApp alias: %q", r.Alias)
+	}
+	if strings.TrimSpace(r.DisplayName) == "" {
+		return fmt.Errorf("RemoteApp display name is required")
+	}
+	if strings.ContainsAny(r.DisplayName+r.Arguments, "\r\n") {
+		return fmt.Errorf("RemoteApp fields must be a single line")
+	}
+	return nil
+}
+
+// SetRemoteApp adds or replaces a RemoteApp on a favorite, keyed by alias
+func (a *App) SetRemoteApp(favoriteID string, app RemoteApp) error {
+	app.DisplayName = strings.TrimSpace(app.DisplayName)
+	if err := app.validate(); err != nil {
+		return err
+	}
+
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		for i := range f.RemoteApps {
+			if strings.EqualFold(f.RemoteApps[i].Alias, app.Alias) {
+				f.RemoteApps[i] = app
+				return nil
+			}
+		}
+		f.RemoteApps = append(f.RemoteApps, app)
+		return nil
+	})
+}
+
+// RemoveRemoteApp removes a RemoteApp from a favorite
+func (a *App) RemoveRemoteApp(favoriteID, alias string) error {
+	return a.updateFavorite(favoriteID, func(f *Favorite) error {
+		for i := range f.RemoteApps {
+			if strings.EqualFold(f.RemoteApps[i].Alias, alias) {
+				f.RemoteApps = append(f.RemoteApps[:i], f.RemoteApps[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("RemoteApp %q not found", alias)
+	})
+}
+
+// ConnectRemoteApp starts the favorite's tunnel (unless one is running) and opens just the
+// published application in Windows App instead of a full desktop
+func (a *App) ConnectRemoteApp(favoriteID, alias string) ConnectResult {
+	a.countFeature("remote_app")
+	fav := a.GetConnectionInfo(favoriteID)
+	if fav == nil {
+		return ConnectResult{Error: "Connection not found"}
+	}
+	var app *RemoteApp
+	for i := range fav.RemoteApps {
+		if strings.EqualFold(fav.RemoteApps[i].Alias, alias) {
+			app = &fav.RemoteApps[i]
+			break
+		}
+	}
+	if app == nil {
+		return ConnectResult{Error: fmt.Sprintf("RemoteApp %q not found", alias)}
+	}
+	if status := a.CheckWindowsApp(); !status.Installed {
+		return ConnectResult{Error: status.Error}
+	}
+
+	tunnel := a.findActiveTunnel(fav)
+	if tunnel == nil {
+		var err error
+		tunnel, err = a.StartTunnelForConnection(fav.ID)
+		if err != nil {
+			return ConnectResult{Error: fmt.Sprintf("Failed to start tunnel: %v", err)}
+		}
+	}
+	if !a.waitForTunnelRunning(tunnel.ID, 2*time.Second) {
+		return a.connectError(tunnel, MsgTunnelStartTimeout)
+	}
+	if probe := a.ProbeTunnel(tunnel.ID); !probe.Reachable {
+		return ConnectResult{Tunnel: tunnel, Error: probe.Error, Code: probe.Code, RetryAfter: probe.RetryAfter}
+	}
+
+	path, err := a.writeRemoteAppFile(fav, *app, tunnel.LocalPort)
+	if err != nil {
+		return ConnectResult{Tunnel: tunnel, Error: err.Error()}
+	}
+	if _, err := runCommand("open", "-a", "Windows App", path); err != nil {
+		return ConnectResult{Tunnel: tunnel, Error: fmt.Sprintf("Tunnel started but failed to open Windows App: %v", err)}
+	}
+
+	a.logToTunnel(tunnel.ID, fmt.Sprintf("Opened RemoteApp %s", app.DisplayName))
+	if info, err := a.GetTunnel(tunnel.ID); err == nil {
+		tunnel = info
+	}
+	return ConnectResult{Success: true, Tunnel: tunnel}
+}
+
+// writeRemoteAppFile writes an .rdp file that launches a RemoteApp through the tunnel.
+// The password is not written, Windows App prompts for it or uses its stored credentials.
+func (a *App) writeRemoteAppFile(fav *Favorite, app RemoteApp, localPort int) (string, error) {
+	dir := filepath.Join(a.getConfigDir(), "remoteapps")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create RemoteApp directory: %w", err)
+	}
+
+	lines := []string{
+		fmt.Sprintf("full address:s:localhost:%d", localPort),
+		"remoteapplicationmode:i:1",
+		"remoteapplicationprogram:s:||" + app.Alias,
+		"remoteapplicationname:s:" + app.DisplayName,
+		"disableremoteappcapscheck:i:1",
+		"autoreconnection enabled:i:1",
+	}
+	if app.Arguments != "" {
+		lines = append(lines, "remoteapplicationcmdline:s:"+app.Arguments)
+	}
+	if fav.Username != "" {
+		lines = append(lines, "username:s:"+fav.Username)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.rdp", fav.ID, app.Alias))
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write RemoteApp file: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// InstanceResolution describes where a favorite's VM currently lives
+type InstanceResolution struct {
+	ConnectionID string   `json:"connectionId"`
+	InstanceName string   `json:"instanceName"`
+	StoredZone   string   `json:"storedZone"`
+	Found        bool     `json:"found"` // The VM exists in the stored zone
+	Moved        bool     `json:"moved"` // The VM only exists in other zones
+	Zones        []string `json:"zones,omitempty"`
+	// InstanceGroup is set when the name matches a managed instance group instead of an instance
+	InstanceGroup string `json:"instanceGroup,omitempty"`
+	Error         string `json:"error,omitempty"`
+	// DisabledService is set when the lookup failed because the API isn't enabled, see EnableService
+	DisabledService string `json:"disabledService,omitempty"`
+}
+
+// ResolveInstance looks up a favorite's VM, searching the whole project by name
+// when it is no longer in the stored zone (e.g. recreated elsewhere by Terraform)
+func (a *App) ResolveInstance(connectionID string) InstanceResolution {
+	conn := a.GetConnectionInfo(connectionID)
+	if conn == nil {
+		return InstanceResolution{ConnectionID: connectionID, Error: "connection not found"}
+	}
+	result := InstanceResolution{
+		ConnectionID: conn.ID,
+		InstanceName: conn.InstanceName,
+		StoredZone:   conn.Zone,
+	}
+	if a.tokenSource == nil {
+		result.Error = "not authenticated"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to create compute client: %v", err)
+		return result
+	}
+
+	_, err = computeService.Instances.Get(conn.ProjectID, conn.Zone, conn.InstanceName).Context(ctx).Do()
+	if err == nil {
+		result.Found = true
+		result.Zones = []string{conn.Zone}
+		return result
+	}
+	if !isNotFound(err) {
+		if disabled := a.serviceDisabled(conn.ProjectID, err); disabled != nil {
+			result.Error = disabled.Error()
+			result.DisabledService = disabled.Service
+			return result
+		}
+		result.Error = fmt.Sprintf("failed to get instance: %v", err)
+		return result
+	}
+
+	zones, err := findInstanceZones(ctx, computeService, conn.ProjectID, conn.InstanceName)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Zones = zones
+	result.Moved = len(zones) > 0
+	if !result.Moved {
+		if group, found, err := findInstanceGroup(ctx, computeService, conn.ProjectID, conn.InstanceName); err == nil && found {
+			result.InstanceGroup = group.String()
+		}
+	}
+	return result
+}
+
+// ConfirmInstanceZone stores the VM's new zone for a favorite and starts its tunnel
+func (a *App) ConfirmInstanceZone(connectionID, zone string) (*TunnelInfo, error) {
+	resolution := a.ResolveInstance(connectionID)
+	if resolution.Error != "" {
+		return nil, fmt.Errorf("%s", resolution.Error)
+	}
+	found := false
+	for _, z := range resolution.Zones {
+		if z == zone {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("instance %s not found in zone %s", resolution.InstanceName, zone)
+	}
+
+	if zone != resolution.StoredZone {
+		var project string
+		err := a.updateFavorite(connectionID, func(f *Favorite) error {
+			f.Zone = zone
+			project = f.ProjectID
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("connection not found")
+		}
+
+		a.audit("favorite.zone-updated", connectionID, auditTarget(project, zone, resolution.InstanceName),
+			fmt.Sprintf("Zone changed from %s", resolution.StoredZone))
+	}
+
+	return a.StartTunnelForConnection(connectionID)
+}
+
+// findInstanceZones returns the zones holding an instance with the given name
+func findInstanceZones(ctx context.Context, computeService *compute.Service, projectID, name string) ([]string, error) {
+	var zones []string
+	err := computeService.Instances.AggregatedList(projectID).
+		Filter(fmt.Sprintf("name = %q", name)).
+		Pages(ctx, func(page *compute.InstanceAggregatedList) error {
+			for zonePath, instanceList := range page.Items {
+				for _, instance := range instanceList.Instances {
+					if instance.Name == name {
+						zones = append(zones, strings.TrimPrefix(zonePath, "zones/"))
+					}
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search project for instance: %w", err)
+	}
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// isNotFound reports whether a Google API error is a 404
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// defaultRotationConcurrency bounds parallel resets when no concurrency is given
+const defaultRotationConcurrency = 3
+
+// PasswordRotationOptions configures a batch password rotation
+type PasswordRotationOptions struct {
+	Username       string `json:"username"` // Overrides each favorite's stored username when set
+	SaveToKeychain bool   `json:"saveToKeychain"`
+	UpdateBookmark bool   `json:"updateBookmark"`
+	Concurrency    int    `json:"concurrency"`
+	// DryRun fills each result's Plan without changing anything
+	DryRun bool `json:"dryRun,omitempty"`
+	// Confirmed must be set when the ConfirmCloudMutations setting is on
+	Confirmed bool `json:"confirmed,omitempty"`
+}
+
+// validate rejects rotations that would lose the new passwords: the results never carry them, so
+// they have to go to Keychain
+func (o PasswordRotationOptions) validate() error {
+	if !o.DryRun && !o.SaveToKeychain {
+		return fmt.Errorf("rotated passwords must be saved to Keychain, they aren't returned")
+	}
+	return nil
+}
+
+// PasswordRotationResult is one row of the batch rotation result table. It never contains the password.
+type PasswordRotationResult struct {
+	FavoriteID      string `json:"favoriteId"`
+	DisplayName     string `json:"displayName"`
+	ProjectID       string `json:"projectId"`
+	InstanceName    string `json:"instanceName"`
+	Username        string `json:"username,omitempty"`
+	Success         bool   `json:"success"`
+	Skipped         bool   `json:"skipped"`
+	Error           string `json:"error,omitempty"`
+	KeychainSaved   bool   `json:"keychainSaved"`
+	BookmarkUpdated bool   `json:"bookmarkUpdated"`
+	// Plan is what the rotation changes on this VM, set for dry runs
+	Plan *MutationPlan `json:"plan,omitempty"`
+}
+
+// RotatePasswordsForGroup resets the Windows password of every Windows favorite in a bookmark group.
+// Non-Windows VMs are skipped. Results are returned in favorites order.
+func (a *App) RotatePasswordsForGroup(group string, opts PasswordRotationOptions) ([]PasswordRotationResult, error) {
+	if a.tokenSource == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		if err := a.requireOperatorUnlock(OperatorActionPasswordRotation); err != nil {
+			return nil, err
+		}
+	}
+	return a.rotatePasswordsForGroup(context.Background(), group, opts, nil)
+}
+
+// rotatePasswordsForGroup runs a batch rotation. Canceling ctx skips members that haven't started
+// and aborts resets in flight. report may be nil.
+func (a *App) rotatePasswordsForGroup(ctx context.Context, group string, opts PasswordRotationOptions, report operationReporter) ([]PasswordRotationResult, error) {
+	members := a.groupMembers(group)
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no connections in group %q", group)
+	}
+
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %w", err)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultRotationConcurrency
+	}
+
+	results := make([]PasswordRotationResult, len(members))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var doneMu sync.Mutex
+	done := 0
+	for i, f := range members {
+		wg.Add(1)
+		go func(i int, f Favorite) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				results[i] = PasswordRotationResult{
+					FavoriteID:   f.ID,
+					DisplayName:  favoriteLabel(f),
+					ProjectID:    f.ProjectID,
+					InstanceName: f.InstanceName,
+					Skipped:      true,
+					Error:        "Canceled",
+				}
+			} else {
+				results[i] = a.rotateFavoritePassword(ctx, computeService, f, opts)
+			}
+
+			if report != nil {
+				doneMu.Lock()
+				done++
+				report(done*100/len(members), fmt.Sprintf("%d of %d VMs done", done, len(members)))
+				doneMu.Unlock()
+			}
+		}(i, f)
+	}
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// rotateFavoritePassword resets the password of a single favorite if it is a Windows VM
+func (a *App) rotateFavoritePassword(ctx context.Context, svc *compute.Service, f Favorite, opts PasswordRotationOptions) PasswordRotationResult {
+	result := PasswordRotationResult{
+		FavoriteID:   f.ID,
+		DisplayName:  favoriteLabel(f),
+		ProjectID:    f.ProjectID,
+		InstanceName: f.InstanceName,
+	}
+
+	instance, err := svc.Instances.Get(f.ProjectID, f.Zone, f.InstanceName).Context(ctx).Do()
+	if err != nil {
+		result.Error = fmt.Sprintf("Failed to get instance: %v", err)
+		return result
+	}
+	if !instanceIsWindows(instance) {
+		result.Skipped = true
+		result.Error = "Not a Windows VM"
+		return result
+	}
+	if instance.Status != "RUNNING" {
+		result.Error = fmt.Sprintf("VM is %s", instance.Status)
+		return result
+	}
+
+	username := opts.Username
+	if username == "" {
+		username = f.Username
+	}
+
+	// The reset runs on its own context, abort it through CancelWindowsPasswordReset
+	stop := context.AfterFunc(ctx, func() { a.CancelWindowsPasswordReset(f.ID) })
+	reset := a.generateWindowsPassword(WindowsPasswordRequest{
+		ConnectionID:   f.ID,
+		Username:       username,
+		SaveToKeychain: opts.SaveToKeychain,
+		UpdateBookmark: opts.UpdateBookmark,
+		DryRun:         opts.DryRun,
+		Confirmed:      opts.Confirmed,
+	})
+	stop()
+
+	result.Username = reset.Username
+	result.Success = reset.Success
+	result.Error = reset.Error
+	result.KeychainSaved = reset.KeychainSaved
+	result.BookmarkUpdated = reset.BookmarkUpdated
+	result.Plan = reset.Plan
+	return result
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// commandTimeout bounds helper commands such as security, open, launchctl or plutil
+	commandTimeout = 15 * time.Second
+	// commandWaitDelay bounds waiting for output pipes after a command was killed
+	commandWaitDelay = 2 * time.Second
+)
+
+// commandsCtx is canceled at shutdown, killing helper commands that are still running
+var commandsCtx, cancelCommands = context.WithCancel(context.Background())
+
+// CommandError is a failed external command with its captured error output
+type CommandError struct {
+	Command  string // Program name
+	ExitCode int    // -1 when the command didn't exit on its own
+	Output   string // stderr, or the combined output for CLIs that report errors on stdout
+	TimedOut bool
+	Canceled bool
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	var msg string
+	switch {
+	case e.TimedOut:
+		msg = fmt.Sprintf("%s timed out", e.Command)
+	case e.Canceled:
+		msg = fmt.Sprintf("%s was canceled", e.Command)
+	case e.ExitCode >= 0:
+		msg = fmt.Sprintf("%s exited with status %d", e.Command, e.ExitCode)
+	default:
+		msg = fmt.Sprintf("%s failed: %v", e.Command, e.Err)
+	}
+	if e.Output != "" {
+		msg += ": " + redact(e.Output)
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandSpec describes an external command for runCommandSpec
+type commandSpec struct {
+	name  string
+	args  []string
+	stdin string
+	// timeout defaults to commandTimeout
+	timeout time.Duration
+	// ctx cancels the command early, in addition to shutdown
+	ctx context.Context
+	// combined returns stderr along with stdout, for CLIs that report errors on stdout
+	combined bool
+	// env is added to the app's environment
+	env []string
+	// stream also receives the output while the command runs, from two goroutines unless combined
+	stream io.Writer
+}
+
+// runCommand runs a helper command with the default timeout and returns its stdout
+func runCommand(name string, args ...string) ([]byte, error) {
+	return runCommandSpec(commandSpec{name: name, args: args})
+}
+
+// runCommandSpec runs an external command bound to a timeout and to app shutdown.
+// Failures are returned as *CommandError.
+func runCommandSpec(spec commandSpec) ([]byte, error) {
+	parent := spec.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	timeout := spec.timeout
+	if timeout == 0 {
+		timeout = commandTimeout
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	// Shutdown cancels every command
+	stop := context.AfterFunc(commandsCtx, cancel)
+	defer stop()
+
+	cmd := exec.CommandContext(ctx, spec.name, spec.args...)
+	cmd.WaitDelay = commandWaitDelay
+	if spec.stdin != "" {
+		cmd.Stdin = strings.NewReader(spec.stdin)
+	}
+	if len(spec.env) > 0 {
+		cmd.Env = append(cmd.Environ(), spec.env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	if spec.stream != nil {
+		cmd.Stdout = io.MultiWriter(&stdout, spec.stream)
+	}
+	switch {
+	case spec.combined:
+		cmd.Stderr = cmd.Stdout
+	case spec.stream != nil:
+		cmd.Stderr = io.MultiWriter(&stderr, spec.stream)
+	default:
+		cmd.Stderr = &stderr
+	}
+
+	err := cmd.Run()
+	if err == nil {
+		return stdout.Bytes(), nil
+	}
+
+	cmdErr := &CommandError{Command: spec.name, ExitCode: -1, Err: err}
+	if spec.combined {
+		cmdErr.Output = strings.TrimSpace(stdout.String())
+	} else {
+		cmdErr.Output = strings.TrimSpace(stderr.String())
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		cmdErr.TimedOut = true
+	case ctx.Err() != nil:
+		cmdErr.Canceled = true
+	case errors.As(err, &exitErr):
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+	return stdout.Bytes(), cmdErr
+}
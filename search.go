@@ -0,0 +1,150 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxSearchResults bounds the results returned by SearchFavorites
+const maxSearchResults = 50
+
+// FavoriteSearchResult is a favorite matched by SearchFavorites
+type FavoriteSearchResult struct {
+	Favorite Favorite `json:"favorite"`
+	Score    int      `json:"score"`
+	// Matched lists the fields that matched, e.g. "name", "instance", "notes"
+	Matched []string `json:"matched"`
+}
+
+// searchField is a weighted favorite field considered by SearchFavorites
+type searchField struct {
+	name   string
+	value  string
+	weight int
+}
+
+// searchFields returns the searchable fields of a favorite. There are no tags on
+// favorites, so the bookmark group and connection type act as tags.
+func searchFields(f Favorite) []searchField {
+	return []searchField{
+		{"name", f.DisplayName, 4},
+		{"instance", f.InstanceName, 4},
+		{"hostname", f.Hostname, 3},
+		{"project", f.ProjectID + " " + f.ProjectName, 2},
+		{"tags", f.BookmarkGroup + " " + f.ConnectionType, 2},
+		{"zone", f.Zone, 1},
+		{"notes", f.Notes, 1},
+	}
+}
+
+// SearchFavorites ranks favorites by fuzzy matching every word of the query against
+// their name, VM, project, tags and notes. An empty query returns the custom order.
+func (a *App) SearchFavorites(query string) []FavoriteSearchResult {
+	terms := strings.Fields(strings.ToLower(query))
+	favorites := a.GetFavorites()
+
+	results := make([]FavoriteSearchResult, 0, len(favorites))
+	for _, f := range favorites {
+		if len(terms) == 0 {
+			results = append(results, FavoriteSearchResult{Favorite: f, Matched: []string{}})
+			continue
+		}
+
+		fields := searchFields(f)
+		total := 0
+		matched := map[string]bool{}
+		for _, term := range terms {
+			best := 0
+			bestField := ""
+			for _, field := range fields {
+				if score := fuzzyScore(strings.ToLower(field.value), term) * field.weight; score > best {
+					best, bestField = score, field.name
+				}
+			}
+			if best == 0 {
+				total = 0
+				break
+			}
+			total += best
+			matched[bestField] = true
+		}
+		if total == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(matched))
+		for _, field := range fields {
+			if matched[field.name] {
+				names = append(names, field.name)
+			}
+		}
+		results = append(results, FavoriteSearchResult{Favorite: f, Score: total, Matched: names})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+	return results
+}
+
+// fuzzyScore scores how well term matches text: exact and prefix matches rank above
+// substrings, which rank above in-order subsequences. Zero means no match.
+func fuzzyScore(text, term string) int {
+	if text == "" || term == "" {
+		return 0
+	}
+	if text == term {
+		return 100
+	}
+	if idx := strings.Index(text, term); idx >= 0 {
+		if idx == 0 {
+			return 80
+		}
+		if isWordBoundary(text, idx) {
+			return 70
+		}
+		return 50
+	}
+
+	// Subsequence match, rewarding consecutive characters and word starts
+	runes := []rune(term)
+	score := 0
+	ti := 0
+	prev := -2
+	for i, r := range text {
+		if ti >= len(runes) {
+			break
+		}
+		if r != runes[ti] {
+			continue
+		}
+		switch {
+		case i == prev+1:
+			score += 3
+		case isWordBoundary(text, i):
+			score += 2
+		default:
+			score++
+		}
+		prev = i
+		ti++
+	}
+	if ti < len(runes) {
+		return 0
+	}
+	// Keep subsequences below substring matches
+	return min(score*30/(3*len(runes)), 40)
+}
+
+// isWordBoundary reports whether the byte at idx starts a word (after a separator)
+func isWordBoundary(text string, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+	prev := rune(text[idx-1])
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/serviceusage/v1"
+)
+
+// Google APIs a project needs enabled for tunnels
+const (
+	ServiceCompute = "compute.googleapis.com"
+	ServiceIAP     = "iap.googleapis.com"
+)
+
+// requiredServices are the APIs CheckProjectServices reports on
+var requiredServices = []string{ServiceCompute, ServiceIAP}
+
+// serviceTitles name the required APIs as the Cloud console does
+var serviceTitles = map[string]string{
+	ServiceCompute: "Compute Engine API",
+	ServiceIAP:     "Cloud Identity-Aware Proxy API",
+}
+
+const (
+	// serviceStateTTL is how long the enablement of an API is trusted before asking again
+	serviceStateTTL = 10 * time.Minute
+	// enableServiceTimeout bounds waiting for the enable operation, it usually takes seconds
+	enableServiceTimeout = 2 * time.Minute
+)
+
+// ServiceStatus reports whether an API is enabled in a project
+type ServiceStatus struct {
+	Service string `json:"service"` // e.g. "compute.googleapis.com"
+	Title   string `json:"title"`
+	Enabled bool   `json:"enabled"`
+	Error   string `json:"error,omitempty"` // The state couldn't be read, e.g. Service Usage access denied
+}
+
+// ServiceDisabledError is returned in place of the raw 403 when an API the app needs isn't
+// enabled in a project; EnableService fixes it
+type ServiceDisabledError struct {
+	ProjectID string
+	Service   string
+}
+
+func (e *ServiceDisabledError) Error() string {
+	return fmt.Sprintf("API not enabled: %s (%s) is not enabled in project %s", serviceTitle(e.Service), e.Service, e.ProjectID)
+}
+
+// serviceStates caches which APIs are enabled, by "project/service"
+type serviceStates struct {
+	mu     sync.Mutex
+	states map[string]serviceState
+}
+
+type serviceState struct {
+	enabled   bool
+	checkedAt time.Time
+}
+
+// serviceTitle returns the console name of an API, or the service name
+func serviceTitle(service string) string {
+	if title, ok := serviceTitles[service]; ok {
+		return title
+	}
+	return service
+}
+
+// Patterns that find the disabled service in a 403: the ErrorInfo metadata, or the activation
+// URL of the message ("https://console.developers.google.com/apis/api/iap.googleapis.com/overview")
+var (
+	serviceMetadataPattern = regexp.MustCompile(`"service":\s*"([a-z0-9.-]+\.googleapis\.com)"`)
+	serviceURLPattern      = regexp.MustCompile(`/apis/api/([a-z0-9.-]+\.googleapis\.com)`)
+)
+
+// asServiceDisabled returns the ServiceDisabledError behind a Google API error, nil when the
+// error is about something else
+func asServiceDisabled(projectID string, err error) *ServiceDisabledError {
+	var disabled *ServiceDisabledError
+	if errors.As(err, &disabled) {
+		return disabled
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden {
+		return nil
+	}
+	reasonDisabled := strings.Contains(apiErr.Body, "SERVICE_DISABLED")
+	for _, item := range apiErr.Errors {
+		if item.Reason == "accessNotConfigured" {
+			reasonDisabled = true
+		}
+	}
+	if !reasonDisabled {
+		return nil
+	}
+	text := apiErr.Body + " " + apiErr.Message
+	service := ""
+	if m := serviceMetadataPattern.FindStringSubmatch(text); m != nil {
+		service = m[1]
+	} else if m := serviceURLPattern.FindStringSubmatch(text); m != nil {
+		service = m[1]
+	}
+	if service == "" {
+		return nil
+	}
+	return &ServiceDisabledError{ProjectID: projectID, Service: service}
+}
+
+// serviceDisabled returns the ServiceDisabledError behind err and remembers the API is off,
+// nil when err is about something else
+func (a *App) serviceDisabled(projectID string, err error) *ServiceDisabledError {
+	disabled := asServiceDisabled(projectID, err)
+	if disabled != nil {
+		a.rememberServiceState(projectID, disabled.Service, false)
+	}
+	return disabled
+}
+
+// explainDialError checks whether a failed IAP dial was refused because the IAP API is off.
+// The websocket handshake doesn't say why it was rejected, so Service Usage is asked, at most
+// once per serviceStateTTL.
+func (a *App) explainDialError(ctx context.Context, projectID string, err error) error {
+	msg := strings.ToLower(err.Error())
+	if !strings.Contains(msg, "403") && !strings.Contains(msg, "forbidden") {
+		return err
+	}
+	if enabled, checkErr := a.serviceEnabled(ctx, projectID, ServiceIAP); checkErr == nil && !enabled {
+		return &ServiceDisabledError{ProjectID: projectID, Service: ServiceIAP}
+	}
+	return err
+}
+
+// serviceEnabled returns whether an API is enabled in a project, cached for serviceStateTTL
+func (a *App) serviceEnabled(ctx context.Context, projectID, service string) (bool, error) {
+	key := projectID + "/" + service
+	a.services.mu.Lock()
+	state, ok := a.services.states[key]
+	a.services.mu.Unlock()
+	if ok && time.Since(state.checkedAt) < serviceStateTTL {
+		return state.enabled, nil
+	}
+
+	svc, err := a.serviceUsage(ctx)
+	if err != nil {
+		return false, err
+	}
+	info, err := svc.Services.Get(serviceResourceName(projectID, service)).Context(ctx).Do()
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s state: %w", service, err)
+	}
+	enabled := info.State == "ENABLED"
+	a.rememberServiceState(projectID, service, enabled)
+	return enabled, nil
+}
+
+// rememberServiceState caches the enablement of an API
+func (a *App) rememberServiceState(projectID, service string, enabled bool) {
+	a.services.mu.Lock()
+	defer a.services.mu.Unlock()
+	if a.services.states == nil {
+		a.services.states = make(map[string]serviceState)
+	}
+	a.services.states[projectID+"/"+service] = serviceState{enabled: enabled, checkedAt: time.Now()}
+}
+
+// serviceUsage creates a Service Usage API client
+func (a *App) serviceUsage(ctx context.Context) (*serviceusage.Service, error) {
+	if a.tokenSource == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	svc, err := serviceusage.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service usage client: %w", err)
+	}
+	return svc, nil
+}
+
+// serviceResourceName returns the Service Usage name of an API in a project
+func serviceResourceName(projectID, service string) string {
+	return fmt.Sprintf("projects/%s/services/%s", projectID, service)
+}
+
+// CheckProjectServices reports whether the APIs tunnels need are enabled in a project
+func (a *App) CheckProjectServices(projectID string) []ServiceStatus {
+	ctx := context.Background()
+	statuses := make([]ServiceStatus, 0, len(requiredServices))
+	for _, service := range requiredServices {
+		status := ServiceStatus{Service: service, Title: serviceTitle(service)}
+		// A fresh answer, the user may just have enabled it in the console
+		a.forgetServiceState(projectID, service)
+		enabled, err := a.serviceEnabled(ctx, projectID, service)
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Enabled = enabled
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// forgetServiceState drops the cached enablement of an API
+func (a *App) forgetServiceState(projectID, service string) {
+	a.services.mu.Lock()
+	defer a.services.mu.Unlock()
+	delete(a.services.states, projectID+"/"+service)
+}
+
+// EnableService enables an API in a project through the Service Usage API and waits until it
+// is on. It needs the serviceusage.services.enable permission, e.g. through Service Usage Admin.
+func (a *App) EnableService(projectID, service string) error {
+	if !containsString(requiredServices, service) {
+		return fmt.Errorf("enabling %s is not supported", service)
+	}
+	a.countFeature("enable_service")
+	ctx, cancel := context.WithTimeout(context.Background(), enableServiceTimeout)
+	defer cancel()
+	svc, err := a.serviceUsage(ctx)
+	if err != nil {
+		return err
+	}
+
+	op, err := svc.Services.Enable(serviceResourceName(projectID, service), &serviceusage.EnableServiceRequest{}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to enable %s: %w", serviceTitle(service), err)
+	}
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s is still being enabled, check again in a minute", serviceTitle(service))
+		case <-time.After(2 * time.Second):
+		}
+		if op, err = svc.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("failed to check enabling %s: %w", serviceTitle(service), err)
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("failed to enable %s: %s", serviceTitle(service), op.Error.Message)
+	}
+
+	a.rememberServiceState(projectID, service, true)
+	a.audit("service.enabled", "", projectID, service)
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TunnelSpec describes a tunnel well enough to start it again
+type TunnelSpec struct {
+	ProjectID  string `json:"projectId"`
+	VMName     string `json:"vmName"`
+	Zone       string `json:"zone"`
+	LocalPort  int    `json:"localPort"`
+	RemotePort int    `json:"remotePort"`
+}
+
+// TunnelRestoreResult represents the outcome of restoring a single tunnel
+type TunnelRestoreResult struct {
+	Spec   TunnelSpec  `json:"spec"`
+	Tunnel *TunnelInfo `json:"tunnel,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// initSessionRestore moves the persisted open tunnels into the pending restore list
+// and restores them right away when the restore mode is "auto"
+func (a *App) initSessionRestore() {
+	a.configMu.RLock()
+	var specs []TunnelSpec
+	mode := RestoreModeAsk
+	if a.config != nil {
+		specs = append(specs, a.config.OpenTunnels...)
+		if a.config.Settings.RestoreTunnels != "" {
+			mode = a.config.Settings.RestoreTunnels
+		}
+	}
+	a.configMu.RUnlock()
+
+	// Tunnels the agent still runs don't need restoring
+	specs = a.withoutAgentTunnels(specs)
+	if len(specs) == 0 || mode == RestoreModeOff {
+		return
+	}
+
+	a.pendingRestoreMu.Lock()
+	a.pendingRestore = specs
+	a.pendingRestoreMu.Unlock()
+
+	if mode == RestoreModeAuto {
+		go a.RestorePreviousTunnels()
+	}
+}
+
+// GetPendingRestore returns the tunnels from the previous session that have not been restored yet
+func (a *App) GetPendingRestore() []TunnelSpec {
+	a.pendingRestoreMu.Lock()
+	defer a.pendingRestoreMu.Unlock()
+
+	specs := make([]TunnelSpec, len(a.pendingRestore))
+	copy(specs, a.pendingRestore)
+	return specs
+}
+
+// RestorePreviousTunnels starts every tunnel from the previous session
+func (a *App) RestorePreviousTunnels() []TunnelRestoreResult {
+	a.pendingRestoreMu.Lock()
+	specs := a.pendingRestore
+	a.pendingRestore = nil
+	a.pendingRestoreMu.Unlock()
+
+	results := make([]TunnelRestoreResult, 0, len(specs))
+	for _, spec := range specs {
+		result := TunnelRestoreResult{Spec: spec}
+		info, err := a.StartTunnelWithRemotePort(spec.ProjectID, spec.VMName, spec.Zone, spec.LocalPort, spec.RemotePort)
+		if err != nil {
+			result.Error = fmt.Sprintf("Failed to restore tunnel: %v", err)
+		} else {
+			result.Tunnel = info
+		}
+		results = append(results, result)
+	}
+	// Tunnels started before the restore weren't saved while it was pending
+	if err := a.saveOpenTunnels(); err != nil {
+		a.logWarningf("Failed to save open tunnels: %v", err)
+	}
+	return results
+}
+
+// DismissPreviousTunnels discards the previous session's tunnels without starting them
+func (a *App) DismissPreviousTunnels() error {
+	a.pendingRestoreMu.Lock()
+	a.pendingRestore = nil
+	a.pendingRestoreMu.Unlock()
+
+	return a.saveOpenTunnels()
+}
+
+// saveOpenTunnels persists the specs of all running or starting tunnels. Nothing is saved while
+// the previous session's tunnels wait to be restored, so starting a tunnel first doesn't lose them.
+func (a *App) saveOpenTunnels() error {
+	a.tunnelsMu.RLock()
+	var tunnels []*Tunnel
+	for _, t := range a.tunnels {
+		// Tunnels to other targets come back by reconnecting their favorite
+		if t.target.isInstance() && (t.Status == "running" || t.Status == "starting") {
+			tunnels = append(tunnels, t)
+		}
+	}
+	a.tunnelsMu.RUnlock()
+
+	// Keep a stable order so the config file doesn't churn
+	sort.Slice(tunnels, func(i, j int) bool {
+		return tunnels[i].StartedAt.Before(tunnels[j].StartedAt)
+	})
+
+	// The agent doesn't write the config, it keeps its own registry
+	if a.isAgent {
+		return a.saveAgentRegistry(tunnels)
+	}
+	// The previous session's tunnels stay saved until the user restored or dismissed them
+	if len(a.GetPendingRestore()) > 0 {
+		return nil
+	}
+
+	specs := make([]TunnelSpec, 0, len(tunnels))
+	for _, t := range tunnels {
+		specs = append(specs, TunnelSpec{
+			ProjectID:  t.ProjectID,
+			VMName:     t.VMName,
+			Zone:       t.Zone,
+			LocalPort:  t.LocalPort,
+			RemotePort: t.RemotePort,
+		})
+	}
+
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		cfg.OpenTunnels = specs
+		return nil
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// TunnelURLScheme is the URL scheme used for shareable tunnel links
+	TunnelURLScheme = "iaptunnel"
+	// TunnelImportEvent is emitted when a shared tunnel link is opened
+	TunnelImportEvent = "tunnel:import"
+)
+
+// SharedTunnel is the portable description of a tunnel that can be sent to a teammate.
+// It intentionally carries no local ports, usernames or credentials.
+type SharedTunnel struct {
+	ProjectID    string `json:"projectId"`
+	InstanceName string `json:"instanceName"`
+	Zone         string `json:"zone"`
+	RemotePort   int    `json:"remotePort"`
+}
+
+// TunnelSnippet holds the shareable representations of a tunnel
+type TunnelSnippet struct {
+	Tunnel SharedTunnel `json:"tunnel"`
+	JSON   string       `json:"json"`
+	URL    string       `json:"url"`
+}
+
+// ExportTunnelSpec returns a JSON and iaptunnel:// snippet describing the tunnel
+func (a *App) ExportTunnelSpec(tunnelID string) (*TunnelSnippet, error) {
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	if !ok {
+		a.tunnelsMu.RUnlock()
+		return nil, fmt.Errorf("tunnel not found")
+	}
+	shared := SharedTunnel{
+		ProjectID:    tunnel.ProjectID,
+		InstanceName: tunnel.VMName,
+		Zone:         tunnel.Zone,
+		RemotePort:   tunnel.RemotePort,
+	}
+	a.tunnelsMu.RUnlock()
+
+	data, err := json.MarshalIndent(shared, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tunnel: %w", err)
+	}
+
+	return &TunnelSnippet{
+		Tunnel: shared,
+		JSON:   string(data),
+		URL:    shared.URL(),
+	}, nil
+}
+
+// ImportTunnelSpec parses a snippet produced by ExportTunnelSpec (JSON or iaptunnel:// URL)
+func (a *App) ImportTunnelSpec(snippet string) (*SharedTunnel, error) {
+	snippet = strings.TrimSpace(snippet)
+	if snippet == "" {
+		return nil, fmt.Errorf("snippet is empty")
+	}
+
+	var shared SharedTunnel
+	if strings.HasPrefix(snippet, TunnelURLScheme+"://") {
+		parsed, err := parseTunnelURL(snippet)
+		if err != nil {
+			return nil, err
+		}
+		shared = *parsed
+	} else if err := json.Unmarshal([]byte(snippet), &shared); err != nil {
+		return nil, fmt.Errorf("failed to parse snippet: %w", err)
+	}
+
+	if err := shared.validate(); err != nil {
+		return nil, err
+	}
+	return &shared, nil
+}
+
+// onUrlOpen handles iaptunnel:// links opened from other applications
+func (a *App) onUrlOpen(rawURL string) {
+	if a.ctx == nil {
+		return
+	}
+	shared, err := a.ImportTunnelSpec(rawURL)
+	if err != nil {
+		a.logWarningf("Ignoring invalid tunnel link %q: %v", rawURL, err)
+		return
+	}
+	// Let the frontend confirm before anything is started
+	runtime.EventsEmit(a.ctx, TunnelImportEvent, shared)
+}
+
+// URL returns the iaptunnel:// link for the shared tunnel
+func (s SharedTunnel) URL() string {
+	query := url.Values{}
+	query.Set("project", s.ProjectID)
+	query.Set("instance", s.InstanceName)
+	query.Set("zone", s.Zone)
+	query.Set("port", strconv.Itoa(s.RemotePort))
+	return fmt.Sprintf("%s://connect?%s", TunnelURLScheme, query.Encode())
+}
+
+func (s SharedTunnel) validate() error {
+	if s.ProjectID == "" || s.InstanceName == "" || s.Zone == "" {
+		return fmt.Errorf("snippet must include project, instance and zone")
+	}
+	if s.RemotePort <= 0 || s.RemotePort > 65535 {
+		return fmt.Errorf("invalid remote port: %d", s.RemotePort)
+	}
+	return nil
+}
+
+// parseTunnelURL parses an iaptunnel://connect?project=...&instance=...&zone=...&port=... link
+func parseTunnelURL(rawURL string) (*SharedTunnel, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse link: %w", err)
+	}
+	if u.Scheme != TunnelURLScheme || u.Host != "connect" {
+		return nil, fmt.Errorf("unsupported link: %s", rawURL)
+	}
+
+	query := u.Query()
+	port := 3389
+	if p := query.Get("port"); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in link: %s", p)
+		}
+	}
+
+	return &SharedTunnel{
+		ProjectID:    query.Get("project"),
+		InstanceName: query.Get("instance"),
+		Zone:         query.Get("zone"),
+		RemotePort:   port,
+	}, nil
+}
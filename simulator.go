@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// TargetKindSimulated is the local echo server SimulateTunnel connects to. It isn't in
+// targetProviders, favorites can't point at it.
+const TargetKindSimulated = "simulated"
+
+// Names a simulated tunnel shows, so it's easy to tell apart from a real one
+const (
+	simulatedProjectID = "simulated-project"
+	simulatedVMName    = "demo-vm"
+	simulatedZone      = "simulated-zone"
+)
+
+// Dial latency of the simulated IAP leg, picked at random for each connection
+const (
+	simulatedDialMin    = 40 * time.Millisecond
+	simulatedDialJitter = 80 * time.Millisecond
+)
+
+// simulatorState is the echo server standing in for a VM, started by the first SimulateTunnel
+// and kept for the life of the app
+type simulatorState struct {
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// simulatedUpstream locates the echo server of a simulated endpoint
+type simulatedUpstream struct {
+	addr string
+}
+
+// SimulateTunnel starts a tunnel through the full local pipeline (listener, logs, dial metrics
+// and session records) to an echo server instead of a VM. It needs neither credentials nor a
+// project, e.g. to explore the app or to test the frontend against real events.
+func (a *App) SimulateTunnel() (*TunnelInfo, error) {
+	a.countFeature("simulate_tunnel")
+	addr, err := a.simulatorAddr()
+	if err != nil {
+		return nil, err
+	}
+	ep := &targetEndpoint{
+		provider:  simulatedTargets{a},
+		ProjectID: simulatedProjectID,
+		Name:      simulatedVMName,
+		Zone:      simulatedZone,
+		note:      "Simulated tunnel: connections go to a local echo server, nothing reaches Google Cloud",
+		cloud:     simulatedUpstream{addr: addr},
+	}
+	// Always in the app, the agent would keep it running after the demo
+	return a.startTunnel(ep, 0, defaultRemotePort)
+}
+
+// simulatorAddr returns the address of the echo server, starting it on first use
+func (a *App) simulatorAddr() (string, error) {
+	s := &a.simulator
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Addr().String(), nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to start simulated upstream: %w", err)
+	}
+	s.listener = listener
+	go serveEcho(listener)
+	return listener.Addr().String(), nil
+}
+
+// serveEcho echoes back whatever each connection sends until the listener is closed
+func serveEcho(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	}
+}
+
+// simulatedTargets provides the echo server of SimulateTunnel
+type simulatedTargets struct{ a *App }
+
+func (p simulatedTargets) Kind() string { return TargetKindSimulated }
+
+func (p simulatedTargets) List(ctx context.Context, projectID string) ([]Target, error) {
+	return []Target{{Kind: TargetKindSimulated, Name: simulatedVMName, Location: simulatedZone, Status: "RUNNING"}}, nil
+}
+
+func (p simulatedTargets) Resolve(ctx context.Context, fav *Favorite) (*targetEndpoint, error) {
+	return nil, fmt.Errorf("favorites can't use simulated targets, use SimulateTunnel")
+}
+
+// Dial connects to the echo server after a delay like that of an IAP dial
+func (p simulatedTargets) Dial(ctx context.Context, ep *targetEndpoint, port int) (io.ReadWriteCloser, error) {
+	upstream, ok := ep.cloud.(simulatedUpstream)
+	if !ok {
+		return nil, fmt.Errorf("not a simulated endpoint: %s", ep)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(simulatedDialMin + time.Duration(rand.Int63n(int64(simulatedDialJitter)))):
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", upstream.addr)
+}
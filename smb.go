@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// smbPort is the Windows file sharing port tunneled for share mounts
+const smbPort = 445
+
+// SMBMount is a Windows share mounted through a tunnel
+type SMBMount struct {
+	ConnectionID string `json:"connectionId"`
+	TunnelID     string `json:"tunnelId"`
+	Share        string `json:"share"`
+	MountPath    string `json:"mountPath"`
+	MountedAt    string `json:"mountedAt"`
+}
+
+// smbMountState holds the active mounts keyed by mount path
+type smbMountState struct {
+	mu     sync.Mutex
+	mounts map[string]*SMBMount
+}
+
+// MountSMBShare tunnels port 445 to a favorite's VM and mounts a share (e.g. "C$") under /Volumes
+// using the Windows password stored in Keychain. The share is unmounted when the tunnel stops.
+func (a *App) MountSMBShare(connectionID, share string) (*SMBMount, error) {
+	a.countFeature("smb_mount")
+	share = strings.Trim(strings.TrimSpace(share), "/")
+	if share == "" {
+		return nil, fmt.Errorf("share name is required")
+	}
+	conn := a.GetConnectionInfo(connectionID)
+	if conn == nil {
+		return nil, fmt.Errorf("connection not found")
+	}
+	if conn.Username == "" {
+		return nil, fmt.Errorf("connection has no Windows username, generate a password first")
+	}
+	password, err := a.cachedPassword(conn.ProjectID, conn.Zone, conn.InstanceName, conn.Username)
+	if err != nil {
+		return nil, fmt.Errorf("no Windows password in Keychain for %s: %w", conn.Username, err)
+	}
+
+	tunnel := a.findSMBTunnel(conn)
+	if tunnel == nil {
+		tunnel, err = a.StartTunnelWithRemotePort(conn.ProjectID, conn.InstanceName, conn.Zone, 0, smbPort)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start SMB tunnel: %w", err)
+		}
+	}
+	if !a.waitForTunnelRunning(tunnel.ID, 2*time.Second) {
+		return nil, fmt.Errorf("tunnel did not start in time")
+	}
+
+	shareURL := (&url.URL{
+		Scheme: "smb",
+		User:   url.User(conn.Username),
+		Host:   fmt.Sprintf("localhost:%d", tunnel.LocalPort),
+		Path:   "/" + share,
+	}).String()
+	mountPath, err := mountSMBShare(shareURL, conn.Username, password)
+	if err != nil {
+		a.logToTunnel(tunnel.ID, fmt.Sprintf("Failed to mount %s: %v", share, err))
+		return nil, fmt.Errorf("failed to mount %s: %w", share, err)
+	}
+
+	mount := &SMBMount{
+		ConnectionID: conn.ID,
+		TunnelID:     tunnel.ID,
+		Share:        share,
+		MountPath:    mountPath,
+		MountedAt:    time.Now().Format(time.RFC3339),
+	}
+	a.smbMounts.mu.Lock()
+	if a.smbMounts.mounts == nil {
+		a.smbMounts.mounts = make(map[string]*SMBMount)
+	}
+	a.smbMounts.mounts[mountPath] = mount
+	a.smbMounts.mu.Unlock()
+
+	a.logToTunnel(tunnel.ID, fmt.Sprintf("Mounted %s at %s", share, mountPath))
+	a.tunnelsMu.RLock()
+	if t, ok := a.tunnels[tunnel.ID]; ok {
+		t.addStopHook(func() { a.unmountSMB(mountPath) })
+	}
+	a.tunnelsMu.RUnlock()
+
+	result := *mount
+	return &result, nil
+}
+
+// UnmountSMBShare unmounts a share mounted by MountSMBShare
+func (a *App) UnmountSMBShare(mountPath string) error {
+	a.smbMounts.mu.Lock()
+	_, ok := a.smbMounts.mounts[mountPath]
+	a.smbMounts.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("share is not mounted")
+	}
+	return a.unmountSMB(mountPath)
+}
+
+// GetSMBMounts returns the shares mounted through tunnels
+func (a *App) GetSMBMounts() []SMBMount {
+	a.smbMounts.mu.Lock()
+	defer a.smbMounts.mu.Unlock()
+
+	mounts := make([]SMBMount, 0, len(a.smbMounts.mounts))
+	for _, m := range a.smbMounts.mounts {
+		mounts = append(mounts, *m)
+	}
+	sort.Slice(mounts, func(i, j int) bool {
+		return mounts[i].MountPath < mounts[j].MountPath
+	})
+	return mounts
+}
+
+// unmountSMB unmounts a share and forgets it; unknown paths are ignored
+func (a *App) unmountSMB(mountPath string) error {
+	a.smbMounts.mu.Lock()
+	mount, ok := a.smbMounts.mounts[mountPath]
+	delete(a.smbMounts.mounts, mountPath)
+	a.smbMounts.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if err := unmountSMBShare(mountPath); err != nil {
+		a.logToTunnel(mount.TunnelID, fmt.Sprintf("Failed to unmount %s: %v", mountPath, err))
+		return fmt.Errorf("failed to unmount %s: %w", mountPath, err)
+	}
+	a.logToTunnel(mount.TunnelID, fmt.Sprintf("Unmounted %s", mountPath))
+	return nil
+}
+
+// findSMBTunnel returns a running port 445 tunnel to the favorite's VM, if any
+func (a *App) findSMBTunnel(conn *Favorite) *TunnelInfo {
+	for _, t := range a.GetActiveTunnels() {
+		if t.ProjectID == conn.ProjectID && t.VMName == conn.InstanceName && t.Zone == conn.Zone && t.RemotePort == smbPort {
+			return &t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Share mounting through the NetFS framework, which takes the password directly
+// instead of exposing it in mount_smbfs arguments.
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <NetFS/NetFS.h>
+#include <string.h>
+
+// iaptmMountSMB mounts url under /Volumes without any UI and copies the mount path into out.
+// Returns 0 on success or the NetFS error code.
+int iaptmMountSMB(const char *url, const char *user, const char *password, char *out, size_t outLen) {
+	CFURLRef cfURL = CFURLCreateWithBytes(NULL, (const UInt8 *)url, strlen(url), kCFStringEncodingUTF8, NULL);
+	if (cfURL == NULL) {
+		return -1;
+	}
+	CFStringRef cfUser = CFStringCreateWithCString(NULL, user, kCFStringEncodingUTF8);
+	CFStringRef cfPassword = CFStringCreateWithCString(NULL, password, kCFStringEncodingUTF8);
+
+	CFMutableDictionaryRef openOptions = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFDictionarySetValue(openOptions, kNAUIOptionKey, kNAUIOptionNoUI);
+
+	CFArrayRef mountpoints = NULL;
+	int status = NetFSMountURLSync(cfURL, NULL, cfUser, cfPassword, openOptions, NULL, &mountpoints);
+	if (status == 0 && mountpoints != NULL && CFArrayGetCount(mountpoints) > 0) {
+		CFStringRef path = (CFStringRef)CFArrayGetValueAtIndex(mountpoints, 0);
+		if (!CFStringGetCString(path, out, (CFIndex)outLen, kCFStringEncodingUTF8)) {
+			out[0] = '\0';
+		}
+	}
+
+	if (mountpoints != NULL) {
+		CFRelease(mountpoints);
+	}
+	CFRelease(openOptions);
+	if (cfPassword != NULL) {
+		CFRelease(cfPassword);
+	}
+	if (cfUser != NULL) {
+		CFRelease(cfUser);
+	}
+	CFRelease(cfURL);
+	return status;
+}
@@ -0,0 +1,55 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework NetFS -framework CoreFoundation
+#include <stdlib.h>
+#include <string.h>
+
+int iaptmMountSMB(const char *url, const char *user, const char *password, char *out, size_t outLen);
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// mountPathMax bounds the mount path returned by NetFS
+const mountPathMax = 1024
+
+// mountSMBShare mounts an smb:// URL with NetFS so the password never appears in process arguments
+func mountSMBShare(shareURL, username, password string) (string, error) {
+	cURL := C.CString(shareURL)
+	defer C.free(unsafe.Pointer(cURL))
+	cUser := C.CString(username)
+	defer C.free(unsafe.Pointer(cUser))
+	cPassword := C.CString(password)
+	defer func() {
+		C.memset(unsafe.Pointer(cPassword), 0, C.size_t(len(password)))
+		C.free(unsafe.Pointer(cPassword))
+	}()
+
+	out := (*C.char)(C.calloc(mountPathMax, 1))
+	defer C.free(unsafe.Pointer(out))
+
+	if status := C.iaptmMountSMB(cURL, cUser, cPassword, out, mountPathMax); status != 0 {
+		return "", fmt.Errorf("NetFS error %d", int(status))
+	}
+	mountPath := C.GoString(out)
+	if mountPath == "" {
+		return "", fmt.Errorf("share mounted but mount path is unknown")
+	}
+	return mountPath, nil
+}
+
+// unmountSMBShare unmounts a mounted share
+func unmountSMBShare(mountPath string) error {
+	out, err := runCommandSpec(commandSpec{name: "/usr/sbin/diskutil", args: []string{"unmount", mountPath}, combined: true})
+	if err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !darwin
+
+package main
+
+import "fmt"
+
+// mountSMBShare is only implemented on macOS
+func mountSMBShare(shareURL, username, password string) (string, error) {
+	return "", fmt.Errorf("mounting shares is only supported on macOS")
+}
+
+// unmountSMBShare is only implemented on macOS
+func unmountSMBShare(mountPath string) error {
+	return fmt.Errorf("mounting shares is only supported on macOS")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// TunnelStalledEvent is emitted with a TunnelStall when a connection stalls or recovers
+	TunnelStalledEvent = "tunnel:stalled"
+
+	// defaultStallTimeout is used when Advanced.StallTimeout is 0
+	defaultStallTimeout = 30
+	// MaxStallTimeout is the largest accepted stall timeout in seconds
+	MaxStallTimeout = 600
+	// stallCheckInterval is how often open connections are checked for stalls
+	stallCheckInterval = 5 * time.Second
+)
+
+// TunnelStall reports a connection of a tunnel that stopped getting data back, or recovered
+type TunnelStall struct {
+	TunnelID string `json:"tunnelId"`
+	Client   string `json:"client"`
+	Stalled  bool   `json:"stalled"` // false when the connection recovered
+	Seconds  int    `json:"seconds"` // Time since data was sent without a reply
+	// Reset is set when the IAP connection was closed so the client reconnects
+	Reset bool `json:"reset"`
+}
+
+// stallWatch tracks whether a connection is waiting for the VM to answer. An idle session sends
+// nothing and waits for nothing; a half-dead websocket takes data but never answers.
+type stallWatch struct {
+	// waitingSince is when data went toward the VM without a reply since, in UnixNano, 0 when
+	// nothing is unanswered. It is set before the write so a write blocked by backpressure counts.
+	waitingSince atomic.Int64
+}
+
+// sending marks data about to be written toward the VM
+func (w *stallWatch) sending() {
+	w.waitingSince.CompareAndSwap(0, time.Now().UnixNano())
+}
+
+// received marks data that arrived from the VM
+func (w *stallWatch) received() {
+	w.waitingSince.Store(0)
+}
+
+// waiting returns how long sent data has been unanswered, 0 when nothing is
+func (w *stallWatch) waiting() time.Duration {
+	since := w.waitingSince.Load()
+	if since == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, since))
+}
+
+// markingWriter calls mark before each write to w
+type markingWriter struct {
+	w    io.Writer
+	mark func()
+}
+
+func (m markingWriter) Write(p []byte) (int, error) {
+	m.mark()
+	return m.w.Write(p)
+}
+
+// stallTimeout returns the configured stall timeout
+func (a *App) stallTimeout() time.Duration {
+	seconds := a.GetSettings().Advanced.StallTimeout
+	if seconds <= 0 {
+		seconds = defaultStallTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// watchStall checks a connection of the tunnel until stop is closed. A stall is logged, marks the
+// tunnel stalled and, with Advanced.ResetStalled, calls reset to drop the IAP leg.
+func (a *App) watchStall(tunnel *Tunnel, client string, watch *stallWatch, reset func(), stop <-chan struct{}) {
+	ticker := time.NewTicker(stallCheckInterval)
+	defer ticker.Stop()
+
+	timeout := a.stallTimeout()
+	stalled := false
+	defer func() {
+		if stalled {
+			tunnel.setStalled(-1)
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		waiting := watch.waiting()
+		switch {
+		case !stalled && waiting >= timeout:
+			stalled = true
+			tunnel.setStalled(1)
+			event := TunnelStall{TunnelID: tunnel.ID, Client: client, Stalled: true, Seconds: int(waiting.Seconds())}
+			tunnel.addLog(fmt.Sprintf("Connection from %s stalled: no data from the VM for %ds", client, event.Seconds))
+			if a.GetSettings().Advanced.ResetStalled {
+				event.Reset = true
+				tunnel.addLog(fmt.Sprintf("Resetting the IAP connection of %s", client))
+				reset()
+			}
+			a.emitEvent(TunnelStalledEvent, event)
+		case stalled && waiting < timeout:
+			stalled = false
+			tunnel.setStalled(-1)
+			tunnel.addLog(fmt.Sprintf("Connection from %s recovered", client))
+			a.emitEvent(TunnelStalledEvent, TunnelStall{TunnelID: tunnel.ID, Client: client})
+		}
+	}
+}
+
+// setStalled adds delta to the number of stalled connections of the tunnel
+func (t *Tunnel) setStalled(delta int) {
+	t.logsMu.Lock()
+	defer t.logsMu.Unlock()
+	t.stalledConns += delta
+}
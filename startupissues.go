@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// StartupIssuesEvent is emitted with []StartupIssue when startup ran into problems
+	StartupIssuesEvent = "startup:issues"
+
+	// configBackupSuffix names the copy of the last config that parsed successfully
+	configBackupSuffix = ".bak"
+)
+
+// Startup issue codes
+const (
+	StartupIssueConfigUnreadable = "config_unreadable"
+	StartupIssueConfigCorrupt    = "config_corrupt"
+)
+
+// Recovery actions offered with a startup issue
+const (
+	StartupActionRestoreBackup = "restore_backup"
+	StartupActionStartFresh    = "start_fresh"
+)
+
+// StartupIssue is a problem found while the app started, shown to the user with its recovery actions
+type StartupIssue struct {
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Detail  string   `json:"detail,omitempty"`
+	Path    string   `json:"path,omitempty"`    // The config file involved
+	MovedTo string   `json:"movedTo,omitempty"` // Where the unparseable config was set aside
+	Backup  string   `json:"backup,omitempty"`  // Backup that RestoreConfigBackup would restore
+	Actions []string `json:"actions"`
+}
+
+// startupIssuesState holds the issues found during startup until they are resolved
+type startupIssuesState struct {
+	mu     sync.Mutex
+	issues []StartupIssue
+}
+
+// configLoadError is returned by loadConfig when a config file exists but couldn't be used
+type configLoadError struct {
+	Code string
+	Path string
+	Err  error
+}
+
+func (e *configLoadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *configLoadError) Unwrap() error {
+	return e.Err
+}
+
+// configBackupPath returns the path of the last known good config
+func (a *App) configBackupPath() string {
+	return a.configPath + configBackupSuffix
+}
+
+// backupConfig keeps a copy of a config that parsed, so a later corrupted config can be restored
+func (a *App) backupConfig(data []byte) {
+	if a.isAgent || len(data) == 0 {
+		return
+	}
+	if err := writePrivateFile(a.configBackupPath(), data); err != nil {
+		a.logWarningf("Failed to back up config: %v", err)
+	}
+}
+
+// quarantineConfig moves an unparseable config file aside so saving the defaults doesn't destroy it
+func (a *App) quarantineConfig(path string) string {
+	movedTo := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, movedTo); err != nil {
+		a.logWarningf("Failed to move corrupted config aside: %v", err)
+		return ""
+	}
+	return movedTo
+}
+
+// recordConfigLoadError turns a loadConfig error into startup issues and notifies the frontend.
+// A corrupted config file is moved aside, otherwise the next save would replace it with the defaults.
+func (a *App) recordConfigLoadError(err error) {
+	// readSplitConfig joins the errors of the files that failed
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			a.recordConfigLoadError(e)
+		}
+		return
+	}
+
+	issue := StartupIssue{
+		Code:    StartupIssueConfigUnreadable,
+		Detail:  err.Error(),
+		Path:    a.configPath,
+		Actions: []string{StartupActionStartFresh},
+	}
+	var loadErr *configLoadError
+	if errors.As(err, &loadErr) {
+		issue.Code = loadErr.Code
+		issue.Path = loadErr.Path
+	}
+	what := configFileContents(issue.Path)
+	issue.Message = fmt.Sprintf("%s couldn't be read, the app started without them.", what)
+	if issue.Code == StartupIssueConfigCorrupt {
+		issue.MovedTo = a.quarantineConfig(issue.Path)
+		issue.Message = fmt.Sprintf("%s failed to parse, the app started without them.", what)
+	}
+	if _, statErr := os.Stat(a.configBackupPath()); statErr == nil {
+		issue.Backup = a.configBackupPath()
+		issue.Actions = append([]string{StartupActionRestoreBackup}, issue.Actions...)
+	}
+	a.logWarningf("Failed to load config: %v", err)
+
+	a.startupIssues.mu.Lock()
+	a.startupIssues.issues = append(a.startupIssues.issues, issue)
+	issues := append([]StartupIssue(nil), a.startupIssues.issues...)
+	a.startupIssues.mu.Unlock()
+
+	a.emitEvent(StartupIssuesEvent, issues)
+}
+
+// configFileContents describes what a config file holds, for issue messages
+func configFileContents(path string) string {
+	switch filepath.Base(path) {
+	case FavoritesFileName:
+		return "Your favorites"
+	case SettingsFileName:
+		return "Your settings"
+	case StateFileName:
+		return "The window and session state"
+	}
+	return "Your settings and favorites"
+}
+
+// GetStartupIssues returns the problems found at startup that haven't been resolved, for a UI that
+// subscribed to StartupIssuesEvent too late
+func (a *App) GetStartupIssues() []StartupIssue {
+	a.startupIssues.mu.Lock()
+	defer a.startupIssues.mu.Unlock()
+	return append([]StartupIssue{}, a.startupIssues.issues...)
+}
+
+// DismissStartupIssue resolves an issue by keeping the current configuration (the start_fresh action)
+func (a *App) DismissStartupIssue(code string) {
+	a.startupIssues.mu.Lock()
+	defer a.startupIssues.mu.Unlock()
+	a.removeStartupIssueLocked(code)
+}
+
+// RestoreConfigBackup replaces the configuration with the last config that loaded successfully
+func (a *App) RestoreConfigBackup() error {
+	data, err := os.ReadFile(a.configBackupPath())
+	if err != nil {
+		return fmt.Errorf("failed to read config backup: %w", err)
+	}
+	var config AppConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse config backup: %w", err)
+	}
+	if config.Favorites == nil {
+		config.Favorites = []Favorite{}
+	}
+	migrateFavoriteIDs(config.Favorites, config.DeletedFavorites)
+
+	a.configMu.Lock()
+	a.config = &config
+	a.configMu.Unlock()
+
+	// Saves the restored config and applies its settings
+	if err := a.UpdateSettings(config.Settings); err != nil {
+		return err
+	}
+
+	a.startupIssues.mu.Lock()
+	a.removeStartupIssueLocked(StartupIssueConfigCorrupt)
+	a.removeStartupIssueLocked(StartupIssueConfigUnreadable)
+	a.startupIssues.mu.Unlock()
+
+	a.emitEvent(ConfigSyncedEvent)
+	return nil
+}
+
+// removeStartupIssueLocked drops issues with the given code (caller must hold startupIssues.mu)
+func (a *App) removeStartupIssueLocked(code string) {
+	kept := a.startupIssues.issues[:0]
+	for _, issue := range a.startupIssues.issues {
+		if issue.Code != code {
+			kept = append(kept, issue)
+		}
+	}
+	a.startupIssues.issues = kept
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StatusArg prints the health of auth and the open tunnels for scripts and exits with a code
+// that tells whether they can rely on them:
+//
+//	IAP\ Tunnel\ Manager --status [--json|--prometheus] [name...]
+const StatusArg = "--status"
+
+// Exit codes of status mode
+const (
+	StatusExitHealthy  = 0  // Auth works and every (named) tunnel is healthy
+	StatusExitDegraded = 1  // A tunnel is missing, not listening or not healthy
+	StatusExitAuth     = 2  // Credentials are missing or don't yield a token
+	StatusExitUsage    = 64 // Invalid arguments, EX_USAGE
+)
+
+const (
+	// StatusSocketName is the socket in the config directory the GUI reports its tunnels on
+	StatusSocketName = "status.sock"
+	// statusQueryTimeout bounds asking the GUI for its tunnels
+	statusQueryTimeout = 2 * time.Second
+)
+
+// StatusReport is the machine-readable health printed by status mode
+type StatusReport struct {
+	// Status is "healthy", "degraded" or "auth", matching the exit code
+	Status  string         `json:"status"`
+	Auth    AuthHealth     `json:"auth"`
+	Tunnels []TunnelHealth `json:"tunnels"`
+}
+
+// AuthHealth reports whether Application Default Credentials yield a token
+type AuthHealth struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// TunnelHealth is the health of one open tunnel, or of a named tunnel that isn't open
+type TunnelHealth struct {
+	Name       string `json:"name"`
+	ProjectID  string `json:"projectId,omitempty"`
+	VMName     string `json:"vmName,omitempty"`
+	LocalPort  int    `json:"localPort,omitempty"`
+	RemotePort int    `json:"remotePort,omitempty"`
+	// Health is a tunnel health level, or "missing" for a named tunnel that isn't open
+	Health string `json:"health"`
+	// Listening is set when the tunnel's local listener is up
+	Listening bool `json:"listening"`
+	// Agent is set for tunnels the background agent runs, their health comes from its dial metrics
+	Agent bool   `json:"agent,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// runStatusMode handles StatusArg
+func runStatusMode(args []string) (int, bool) {
+	if len(args) == 0 || args[0] != StatusArg {
+		return 0, false
+	}
+	format := "text"
+	var names []string
+	for _, arg := range args[1:] {
+		switch {
+		case arg == "--json":
+			format = "json"
+		case arg == "--prometheus":
+			format = "prometheus"
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(os.Stderr, "usage: %s [--json|--prometheus] [name...]\n", StatusArg)
+			return StatusExitUsage, true
+		default:
+			names = append(names, arg)
+		}
+	}
+
+	app := NewApp()
+	app.loadConfigReadOnly()
+	report, code := app.statusReport(names)
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return StatusExitDegraded, true
+		}
+		os.Stdout.Write(append(data, '\n'))
+	case "prometheus":
+		os.Stdout.WriteString(report.prometheus())
+	default:
+		os.Stdout.WriteString(report.text())
+	}
+	return code, true
+}
+
+// statusReport checks auth and the tunnels, limited to names when given, and returns the exit code
+func (a *App) statusReport(names []string) (StatusReport, int) {
+	report := StatusReport{Tunnels: []TunnelHealth{}}
+	if err := a.initCredentials(); err != nil {
+		report.Auth.Error = err.Error()
+	} else if _, err := a.tokenSource.Token(); err != nil {
+		report.Auth.Error = fmt.Sprintf("failed to get token: %v", err)
+	} else {
+		report.Auth.OK = true
+	}
+
+	// The agent and the GUI report the dial health of their own tunnels. Nothing is dialed, a
+	// connection to a tunnel's port would open an IAP connection on every poll.
+	var tunnels []TunnelInfo
+	if err := a.agentCall("GetTunnels", &tunnels); err == nil {
+		for i := range tunnels {
+			tunnels[i].Agent = true
+		}
+	}
+	guiTunnels, guiErr := a.statusGUITunnels()
+	tunnels = append(tunnels, guiTunnels...)
+
+	seenPorts := make(map[int]bool)
+	for _, t := range tunnels {
+		if t.Status != "running" && t.Status != "starting" {
+			continue
+		}
+		seenPorts[t.LocalPort] = true
+		report.Tunnels = append(report.Tunnels, TunnelHealth{
+			Name:       a.statusTunnelName(t.ProjectID, t.VMName, t.RemotePort),
+			ProjectID:  t.ProjectID,
+			VMName:     t.VMName,
+			LocalPort:  t.LocalPort,
+			RemotePort: t.RemotePort,
+			Health:     t.Health,
+			Listening:  t.Status == "running",
+			Agent:      t.Agent,
+		})
+	}
+
+	// Tunnels open at the GUI's last save are down when it isn't running to report them
+	if guiErr != nil {
+		a.configMu.RLock()
+		var specs []TunnelSpec
+		if a.config != nil {
+			specs = append(specs, a.config.OpenTunnels...)
+		}
+		a.configMu.RUnlock()
+		for _, spec := range specs {
+			if seenPorts[spec.LocalPort] {
+				continue
+			}
+			seenPorts[spec.LocalPort] = true
+			report.Tunnels = append(report.Tunnels, TunnelHealth{
+				Name:       a.statusTunnelName(spec.ProjectID, spec.VMName, spec.RemotePort),
+				ProjectID:  spec.ProjectID,
+				VMName:     spec.VMName,
+				LocalPort:  spec.LocalPort,
+				RemotePort: spec.RemotePort,
+				Health:     TunnelFailing,
+				Error:      "the app is not running",
+			})
+		}
+	}
+
+	if len(names) > 0 {
+		report.Tunnels = filterTunnelHealth(report.Tunnels, names)
+	}
+	sort.SliceStable(report.Tunnels, func(i, j int) bool {
+		return report.Tunnels[i].Name < report.Tunnels[j].Name
+	})
+
+	report.Status = "healthy"
+	code := StatusExitHealthy
+	for _, t := range report.Tunnels {
+		if t.Health != TunnelHealthy {
+			report.Status, code = "degraded", StatusExitDegraded
+		}
+	}
+	if !report.Auth.OK {
+		report.Status, code = "auth", StatusExitAuth
+	}
+	return report, code
+}
+
+// serveStatusSocket reports the GUI's tunnels on StatusSocketName for status mode
+func (a *App) serveStatusSocket() {
+	listener, err := a.listenConfigSocket(filepath.Join(a.getConfigDir(), StatusSocketName))
+	if err != nil {
+		a.logWarningf("Failed to serve tunnel status: %v", err)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tunnels", func(w http.ResponseWriter, r *http.Request) {
+		a.tunnelsMu.RLock()
+		tunnels := make([]TunnelInfo, 0, len(a.tunnels))
+		for _, t := range a.tunnels {
+			tunnels = append(tunnels, *t.toInfo())
+		}
+		a.tunnelsMu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tunnels)
+	})
+	a.statusServer = &http.Server{Handler: mux}
+	go a.statusServer.Serve(listener)
+}
+
+// statusGUITunnels asks the running GUI for its tunnels, an error means it isn't running
+func (a *App) statusGUITunnels() ([]TunnelInfo, error) {
+	client := unixSocketClient(filepath.Join(a.getConfigDir(), StatusSocketName))
+	client.Timeout = statusQueryTimeout
+	resp, err := client.Get("http://app/tunnels")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tunnels []TunnelInfo
+	if err := json.NewDecoder(resp.Body).Decode(&tunnels); err != nil {
+		return nil, fmt.Errorf("invalid status response: %w", err)
+	}
+	return tunnels, nil
+}
+
+// statusTunnelName names a tunnel after its favorite, or its VM without one
+func (a *App) statusTunnelName(projectID, vmName string, remotePort int) string {
+	for _, f := range a.GetFavorites() {
+		if f.ProjectID == projectID && f.InstanceName == vmName && f.RemotePort == remotePort {
+			return favoriteLabel(f)
+		}
+	}
+	return vmName
+}
+
+// filterTunnelHealth keeps the tunnels matching names by name or VM, ignoring case, and adds
+// a missing entry for each name without one
+func filterTunnelHealth(tunnels []TunnelHealth, names []string) []TunnelHealth {
+	filtered := []TunnelHealth{}
+	for _, name := range names {
+		found := false
+		for _, t := range tunnels {
+			if strings.EqualFold(t.Name, name) || strings.EqualFold(t.VMName, name) {
+				filtered = append(filtered, t)
+				found = true
+			}
+		}
+		if !found {
+			filtered = append(filtered, TunnelHealth{Name: name, Health: "missing", Error: "no open tunnel"})
+		}
+	}
+	return filtered
+}
+
+// text formats the report for people
+func (r StatusReport) text() string {
+	var b strings.Builder
+	if r.Auth.OK {
+		b.WriteString("auth: ok\n")
+	} else {
+		fmt.Fprintf(&b, "auth: broken (%s)\n", r.Auth.Error)
+	}
+	for _, t := range r.Tunnels {
+		fmt.Fprintf(&b, "%s: %s", t.Name, t.Health)
+		if t.LocalPort != 0 {
+			fmt.Fprintf(&b, " (localhost:%d -> %s:%d)", t.LocalPort, t.VMName, t.RemotePort)
+		}
+		if t.Error != "" {
+			fmt.Fprintf(&b, ": %s", t.Error)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "status: %s\n", r.Status)
+	return b.String()
+}
+
+// prometheus formats the report in the Prometheus text exposition format, e.g. for the
+// node_exporter textfile collector
+func (r StatusReport) prometheus() string {
+	var b strings.Builder
+	b.WriteString("# HELP iap_auth_ok Whether Application Default Credentials yield a token.\n")
+	b.WriteString("# TYPE iap_auth_ok gauge\n")
+	fmt.Fprintf(&b, "iap_auth_ok %d\n", boolMetric(r.Auth.OK))
+
+	b.WriteString("# HELP iap_tunnel_up Whether the tunnel's local listener is up.\n")
+	b.WriteString("# TYPE iap_tunnel_up gauge\n")
+	for _, t := range r.Tunnels {
+		fmt.Fprintf(&b, "iap_tunnel_up{%s} %d\n", t.promLabels(), boolMetric(t.Listening))
+	}
+	b.WriteString("# HELP iap_tunnel_healthy Whether the tunnel is listening and its IAP dials succeed.\n")
+	b.WriteString("# TYPE iap_tunnel_healthy gauge\n")
+	for _, t := range r.Tunnels {
+		fmt.Fprintf(&b, "iap_tunnel_healthy{%s} %d\n", t.promLabels(), boolMetric(t.Health == TunnelHealthy))
+	}
+	return b.String()
+}
+
+// promLabels returns the Prometheus labels of a tunnel
+func (t TunnelHealth) promLabels() string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+	return fmt.Sprintf(`name="%s",project="%s",vm="%s",local_port="%d",health="%s"`,
+		escape(t.Name), escape(t.ProjectID), escape(t.VMName), t.LocalPort, t.Health)
+}
+
+// boolMetric returns 1 for true and 0 for false
+func boolMetric(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The config is stored in three files, so a small change rewrites only the file it touches and a
+// damaged file loses only its own part
+const (
+	// FavoritesFileName holds the user's connections
+	FavoritesFileName = "favorites.json"
+	// SettingsFileName holds the app settings and project defaults
+	SettingsFileName = "settings.json"
+	// StateFileName holds what the app remembers between launches, e.g. window geometry
+	StateFileName = "state.json"
+
+	// configSaveDelay batches the changes made shortly after each other into one write
+	configSaveDelay = 500 * time.Millisecond
+	// configRetryDelay is the wait before retrying a failed write
+	configRetryDelay = 30 * time.Second
+)
+
+// favoritesFile is the content of FavoritesFileName
+type favoritesFile struct {
+	Favorites        []Favorite        `json:"favorites"`
+	DeletedFavorites map[string]string `json:"deletedFavorites,omitempty"`
+}
+
+// settingsFile is the content of SettingsFileName
+type settingsFile struct {
+	Settings        AppSettings                `json:"settings"`
+	ProjectDefaults map[string]ProjectDefaults `json:"projectDefaults,omitempty"`
+}
+
+// stateFile is the content of StateFileName
+type stateFile struct {
+	LastConnection *LastConnection `json:"lastConnection,omitempty"`
+	OpenTunnels    []TunnelSpec    `json:"openTunnels,omitempty"`
+	Window         *WindowState    `json:"window,omitempty"`
+	JITSessions    []JITSession    `json:"jitSessions,omitempty"`
+}
+
+// configFile is one of the files the config is split into. mu serializes its writes and last is
+// the content last read or written, so unchanged files aren't rewritten.
+type configFile struct {
+	mu   sync.Mutex
+	last []byte
+}
+
+// configStorage tracks the config files independently of each other, and whether the config has
+// changes that weren't written yet
+type configStorage struct {
+	favorites configFile
+	settings  configFile
+	state     configFile
+
+	mu    sync.Mutex
+	dirty bool
+	timer *time.Timer // Pending write, nil when none is scheduled
+}
+
+// configPart is a config file with the part of AppConfig it stores
+type configPart struct {
+	name  string
+	file  *configFile
+	value interface{}
+}
+
+// configParts splits config into its files
+func (a *App) configParts(config *AppConfig) []configPart {
+	return []configPart{
+		{FavoritesFileName, &a.storage.favorites, favoritesFile{
+			Favorites:        config.Favorites,
+			DeletedFavorites: config.DeletedFavorites,
+		}},
+		{SettingsFileName, &a.storage.settings, settingsFile{
+			Settings:        config.Settings,
+			ProjectDefaults: config.ProjectDefaults,
+		}},
+		{StateFileName, &a.storage.state, stateFile{
+			LastConnection: config.LastConnection,
+			OpenTunnels:    config.OpenTunnels,
+			Window:         config.Window,
+			JITSessions:    config.JITSessions,
+		}},
+	}
+}
+
+// errConfigUnchanged is returned by a mutateConfig function that made no change, to skip the save
+var errConfigUnchanged = errors.New("config unchanged")
+
+// mutateConfig runs fn with the config locked for writing and schedules a save unless fn fails.
+// Every change to the config goes through it, so a change is never interleaved with another writer.
+// fn must check what it needs before changing anything and must not call methods that lock configMu.
+func (a *App) mutateConfig(fn func(cfg *AppConfig) error) error {
+	a.configMu.Lock()
+	if a.config == nil {
+		a.config = &AppConfig{Favorites: []Favorite{}}
+	}
+	err := fn(a.config)
+	a.configMu.Unlock()
+	if errors.Is(err, errConfigUnchanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	a.markConfigDirty()
+	return nil
+}
+
+// updateFavorite applies fn to a favorite under mutateConfig and stamps its UpdatedAt
+func (a *App) updateFavorite(favoriteID string, fn func(f *Favorite) error) error {
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		fav := favoriteIn(cfg, favoriteID)
+		if fav == nil {
+			return fmt.Errorf("favorite not found")
+		}
+		if err := fn(fav); err != nil {
+			return err
+		}
+		fav.UpdatedAt = time.Now().Format(time.RFC3339)
+		return nil
+	})
+}
+
+// favoriteIn returns the favorite with the ID in cfg, nil if there is none. Legacy IDs find the
+// favorite they were migrated to.
+func favoriteIn(cfg *AppConfig, favoriteID string) *Favorite {
+	favoriteID = canonicalFavoriteID(favoriteID)
+	for i := range cfg.Favorites {
+		if cfg.Favorites[i].ID == favoriteID {
+			return &cfg.Favorites[i]
+		}
+	}
+	return nil
+}
+
+// markConfigDirty schedules writing the config after configSaveDelay. It doesn't touch configMu,
+// so callers may hold it.
+func (a *App) markConfigDirty() {
+	// The GUI owns the config, the agent only reads it
+	if a.isAgent {
+		return
+	}
+	a.storage.mu.Lock()
+	defer a.storage.mu.Unlock()
+	a.storage.dirty = true
+	if a.storage.timer == nil {
+		a.storage.timer = time.AfterFunc(configSaveDelay, a.writePendingConfig)
+	}
+}
+
+// writePendingConfig is the scheduled write of markConfigDirty
+func (a *App) writePendingConfig() {
+	if err := a.flushConfig(); err != nil {
+		a.logWarningf("Failed to save config: %v", err)
+	}
+}
+
+// flushConfig writes pending changes now, e.g. at shutdown. A failed write is retried after
+// configRetryDelay. The caller must not hold configMu.
+func (a *App) flushConfig() error {
+	a.storage.mu.Lock()
+	if a.storage.timer != nil {
+		a.storage.timer.Stop()
+		a.storage.timer = nil
+	}
+	dirty := a.storage.dirty
+	a.storage.dirty = false
+	a.storage.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+
+	a.configMu.RLock()
+	var err error
+	if a.config != nil {
+		err = a.writeConfigFiles(a.config)
+	}
+	a.configMu.RUnlock()
+	if err != nil {
+		a.storage.mu.Lock()
+		a.storage.dirty = true
+		if a.storage.timer == nil {
+			a.storage.timer = time.AfterFunc(configRetryDelay, a.writePendingConfig)
+		}
+		a.storage.mu.Unlock()
+		return err
+	}
+
+	a.scheduleICloudSync()
+	return nil
+}
+
+// writeConfigFiles writes the files whose part of config changed. The caller must hold configMu,
+// at least for reading, so writes land in the order of the changes.
+func (a *App) writeConfigFiles(config *AppConfig) error {
+	dir := a.getConfigDir()
+	if dir == "" {
+		return fmt.Errorf("config path not set")
+	}
+	for _, part := range a.configParts(config) {
+		data, err := json.MarshalIndent(part.value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", part.name, err)
+		}
+		if err := part.file.write(filepath.Join(dir, part.name), data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+	return nil
+}
+
+// write replaces the file at path unless it already holds data
+func (f *configFile) write(path string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if bytes.Equal(data, f.last) {
+		return nil
+	}
+	if err := writePrivateFile(path, data); err != nil {
+		return err
+	}
+	f.last = data
+	return nil
+}
+
+// splitConfigExists reports whether any of the split config files exists
+func (a *App) splitConfigExists() bool {
+	for _, name := range []string{FavoritesFileName, SettingsFileName, StateFileName} {
+		if _, err := os.Stat(filepath.Join(a.getConfigDir(), name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readSplitConfig reads the config files. A file that is missing or can't be used leaves its part
+// empty, the others still load; the failures are returned joined as *configLoadError.
+func (a *App) readSplitConfig() (*AppConfig, error) {
+	config := &AppConfig{}
+	var favorites favoritesFile
+	var settings settingsFile
+	var state stateFile
+
+	var errs []error
+	read := func(name string, file *configFile, target interface{}) bool {
+		path := filepath.Join(a.getConfigDir(), name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return false
+		}
+		if err != nil {
+			errs = append(errs, &configLoadError{Code: StartupIssueConfigUnreadable, Path: path, Err: fmt.Errorf("failed to read %s: %w", name, err)})
+			return false
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			errs = append(errs, &configLoadError{Code: StartupIssueConfigCorrupt, Path: path, Err: fmt.Errorf("failed to parse %s: %w", name, err)})
+			return false
+		}
+		file.mu.Lock()
+		file.last = data
+		file.mu.Unlock()
+		return true
+	}
+
+	if read(FavoritesFileName, &a.storage.favorites, &favorites) {
+		config.Favorites = favorites.Favorites
+		config.DeletedFavorites = favorites.DeletedFavorites
+	}
+	if read(SettingsFileName, &a.storage.settings, &settings) {
+		config.Settings = settings.Settings
+		config.ProjectDefaults = settings.ProjectDefaults
+	}
+	if read(StateFileName, &a.storage.state, &state) {
+		config.LastConnection = state.LastConnection
+		config.OpenTunnels = state.OpenTunnels
+		config.Window = state.Window
+		config.JITSessions = state.JITSessions
+	}
+	return config, errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestApp returns an app with its config in a temporary directory
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	t.Setenv(ConfigDirEnv, t.TempDir())
+	app := NewApp()
+	if err := app.loadConfig(); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	return app
+}
+
+// addTestFavorite adds a favorite, retrying when a concurrent add took the same free port
+func addTestFavorite(app *App, name string) (*Favorite, error) {
+	for {
+		fav, err := app.AddFavorite(name, "project", "Project", name, "europe-west1-b", 3389, 0)
+		if err != nil && strings.Contains(err.Error(), "was just taken") {
+			continue
+		}
+		return fav, err
+	}
+}
+
+// TestConcurrentConfigMutations runs the config writers side by side, run it with -race
+func TestConcurrentConfigMutations(t *testing.T) {
+	app := newTestApp(t)
+	const workers = 8
+	const perWorker = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker*3)
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				name := fmt.Sprintf("vm-%d-%d", w, i)
+				fav, err := addTestFavorite(app, name)
+				if err != nil {
+					errs <- fmt.Errorf("add %s: %w", name, err)
+					continue
+				}
+				// Every other favorite is removed again
+				if i%2 == 1 {
+					if err := app.RemoveFavorite(fav.ID); err != nil {
+						errs <- fmt.Errorf("remove %s: %w", name, err)
+					}
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				settings := app.GetSettings()
+				settings.HealthCheckInterval = 0
+				settings.TimeZone = ""
+				settings.RestoreTunnels = RestoreModeOff
+				if err := app.UpdateSettings(settings); err != nil {
+					errs <- fmt.Errorf("update settings: %w", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	want := workers * perWorker / 2
+	favorites := app.GetFavorites()
+	if len(favorites) != want {
+		t.Fatalf("got %d favorites, want %d", len(favorites), want)
+	}
+	ports := make(map[int]string)
+	for _, f := range favorites {
+		if other, ok := ports[f.LocalPort]; ok {
+			t.Errorf("%s and %s share local port %d", f.DisplayName, other, f.LocalPort)
+		}
+		ports[f.LocalPort] = f.DisplayName
+	}
+
+	// What was written must load back the same
+	if err := app.flushConfig(); err != nil {
+		t.Fatalf("flushConfig: %v", err)
+	}
+	reloaded := NewApp()
+	if err := reloaded.loadConfig(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := len(reloaded.GetFavorites()); got != want {
+		t.Errorf("reloaded %d favorites, want %d", got, want)
+	}
+	if got := reloaded.GetSettings().RestoreTunnels; got != RestoreModeOff {
+		t.Errorf("reloaded restore mode %q, want %q", got, RestoreModeOff)
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cedws/iapc/iap"
+)
+
+// Target kinds, each served by a TargetProvider
+const (
+	// TargetKindInstance is a Compute Engine instance, or a managed instance group by name
+	TargetKindInstance = "instance"
+	// TargetKindHostname is an internal DNS name, resolved to an instance or a destination group host
+	TargetKindHostname = "hostname"
+)
+
+// targetResolveTimeout bounds the lookups a provider makes to resolve a favorite
+const targetResolveTimeout = 30 * time.Second
+
+// Target is something a provider can tunnel to in a project, as listed for pickers
+type Target struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// ID identifies the target to its provider when Name doesn't, e.g. an Azure resource ID
+	ID       string `json:"id,omitempty"`
+	Location string `json:"location,omitempty"` // Zone or region
+	Status   string `json:"status,omitempty"`
+}
+
+// TargetProvider is a type of target favorites and tunnels can reach. The tunnel machinery only
+// deals with the endpoints a provider resolves and dials, so a new type of target is a new
+// provider in targetProviders instead of another special case in App.
+type TargetProvider interface {
+	Kind() string
+	// List returns the targets of a project
+	List(ctx context.Context, projectID string) ([]Target, error)
+	// Resolve picks the endpoint a new connection for the favorite goes to
+	Resolve(ctx context.Context, fav *Favorite) (*targetEndpoint, error)
+	// Dial connects to a port of an endpoint the provider resolved
+	Dial(ctx context.Context, ep *targetEndpoint, port int) (io.ReadWriteCloser, error)
+}
+
+// targetProviders creates the provider of each target kind
+var targetProviders = map[string]func(*App) TargetProvider{
+	TargetKindInstance: func(a *App) TargetProvider { return instanceTargets{a} },
+	TargetKindHostname: func(a *App) TargetProvider { return hostnameTargets{a} },
+}
+
+// experimentalTargetKinds are only offered with AppSettings.ExperimentalProviders
+var experimentalTargetKinds = map[string]bool{}
+
+// targetEndpoint is what a tunnel dials, as resolved by a provider
+type targetEndpoint struct {
+	provider  TargetProvider
+	ProjectID string
+	// Name and Zone identify an instance; for other targets Name is the host and Zone is empty
+	Name string
+	Zone string
+	dest *destGroupTarget
+	// group is the instance group name of the favorite Name was picked from
+	group string
+	// note tells how the favorite was resolved, logged to the tunnel
+	note string
+	// cloud locates targets reached without IAP, e.g. an AWSTarget
+	cloud interface{}
+	// preamble is sent on each new connection before the client's data, from the favorite
+	preamble *PreambleSettings
+	// favorite is the favorite the endpoint was resolved for, its production safeguards apply
+	favorite *Favorite
+}
+
+// external reports whether the endpoint is reached without IAP, through another CLI
+func (ep *targetEndpoint) external() bool {
+	return ep.cloud != nil
+}
+
+// isInstance reports whether the endpoint is a plain Compute Engine instance
+func (ep *targetEndpoint) isInstance() bool {
+	return ep.provider.Kind() == TargetKindInstance
+}
+
+// String describes the endpoint for tunnel logs
+func (ep *targetEndpoint) String() string {
+	switch {
+	case ep.dest != nil:
+		return fmt.Sprintf("%s via destination group %s in %s", ep.dest.Host, ep.dest.DestGroup, ep.dest.Region)
+	case ep.Zone != "":
+		return fmt.Sprintf("%s in zone %s", ep.Name, ep.Zone)
+	default:
+		return ep.Name
+	}
+}
+
+// favoriteTargetKind returns the kind of target a favorite points at
+func favoriteTargetKind(f *Favorite) string {
+	switch {
+	case f.TargetKind != "":
+		return f.TargetKind
+	case f.Hostname != "":
+		return TargetKindHostname
+	default:
+		return TargetKindInstance
+	}
+}
+
+// inGoogleCloud reports whether a favorite's target is a Compute Engine instance or host reached
+// through IAP
+func inGoogleCloud(f *Favorite) bool {
+	return f.TargetKind == ""
+}
+
+// targetProvider returns the provider of a target kind
+func (a *App) targetProvider(kind string) (TargetProvider, error) {
+	newProvider, ok := targetProviders[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown target kind: %s", kind)
+	}
+	if experimentalTargetKinds[kind] && !a.GetSettings().ExperimentalProviders {
+		return nil, fmt.Errorf("%s targets are experimental, enable experimental providers in settings", kind)
+	}
+	return newProvider(a), nil
+}
+
+// GetTargetKinds returns the target kinds favorites can use, sorted
+func (a *App) GetTargetKinds() []string {
+	experimental := a.GetSettings().ExperimentalProviders
+	kinds := make([]string, 0, len(targetProviders))
+	for kind := range targetProviders {
+		if experimentalTargetKinds[kind] && !experimental {
+			continue
+		}
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// ListTargets returns the targets of one kind in a project
+func (a *App) ListTargets(projectID, kind string) ([]Target, error) {
+	provider, err := a.targetProvider(kind)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), targetResolveTimeout)
+	defer cancel()
+	return provider.List(ctx, projectID)
+}
+
+// resolveTarget resolves a favorite to the endpoint its next connection goes to
+func (a *App) resolveTarget(fav *Favorite) (*targetEndpoint, error) {
+	provider, err := a.targetProvider(favoriteTargetKind(fav))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), targetResolveTimeout)
+	defer cancel()
+	ep, err := provider.Resolve(ctx, fav)
+	if err != nil {
+		return nil, err
+	}
+	ep.favorite = fav
+	return ep, nil
+}
+
+// dialEndpoint connects to a port of an endpoint through its provider
+func (a *App) dialEndpoint(ctx context.Context, ep *targetEndpoint, port int) (io.ReadWriteCloser, error) {
+	return ep.provider.Dial(ctx, ep, port)
+}
+
+// instanceEndpoint returns the endpoint of a Compute Engine instance
+func (a *App) instanceEndpoint(projectID, vmName, zone string) *targetEndpoint {
+	return &targetEndpoint{provider: instanceTargets{a}, ProjectID: projectID, Name: vmName, Zone: zone}
+}
+
+// instanceTargets provides Compute Engine instances. A favorite's instance name may also name a
+// managed instance group, a member is picked for each tunnel.
+type instanceTargets struct{ a *App }
+
+func (p instanceTargets) Kind() string { return TargetKindInstance }
+
+func (p instanceTargets) List(ctx context.Context, projectID string) ([]Target, error) {
+	vms, err := p.a.ListVMs(projectID, "")
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]Target, 0, len(vms))
+	for _, vm := range vms {
+		targets = append(targets, Target{Kind: TargetKindInstance, Name: vm.Name, Location: vm.Zone, Status: vm.Status})
+	}
+	return targets, nil
+}
+
+func (p instanceTargets) Resolve(ctx context.Context, fav *Favorite) (*targetEndpoint, error) {
+	ep := p.a.instanceEndpoint(fav.ProjectID, fav.InstanceName, fav.Zone)
+	member, group, isGroup, err := p.a.selectGroupMember(fav)
+	if err != nil {
+		return nil, err
+	}
+	if isGroup {
+		mode := fav.MemberSelection
+		if mode == "" {
+			mode = MemberSelectionRoundRobin
+		}
+		ep.Name, ep.Zone = member.Name, member.Zone
+		ep.group = fav.InstanceName
+		ep.note = fmt.Sprintf("Instance group %s: using member %s in %s (%s)", group, member.Name, member.Zone, mode)
+	}
+	return ep, nil
+}
+
+func (p instanceTargets) Dial(ctx context.Context, ep *targetEndpoint, port int) (io.ReadWriteCloser, error) {
+	conn, err := p.a.dialIAP(ctx, ep.String(), p.a.iapDialOptions(ep.ProjectID, ep.Name, ep.Zone, port))
+	if err != nil {
+		return nil, p.a.explainDialError(ctx, ep.ProjectID, err)
+	}
+	return conn, nil
+}
+
+// hostnameTargets provides internal DNS names. A name that matches an instance resolves to it,
+// otherwise the favorite's destination group host is dialed.
+type hostnameTargets struct{ a *App }
+
+func (p hostnameTargets) Kind() string { return TargetKindHostname }
+
+// List returns the internal DNS names of the project's instances
+func (p hostnameTargets) List(ctx context.Context, projectID string) ([]Target, error) {
+	vms, err := p.a.ListVMs(projectID, "")
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]Target, 0, len(vms))
+	for _, vm := range vms {
+		targets = append(targets, Target{
+			Kind:     TargetKindHostname,
+			Name:     fmt.Sprintf("%s.%s.c.%s.internal", vm.Name, vm.Zone, projectID),
+			Location: vm.Zone,
+			Status:   vm.Status,
+		})
+	}
+	return targets, nil
+}
+
+func (p hostnameTargets) Resolve(ctx context.Context, fav *Favorite) (*targetEndpoint, error) {
+	resolution := p.a.ResolveHostname(fav.ProjectID, fav.Hostname)
+	if resolution.Error != "" && fav.DestGroup == "" {
+		return nil, fmt.Errorf("failed to resolve %s: %s", fav.Hostname, resolution.Error)
+	}
+
+	if resolution.Found {
+		// Remember the instance so renames and recreations are picked up transparently
+		if resolution.InstanceName != fav.InstanceName || resolution.Zone != fav.Zone {
+			p.a.updateResolvedInstance(fav.ID, resolution.InstanceName, resolution.Zone)
+		}
+		ep := p.a.instanceEndpoint(fav.ProjectID, resolution.InstanceName, resolution.Zone)
+		ep.note = fmt.Sprintf("Resolved %s to instance %s in %s (%s)", fav.Hostname, resolution.InstanceName, resolution.Zone, resolution.MatchedBy)
+		return ep, nil
+	}
+
+	if fav.DestGroup == "" {
+		return nil, fmt.Errorf("%s does not match any instance in project %s", fav.Hostname, fav.ProjectID)
+	}
+	// Destination group tunnels are keyed by host
+	return &targetEndpoint{
+		provider:  p,
+		ProjectID: fav.ProjectID,
+		Name:      fav.Hostname,
+		dest: &destGroupTarget{
+			Host:      fav.Hostname,
+			Region:    fav.Region,
+			Network:   fav.Network,
+			DestGroup: fav.DestGroup,
+		},
+	}, nil
+}
+
+func (p hostnameTargets) Dial(ctx context.Context, ep *targetEndpoint, port int) (io.ReadWriteCloser, error) {
+	if ep.dest == nil {
+		return instanceTargets(p).Dial(ctx, ep, port)
+	}
+	conn, err := p.a.dialIAP(ctx, ep.String(), p.a.withCommonDialOptions(
+		iap.WithProject(ep.ProjectID),
+		iap.WithHost(ep.dest.Host, ep.dest.Region, ep.dest.Network, ep.dest.DestGroup),
+		iap.WithPort(fmt.Sprintf("%d", port)),
+	))
+	if err != nil {
+		return nil, p.a.explainDialError(ctx, ep.ProjectID, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TelemetryFileName holds the local usage counters in the config directory
+	TelemetryFileName = "telemetry.json"
+	// telemetryReportInterval is how often an opted-in report is sent
+	telemetryReportInterval = 24 * time.Hour
+	// telemetryCheckInterval is how often the reporter checks whether a report is due
+	telemetryCheckInterval = time.Hour
+)
+
+var (
+	// telemetryEndpoint receives opted-in reports. It is set at build time with
+	// -ldflags "-X main.telemetryEndpoint=https://..."; without it nothing is ever sent.
+	telemetryEndpoint string
+	// appVersion is the release version, set at build time with -ldflags "-X main.appVersion=..."
+	appVersion = "dev"
+)
+
+// Error categories reported instead of error messages, which can contain resource names
+const (
+	ErrorCategoryAuth       = "auth"
+	ErrorCategoryPermission = "permission"
+	ErrorCategoryNotFound   = "not_found"
+	ErrorCategoryTimeout    = "timeout"
+	ErrorCategoryPort       = "port"
+	ErrorCategoryClient     = "client"
+	ErrorCategoryOther      = "other"
+)
+
+// TelemetryReport is exactly what an opted-in report sends: counts only, never
+// identifiers, usernames, project, instance or host names
+type TelemetryReport struct {
+	AppVersion  string         `json:"appVersion"`
+	OS          string         `json:"os"`
+	PeriodStart string         `json:"periodStart"` // Date only
+	Features    map[string]int `json:"features"`    // Feature -> times used
+	Errors      map[string]int `json:"errors"`      // Feature/category -> times failed
+}
+
+// TelemetryPreview is shown before opting in
+type TelemetryPreview struct {
+	OptedIn  bool            `json:"optedIn"`
+	Allowed  bool            `json:"allowed"`            // False when an administrator disabled telemetry
+	Endpoint string          `json:"endpoint,omitempty"` // Empty in builds that never send reports
+	LastSent string          `json:"lastSent,omitempty"`
+	Report   TelemetryReport `json:"report"`
+}
+
+// telemetryState holds the local counters, persisted to TelemetryFileName
+type telemetryState struct {
+	mu       sync.Mutex
+	loaded   bool
+	Start    time.Time      `json:"start"`
+	LastSent time.Time      `json:"lastSent,omitempty"`
+	Features map[string]int `json:"features"`
+	Errors   map[string]int `json:"errors"`
+}
+
+// GetTelemetryPreview returns the report that would be sent right now
+func (a *App) GetTelemetryPreview() TelemetryPreview {
+	a.telemetry.mu.Lock()
+	defer a.telemetry.mu.Unlock()
+	a.loadTelemetryLocked()
+
+	preview := TelemetryPreview{
+		OptedIn:  a.GetSettings().TelemetryOptIn,
+		Allowed:  a.telemetryAllowed(),
+		Endpoint: telemetryEndpoint,
+		Report:   a.telemetryReportLocked(),
+	}
+	if !a.telemetry.LastSent.IsZero() {
+		preview.LastSent = a.telemetry.LastSent.Format(time.RFC3339)
+	}
+	return preview
+}
+
+// SetTelemetryOptIn turns usage reports on or off
+func (a *App) SetTelemetryOptIn(optIn bool) error {
+	if optIn && !a.telemetryAllowed() {
+		return fmt.Errorf("usage metrics are disabled by your administrator")
+	}
+	if err := a.requireOperatorUnlock(OperatorActionSettings); err != nil {
+		return err
+	}
+
+	return a.mutateConfig(func(cfg *AppConfig) error {
+		cfg.Settings.TelemetryOptIn = optIn
+		return nil
+	})
+}
+
+// telemetryAllowed reports whether an administrator left telemetry available
+func (a *App) telemetryAllowed() bool {
+	return a.managed == nil || !a.managed.TelemetryDisabled
+}
+
+// countFeature records one use of a feature. Counters stay local until the user opts in.
+func (a *App) countFeature(feature string) {
+	a.telemetry.mu.Lock()
+	defer a.telemetry.mu.Unlock()
+	a.loadTelemetryLocked()
+	a.telemetry.Features[feature]++
+}
+
+// countError records a failed use of a feature by error category
+func (a *App) countError(feature, message string) {
+	if message == "" {
+		return
+	}
+	a.telemetry.mu.Lock()
+	defer a.telemetry.mu.Unlock()
+	a.loadTelemetryLocked()
+	a.telemetry.Errors[feature+"/"+errorCategory(message)]++
+}
+
+// errorCategory maps an error message to a coarse category
+func errorCategory(message string) string {
+	msg := strings.ToLower(message)
+	switch {
+	case strings.Contains(msg, "not authenticated") || strings.Contains(msg, "credentials") || strings.Contains(msg, "token"):
+		return ErrorCategoryAuth
+	case strings.Contains(msg, "permission") || strings.Contains(msg, "forbidden") || strings.Contains(msg, "not allowed"):
+		return ErrorCategoryPermission
+	case strings.Contains(msg, "not found") || strings.Contains(msg, "not_found"):
+		return ErrorCategoryNotFound
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "in time") || strings.Contains(msg, "deadline") || strings.Contains(msg, "not_ready"):
+		return ErrorCategoryTimeout
+	case strings.Contains(msg, "port"):
+		return ErrorCategoryPort
+	case strings.Contains(msg, "client") || strings.Contains(msg, "windows app") || strings.Contains(msg, "freerdp"):
+		return ErrorCategoryClient
+	default:
+		return ErrorCategoryOther
+	}
+}
+
+// startTelemetryReporter sends a report once a day while the user is opted in
+func (a *App) startTelemetryReporter() {
+	go func() {
+		ticker := time.NewTicker(telemetryCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := a.sendTelemetryIfDue(); err != nil {
+				a.logWarningf("Failed to send usage metrics: %v", err)
+			}
+		}
+	}()
+}
+
+// sendTelemetryIfDue posts the report and resets the counters if a report is due
+func (a *App) sendTelemetryIfDue() error {
+	if telemetryEndpoint == "" || !a.telemetryAllowed() || !a.GetSettings().TelemetryOptIn {
+		return nil
+	}
+
+	a.telemetry.mu.Lock()
+	a.loadTelemetryLocked()
+	due := time.Since(a.telemetry.Start) >= telemetryReportInterval
+	report := a.telemetryReportLocked()
+	a.telemetry.mu.Unlock()
+	if !due {
+		return nil
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(telemetryEndpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	a.telemetry.mu.Lock()
+	a.telemetry.Start = time.Now()
+	a.telemetry.LastSent = time.Now()
+	a.telemetry.Features = make(map[string]int)
+	a.telemetry.Errors = make(map[string]int)
+	a.telemetry.mu.Unlock()
+	return a.saveTelemetry()
+}
+
+// telemetryReportLocked builds the report from the counters (caller must hold telemetry.mu)
+func (a *App) telemetryReportLocked() TelemetryReport {
+	report := TelemetryReport{
+		AppVersion:  appVersion,
+		OS:          runtime.GOOS,
+		PeriodStart: a.telemetry.Start.Format("2006-01-02"),
+		Features:    make(map[string]int, len(a.telemetry.Features)),
+		Errors:      make(map[string]int, len(a.telemetry.Errors)),
+	}
+	for k, v := range a.telemetry.Features {
+		report.Features[k] = v
+	}
+	for k, v := range a.telemetry.Errors {
+		report.Errors[k] = v
+	}
+	return report
+}
+
+// loadTelemetryLocked reads the counters from disk on first use (caller must hold telemetry.mu)
+func (a *App) loadTelemetryLocked() {
+	if a.telemetry.loaded {
+		return
+	}
+	a.telemetry.loaded = true
+	if data, err := os.ReadFile(filepath.Join(a.getConfigDir(), TelemetryFileName)); err == nil {
+		json.Unmarshal(data, &a.telemetry)
+	}
+	if a.telemetry.Start.IsZero() {
+		a.telemetry.Start = time.Now()
+	}
+	if a.telemetry.Features == nil {
+		a.telemetry.Features = make(map[string]int)
+	}
+	if a.telemetry.Errors == nil {
+		a.telemetry.Errors = make(map[string]int)
+	}
+}
+
+// saveTelemetry persists the counters
+func (a *App) saveTelemetry() error {
+	dir := a.getConfigDir()
+	if dir == "" {
+		return nil
+	}
+
+	a.telemetry.mu.Lock()
+	if !a.telemetry.loaded {
+		a.telemetry.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(&a.telemetry, "", "  ")
+	a.telemetry.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writePrivateFile(filepath.Join(dir, TelemetryFileName), data)
+}
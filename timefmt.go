@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message codes of relative times
+const (
+	MsgTimeJustNow    = "time_just_now"
+	MsgTimeMinutesAgo = "time_minutes_ago"
+	MsgTimeHoursAgo   = "time_hours_ago"
+	MsgTimeDaysAgo    = "time_days_ago"
+	MsgTimeInMinutes  = "time_in_minutes"
+	MsgTimeInHours    = "time_in_hours"
+	MsgTimeInDays     = "time_in_days"
+)
+
+// DisplayTime is a timestamp formatted for the user, next to the raw RFC3339 value it was made
+// from. The webview's Intl time zone doesn't always follow the app's, so the backend formats it.
+type DisplayTime struct {
+	Local    string `json:"local"`    // In the display time zone and region's date format, e.g. "3/14/2026, 4:05 PM"
+	Relative string `json:"relative"` // Relative to now in the user's language, e.g. "2h ago" or "in 5 min"
+	TimeZone string `json:"timeZone"` // Abbreviation of the display time zone, e.g. "CET"
+}
+
+// dateFormat is how the user's region writes dates and clock times
+type dateFormat struct {
+	date    string // Go layout of the date
+	clock24 bool
+}
+
+// layout returns the Go layout of a date with its clock time
+func (f dateFormat) layout() string {
+	if f.clock24 {
+		return f.date + ", 15:04"
+	}
+	return f.date + ", 3:04 PM"
+}
+
+// Date layouts by region, other regions write day/month/year
+var (
+	regionDateLayouts = map[string]string{
+		"US": "1/2/2006", "PH": "1/2/2006",
+		"DE": "02.01.2006", "AT": "02.01.2006", "CH": "02.01.2006", "UA": "02.01.2006",
+		"RU": "02.01.2006", "PL": "02.01.2006", "CZ": "02.01.2006", "FI": "02.01.2006",
+		"NO": "02.01.2006", "TR": "02.01.2006",
+		"JP": "2006/01/02", "CN": "2006/01/02", "TW": "2006/01/02", "KR": "2006/01/02",
+		"SE": "2006-01-02", "LT": "2006-01-02", "CA": "2006-01-02",
+	}
+	// regions12Hour use a 12-hour clock unless macOS is set to 24-hour time
+	regions12Hour = map[string]bool{"US": true, "CA": true, "AU": true, "NZ": true, "IN": true, "PH": true}
+)
+
+const defaultDateLayout = "02/01/2006"
+
+var (
+	systemDateFormatOnce sync.Once
+	systemDate           dateFormat
+)
+
+// detectDateFormat returns the date format of the macOS region and its 12/24-hour time setting
+func detectDateFormat() dateFormat {
+	systemDateFormatOnce.Do(func() {
+		locale := ""
+		if output, err := runCommand("defaults", "read", "-g", "AppleLocale"); err == nil {
+			locale = strings.TrimSpace(string(output))
+		} else {
+			locale = os.Getenv("LANG")
+		}
+		systemDate = dateFormatForLocale(locale)
+		if output, err := runCommand("defaults", "read", "-g", "AppleICUForce24HourTime"); err == nil && strings.TrimSpace(string(output)) == "1" {
+			systemDate.clock24 = true
+		}
+		if output, err := runCommand("defaults", "read", "-g", "AppleICUForce12HourTime"); err == nil && strings.TrimSpace(string(output)) == "1" {
+			systemDate.clock24 = false
+		}
+	})
+	return systemDate
+}
+
+// dateFormatForLocale picks the date format for a locale like "en_US", "uk_UA" or "de_DE.UTF-8"
+func dateFormatForLocale(locale string) dateFormat {
+	region := ""
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		region = locale[i+1:]
+		if j := strings.IndexAny(region, ".@"); j >= 0 {
+			region = region[:j]
+		}
+		region = strings.ToUpper(region)
+	}
+	format := dateFormat{date: defaultDateLayout, clock24: !regions12Hour[region]}
+	if layout, ok := regionDateLayouts[region]; ok {
+		format.date = layout
+	}
+	return format
+}
+
+// displayLocation returns the time zone timestamps are shown in: the TimeZone setting or the Mac's
+func (a *App) displayLocation() *time.Location {
+	if name := a.GetSettings().TimeZone; name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// validateTimeZone accepts empty (the Mac's time zone) or an IANA time zone like "Europe/Kyiv"
+func validateTimeZone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("unknown time zone: %s", name)
+	}
+	return nil
+}
+
+// displayTime formats a raw RFC3339 timestamp, nil when it is empty or not a timestamp
+func (a *App) displayTime(raw string) *DisplayTime {
+	if raw == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil
+	}
+	t = t.In(a.displayLocation())
+	zone, _ := t.Zone()
+	return &DisplayTime{
+		Local:    t.Format(detectDateFormat().layout()),
+		Relative: a.relativeTime(t, time.Now()),
+		TimeZone: zone,
+	}
+}
+
+// relativeTime describes t relative to now in the largest whole unit, e.g. "2h ago"
+func (a *App) relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	past := d >= 0
+	if !past {
+		d = -d
+	}
+	switch {
+	case d < time.Minute:
+		return a.tr(MsgTimeJustNow)
+	case d < time.Hour && past:
+		return a.tr(MsgTimeMinutesAgo, int(d/time.Minute))
+	case d < time.Hour:
+		return a.tr(MsgTimeInMinutes, int(d/time.Minute))
+	case d < 24*time.Hour && past:
+		return a.tr(MsgTimeHoursAgo, int(d/time.Hour))
+	case d < 24*time.Hour:
+		return a.tr(MsgTimeInHours, int(d/time.Hour))
+	case past:
+		return a.tr(MsgTimeDaysAgo, int(d/(24*time.Hour)))
+	default:
+		return a.tr(MsgTimeInDays, int(d/(24*time.Hour)))
+	}
+}
+
+// withDisplayTimes adds the formatted timestamps to tunnel infos
+func (a *App) withDisplayTimes(tunnels []TunnelInfo) []TunnelInfo {
+	for i := range tunnels {
+		tunnels[i].StartedAtDisplay = a.displayTime(tunnels[i].StartedAt)
+		tunnels[i].ExpiresAtDisplay = a.displayTime(tunnels[i].ExpiresAt)
+	}
+	return tunnels
+}
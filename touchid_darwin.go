@@ -0,0 +1,38 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework Foundation -framework LocalAuthentication
+#include <stdlib.h>
+
+int iaptmTouchIDAvailable(void);
+int iaptmAuthenticateTouchID(const char *reason, char **errorOut);
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// touchIDAvailable reports whether this Mac has Touch ID with an enrolled finger
+func touchIDAvailable() bool {
+	return C.iaptmTouchIDAvailable() != 0
+}
+
+// authenticateTouchID asks for a fingerprint, completing "<app> wants to <reason>". It blocks
+// until the user answers.
+func authenticateTouchID(reason string) error {
+	cReason := C.CString(reason)
+	defer C.free(unsafe.Pointer(cReason))
+	var cErr *C.char
+	if C.iaptmAuthenticateTouchID(cReason, &cErr) != 0 {
+		return nil
+	}
+	if cErr == nil {
+		return fmt.Errorf("Touch ID authentication failed")
+	}
+	defer C.free(unsafe.Pointer(cErr))
+	return fmt.Errorf("Touch ID authentication failed: %s", C.GoString(cErr))
+}
@@ -0,0 +1,49 @@
+// Touch ID through LocalAuthentication, for the administrator unlock of operator mode.
+// Only biometrics are accepted: the login password of a shared Mac is known to its operators.
+
+#import <Foundation/Foundation.h>
+#import <LocalAuthentication/LocalAuthentication.h>
+#include <stdlib.h>
+#include <string.h>
+
+// iaptmTouchIDAvailable returns 1 when Touch ID can be used, with at least one finger enrolled.
+int iaptmTouchIDAvailable(void) {
+	@autoreleasepool {
+		LAContext *context = [[LAContext alloc] init];
+		return [context canEvaluatePolicy:LAPolicyDeviceOwnerAuthenticationWithBiometrics error:nil] ? 1 : 0;
+	}
+}
+
+// iaptmAuthenticateTouchID shows the Touch ID prompt and waits for it. It returns 1 on success;
+// otherwise 0 with *errorOut set to a malloc'ed description the caller frees.
+int iaptmAuthenticateTouchID(const char *reason, char **errorOut) {
+	@autoreleasepool {
+		*errorOut = NULL;
+		LAContext *context = [[LAContext alloc] init];
+		NSError *error = nil;
+		if (![context canEvaluatePolicy:LAPolicyDeviceOwnerAuthenticationWithBiometrics error:&error]) {
+			*errorOut = strdup(error ? error.localizedDescription.UTF8String : "Touch ID is not available");
+			return 0;
+		}
+
+		// The reply comes on a private queue, the caller is a goroutine that may block
+		dispatch_semaphore_t done = dispatch_semaphore_create(0);
+		__block BOOL success = NO;
+		__block NSString *failure = nil;
+		[context evaluatePolicy:LAPolicyDeviceOwnerAuthenticationWithBiometrics
+		        localizedReason:[NSString stringWithUTF8String:reason]
+		                  reply:^(BOOL ok, NSError *replyError) {
+			success = ok;
+			if (!ok && replyError) {
+				failure = [replyError.localizedDescription copy];
+			}
+			dispatch_semaphore_signal(done);
+		}];
+		dispatch_semaphore_wait(done, DISPATCH_TIME_FOREVER);
+
+		if (!success && failure) {
+			*errorOut = strdup(failure.UTF8String);
+		}
+		return success ? 1 : 0;
+	}
+}
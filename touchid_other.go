@@ -0,0 +1,15 @@
+//go:build !darwin
+
+package main
+
+import "fmt"
+
+// touchIDAvailable is false outside macOS
+func touchIDAvailable() bool {
+	return false
+}
+
+// authenticateTouchID is only supported on macOS
+func authenticateTouchID(reason string) error {
+	return fmt.Errorf("Touch ID is only available on macOS")
+}
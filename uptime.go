@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// longUptimeThreshold flags VMs that have been running long enough to likely miss patches
+const longUptimeThreshold = 30 * 24 * time.Hour
+
+// InstanceTimes holds when a VM was created and last started or stopped, as RFC3339 timestamps
+type InstanceTimes struct {
+	CreatedAt     string `json:"createdAt,omitempty"`
+	LastStartedAt string `json:"lastStartedAt,omitempty"`
+	LastStoppedAt string `json:"lastStoppedAt,omitempty"`
+	// UptimeSeconds is the time since the last start, 0 unless the VM is running
+	UptimeSeconds int64 `json:"uptimeSeconds,omitempty"`
+	// LongRunning is set once the VM has been running for 30 days without a restart
+	LongRunning bool `json:"longRunning,omitempty"`
+}
+
+// instanceTimes reads the lifecycle timestamps of an instance and computes its uptime
+func instanceTimes(instance *compute.Instance) InstanceTimes {
+	times := InstanceTimes{
+		CreatedAt:     instance.CreationTimestamp,
+		LastStartedAt: instance.LastStartTimestamp,
+		LastStoppedAt: instance.LastStopTimestamp,
+	}
+	if instance.Status != "RUNNING" {
+		return times
+	}
+
+	// VMs that were never stopped may only have a creation time
+	started := parseTimestamp(instance.LastStartTimestamp)
+	if started.IsZero() {
+		started = parseTimestamp(instance.CreationTimestamp)
+	}
+	if started.IsZero() {
+		return times
+	}
+	uptime := time.Since(started)
+	times.UptimeSeconds = int64(uptime.Seconds())
+	times.LongRunning = uptime >= longUptimeThreshold
+	return times
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// What happens to tunnels when Fast User Switching makes another user active
+const (
+	UserSwitchKeep  = "keep"  // Default, tunnels keep accepting connections
+	UserSwitchPause = "pause" // Tunnels refuse connections and operator mode locks until the user is back
+)
+
+// TunnelsPausedEvent is emitted with the TunnelPauseStatus when tunnels are paused or resumed
+const TunnelsPausedEvent = "tunnels:paused"
+
+// TunnelPauseStatus reports whether tunnels refuse new connections
+type TunnelPauseStatus struct {
+	Paused      bool   `json:"paused"`
+	PausedSince string `json:"pausedSince,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// tunnelPauseState pauses the listeners of all tunnels. Tunnel ports on 127.0.0.1 are open to
+// every user of the Mac, so a paused tunnel closes new connections instead of forwarding them.
+type tunnelPauseState struct {
+	mu     sync.Mutex
+	paused bool
+	since  time.Time
+	reason string
+}
+
+// validateUserSwitchMode checks the UserSwitch setting
+func validateUserSwitchMode(mode string) error {
+	switch mode {
+	case "", UserSwitchKeep, UserSwitchPause:
+		return nil
+	default:
+		return fmt.Errorf("invalid user switch mode: %s", mode)
+	}
+}
+
+// tunnelsPaused reports whether tunnels should refuse new connections
+func (a *App) tunnelsPaused() bool {
+	a.tunnelPause.mu.Lock()
+	defer a.tunnelPause.mu.Unlock()
+	return a.tunnelPause.paused
+}
+
+// GetTunnelPauseStatus returns whether tunnels are paused
+func (a *App) GetTunnelPauseStatus() TunnelPauseStatus {
+	a.tunnelPause.mu.Lock()
+	defer a.tunnelPause.mu.Unlock()
+	status := TunnelPauseStatus{Paused: a.tunnelPause.paused, Reason: a.tunnelPause.reason}
+	if a.tunnelPause.paused {
+		status.PausedSince = a.tunnelPause.since.Format(time.RFC3339)
+	}
+	return status
+}
+
+// SetTunnelsPaused pauses or resumes every tunnel. Connections open when pausing are left alone,
+// only new ones are refused. The GUI forwards it to the agent, whose tunnels listen on the same ports.
+func (a *App) SetTunnelsPaused(paused bool) TunnelPauseStatus {
+	reason := ""
+	if paused {
+		reason = "paused by user"
+	}
+	return a.setTunnelsPaused(paused, reason)
+}
+
+// setTunnelsPaused changes the pause and tells the agent and the frontend
+func (a *App) setTunnelsPaused(paused bool, reason string) TunnelPauseStatus {
+	a.tunnelPause.mu.Lock()
+	changed := a.tunnelPause.paused != paused
+	a.tunnelPause.paused = paused
+	a.tunnelPause.reason = reason
+	if changed {
+		a.tunnelPause.since = time.Now()
+	}
+	a.tunnelPause.mu.Unlock()
+
+	if a.agentAttached() {
+		if err := a.agentCall("SetTunnelsPaused", nil, paused); err != nil {
+			a.logWarningf("Failed to pause agent tunnels: %v", err)
+		}
+	}
+	if !changed {
+		return a.GetTunnelPauseStatus()
+	}
+
+	if paused {
+		// A connection pre-dialed for the previous user must not serve the next one
+		a.tunnelsMu.RLock()
+		for _, t := range a.tunnels {
+			t.spare.discard()
+		}
+		a.tunnelsMu.RUnlock()
+		a.audit("tunnels.paused", "", "", reason)
+	} else {
+		a.audit("tunnels.resumed", "", "", "")
+	}
+
+	status := a.GetTunnelPauseStatus()
+	if a.ctx != nil && !a.isAgent {
+		runtime.EventsEmit(a.ctx, TunnelsPausedEvent, status)
+	}
+	return status
+}
+
+// startUserSwitchWatcher follows Fast User Switching. Cached passwords are already dropped
+// when the user switches away (see startCredentialFlusher); with the pause mode tunnels stop
+// accepting connections and operator mode locks until this user's session is active again.
+func (a *App) startUserSwitchWatcher() {
+	watchUserSession(func(active bool) {
+		if a.GetSettings().UserSwitch != UserSwitchPause {
+			// Tunnels paused by an earlier setting still resume
+			if active && a.tunnelsPaused() {
+				a.setTunnelsPaused(false, "")
+			}
+			return
+		}
+		if active {
+			a.setTunnelsPaused(false, "")
+			return
+		}
+		a.LockOperator()
+		a.setTunnelsPaused(true, "another user became active")
+	})
+}
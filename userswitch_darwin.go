@@ -0,0 +1,40 @@
+//go:build darwin
+
+package main
+
+/*
+#cgo LDFLAGS: -framework AppKit -framework Foundation
+
+void iaptmWatchUserSession(void);
+*/
+import "C"
+
+import "sync"
+
+var (
+	userSessionHandler   func(active bool)
+	userSessionHandlerMu sync.Mutex
+	userSessionOnce      sync.Once
+)
+
+// watchUserSession calls handler with false when Fast User Switching makes another user
+// active, and with true when this user's session is active again
+func watchUserSession(handler func(active bool)) {
+	userSessionHandlerMu.Lock()
+	userSessionHandler = handler
+	userSessionHandlerMu.Unlock()
+
+	userSessionOnce.Do(func() { C.iaptmWatchUserSession() })
+}
+
+//export iaptmUserSessionChanged
+func iaptmUserSessionChanged(active C.int) {
+	userSessionHandlerMu.Lock()
+	handler := userSessionHandler
+	userSessionHandlerMu.Unlock()
+
+	if handler != nil {
+		// Off the main queue, the handler may call the agent
+		go handler(active != 0)
+	}
+}
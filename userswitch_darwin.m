@@ -0,0 +1,18 @@
+// Fast User Switching notifications, so tunnels can be paused while another user has the console.
+
+#import <AppKit/AppKit.h>
+
+extern void iaptmUserSessionChanged(int active);
+
+// iaptmWatchUserSession subscribes to the session notifications once for the life of the app
+void iaptmWatchUserSession(void) {
+	@autoreleasepool {
+		NSNotificationCenter *workspace = [[NSWorkspace sharedWorkspace] notificationCenter];
+		NSOperationQueue *queue = [NSOperationQueue mainQueue];
+
+		[workspace addObserverForName:NSWorkspaceSessionDidResignActiveNotification object:nil queue:queue
+		                   usingBlock:^(NSNotification *note) { iaptmUserSessionChanged(0); }];
+		[workspace addObserverForName:NSWorkspaceSessionDidBecomeActiveNotification object:nil queue:queue
+		                   usingBlock:^(NSNotification *note) { iaptmUserSessionChanged(1); }];
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !darwin
+
+package main
+
+// watchUserSession is only supported on macOS, which has Fast User Switching
+func watchUserSession(handler func(active bool)) {}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// VMChangesEvent is emitted with the changes a VM watch found in a project's VM list
+	VMChangesEvent = "vms:changes"
+
+	// vmWatchInterval is how often watched projects are listed again
+	vmWatchInterval = 30 * time.Second
+)
+
+// VMSnapshot is the VM list of a watched project. Revision grows with every change, so a
+// client that missed an event can tell and fetch the snapshot again.
+type VMSnapshot struct {
+	ProjectID string `json:"projectId"`
+	Revision  int    `json:"revision"`
+	VMs       []VM   `json:"vms"`
+}
+
+// VMChanges is the difference between two listings of a watched project
+type VMChanges struct {
+	ProjectID string `json:"projectId"`
+	Revision  int    `json:"revision"`
+	Added     []VM   `json:"added"`
+	// Removed are the VMs that are gone, with their last known state
+	Removed []VM `json:"removed"`
+	// Changed are the VMs whose status, address, machine type or start/stop times changed
+	Changed []VM `json:"changed"`
+}
+
+// vmWatchState holds the running VM watches by project ID
+type vmWatchState struct {
+	mu      sync.Mutex
+	watches map[string]*vmWatch
+}
+
+// vmWatch re-lists one project's VMs while any window watches it
+type vmWatch struct {
+	cancel   context.CancelFunc
+	watchers int
+	// Guarded by vmWatchState.mu
+	revision int
+	vms      map[string]VM // By zone/name
+}
+
+// WatchVMs starts keeping the project's VM list current, or joins a running watch, and returns
+// the current list. VMChangesEvent reports changes from then on; every WatchVMs needs a
+// matching UnwatchVMs.
+func (a *App) WatchVMs(projectID string) (*VMSnapshot, error) {
+	a.vmWatches.mu.Lock()
+	if w, ok := a.vmWatches.watches[projectID]; ok {
+		w.watchers++
+		snapshot := w.snapshot(projectID)
+		a.vmWatches.mu.Unlock()
+		return snapshot, nil
+	}
+	a.vmWatches.mu.Unlock()
+
+	vms, err := a.ListVMs(projectID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	a.vmWatches.mu.Lock()
+	defer a.vmWatches.mu.Unlock()
+	if a.vmWatches.watches == nil {
+		a.vmWatches.watches = make(map[string]*vmWatch)
+	}
+	// Another window may have started the watch meanwhile
+	w, ok := a.vmWatches.watches[projectID]
+	if !ok {
+		ctx, cancel := context.WithCancel(commandsCtx)
+		w = &vmWatch{cancel: cancel, vms: vmsByKey(vms)}
+		a.vmWatches.watches[projectID] = w
+		go a.runVMWatch(ctx, projectID)
+	}
+	w.watchers++
+	return w.snapshot(projectID), nil
+}
+
+// UnwatchVMs ends a WatchVMs, stopping the watch when no window needs it anymore
+func (a *App) UnwatchVMs(projectID string) {
+	a.vmWatches.mu.Lock()
+	defer a.vmWatches.mu.Unlock()
+	w, ok := a.vmWatches.watches[projectID]
+	if !ok {
+		return
+	}
+	if w.watchers--; w.watchers <= 0 {
+		w.cancel()
+		delete(a.vmWatches.watches, projectID)
+	}
+}
+
+// runVMWatch lists the project's VMs every vmWatchInterval and emits what changed. Ticks are
+// skipped in low power mode; the next one after it ends catches up.
+func (a *App) runVMWatch(ctx context.Context, projectID string) {
+	ticker := time.NewTicker(vmWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if a.lowPowerActive() {
+			continue
+		}
+		vms, err := a.listVMs(ctx, projectID, "")
+		if err != nil {
+			// Auth or network trouble shows elsewhere, the watch tries again on the next tick
+			continue
+		}
+		if changes := a.applyVMListing(projectID, vms); changes != nil && a.ctx != nil {
+			runtime.EventsEmit(a.ctx, VMChangesEvent, *changes)
+		}
+	}
+}
+
+// applyVMListing replaces the watch's snapshot with a new listing and returns the differences,
+// nil when nothing changed or the watch has stopped
+func (a *App) applyVMListing(projectID string, vms []VM) *VMChanges {
+	a.vmWatches.mu.Lock()
+	defer a.vmWatches.mu.Unlock()
+	w, ok := a.vmWatches.watches[projectID]
+	if !ok {
+		return nil
+	}
+
+	current := vmsByKey(vms)
+	changes := &VMChanges{ProjectID: projectID, Added: []VM{}, Removed: []VM{}, Changed: []VM{}}
+	for key, vm := range current {
+		old, ok := w.vms[key]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, vm)
+		case vmChanged(old, vm):
+			changes.Changed = append(changes.Changed, vm)
+		}
+	}
+	for key, vm := range w.vms {
+		if _, ok := current[key]; !ok {
+			changes.Removed = append(changes.Removed, vm)
+		}
+	}
+	// Uptimes alone change on every listing; keep the fresh values without an event
+	w.vms = current
+	if len(changes.Added)+len(changes.Removed)+len(changes.Changed) == 0 {
+		return nil
+	}
+	w.revision++
+	changes.Revision = w.revision
+	return changes
+}
+
+// snapshot returns the watch's VM list sorted by name; callers must hold vmWatchState.mu
+func (w *vmWatch) snapshot(projectID string) *VMSnapshot {
+	vms := make([]VM, 0, len(w.vms))
+	for _, vm := range w.vms {
+		vms = append(vms, vm)
+	}
+	sortVMs(vms)
+	return &VMSnapshot{ProjectID: projectID, Revision: w.revision, VMs: vms}
+}
+
+// vmsByKey indexes VMs by zone and name, names are only unique per zone
+func vmsByKey(vms []VM) map[string]VM {
+	byKey := make(map[string]VM, len(vms))
+	for _, vm := range vms {
+		byKey[fmt.Sprintf("%s/%s", vm.Zone, vm.Name)] = vm
+	}
+	return byKey
+}
+
+// vmChanged reports whether a VM changed in a way the picker shows
+func vmChanged(old, vm VM) bool {
+	return old.Status != vm.Status ||
+		old.PrivateIP != vm.PrivateIP ||
+		old.MachineType != vm.MachineType ||
+		old.IsWindows != vm.IsWindows ||
+		old.LastStartedAt != vm.LastStartedAt ||
+		old.LastStoppedAt != vm.LastStoppedAt
+}
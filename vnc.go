@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Connection types for favorites
+const (
+	ConnectionTypeRDP = "rdp" // Default, Windows App or FreeRDP
+	ConnectionTypeVNC = "vnc" // macOS Screen Sharing
+)
+
+const (
+	// vncBasePort is the VNC port for display :0
+	vncBasePort = 5900
+	// maxVNCDisplay is the highest accepted VNC display number
+	maxVNCDisplay = 99
+)
+
+// VNCSettings holds the per-favorite VNC display settings
+type VNCSettings struct {
+	Display    int    `json:"display"`            // X display number, the remote port is 5900+display
+	OpenViewer bool   `json:"openViewer"`         // Open Screen Sharing once the tunnel is up
+	Username   string `json:"username,omitempty"` // Prefilled in Screen Sharing
+}
+
+// validate checks the VNC settings
+func (s VNCSettings) validate() error {
+	if s.Display < 0 || s.Display > maxVNCDisplay {
+		return fmt.Errorf("invalid VNC display: %d", s.Display)
+	}
+	return nil
+}
+
+// connectionType returns ConnectionTypeVNC
+func (s VNCSettings) connectionType() string {
+	return ConnectionTypeVNC
+}
+
+// apply stores the settings on a VNC favorite, the display decides the remote port
+func (s VNCSettings) apply(f *Favorite) {
+	f.VNC = &s
+	f.RemotePort = vncBasePort + s.Display
+}
+
+// AddVNCFavorite saves a VNC connection to a Linux desktop VM
+func (a *App) AddVNCFavorite(displayName, projectID, projectName, instanceName, zone string, settings VNCSettings) (*Favorite, error) {
+	return a.addConnectionFavorite(displayName, projectID, projectName, instanceName, zone, vncBasePort+settings.Display, settings)
+}
+
+// UpdateVNCSettings changes a VNC favorite's display settings
+func (a *App) UpdateVNCSettings(favoriteID string, settings VNCSettings) error {
+	return a.updateConnectionSettings(favoriteID, settings)
+}
+
+// openVNCForTunnel waits for the VNC server behind the tunnel and opens Screen Sharing
+func (a *App) openVNCForTunnel(fav *Favorite, tunnel *TunnelInfo) ConnectResult {
+	if !a.waitForTunnelRunning(tunnel.ID, 2*time.Second) {
+		return a.connectError(tunnel, MsgTunnelStartTimeout)
+	}
+	if probe := a.ProbeTunnel(tunnel.ID); !probe.Reachable {
+		return ConnectResult{Tunnel: tunnel, Error: probe.Error, Code: probe.Code, RetryAfter: probe.RetryAfter}
+	}
+
+	if fav.VNC == nil || fav.VNC.OpenViewer {
+		if err := openScreenSharing(tunnel.LocalPort, vncUsername(fav)); err != nil {
+			return a.connectError(tunnel, MsgScreenSharingFailed, err)
+		}
+	}
+
+	if info, err := a.GetTunnel(tunnel.ID); err == nil {
+		tunnel = info
+	}
+	return ConnectResult{Success: true, Tunnel: tunnel}
+}
+
+// vncUsername returns the username to prefill in Screen Sharing
+func vncUsername(fav *Favorite) string {
+	if fav.VNC != nil {
+		return fav.VNC.Username
+	}
+	return ""
+}
+
+// openScreenSharing opens macOS Screen Sharing at vnc://[user@]localhost:port
+func openScreenSharing(localPort int, username string) error {
+	u := url.URL{Scheme: "vnc", Host: fmt.Sprintf("localhost:%d", localPort)}
+	if username != "" {
+		u.User = url.User(username)
+	}
+	_, err := runCommand("open", u.String())
+	return err
+}
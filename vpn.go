@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vpnCheckTimeout bounds resolving and connecting to each Google endpoint
+const vpnCheckTimeout = 5 * time.Second
+
+// VPN diagnostic issue codes
+const (
+	VPNIssueRoutedThroughVPN  = "routed_through_vpn"
+	VPNIssueUnreachable       = "unreachable"
+	VPNIssuePrivateDNS        = "private_dns"
+	VPNIssueTLSInspection     = "tls_inspection"
+	VPNIssueResolveFailed     = "resolve_failed"
+	VPNIssuePrivateGoogleAPIs = "private_google_apis"
+)
+
+// vpnInterfacePrefixes are the macOS interface names VPN clients route through
+var vpnInterfacePrefixes = []string{"utun", "ipsec", "ppp", "tun", "tap", "gpd"}
+
+// vpnClients maps process names of common VPN clients to their product names
+var vpnClients = map[string]string{
+	"PanGPS":            "GlobalProtect",
+	"GlobalProtect":     "GlobalProtect",
+	"vpnagentd":         "Cisco Secure Client",
+	"Cisco Secure":      "Cisco Secure Client",
+	"ZscalerService":    "Zscaler",
+	"Zscaler":           "Zscaler",
+	"FortiTray":         "FortiClient",
+	"fctservctl":        "FortiClient",
+	"openvpn":           "OpenVPN",
+	"OpenVPN Connect":   "OpenVPN",
+	"tailscaled":        "Tailscale",
+	"WireGuardNetworkE": "WireGuard",
+	"NordVPN":           "NordVPN",
+	"netskope":          "Netskope",
+	"stAgentSvc":        "Netskope",
+}
+
+// googleTrustIssuers are the organizations that issue certificates for Google endpoints
+var googleTrustIssuers = []string{"Google Trust Services"}
+
+// RouteCheck is how traffic to a Google endpoint leaves the Mac
+type RouteCheck struct {
+	Host      string   `json:"host"`
+	Addresses []string `json:"addresses"`
+	Interface string   `json:"interface,omitempty"`
+	Gateway   string   `json:"gateway,omitempty"`
+	ViaVPN    bool     `json:"viaVpn"`
+	Reachable bool     `json:"reachable"`
+	LatencyMs int64    `json:"latencyMs,omitempty"`
+	// CertIssuer is the organization of the TLS certificate presented for the host
+	CertIssuer string `json:"certIssuer,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// VPNIssue is a likely VPN interaction with guidance on fixing it
+type VPNIssue struct {
+	Severity string `json:"severity"` // "warning" or "error"
+	Code     string `json:"code"`
+	Host     string `json:"host,omitempty"`
+	Message  string `json:"message"`
+	Guidance string `json:"guidance"`
+}
+
+// VPNDiagnostics is the result of CheckVPNConflicts
+type VPNDiagnostics struct {
+	VPNInterfaces []string     `json:"vpnInterfaces"` // utun and similar interfaces with an address
+	VPNClients    []string     `json:"vpnClients"`    // Running VPN clients recognized by process name
+	Routes        []RouteCheck `json:"routes"`
+	Issues        []VPNIssue   `json:"issues"`
+}
+
+// CheckVPNConflicts inspects the routes, DNS answers and TLS certificates of the endpoints tunnels
+// depend on, and reports VPN setups likely to break IAP: full tunnels that drop Google traffic,
+// DNS that resolves Google APIs to private addresses and proxies that intercept TLS
+func (a *App) CheckVPNConflicts() VPNDiagnostics {
+	result := VPNDiagnostics{
+		VPNInterfaces: vpnInterfaces(),
+		VPNClients:    runningVPNClients(),
+		Issues:        []VPNIssue{},
+	}
+
+	hosts := []string{hostIAP, hostOAuth, hostCompute}
+	result.Routes = make([]RouteCheck, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.Routes[i] = checkRoute(host)
+		}()
+	}
+	wg.Wait()
+
+	client := "your VPN client"
+	if len(result.VPNClients) > 0 {
+		client = strings.Join(result.VPNClients, " or ")
+	}
+	for _, r := range result.Routes {
+		result.Issues = append(result.Issues, routeIssues(r, client)...)
+	}
+	return result
+}
+
+// routeIssues turns the findings of a route check into issues
+func routeIssues(r RouteCheck, client string) []VPNIssue {
+	var issues []VPNIssue
+	if len(r.Addresses) == 0 {
+		return append(issues, VPNIssue{
+			Severity: "error",
+			Code:     VPNIssueResolveFailed,
+			Host:     r.Host,
+			Message:  r.Host + " does not resolve: " + r.Error,
+			Guidance: "Check that " + client + " forwards DNS queries for Google domains, or disconnect it and retry.",
+		})
+	}
+
+	private, restricted := false, false
+	for _, addr := range r.Addresses {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		if isPrivateGoogleAPIs(ip) {
+			restricted = true
+		} else if ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || inCGNAT(ip) {
+			private = true
+		}
+	}
+	switch {
+	case restricted:
+		issues = append(issues, VPNIssue{
+			Severity: "warning",
+			Code:     VPNIssuePrivateGoogleAPIs,
+			Host:     r.Host,
+			Message:  r.Host + " resolves to the Private Google Access range (private or restricted.googleapis.com)",
+			Guidance: "Private Google Access only reaches services in your VPC Service Controls perimeter. If tunnels fail, ask your network team to exempt " + hostIAP + " or allow IAP TCP forwarding in the perimeter.",
+		})
+	case private:
+		issues = append(issues, VPNIssue{
+			Severity: "error",
+			Code:     VPNIssuePrivateDNS,
+			Host:     r.Host,
+			Message:  r.Host + " resolves to a private address (" + strings.Join(r.Addresses, ", ") + ")",
+			Guidance: "The DNS servers of " + client + " are sinkholing or rewriting Google domains. Ask your network team to resolve " + r.Host + " publicly.",
+		})
+	}
+
+	if r.ViaVPN && !r.Reachable {
+		issues = append(issues, VPNIssue{
+			Severity: "error",
+			Code:     VPNIssueRoutedThroughVPN,
+			Host:     r.Host,
+			Message:  "Traffic to " + r.Host + " goes through " + r.Interface + " and is dropped",
+			Guidance: "The VPN is a full tunnel that doesn't allow Google APIs. Ask your network team to split-tunnel *.googleapis.com and " + hostIAP + ", or disconnect " + client + " while using tunnels.",
+		})
+	} else if !r.Reachable {
+		issues = append(issues, VPNIssue{
+			Severity: "error",
+			Code:     VPNIssueUnreachable,
+			Host:     r.Host,
+			Message:  r.Host + " is not reachable on port 443: " + r.Error,
+			Guidance: "A firewall or proxy blocks the connection. Allow outbound HTTPS to " + r.Host + ".",
+		})
+	}
+
+	if r.CertIssuer != "" && !isGoogleIssuer(r.CertIssuer) {
+		issues = append(issues, VPNIssue{
+			Severity: "warning",
+			Code:     VPNIssueTLSInspection,
+			Host:     r.Host,
+			Message:  r.Host + " presents a certificate issued by " + r.CertIssuer + ", so TLS is being inspected",
+			Guidance: "Inspecting proxies often break the WebSockets IAP uses. Ask your network team to bypass TLS inspection for " + hostIAP + " and *.googleapis.com.",
+		})
+	}
+	return issues
+}
+
+// checkRoute resolves host, asks the routing table which interface reaches it and connects with TLS
+func checkRoute(host string) RouteCheck {
+	r := RouteCheck{Host: host, Addresses: []string{}}
+	ctx, cancel := context.WithTimeout(context.Background(), vpnCheckTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Addresses = addrs
+	r.Interface, r.Gateway = routeTo(addrs[0])
+	r.ViaVPN = isVPNInterface(r.Interface)
+
+	start := time.Now()
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: vpnCheckTimeout}, Config: &tls.Config{ServerName: host}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, "443"))
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	defer conn.Close()
+	r.Reachable = true
+	r.LatencyMs = time.Since(start).Milliseconds()
+	if certs := conn.(*tls.Conn).ConnectionState().PeerCertificates; len(certs) > 0 {
+		r.CertIssuer = strings.Join(certs[0].Issuer.Organization, ", ")
+		if r.CertIssuer == "" {
+			r.CertIssuer = certs[0].Issuer.CommonName
+		}
+	}
+	return r
+}
+
+// routeTo returns the interface and gateway macOS uses for an address
+func routeTo(addr string) (iface, gateway string) {
+	args := []string{"-n", "get", addr}
+	if strings.Contains(addr, ":") {
+		args = []string{"-n", "get", "-inet6", addr}
+	}
+	output, err := runCommand("route", args...)
+	if err != nil {
+		return "", ""
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "interface":
+			iface = strings.TrimSpace(value)
+		case "gateway":
+			gateway = strings.TrimSpace(value)
+		}
+	}
+	return iface, gateway
+}
+
+// vpnInterfaces lists VPN-type interfaces that are up with an address
+func vpnInterfaces() []string {
+	names := []string{}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return names
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || !isVPNInterface(iface.Name) {
+			continue
+		}
+		if addrs, err := iface.Addrs(); err == nil && len(addrs) > 0 {
+			names = append(names, iface.Name)
+		}
+	}
+	return names
+}
+
+// runningVPNClients names the VPN clients with a running process
+func runningVPNClients() []string {
+	clients := []string{}
+	output, err := runCommand("ps", "-axo", "comm=")
+	if err != nil {
+		return clients
+	}
+	found := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		for process, client := range vpnClients {
+			if strings.Contains(line, process) {
+				found[client] = true
+			}
+		}
+	}
+	for client := range found {
+		clients = append(clients, client)
+	}
+	sort.Strings(clients)
+	return clients
+}
+
+// isVPNInterface reports whether an interface name is one VPN clients use
+func isVPNInterface(name string) bool {
+	for _, prefix := range vpnInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrivateGoogleAPIs reports whether ip is in private.googleapis.com or restricted.googleapis.com
+func isPrivateGoogleAPIs(ip net.IP) bool {
+	for _, cidr := range []string{"199.36.153.8/30", "199.36.153.4/30"} {
+		if _, n, err := net.ParseCIDR(cidr); err == nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// inCGNAT reports whether ip is in the shared address space some VPNs (e.g. Tailscale) assign
+func inCGNAT(ip net.IP) bool {
+	_, n, _ := net.ParseCIDR("100.64.0.0/10")
+	return n.Contains(ip)
+}
+
+// isGoogleIssuer reports whether a certificate issuer is one Google uses
+func isGoogleIssuer(issuer string) bool {
+	for _, org := range googleTrustIssuers {
+		if strings.Contains(issuer, org) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ConnectionTypeWeb opens an internal web UI in the default browser
+const ConnectionTypeWeb = "web"
+
+// WebHostAuto rewrites the Host header to the VM's internal DNS name
+const WebHostAuto = "auto"
+
+// WebSettings holds the per-favorite web preview settings
+type WebSettings struct {
+	Scheme string `json:"scheme,omitempty"` // "http" (default) or "https"
+	Path   string `json:"path,omitempty"`   // Opened path, e.g. "/admin"
+	// HostHeader rewrites the Host header through a local proxy, "auto" uses the VM's internal DNS name
+	HostHeader string `json:"hostHeader,omitempty"`
+	// InsecureTLS skips certificate verification for self-signed https UIs (rewrite proxy only)
+	InsecureTLS bool `json:"insecureTls,omitempty"`
+}
+
+// validate checks the web settings
+func (s WebSettings) validate() error {
+	switch s.Scheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("invalid scheme: %s", s.Scheme)
+	}
+	if s.Path != "" && !strings.HasPrefix(s.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	return nil
+}
+
+// connectionType returns ConnectionTypeWeb
+func (s WebSettings) connectionType() string {
+	return ConnectionTypeWeb
+}
+
+// apply stores the settings on a web favorite
+func (s WebSettings) apply(f *Favorite) {
+	f.Web = &s
+}
+
+// AddWebFavorite saves a web preview connection to a VM's internal web port
+func (a *App) AddWebFavorite(displayName, projectID, projectName, instanceName, zone string, remotePort int, settings WebSettings) (*Favorite, error) {
+	return a.addConnectionFavorite(displayName, projectID, projectName, instanceName, zone, remotePort, settings)
+}
+
+// UpdateWebSettings changes a web favorite's preview settings
+func (a *App) UpdateWebSettings(favoriteID string, settings WebSettings) error {
+	return a.updateConnectionSettings(favoriteID, settings)
+}
+
+// OpenWebPreview opens a running tunnel in the default browser once the web port is reachable
+func (a *App) OpenWebPreview(tunnelID string, settings WebSettings) ConnectResult {
+	a.countFeature("web_preview")
+	if err := settings.validate(); err != nil {
+		return ConnectResult{Error: err.Error()}
+	}
+	tunnel, err := a.GetTunnel(tunnelID)
+	if err != nil {
+		return ConnectResult{Error: err.Error()}
+	}
+	return a.openWebForTunnel(settings, tunnel)
+}
+
+// openWebForTunnel waits for the web port, starts the Host rewrite proxy if needed and opens the browser
+func (a *App) openWebForTunnel(settings WebSettings, tunnel *TunnelInfo) ConnectResult {
+	if !a.waitForTunnelRunning(tunnel.ID, 2*time.Second) {
+		return a.connectError(tunnel, MsgTunnelStartTimeout)
+	}
+	if probe := a.ProbeTunnel(tunnel.ID); !probe.Reachable {
+		return ConnectResult{Tunnel: tunnel, Error: probe.Error, Code: probe.Code, RetryAfter: probe.RetryAfter}
+	}
+
+	scheme := settings.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	target := &url.URL{Scheme: scheme, Host: fmt.Sprintf("localhost:%d", tunnel.LocalPort), Path: settings.Path}
+	open := target
+
+	if settings.HostHeader != "" {
+		host := settings.HostHeader
+		if host == WebHostAuto {
+			host = fmt.Sprintf("%s.%s.c.%s.internal", tunnel.VMName, tunnel.Zone, tunnel.ProjectID)
+		}
+		proxyPort, err := a.startHostRewriteProxy(tunnel.ID, target, host, settings.InsecureTLS)
+		if err != nil {
+			return a.connectError(tunnel, MsgPreviewProxyFailed, err)
+		}
+		open = &url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", proxyPort), Path: settings.Path}
+	}
+
+	if _, err := runCommand("open", open.String()); err != nil {
+		return a.connectError(tunnel, MsgBrowserFailed, err)
+	}
+
+	if info, err := a.GetTunnel(tunnel.ID); err == nil {
+		tunnel = info
+	}
+	return ConnectResult{Success: true, Tunnel: tunnel, URL: open.String()}
+}
+
+// webProxyKey tells apart the Host rewrite proxies of a tunnel
+type webProxyKey struct {
+	scheme      string
+	host        string
+	insecureTLS bool
+}
+
+// startHostRewriteProxy serves a local reverse proxy that forwards to the tunnel with a rewritten Host header.
+// A proxy the tunnel already runs for the same host is reused. The proxy stops with the tunnel.
+func (a *App) startHostRewriteProxy(tunnelID string, target *url.URL, host string, insecureTLS bool) (int, error) {
+	a.tunnelsMu.RLock()
+	tunnel, ok := a.tunnels[tunnelID]
+	a.tunnelsMu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("tunnel not found")
+	}
+	key := webProxyKey{scheme: target.Scheme, host: host, insecureTLS: insecureTLS}
+	tunnel.logsMu.Lock()
+	port, running := tunnel.webProxies[key]
+	tunnel.logsMu.Unlock()
+	if running {
+		return port, nil
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+
+	backend := &url.URL{Scheme: target.Scheme, Host: target.Host}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(backend)
+			r.Out.Host = host
+		},
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				ServerName:         host,
+				InsecureSkipVerify: insecureTLS,
+			},
+		},
+	}
+	server := &http.Server{Handler: proxy}
+	port = listener.Addr().(*net.TCPAddr).Port
+
+	// Another preview may have started the same proxy meanwhile
+	tunnel.logsMu.Lock()
+	if existing, running := tunnel.webProxies[key]; running {
+		tunnel.logsMu.Unlock()
+		listener.Close()
+		return existing, nil
+	}
+	if tunnel.webProxies == nil {
+		tunnel.webProxies = make(map[webProxyKey]int)
+	}
+	tunnel.webProxies[key] = port
+	tunnel.logsMu.Unlock()
+
+	go server.Serve(listener)
+	tunnel.addLog(fmt.Sprintf("Web preview proxy on 127.0.0.1:%d (Host: %s)", port, host))
+	tunnel.addStopHook(func() {
+		tunnel.logsMu.Lock()
+		delete(tunnel.webProxies, key)
+		tunnel.logsMu.Unlock()
+		server.Close()
+	})
+	return port, nil
+}
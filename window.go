@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// Minimum main window size, matches the options in main.go
+	minWindowWidth  = 900
+	minWindowHeight = 600
+
+	// WindowDetachedEvent is emitted with the DetachedView when a view is detached or moved
+	WindowDetachedEvent = "window:detached"
+	// WindowAttachedEvent is emitted with the view ID when a detached view is closed
+	WindowAttachedEvent = "window:attached"
+
+	// DetachedViewTunnelLog is a tunnel's live log
+	DetachedViewTunnelLog = "tunnel-log"
+)
+
+// WindowState is the persisted main window geometry
+type WindowState struct {
+	X         int  `json:"x"`
+	Y         int  `json:"y"`
+	Width     int  `json:"width"`
+	Height    int  `json:"height"`
+	Maximised bool `json:"maximised,omitempty"`
+}
+
+// DetachedView is a view shown outside the main layout. Wails v2 has a single native window,
+// so the frontend renders detached views as floating panels using these bounds.
+type DetachedView struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	TunnelID string `json:"tunnelId,omitempty"`
+	Title    string `json:"title"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// detachedViewState is the registry of detached views by ID
+type detachedViewState struct {
+	mu    sync.Mutex
+	views map[string]DetachedView
+}
+
+// domReady restores the main window geometry saved by the previous session
+func (a *App) domReady(ctx context.Context) {
+	a.configMu.RLock()
+	var state *WindowState
+	if a.config != nil && a.config.Window != nil {
+		s := *a.config.Window
+		state = &s
+	}
+	a.configMu.RUnlock()
+
+	if state == nil || state.Width < minWindowWidth || state.Height < minWindowHeight {
+		return
+	}
+	// The screen the window was on may be gone or smaller now, e.g. an external display
+	if screens, err := runtime.ScreenGetAll(ctx); err == nil {
+		state.clampTo(screens)
+	}
+	runtime.WindowSetSize(ctx, state.Width, state.Height)
+	runtime.WindowSetPosition(ctx, state.X, state.Y)
+	if state.Maximised {
+		runtime.WindowMaximise(ctx)
+	}
+}
+
+// clampTo keeps the window on the current screen, or the primary one. Wails positions the window
+// relative to the screen it is on and only reports screen sizes.
+func (s *WindowState) clampTo(screens []runtime.Screen) {
+	var screen *runtime.Screen
+	for i := range screens {
+		if screens[i].IsCurrent || (screen == nil && screens[i].IsPrimary) {
+			screen = &screens[i]
+		}
+	}
+	if screen == nil || screen.Size.Width <= 0 || screen.Size.Height <= 0 {
+		return
+	}
+	s.Width = max(min(s.Width, screen.Size.Width), minWindowWidth)
+	s.Height = max(min(s.Height, screen.Size.Height), minWindowHeight)
+	s.X = max(min(s.X, screen.Size.Width-s.Width), 0)
+	s.Y = max(min(s.Y, screen.Size.Height-s.Height), 0)
+}
+
+// beforeClose saves the main window geometry, it never prevents closing
+func (a *App) beforeClose(ctx context.Context) bool {
+	state := &WindowState{Maximised: runtime.WindowIsMaximised(ctx)}
+	state.Width, state.Height = runtime.WindowGetSize(ctx)
+	state.X, state.Y = runtime.WindowGetPosition(ctx)
+
+	a.mutateConfig(func(cfg *AppConfig) error {
+		if state.Maximised && cfg.Window != nil {
+			// Keep the restored size so un-maximising after relaunch goes back to it
+			state.X, state.Y = cfg.Window.X, cfg.Window.Y
+			state.Width, state.Height = cfg.Window.Width, cfg.Window.Height
+		}
+		cfg.Window = state
+		return nil
+	})
+	return false
+}
+
+// DetachTunnelLog registers a detached live log view for a tunnel, returning the existing one if already detached
+func (a *App) DetachTunnelLog(tunnelID string) (*DetachedView, error) {
+	tunnel, err := a.GetTunnel(tunnelID)
+	if err != nil {
+		return nil, err
+	}
+
+	id := DetachedViewTunnelLog + "-" + tunnelID
+	a.detachedViews.mu.Lock()
+	view, ok := a.detachedViews.views[id]
+	if !ok {
+		if a.detachedViews.views == nil {
+			a.detachedViews.views = make(map[string]DetachedView)
+		}
+		view = DetachedView{
+			ID:       id,
+			Kind:     DetachedViewTunnelLog,
+			TunnelID: tunnelID,
+			Title:    fmt.Sprintf("%s log", tunnel.VMName),
+			X:        40 + 24*len(a.detachedViews.views),
+			Y:        40 + 24*len(a.detachedViews.views),
+			Width:    640,
+			Height:   360,
+		}
+		a.detachedViews.views[id] = view
+	}
+	a.detachedViews.mu.Unlock()
+
+	if !ok {
+		a.emitEvent(WindowDetachedEvent, view)
+	}
+	return &view, nil
+}
+
+// MoveDetachedView records a detached view's new bounds
+func (a *App) MoveDetachedView(viewID string, x, y, width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid view size: %dx%d", width, height)
+	}
+
+	a.detachedViews.mu.Lock()
+	view, ok := a.detachedViews.views[viewID]
+	if ok {
+		view.X, view.Y, view.Width, view.Height = x, y, width, height
+		a.detachedViews.views[viewID] = view
+	}
+	a.detachedViews.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("view not found")
+	}
+	return nil
+}
+
+// AttachView closes a detached view
+func (a *App) AttachView(viewID string) {
+	a.detachedViews.mu.Lock()
+	_, ok := a.detachedViews.views[viewID]
+	delete(a.detachedViews.views, viewID)
+	a.detachedViews.mu.Unlock()
+
+	if ok {
+		a.emitEvent(WindowAttachedEvent, viewID)
+	}
+}
+
+// GetDetachedViews returns the detached views ordered by ID
+func (a *App) GetDetachedViews() []DetachedView {
+	a.detachedViews.mu.Lock()
+	views := make([]DetachedView, 0, len(a.detachedViews.views))
+	for _, view := range a.detachedViews.views {
+		views = append(views, view)
+	}
+	a.detachedViews.mu.Unlock()
+
+	sort.Slice(views, func(i, j int) bool { return views[i].ID < views[j].ID })
+	return views
+}
+
+// closeTunnelViews closes the detached views of a removed tunnel
+func (a *App) closeTunnelViews(tunnelID string) {
+	var closed []string
+	a.detachedViews.mu.Lock()
+	for id, view := range a.detachedViews.views {
+		if view.TunnelID == tunnelID {
+			delete(a.detachedViews.views, id)
+			closed = append(closed, id)
+		}
+	}
+	a.detachedViews.mu.Unlock()
+
+	for _, id := range closed {
+		a.emitEvent(WindowAttachedEvent, id)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// WindowsAppStatusEvent is emitted with the WindowsAppStatus when Windows App is removed or installed
+	WindowsAppStatusEvent = "windowsapp:status"
+	// windowsAppCheckInterval is how often the watcher re-checks the installation
+	windowsAppCheckInterval = time.Minute
+)
+
+// windowsAppState caches the last Windows App check so changes can be detected
+type windowsAppState struct {
+	mu       sync.Mutex
+	last     *WindowsAppStatus
+	watching bool
+}
+
+// CheckWindowsApp checks if Windows App is installed on macOS. When the result changed since the
+// last check, favorites with bookmarks are annotated and WindowsAppStatusEvent is emitted.
+func (a *App) CheckWindowsApp() WindowsAppStatus {
+	status := probeWindowsApp()
+
+	a.windowsApp.mu.Lock()
+	changed := a.windowsApp.last == nil || a.windowsApp.last.Installed != status.Installed
+	a.windowsApp.last = &status
+	a.windowsApp.mu.Unlock()
+
+	if changed {
+		a.markBookmarksUnavailable(!status.Installed)
+		a.emitEvent(WindowsAppStatusEvent, status)
+	}
+	return status
+}
+
+// startWindowsAppWatcher checks Windows App now and then every minute, skipping checks in low power mode
+func (a *App) startWindowsAppWatcher() {
+	a.windowsApp.mu.Lock()
+	if a.windowsApp.watching {
+		a.windowsApp.mu.Unlock()
+		return
+	}
+	a.windowsApp.watching = true
+	a.windowsApp.mu.Unlock()
+
+	a.CheckWindowsApp()
+	go func() {
+		ticker := time.NewTicker(windowsAppCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !a.lowPowerActive() {
+				a.CheckWindowsApp()
+			}
+		}
+	}()
+}
+
+// markBookmarksUnavailable flags favorites whose bookmark can't be used without Windows App,
+// or clears the flag once it is back
+func (a *App) markBookmarksUnavailable(unavailable bool) {
+	a.mutateConfig(func(cfg *AppConfig) error {
+		changed := false
+		for i := range cfg.Favorites {
+			f := &cfg.Favorites[i]
+			want := unavailable && f.HasBookmark
+			if f.BookmarkUnavailable != want {
+				f.BookmarkUnavailable = want
+				changed = true
+			}
+		}
+		if !changed {
+			return errConfigUnchanged
+		}
+		return nil
+	})
+}
+
+// openRDPFileForTunnel opens a temporary .rdp file with whatever app handles .rdp files,
+// the fallback when neither FreeRDP nor Windows App is installed
+func (a *App) openRDPFileForTunnel(fav *Favorite, tunnel *TunnelInfo) (string, error) {
+	dir := filepath.Join(a.getConfigDir(), "rdp")
+	if err := os.MkdirAll(dir, configDirMode); err != nil {
+		return "", fmt.Errorf("failed to create .rdp directory: %w", err)
+	}
+
+	var f Favorite
+	name := tunnel.VMName
+	if fav != nil {
+		f = *fav
+		name = fav.ID
+	}
+	path := filepath.Join(dir, exportFileName(name)+".rdp")
+	if err := os.WriteFile(path, rdpFileContent(f, tunnel.LocalPort), configFileMode); err != nil {
+		return "", fmt.Errorf("failed to write .rdp file: %w", err)
+	}
+	if _, err := runCommand("open", path); err != nil {
+		return path, err
+	}
+	return path, nil
+}
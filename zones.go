@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// zoneCatalogTTL is how long the zones of a project are reused before listing them again
+const zoneCatalogTTL = 24 * time.Hour
+
+// Favorite zone issue codes
+const (
+	ZoneIssueUnknown = "unknown_zone"
+	ZoneIssueDown    = "zone_down"
+)
+
+// ZoneInfo is a Compute Engine zone available to a project
+type ZoneInfo struct {
+	Name   string `json:"name"`
+	Region string `json:"region"`
+	Status string `json:"status"` // "UP" or "DOWN"
+}
+
+// RegionInfo is a region with its zones, sorted by name
+type RegionInfo struct {
+	Name  string   `json:"name"`
+	Zones []string `json:"zones"`
+}
+
+// ZoneCatalog lists the regions and zones a project can use
+type ZoneCatalog struct {
+	ProjectID string       `json:"projectId"`
+	Regions   []RegionInfo `json:"regions"`
+	Zones     []ZoneInfo   `json:"zones"`
+	FetchedAt string       `json:"fetchedAt"`
+}
+
+// RegionVMs is a region with the VMs running in it, for the VM picker
+type RegionVMs struct {
+	Region string `json:"region"`
+	VMs    []VM   `json:"vms"`
+}
+
+// FavoriteZoneIssue is a favorite whose zone the project doesn't have or that is down
+type FavoriteZoneIssue struct {
+	FavoriteID string `json:"favoriteId"`
+	Zone       string `json:"zone"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	// Suggestion is a zone of the project the favorite probably meant, for unknown zones
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// zoneCatalogState caches the zone catalog of each project
+type zoneCatalogState struct {
+	mu       sync.Mutex
+	projects map[string]*ZoneCatalog
+}
+
+// GetZoneCatalog returns the regions and zones of a project, listing them at most once a day
+func (a *App) GetZoneCatalog(projectID string) (*ZoneCatalog, error) {
+	a.zoneCatalog.mu.Lock()
+	defer a.zoneCatalog.mu.Unlock()
+	if catalog, ok := a.zoneCatalog.projects[projectID]; ok && time.Since(parseTimestamp(catalog.FetchedAt)) < zoneCatalogTTL {
+		return catalog, nil
+	}
+
+	if a.tokenSource == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	ctx := context.Background()
+	computeService, err := compute.NewService(ctx, option.WithTokenSource(a.tokenSource))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compute client: %w", err)
+	}
+
+	var zones []ZoneInfo
+	err = computeService.Zones.List(projectID).Pages(ctx, func(page *compute.ZoneList) error {
+		for _, z := range page.Items {
+			region := z.Region
+			// Region is a URL like .../projects/p/regions/us-central1
+			if i := strings.LastIndex(region, "/"); i != -1 {
+				region = region[i+1:]
+			}
+			if region == "" {
+				region = zoneRegion(z.Name)
+			}
+			zones = append(zones, ZoneInfo{Name: z.Name, Region: region, Status: z.Status})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list zones: %w", err)
+	}
+
+	catalog := newZoneCatalog(projectID, zones)
+	if a.zoneCatalog.projects == nil {
+		a.zoneCatalog.projects = make(map[string]*ZoneCatalog)
+	}
+	a.zoneCatalog.projects[projectID] = catalog
+	return catalog, nil
+}
+
+// newZoneCatalog sorts zones and groups them into regions
+func newZoneCatalog(projectID string, zones []ZoneInfo) *ZoneCatalog {
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+	catalog := &ZoneCatalog{
+		ProjectID: projectID,
+		Regions:   []RegionInfo{},
+		Zones:     zones,
+		FetchedAt: time.Now().Format(time.RFC3339),
+	}
+	if catalog.Zones == nil {
+		catalog.Zones = []ZoneInfo{}
+	}
+	index := make(map[string]int)
+	for _, z := range catalog.Zones {
+		i, ok := index[z.Region]
+		if !ok {
+			i = len(catalog.Regions)
+			index[z.Region] = i
+			catalog.Regions = append(catalog.Regions, RegionInfo{Name: z.Region})
+		}
+		catalog.Regions[i].Zones = append(catalog.Regions[i].Zones, z.Name)
+	}
+	sort.Slice(catalog.Regions, func(i, j int) bool { return catalog.Regions[i].Name < catalog.Regions[j].Name })
+	return catalog
+}
+
+// ListVMsByRegion returns the VMs of a project grouped by region, regions and VMs sorted by name.
+// A non-empty region limits the result to that region.
+func (a *App) ListVMsByRegion(projectID, filter, region string) ([]RegionVMs, error) {
+	vms, err := a.ListVMs(projectID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if region != "" {
+		kept := vms[:0]
+		for _, vm := range vms {
+			if vm.Region == region {
+				kept = append(kept, vm)
+			}
+		}
+		vms = kept
+	}
+	return groupVMsByRegion(vms), nil
+}
+
+// groupVMsByRegion groups VMs by region, keeping their order within each region
+func groupVMsByRegion(vms []VM) []RegionVMs {
+	groups := []RegionVMs{}
+	index := make(map[string]int)
+	for _, vm := range vms {
+		i, ok := index[vm.Region]
+		if !ok {
+			i = len(groups)
+			index[vm.Region] = i
+			groups = append(groups, RegionVMs{Region: vm.Region})
+		}
+		groups[i].VMs = append(groups[i].VMs, vm)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Region < groups[j].Region })
+	return groups
+}
+
+// ValidateFavoriteZones checks the zone of every favorite against the zone catalog of its project.
+// Projects whose zones can't be listed are skipped.
+func (a *App) ValidateFavoriteZones() ([]FavoriteZoneIssue, error) {
+	if a.tokenSource == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	issues := []FavoriteZoneIssue{}
+	catalogs := make(map[string]*ZoneCatalog)
+	for _, f := range a.GetFavorites() {
+		// Hostname favorites follow their instance, the zone is only the last one resolved
+		if f.Zone == "" || f.Hostname != "" || !inGoogleCloud(&f) {
+			continue
+		}
+		catalog, ok := catalogs[f.ProjectID]
+		if !ok {
+			catalog, _ = a.GetZoneCatalog(f.ProjectID)
+			catalogs[f.ProjectID] = catalog
+		}
+		if catalog == nil {
+			continue
+		}
+		if issue := checkFavoriteZone(f, catalog); issue != nil {
+			issues = append(issues, *issue)
+		}
+	}
+	return issues, nil
+}
+
+// checkFavoriteZone returns the problem with a favorite's zone, nil when the zone is fine
+func checkFavoriteZone(f Favorite, catalog *ZoneCatalog) *FavoriteZoneIssue {
+	for _, z := range catalog.Zones {
+		if z.Name != f.Zone {
+			continue
+		}
+		if z.Status == "DOWN" {
+			return &FavoriteZoneIssue{
+				FavoriteID: f.ID,
+				Zone:       f.Zone,
+				Code:       ZoneIssueDown,
+				Message:    fmt.Sprintf("Zone %s of %s is down", f.Zone, favoriteLabel(f)),
+			}
+		}
+		return nil
+	}
+
+	issue := &FavoriteZoneIssue{
+		FavoriteID: f.ID,
+		Zone:       f.Zone,
+		Code:       ZoneIssueUnknown,
+		Message:    fmt.Sprintf("Project %s has no zone %s, used by %s", f.ProjectID, f.Zone, favoriteLabel(f)),
+	}
+	// Suggest a zone of the same region, or one differing only in case or spacing
+	normalized := strings.ToLower(strings.TrimSpace(f.Zone))
+	for _, z := range catalog.Zones {
+		if z.Name == normalized {
+			issue.Suggestion = z.Name
+			return issue
+		}
+	}
+	for _, r := range catalog.Regions {
+		if r.Name == zoneRegion(normalized) && len(r.Zones) > 0 {
+			issue.Suggestion = r.Zones[0]
+			break
+		}
+	}
+	return issue
+}
+
+// zoneRegion returns the region of a zone name, e.g. us-central1 for us-central1-a
+func zoneRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i != -1 {
+		return zone[:i]
+	}
+	return zone
+}